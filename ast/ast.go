@@ -10,6 +10,10 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos is where the node starts in the source, End is just past where it
+	// ends. Both are invalid for nodes that weren't parsed from source.
+	Pos() token.Position
+	End() token.Position
 }
 
 type Statement interface {
@@ -24,8 +28,23 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	// Comments found while parsing, keyed by the node they precede
+	Comments CommentMap
 }
 
+// Comment is a // line comment. Comments aren't part of the tree; the parser
+// records them in Program.Comments so tools like the formatter can keep them.
+type Comment struct {
+	Token token.Token // The COMMENT token, including the leading //
+}
+
+func (c *Comment) Text() string { return c.Token.Literal }
+
+// CommentMap associates comments with the statement that follows them.
+// Comments at the end of a block are keyed by the *BlockStatement, and those
+// at the end of the file by the *Program.
+type CommentMap map[Node][]*Comment
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -81,6 +100,8 @@ func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 type Identifier struct {
 	Token token.Token // This'll be an IDENT token
 	Value string
+	// Where the value lives at run time, filled in by Resolve
+	Binding Binding
 }
 
 func (i *Identifier) String() string {
@@ -212,13 +233,124 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// MatchExpression evaluates the body of the first arm whose pattern matches
+// the subject and whose guard, if any, is truthy.
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Position
+}
+
+// MatchArm is pattern if guard => body. Patterns are written as expressions:
+// _ matches anything, a name matches anything and binds it, literals match
+// equal values, [a, b, ...rest] matches arrays and {key: pattern} matches
+// hashes with those keys. A name as a hash pattern key stands for the
+// string, as in {name: n}.
+type MatchArm struct {
+	Pattern Expression
+	// nil if the arm has no guard
+	Guard Expression
+	Body  Expression
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		s := arm.Pattern.String()
+		if arm.Guard != nil {
+			s += " if " + arm.Guard.String()
+		}
+		arms = append(arms, s+" => "+arm.Body.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// SpreadExpression is ...value. In patterns it matches the rest of an
+// array.
+type SpreadExpression struct {
+	Token token.Token // '...'
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// ImportExpression is import "path", which evaluates to the module defined
+// by the file at path.
+type ImportExpression struct {
+	Token token.Token // 'import'
+	Path  *StringLiteral
+}
+
+func (ie *ImportExpression) expressionNode()      {}
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) String() string       { return "import " + ie.Path.String() }
+
+// NamedArgument is name: value in the arguments of a call, passing value as
+// the parameter called name.
+type NamedArgument struct {
+	Token token.Token // ':'
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
+// PatternBindings returns the names a match pattern binds, in the order they
+// appear.
+func PatternBindings(pattern Expression) []*Identifier {
+	names := []*Identifier{}
+
+	var collect func(Expression)
+	collect = func(exp Expression) {
+		switch exp := exp.(type) {
+		case *Identifier:
+			if exp.Value != "_" {
+				names = append(names, exp)
+			}
+		case *SpreadExpression:
+			collect(exp.Value)
+		case *ArrayLiteral:
+			for _, el := range exp.Elements {
+				collect(el)
+			}
+		case *HashLiteral:
+			// Keys are literals or field names, only the values bind
+			for _, key := range exp.SortedKeys() {
+				collect(exp.Pairs[key])
+			}
+		}
+	}
+	collect(pattern)
+
+	return names
+}
+
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // '{'
 	Statements []Statement
+	Rbrace     token.Position
 }
 
 func (bs *BlockStatement) statementNode()       {}
-func (bs *BlockStatement) TokenLiteral() string { return bs.TokenLiteral() }
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -232,9 +364,17 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	// The types the parameters are annotated with, nil for those that
+	// aren't, or nil if none are
+	ParameterTypes []*TypeAnnotation
+	// The type the function is annotated to return, if any
+	ReturnType *TypeAnnotation
 	Body       *BlockStatement
 	// Used for self referential references
 	Name string
+	// The function's local variables, parameters first and then every name
+	// its body binds with let. Filled in by Resolve.
+	Locals []string
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -244,8 +384,12 @@ func (fl *FunctionLiteral) String() string {
 
 	params := []string{}
 
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		if t := fl.ParameterType(i); t != nil {
+			params = append(params, p.String()+": "+t.String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -257,16 +401,38 @@ func (fl *FunctionLiteral) String() string {
 	out.WriteString(strings.Join(params, ", "))
 
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString("{ ")
 	out.WriteString(fl.Body.String())
 	out.WriteString(" }")
 	return out.String()
 }
 
+// ParameterType returns the type parameter i is annotated with, or nil.
+func (fl *FunctionLiteral) ParameterType(i int) *TypeAnnotation {
+	if i >= len(fl.ParameterTypes) {
+		return nil
+	}
+	return fl.ParameterTypes[i]
+}
+
+// TypeAnnotation is the type a parameter or the result of a function is
+// declared to have, as in fn(x: int) -> int. Programs run the same without
+// them.
+type TypeAnnotation struct {
+	Token token.Token // The type's name
+	Name  string
+}
+
+func (ta *TypeAnnotation) String() string { return ta.Name }
+
 type CallExpression struct {
-	Token     token.Token
-	Function  Expression // Could be an identifier or a function literal!
+	Token     token.Token // '('
+	Function  Expression  // Could be an identifier or a function literal!
 	Arguments []Expression
+	Rparen    token.Position
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -288,9 +454,65 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// MethodCallExpression calls a method of a value, as in arr.push(1).
+type MethodCallExpression struct {
+	Token     token.Token // '.' or '?.'
+	Object    Expression
+	Method    *Identifier
+	Arguments []Expression
+	Rparen    token.Position
+	// Set for value?.name(args), which is null, without evaluating the
+	// arguments, when the value is
+	Optional bool
+}
+
+func (mc *MethodCallExpression) expressionNode()      {}
+func (mc *MethodCallExpression) TokenLiteral() string { return mc.Token.Literal }
+func (mc *MethodCallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+
+	for _, a := range mc.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(mc.Object.String())
+	if mc.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString(".")
+	out.WriteString(mc.Method.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// FieldExpression looks up a field of a hash, as in person.name. It's
+// another way of writing person["name"].
+type FieldExpression struct {
+	Token  token.Token // '.' or '?.'
+	Object Expression
+	Field  *Identifier
+	// Set for value?.name, which is null when the value is
+	Optional bool
+}
+
+func (fe *FieldExpression) expressionNode()      {}
+func (fe *FieldExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *FieldExpression) String() string {
+	if fe.Optional {
+		return fe.Object.String() + "?." + fe.Field.String()
+	}
+	return fe.Object.String() + "." + fe.Field.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // '[' token
 	Elements []Expression
+	Rbracket token.Position
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
@@ -312,9 +534,13 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // '['
-	Left  Expression
-	Index Expression
+	Token    token.Token // '[' or '?['
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position
+	// Set for value?[index], which is null, without evaluating the index,
+	// when the value is
+	Optional bool
 }
 
 func (ie *IndexExpression) expressionNode()      {}
@@ -324,6 +550,9 @@ func (ie *IndexExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -335,6 +564,9 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token // '{'
 	Pairs map[Expression]Expression
+	// Keys of Pairs in source order
+	Keys   []Expression
+	Rbrace token.Position
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -1,7 +1,11 @@
 package ast
 
 import (
+	"bytes"
+	"fmt"
 	"monkey/token"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -27,3 +31,185 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestFprint(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name: &Identifier{
+					Token: token.Token{Type: token.IDENT, Literal: "x"},
+					Value: "x",
+				},
+				Value: &IntegerLiteral{
+					Token: token.Token{Type: token.INT, Literal: "5"},
+					Value: 5,
+				},
+			},
+		},
+	}
+
+	expected := `*ast.Program {
+  Statements: [
+    *ast.LetStatement {
+      Name: *ast.Identifier {
+        Value: "x"
+      }
+      Value: *ast.IntegerLiteral {
+        Value: 5
+      }
+    }
+  ]
+}
+`
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		t.Fatalf("Fprint failed: %s", err)
+	}
+
+	if out.String() != expected {
+		t.Errorf("wrong dump. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	integer := func(v int64) *IntegerLiteral { return &IntegerLiteral{Value: v} }
+
+	// let f = fn(a) { if (a) { a + 1 } }; f([2, {"k": 3}])
+	key := &StringLiteral{Value: "k"}
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{ident("a")},
+					Body: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &IfExpression{
+							Condition: ident("a"),
+							Consequence: &BlockStatement{Statements: []Statement{
+								&ExpressionStatement{Expression: &InfixExpression{Left: ident("a"), Operator: "+", Right: integer(1)}},
+							}},
+						}},
+					}},
+				},
+			},
+			&ExpressionStatement{Expression: &CallExpression{
+				Function: ident("f"),
+				Arguments: []Expression{&ArrayLiteral{Elements: []Expression{
+					integer(2),
+					&HashLiteral{Pairs: map[Expression]Expression{key: integer(3)}, Keys: []Expression{key}},
+				}}},
+			}},
+		},
+	}
+
+	visited := []string{}
+	Inspect(program, func(node Node) bool {
+		if node != nil {
+			visited = append(visited, fmt.Sprintf("%T", node))
+		}
+		return true
+	})
+
+	expected := []string{
+		"*ast.Program", "*ast.LetStatement", "*ast.Identifier", "*ast.FunctionLiteral", "*ast.Identifier",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.IfExpression", "*ast.Identifier",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.InfixExpression", "*ast.Identifier",
+		"*ast.IntegerLiteral", "*ast.ExpressionStatement", "*ast.CallExpression", "*ast.Identifier",
+		"*ast.ArrayLiteral", "*ast.IntegerLiteral", "*ast.HashLiteral", "*ast.StringLiteral", "*ast.IntegerLiteral",
+	}
+
+	if strings.Join(visited, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong visiting order.\nexpected=%v\ngot=     %v", expected, visited)
+	}
+
+	// Returning false skips the children of a node
+	count := 0
+	Inspect(program, func(node Node) bool {
+		if node != nil {
+			count++
+		}
+		_, isFn := node.(*FunctionLiteral)
+		return !isFn
+	})
+
+	if count != 12 {
+		t.Errorf("expected 12 nodes outside the function body, got %d", count)
+	}
+}
+
+func TestWalkMissingNodes(t *testing.T) {
+	// As left behind by a let statement that failed to parse
+	program := &Program{Statements: []Statement{&LetStatement{Name: &Identifier{Value: "x"}}}}
+
+	count := 0
+	Inspect(program, func(node Node) bool {
+		if node != nil {
+			count++
+		}
+		return true
+	})
+
+	if count != 3 {
+		t.Errorf("expected 3 nodes, got %d", count)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	// a + 1
+	node := &InfixExpression{
+		Token:    token.Token{Type: token.PLUS, Literal: "+", Pos: token.Position{Line: 1, Column: 3}},
+		Operator: "+",
+		Left: &Identifier{
+			Token: token.Token{Type: token.IDENT, Literal: "a", Pos: token.Position{Line: 1, Column: 1}},
+			Value: "a",
+		},
+		Right: &IntegerLiteral{
+			Token: token.Token{Type: token.INT, Literal: "1", Pos: token.Position{Line: 1, Column: 5}},
+			Value: 1,
+		},
+	}
+
+	expected := `{"node":"InfixExpression","line":1,"column":3,"operator":"+",` +
+		`"left":{"node":"Identifier","line":1,"column":1,"value":"a"},` +
+		`"right":{"node":"IntegerLiteral","line":1,"column":5,"value":1}}`
+
+	data, err := ToJSON(node)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %s", err)
+	}
+
+	if string(data) != expected {
+		t.Errorf("wrong JSON.\nexpected=%s\ngot=     %s", expected, data)
+	}
+
+	decoded, err := FromJSON(data)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded, node) {
+		t.Errorf("decoded node differs.\nexpected=%#v\ngot=     %#v", node, decoded)
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"node":"Loop"}`, `unknown node type "Loop"`},
+		{`{"node":"Program","statements":[{"node":"Identifier","value":"x"}]}`, "expected a statement, got Identifier"},
+		{`{"node":"IntegerLiteral"}`, "IntegerLiteral is missing its value"},
+		{`{"node":"LetStatement","name":{"node":"IntegerLiteral","value":1}}`, "expected an Identifier, got *ast.IntegerLiteral"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromJSON([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromJSON(%s): expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/token"
+	"strconv"
+)
+
+// jsonNode is the JSON form of every node type. "node" names the type and
+// only the fields that type has are set. Tokens aren't encoded, they're
+// rebuilt from the node when decoding, except for their position.
+type jsonNode struct {
+	Node   string `json:"node"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+
+	// An identifier node for let statements, method calls, fields and named
+	// arguments, a string for functions
+	Name json.RawMessage `json:"name,omitempty"`
+	// A node for let and return statements and named arguments, a literal
+	// for literals
+	Value json.RawMessage `json:"value,omitempty"`
+
+	Operator    string      `json:"operator,omitempty"`
+	Left        *jsonNode   `json:"left,omitempty"`
+	Right       *jsonNode   `json:"right,omitempty"`
+	Index       *jsonNode   `json:"index,omitempty"`
+	Condition   *jsonNode   `json:"condition,omitempty"`
+	Consequence *jsonNode   `json:"consequence,omitempty"`
+	Alternative *jsonNode   `json:"alternative,omitempty"`
+	Parameters  []*jsonNode `json:"parameters,omitempty"`
+	Body        *jsonNode   `json:"body,omitempty"`
+	Function    *jsonNode   `json:"function,omitempty"`
+	Arguments   []*jsonNode `json:"arguments,omitempty"`
+	Elements    []*jsonNode `json:"elements,omitempty"`
+	Pairs       []jsonPair  `json:"pairs,omitempty"`
+	Expression  *jsonNode   `json:"expression,omitempty"`
+	Statements  []*jsonNode `json:"statements,omitempty"`
+	Arms        []jsonArm   `json:"arms,omitempty"`
+
+	// Type annotations of functions, one per parameter, empty for those that
+	// aren't annotated, and of their result
+	Types   []string `json:"types,omitempty"`
+	Returns string   `json:"returns,omitempty"`
+}
+
+type jsonPair struct {
+	Key   *jsonNode `json:"key"`
+	Value *jsonNode `json:"value"`
+}
+
+type jsonArm struct {
+	Pattern *jsonNode `json:"pattern"`
+	Guard   *jsonNode `json:"guard,omitempty"`
+	Body    *jsonNode `json:"body"`
+}
+
+// ToJSON encodes the tree rooted at node as JSON, e.g.
+//
+//	{"node":"InfixExpression","line":1,"column":3,"operator":"+",
+//	 "left":{"node":"Identifier","line":1,"column":1,"value":"a"},
+//	 "right":{"node":"IntegerLiteral","line":1,"column":5,"value":1}}
+//
+// Hash pairs are encoded in source order. Comments aren't part of the tree
+// and are left out.
+func ToJSON(node Node) ([]byte, error) {
+	n, err := encodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(n)
+}
+
+// FromJSON decodes a tree encoded by ToJSON.
+func FromJSON(data []byte) (Node, error) {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+
+	return decodeNode(&n)
+}
+
+func encodeNode(node Node) (*jsonNode, error) {
+	if isMissing(node) {
+		return nil, nil
+	}
+
+	var err error
+	n := &jsonNode{}
+
+	setPos := func(tok token.Token) {
+		n.Line, n.Column = tok.Pos.Line, tok.Pos.Column
+	}
+
+	// Encoding stops at the first error, later calls are no-ops
+	enc := func(node Node) *jsonNode {
+		if err != nil {
+			return nil
+		}
+		var out *jsonNode
+		out, err = encodeNode(node)
+		return out
+	}
+	encList := func(nodes []Node) []*jsonNode {
+		out := []*jsonNode{}
+		for _, node := range nodes {
+			out = append(out, enc(node))
+		}
+		return out
+	}
+	raw := func(v any) json.RawMessage {
+		if err != nil {
+			return nil
+		}
+		var out []byte
+		out, err = json.Marshal(v)
+		return out
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		n.Node = "Program"
+		n.Statements = encList(statementNodes(node.Statements))
+	case *LetStatement:
+		n.Node = "LetStatement"
+		setPos(node.Token)
+		n.Name = raw(enc(node.Name))
+		n.Value = raw(enc(node.Value))
+	case *ReturnStatement:
+		n.Node = "ReturnStatement"
+		setPos(node.Token)
+		if !isMissing(node.ReturnValue) {
+			n.Value = raw(enc(node.ReturnValue))
+		}
+	case *ExpressionStatement:
+		n.Node = "ExpressionStatement"
+		setPos(node.Token)
+		n.Expression = enc(node.Expression)
+	case *BlockStatement:
+		n.Node = "BlockStatement"
+		setPos(node.Token)
+		n.Statements = encList(statementNodes(node.Statements))
+	case *Identifier:
+		n.Node = "Identifier"
+		setPos(node.Token)
+		n.Value = raw(node.Value)
+	case *IntegerLiteral:
+		n.Node = "IntegerLiteral"
+		setPos(node.Token)
+		n.Value = raw(node.Value)
+	case *StringLiteral:
+		n.Node = "StringLiteral"
+		setPos(node.Token)
+		n.Value = raw(node.Value)
+	case *Boolean:
+		n.Node = "Boolean"
+		setPos(node.Token)
+		n.Value = raw(node.Value)
+	case *PrefixExpression:
+		n.Node = "PrefixExpression"
+		setPos(node.Token)
+		n.Operator = node.Operator
+		n.Right = enc(node.Right)
+	case *InfixExpression:
+		n.Node = "InfixExpression"
+		setPos(node.Token)
+		n.Operator = node.Operator
+		n.Left = enc(node.Left)
+		n.Right = enc(node.Right)
+	case *IfExpression:
+		n.Node = "IfExpression"
+		setPos(node.Token)
+		n.Condition = enc(node.Condition)
+		n.Consequence = enc(node.Consequence)
+		n.Alternative = enc(node.Alternative)
+	case *MatchExpression:
+		n.Node = "MatchExpression"
+		setPos(node.Token)
+		n.Expression = enc(node.Subject)
+		for _, arm := range node.Arms {
+			n.Arms = append(n.Arms, jsonArm{Pattern: enc(arm.Pattern), Guard: enc(arm.Guard), Body: enc(arm.Body)})
+		}
+	case *SpreadExpression:
+		n.Node = "SpreadExpression"
+		setPos(node.Token)
+		n.Right = enc(node.Value)
+	case *ImportExpression:
+		n.Node = "ImportExpression"
+		setPos(node.Token)
+		n.Expression = enc(node.Path)
+	case *NamedArgument:
+		n.Node = "NamedArgument"
+		setPos(node.Token)
+		n.Name = raw(enc(node.Name))
+		n.Value = raw(enc(node.Value))
+	case *FunctionLiteral:
+		n.Node = "FunctionLiteral"
+		setPos(node.Token)
+		if node.Name != "" {
+			n.Name = raw(node.Name)
+		}
+		for _, param := range node.Parameters {
+			n.Parameters = append(n.Parameters, enc(param))
+		}
+		for _, t := range node.ParameterTypes {
+			if t != nil {
+				n.Types = append(n.Types, t.Name)
+			} else {
+				n.Types = append(n.Types, "")
+			}
+		}
+		if node.ReturnType != nil {
+			n.Returns = node.ReturnType.Name
+		}
+		n.Body = enc(node.Body)
+	case *CallExpression:
+		n.Node = "CallExpression"
+		setPos(node.Token)
+		n.Function = enc(node.Function)
+		n.Arguments = encList(expressionNodes(node.Arguments))
+	case *MethodCallExpression:
+		n.Node = "MethodCallExpression"
+		setPos(node.Token)
+		if node.Optional {
+			n.Operator = token.OPTIONAL_DOT
+		}
+		n.Left = enc(node.Object)
+		n.Name = raw(enc(node.Method))
+		n.Arguments = encList(expressionNodes(node.Arguments))
+	case *FieldExpression:
+		n.Node = "FieldExpression"
+		setPos(node.Token)
+		if node.Optional {
+			n.Operator = token.OPTIONAL_DOT
+		}
+		n.Left = enc(node.Object)
+		n.Name = raw(enc(node.Field))
+	case *ArrayLiteral:
+		n.Node = "ArrayLiteral"
+		setPos(node.Token)
+		n.Elements = encList(expressionNodes(node.Elements))
+	case *IndexExpression:
+		n.Node = "IndexExpression"
+		setPos(node.Token)
+		if node.Optional {
+			n.Operator = token.OPTIONAL_LBRACKET
+		}
+		n.Left = enc(node.Left)
+		n.Index = enc(node.Index)
+	case *HashLiteral:
+		n.Node = "HashLiteral"
+		setPos(node.Token)
+		for _, key := range node.SortedKeys() {
+			n.Pairs = append(n.Pairs, jsonPair{Key: enc(key), Value: enc(node.Pairs[key])})
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode %T as JSON", node)
+	}
+
+	return n, err
+}
+
+func decodeNode(n *jsonNode) (Node, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	var err error
+	pos := token.Position{Line: n.Line, Column: n.Column}
+	tok := func(t token.TokenType, literal string) token.Token {
+		return token.Token{Type: t, Literal: literal, Pos: pos}
+	}
+	// The dot of a method call or field, operator being set for ?.
+	dotToken := func(operator string) token.Token {
+		if operator != "" {
+			return tok(token.OPTIONAL_DOT, "?.")
+		}
+		return tok(token.DOT, ".")
+	}
+
+	// Decoding stops at the first error, later calls return nil
+	dec := func(n *jsonNode) Node {
+		if err != nil {
+			return nil
+		}
+		var node Node
+		node, err = decodeNode(n)
+		return node
+	}
+	exp := func(n *jsonNode) Expression {
+		node := dec(n)
+		if node == nil {
+			return nil
+		}
+		e, ok := node.(Expression)
+		if !ok && err == nil {
+			err = fmt.Errorf("expected an expression, got %s", n.Node)
+		}
+		return e
+	}
+	exps := func(ns []*jsonNode) []Expression {
+		out := []Expression{}
+		for _, n := range ns {
+			out = append(out, exp(n))
+		}
+		return out
+	}
+	stmts := func(ns []*jsonNode) []Statement {
+		out := []Statement{}
+		for _, n := range ns {
+			node := dec(n)
+			if s, ok := node.(Statement); ok {
+				out = append(out, s)
+			} else if err == nil {
+				err = fmt.Errorf("expected a statement, got %s", n.Node)
+			}
+		}
+		return out
+	}
+	ident := func(n *jsonNode) *Identifier {
+		node := dec(n)
+		i, ok := node.(*Identifier)
+		if !ok && err == nil {
+			err = fmt.Errorf("expected an Identifier, got %T", node)
+		}
+		return i
+	}
+	block := func(n *jsonNode) *BlockStatement {
+		if n == nil {
+			return nil
+		}
+		node := dec(n)
+		b, ok := node.(*BlockStatement)
+		if !ok && err == nil {
+			err = fmt.Errorf("expected a BlockStatement, got %s", n.Node)
+		}
+		return b
+	}
+	// Fields holding either a nested node or a literal
+	rawNode := func(data json.RawMessage) *jsonNode {
+		if len(data) == 0 || string(data) == "null" || err != nil {
+			return nil
+		}
+		var child jsonNode
+		err = json.Unmarshal(data, &child)
+		return &child
+	}
+	literal := func(v any) {
+		if len(n.Value) == 0 {
+			err = fmt.Errorf("%s is missing its value", n.Node)
+			return
+		}
+		if e := json.Unmarshal(n.Value, v); e != nil {
+			err = e
+		}
+	}
+
+	var node Node
+
+	switch n.Node {
+	case "Program":
+		node = &Program{Statements: stmts(n.Statements)}
+	case "LetStatement":
+		node = &LetStatement{Token: tok(token.LET, "let"), Name: ident(rawNode(n.Name)), Value: exp(rawNode(n.Value))}
+	case "ReturnStatement":
+		node = &ReturnStatement{Token: tok(token.RETURN, "return"), ReturnValue: exp(rawNode(n.Value))}
+	case "ExpressionStatement":
+		e := exp(n.Expression)
+		stmt := &ExpressionStatement{Expression: e}
+		if e != nil {
+			stmt.Token = firstToken(e)
+		}
+		stmt.Token.Pos = pos
+		node = stmt
+	case "BlockStatement":
+		node = &BlockStatement{Token: tok(token.LBRACE, "{"), Statements: stmts(n.Statements)}
+	case "Identifier":
+		var value string
+		literal(&value)
+		node = &Identifier{Token: tok(token.IDENT, value), Value: value}
+	case "IntegerLiteral":
+		var value int64
+		literal(&value)
+		node = &IntegerLiteral{Token: tok(token.INT, strconv.FormatInt(value, 10)), Value: value}
+	case "StringLiteral":
+		var value string
+		literal(&value)
+		node = &StringLiteral{Token: tok(token.STRING, value), Value: value}
+	case "Boolean":
+		var value bool
+		literal(&value)
+		node = &Boolean{Token: tok(token.LookupIdent(strconv.FormatBool(value)), strconv.FormatBool(value)), Value: value}
+	case "PrefixExpression":
+		node = &PrefixExpression{Token: tok(token.TokenType(n.Operator), n.Operator), Operator: n.Operator, Right: exp(n.Right)}
+	case "InfixExpression":
+		node = &InfixExpression{Token: tok(token.TokenType(n.Operator), n.Operator), Operator: n.Operator, Left: exp(n.Left), Right: exp(n.Right)}
+	case "IfExpression":
+		node = &IfExpression{Token: tok(token.IF, "if"), Condition: exp(n.Condition), Consequence: block(n.Consequence), Alternative: block(n.Alternative)}
+	case "MatchExpression":
+		match := &MatchExpression{Token: tok(token.MATCH, "match"), Subject: exp(n.Expression), Arms: []*MatchArm{}}
+		for _, arm := range n.Arms {
+			match.Arms = append(match.Arms, &MatchArm{Pattern: exp(arm.Pattern), Guard: exp(arm.Guard), Body: exp(arm.Body)})
+		}
+		node = match
+	case "SpreadExpression":
+		node = &SpreadExpression{Token: tok(token.ELLIPSIS, "..."), Value: exp(n.Right)}
+	case "ImportExpression":
+		path, _ := exp(n.Expression).(*StringLiteral)
+		node = &ImportExpression{Token: tok(token.IMPORT, "import"), Path: path}
+	case "NamedArgument":
+		node = &NamedArgument{Token: tok(token.COLON, ":"), Name: ident(rawNode(n.Name)), Value: exp(rawNode(n.Value))}
+	case "FunctionLiteral":
+		fn := &FunctionLiteral{Token: tok(token.FUNCTION, "fn"), Parameters: []*Identifier{}, Body: block(n.Body)}
+		if len(n.Name) != 0 {
+			err = json.Unmarshal(n.Name, &fn.Name)
+		}
+		for _, param := range n.Parameters {
+			fn.Parameters = append(fn.Parameters, ident(param))
+		}
+		for _, name := range n.Types {
+			fn.ParameterTypes = append(fn.ParameterTypes, typeAnnotation(name))
+		}
+		fn.ReturnType = typeAnnotation(n.Returns)
+		node = fn
+	case "CallExpression":
+		node = &CallExpression{Token: tok(token.LPAREN, "("), Function: exp(n.Function), Arguments: exps(n.Arguments)}
+	case "MethodCallExpression":
+		node = &MethodCallExpression{Token: dotToken(n.Operator), Object: exp(n.Left), Method: ident(rawNode(n.Name)), Arguments: exps(n.Arguments), Optional: n.Operator != ""}
+	case "FieldExpression":
+		node = &FieldExpression{Token: dotToken(n.Operator), Object: exp(n.Left), Field: ident(rawNode(n.Name)), Optional: n.Operator != ""}
+	case "ArrayLiteral":
+		node = &ArrayLiteral{Token: tok(token.LBRACKET, "["), Elements: exps(n.Elements)}
+	case "IndexExpression":
+		bracket := tok(token.LBRACKET, "[")
+		if n.Operator != "" {
+			bracket = tok(token.OPTIONAL_LBRACKET, "?[")
+		}
+		node = &IndexExpression{Token: bracket, Left: exp(n.Left), Index: exp(n.Index), Optional: n.Operator != ""}
+	case "HashLiteral":
+		hash := &HashLiteral{Token: tok(token.LBRACE, "{"), Pairs: map[Expression]Expression{}, Keys: []Expression{}}
+		for _, pair := range n.Pairs {
+			key := exp(pair.Key)
+			hash.Keys = append(hash.Keys, key)
+			hash.Pairs[key] = exp(pair.Value)
+		}
+		node = hash
+	default:
+		return nil, fmt.Errorf("unknown node type %q", n.Node)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// firstToken returns the token an expression statement starting with exp
+// would have been given by the parser.
+func firstToken(exp Expression) token.Token {
+	switch exp := exp.(type) {
+	case *InfixExpression:
+		return firstToken(exp.Left)
+	case *CallExpression:
+		return firstToken(exp.Function)
+	case *IndexExpression:
+		return firstToken(exp.Left)
+	case *MethodCallExpression:
+		return firstToken(exp.Object)
+	case *FieldExpression:
+		return firstToken(exp.Object)
+	case *Identifier:
+		return exp.Token
+	case *IntegerLiteral:
+		return exp.Token
+	case *StringLiteral:
+		return exp.Token
+	case *Boolean:
+		return exp.Token
+	case *PrefixExpression:
+		return exp.Token
+	case *IfExpression:
+		return exp.Token
+	case *MatchExpression:
+		return exp.Token
+	case *SpreadExpression:
+		return exp.Token
+	case *ImportExpression:
+		return exp.Token
+	case *FunctionLiteral:
+		return exp.Token
+	case *ArrayLiteral:
+		return exp.Token
+	case *HashLiteral:
+		return exp.Token
+	}
+
+	return token.Token{}
+}
+
+// isMissing reports whether node is absent, including typed nil pointers left
+// by statements that failed to parse.
+func isMissing(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *Identifier:
+		return n == nil
+	case *BlockStatement:
+		return n == nil
+	}
+
+	return false
+}
+
+func statementNodes(statements []Statement) []Node {
+	nodes := make([]Node, len(statements))
+	for i, s := range statements {
+		nodes[i] = s
+	}
+	return nodes
+}
+
+func expressionNodes(expressions []Expression) []Node {
+	nodes := make([]Node, len(expressions))
+	for i, e := range expressions {
+		nodes[i] = e
+	}
+	return nodes
+}
+
+// typeAnnotation returns the annotation naming the type name, nil if it's
+// empty.
+func typeAnnotation(name string) *TypeAnnotation {
+	if name == "" {
+		return nil
+	}
+
+	if name == "fn" {
+		return &TypeAnnotation{Token: token.Token{Type: token.FUNCTION, Literal: name}, Name: name}
+	}
+	return &TypeAnnotation{Token: token.Token{Type: token.IDENT, Literal: name}, Name: name}
+}
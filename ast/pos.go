@@ -0,0 +1,208 @@
+package ast
+
+import (
+	"monkey/token"
+	"strings"
+)
+
+// tokenEnd returns the position just past tok in the source.
+func tokenEnd(tok token.Token) token.Position {
+	if !tok.Pos.IsValid() {
+		return tok.Pos
+	}
+
+	text := tok.Literal
+	if tok.Type == token.STRING {
+		// The literal leaves out the quotes
+		text = `"` + text + `"`
+	}
+
+	end := tok.Pos
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		// Strings may span lines
+		end.Line += strings.Count(text, "\n")
+		end.Column = len(text) - i
+	} else {
+		end.Column += len(text)
+	}
+
+	return end
+}
+
+// after returns the position just past a one character token at pos.
+func after(pos token.Position) token.Position {
+	if pos.IsValid() {
+		pos.Column++
+	}
+	return pos
+}
+
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[0].Pos()
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[len(p.Statements)-1].End()
+}
+
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if !isMissing(ls.Value) {
+		return ls.Value.End()
+	}
+	if !isMissing(ls.Name) {
+		return ls.Name.End()
+	}
+	return tokenEnd(ls.Token)
+}
+
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if !isMissing(rs.ReturnValue) {
+		return rs.ReturnValue.End()
+	}
+	return tokenEnd(rs.Token)
+}
+
+// The token of an expression statement is its first, which may be an opening
+// parenthesis the expression itself doesn't record.
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if !isMissing(es.Expression) {
+		return es.Expression.End()
+	}
+	return tokenEnd(es.Token)
+}
+
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position { return after(bs.Rbrace) }
+
+func (i *Identifier) Pos() token.Position      { return i.Token.Pos }
+func (i *Identifier) End() token.Position      { return tokenEnd(i.Token) }
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position { return tokenEnd(il.Token) }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return tokenEnd(sl.Token) }
+func (b *Boolean) Pos() token.Position         { return b.Token.Pos }
+func (b *Boolean) End() token.Position         { return tokenEnd(b.Token) }
+
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position {
+	if !isMissing(pe.Right) {
+		return pe.Right.End()
+	}
+	return tokenEnd(pe.Token)
+}
+
+func (ie *InfixExpression) Pos() token.Position {
+	if !isMissing(ie.Left) {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+
+func (ie *InfixExpression) End() token.Position {
+	if !isMissing(ie.Right) {
+		return ie.Right.End()
+	}
+	return tokenEnd(ie.Token)
+}
+
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if !isMissing(ie.Alternative) {
+		return ie.Alternative.End()
+	}
+	if !isMissing(ie.Consequence) {
+		return ie.Consequence.End()
+	}
+	return tokenEnd(ie.Token)
+}
+
+func (me *MatchExpression) Pos() token.Position { return me.Token.Pos }
+func (me *MatchExpression) End() token.Position { return after(me.Rbrace) }
+
+func (se *SpreadExpression) Pos() token.Position { return se.Token.Pos }
+func (se *SpreadExpression) End() token.Position {
+	if !isMissing(se.Value) {
+		return se.Value.End()
+	}
+	return tokenEnd(se.Token)
+}
+
+func (ie *ImportExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *ImportExpression) End() token.Position {
+	if !isMissing(ie.Path) {
+		return ie.Path.End()
+	}
+	return tokenEnd(ie.Token)
+}
+
+func (na *NamedArgument) Pos() token.Position { return na.Name.Pos() }
+func (na *NamedArgument) End() token.Position {
+	if !isMissing(na.Value) {
+		return na.Value.End()
+	}
+	return tokenEnd(na.Token)
+}
+
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position {
+	if !isMissing(fl.Body) {
+		return fl.Body.End()
+	}
+	return tokenEnd(fl.Token)
+}
+
+func (ce *CallExpression) Pos() token.Position {
+	if !isMissing(ce.Function) {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
+
+func (ce *CallExpression) End() token.Position { return after(ce.Rparen) }
+
+func (mc *MethodCallExpression) Pos() token.Position {
+	if !isMissing(mc.Object) {
+		return mc.Object.Pos()
+	}
+	return mc.Token.Pos
+}
+
+func (mc *MethodCallExpression) End() token.Position { return after(mc.Rparen) }
+
+func (fe *FieldExpression) Pos() token.Position {
+	if !isMissing(fe.Object) {
+		return fe.Object.Pos()
+	}
+	return fe.Token.Pos
+}
+
+func (fe *FieldExpression) End() token.Position {
+	if !isMissing(fe.Field) {
+		return fe.Field.End()
+	}
+	return tokenEnd(fe.Token)
+}
+
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position { return after(al.Rbracket) }
+
+func (ie *IndexExpression) Pos() token.Position {
+	if !isMissing(ie.Left) {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+
+func (ie *IndexExpression) End() token.Position { return after(ie.Rbracket) }
+
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position { return after(hl.Rbrace) }
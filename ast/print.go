@@ -0,0 +1,115 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"monkey/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	tokenType      = reflect.TypeOf(token.Token{})
+	commentMapType = reflect.TypeOf(CommentMap{})
+	bindingType    = reflect.TypeOf(Binding{})
+
+	functionLiteralType = reflect.TypeOf(FunctionLiteral{})
+)
+
+// Fprint writes an indented dump of the tree rooted at node to w, listing
+// every node's type and fields. Tokens are left out since the fields already
+// carry their values, and so are comments and what Resolve fills in.
+func Fprint(w io.Writer, node Node) error {
+	p := &printer{w: w}
+	p.print(reflect.ValueOf(node), 0)
+	p.printf("\n")
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, a ...any) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, a...)
+}
+
+func (p *printer) indent(depth int) {
+	p.printf("%s", strings.Repeat("  ", depth))
+}
+
+func (p *printer) print(v reflect.Value, depth int) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			p.printf("nil")
+			return
+		}
+		p.print(v.Elem(), depth)
+	case reflect.Pointer:
+		if v.IsNil() {
+			p.printf("nil")
+			return
+		}
+		p.printf("%s ", v.Type())
+		p.print(v.Elem(), depth)
+	case reflect.Struct:
+		p.printf("{\n")
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Type == tokenType || field.Type == commentMapType {
+				continue
+			}
+			if field.Type == bindingType || (v.Type() == functionLiteralType && field.Name == "Locals") {
+				continue
+			}
+
+			p.indent(depth + 1)
+			p.printf("%s: ", field.Name)
+			p.print(v.Field(i), depth+1)
+			p.printf("\n")
+		}
+		p.indent(depth)
+		p.printf("}")
+	case reflect.Slice:
+		if v.Len() == 0 {
+			p.printf("[]")
+			return
+		}
+
+		p.printf("[\n")
+		for i := 0; i < v.Len(); i++ {
+			p.indent(depth + 1)
+			p.print(v.Index(i), depth+1)
+			p.printf("\n")
+		}
+		p.indent(depth)
+		p.printf("]")
+	case reflect.Map:
+		// Order entries by their source form so dumps are deterministic
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+
+		p.printf("{\n")
+		for _, key := range keys {
+			p.indent(depth + 1)
+			p.print(key, depth+1)
+			p.printf(": ")
+			p.print(v.MapIndex(key), depth+1)
+			p.printf("\n")
+		}
+		p.indent(depth)
+		p.printf("}")
+	case reflect.String:
+		p.printf("%q", v.String())
+	default:
+		p.printf("%v", v.Interface())
+	}
+}
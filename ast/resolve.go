@@ -0,0 +1,142 @@
+package ast
+
+// BindingScope says where the value of an identifier is found.
+type BindingScope int
+
+const (
+	// Unresolved identifiers are looked up by name
+	Unresolved BindingScope = iota
+	// Global identifiers aren't bound by any enclosing function, so they
+	// name a global or a builtin
+	Global
+	// Local identifiers are bound by an enclosing function
+	Local
+)
+
+// Binding tells the evaluator where an identifier's value is.
+type Binding struct {
+	Scope BindingScope
+	// For Local bindings, how many functions out from the identifier the
+	// binding function is, 0 for the innermost, and the index of the name
+	// in its Locals
+	Depth int
+	Slot  int
+}
+
+// Resolve fills in the Binding of every identifier under node and the Locals
+// of every function literal, so the evaluator can keep local variables in
+// slots instead of looking them up by name. node is taken to be at the top
+// level of a program. The parser resolves the programs it returns; trees
+// built otherwise, e.g. with FromJSON, still evaluate without resolving, only
+// slower.
+//
+// Names bound anywhere in a function are local to all of it, even before
+// their let statement runs. The evaluator falls back to looking such names up
+// further out until then.
+func Resolve(node Node) {
+	if !isMissing(node) {
+		Walk(&resolver{}, node)
+	}
+}
+
+type resolveScope struct {
+	slots map[string]int
+	outer *resolveScope
+}
+
+type resolver struct {
+	// nil at the top level
+	scope *resolveScope
+}
+
+func (r *resolver) Visit(node Node) Visitor {
+	switch node := node.(type) {
+	case *FunctionLiteral:
+		return &resolver{scope: newResolveScope(node, r.scope)}
+	case *MethodCallExpression:
+		// Method and field names aren't variables
+		walkNode(r, node.Object)
+		walkExpressions(r, node.Arguments)
+		return nil
+	case *FieldExpression:
+		walkNode(r, node.Object)
+		return nil
+	case *NamedArgument:
+		// The name is the callee's parameter, not a variable here
+		walkNode(r, node.Value)
+		return nil
+	case *MatchExpression:
+		// Patterns are made of literals and the names they bind
+		walkNode(r, node.Subject)
+		for _, arm := range node.Arms {
+			for _, name := range PatternBindings(arm.Pattern) {
+				name.Binding = r.resolve(name.Value)
+			}
+			walkNode(r, arm.Guard)
+			walkNode(r, arm.Body)
+		}
+		return nil
+	case *Identifier:
+		node.Binding = r.resolve(node.Value)
+	}
+
+	return r
+}
+
+func (r *resolver) resolve(name string) Binding {
+	depth := 0
+	for s := r.scope; s != nil; s = s.outer {
+		if slot, ok := s.slots[name]; ok {
+			return Binding{Scope: Local, Depth: depth, Slot: slot}
+		}
+		depth++
+	}
+
+	return Binding{Scope: Global}
+}
+
+// newResolveScope declares the parameters of fn and the names bound by lets
+// and match patterns in its body, leaving out those of nested functions.
+func newResolveScope(fn *FunctionLiteral, outer *resolveScope) *resolveScope {
+	s := &resolveScope{slots: map[string]int{}, outer: outer}
+	fn.Locals = []string{}
+
+	for _, param := range fn.Parameters {
+		if param == nil {
+			continue
+		}
+		// A repeated parameter refers to the last argument for it
+		s.slots[param.Value] = len(fn.Locals)
+		fn.Locals = append(fn.Locals, param.Value)
+	}
+
+	if !isMissing(fn.Body) {
+		Inspect(fn.Body, func(node Node) bool {
+			switch node := node.(type) {
+			case *FunctionLiteral:
+				return false
+			case *LetStatement:
+				if node.Name != nil {
+					s.declare(fn, node.Name.Value)
+				}
+			case *MatchExpression:
+				for _, arm := range node.Arms {
+					for _, name := range PatternBindings(arm.Pattern) {
+						s.declare(fn, name.Value)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	return s
+}
+
+// declare gives name the next slot of fn, unless it already has one.
+func (s *resolveScope) declare(fn *FunctionLiteral, name string) {
+	if _, ok := s.slots[name]; !ok {
+		s.slots[name] = len(fn.Locals)
+		fn.Locals = append(fn.Locals, name)
+	}
+}
@@ -0,0 +1,137 @@
+package ast
+
+import "sort"
+
+// A Visitor's Visit method is called for each node found by Walk. If it
+// returns a non-nil Visitor w, Walk visits each of the children of node with
+// w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node depth first, visiting children in
+// source order. Comments aren't part of the tree and aren't visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		walkNode(v, n.Name)
+		walkNode(v, n.Value)
+	case *ReturnStatement:
+		walkNode(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkNode(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *PrefixExpression:
+		walkNode(v, n.Right)
+	case *InfixExpression:
+		walkNode(v, n.Left)
+		walkNode(v, n.Right)
+	case *IfExpression:
+		walkNode(v, n.Condition)
+		walkNode(v, n.Consequence)
+		walkNode(v, n.Alternative)
+	case *MatchExpression:
+		walkNode(v, n.Subject)
+		for _, arm := range n.Arms {
+			walkNode(v, arm.Pattern)
+			walkNode(v, arm.Guard)
+			walkNode(v, arm.Body)
+		}
+	case *SpreadExpression:
+		walkNode(v, n.Value)
+	case *ImportExpression:
+		walkNode(v, n.Path)
+	case *NamedArgument:
+		walkNode(v, n.Name)
+		walkNode(v, n.Value)
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			walkNode(v, param)
+		}
+		walkNode(v, n.Body)
+	case *CallExpression:
+		walkNode(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *MethodCallExpression:
+		walkNode(v, n.Object)
+		walkNode(v, n.Method)
+		walkExpressions(v, n.Arguments)
+	case *FieldExpression:
+		walkNode(v, n.Object)
+		walkNode(v, n.Field)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *IndexExpression:
+		walkNode(v, n.Left)
+		walkNode(v, n.Index)
+	case *HashLiteral:
+		for _, key := range n.SortedKeys() {
+			walkNode(v, key)
+			walkNode(v, n.Pairs[key])
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// walkNode walks node unless it's missing, e.g. the value of a let statement
+// that failed to parse. Typed nil pointers count as missing too.
+func walkNode(v Visitor, node Node) {
+	if !isMissing(node) {
+		Walk(v, node)
+	}
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, stmt := range statements {
+		walkNode(v, stmt)
+	}
+}
+
+func walkExpressions(v Visitor, expressions []Expression) {
+	for _, exp := range expressions {
+		walkNode(v, exp)
+	}
+}
+
+// SortedKeys returns the keys of the hash in source order. Hashes built by
+// hand rather than parsed may not record their order, and are sorted by the
+// keys' String form instead.
+func (hl *HashLiteral) SortedKeys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+
+	keys := []Expression{}
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node like Walk, calling f for each
+// node. If f returns true, Inspect goes on to the children of node, followed
+// by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
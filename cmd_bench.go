@@ -0,0 +1,23 @@
+package main
+
+import (
+	"monkey/run"
+)
+
+func benchCommand(args []string) int {
+	fs := newFlagSet("bench", "[file]")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return run.ExitUsageError
+	}
+
+	if fs.NArg() == 1 {
+		return run.BenchFile(fs.Arg(0), run.Options{})
+	}
+
+	return run.Bench(run.BenchmarkProgram, run.Options{})
+}
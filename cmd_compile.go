@@ -0,0 +1,41 @@
+package main
+
+import (
+	"monkey/run"
+)
+
+func compileCommand(args []string) int {
+	fs := newFlagSet("compile", "file")
+	output := fs.String("o", "", "output path, defaults to the file with a "+run.BytecodeExt+" extension")
+	inline := fs.Bool("inline", false, "inline calls to small functions bound at the top level")
+	optimize := fs.Bool("optimize", false, "fold constants and drop branches that can't run")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return run.ExitUsageError
+	}
+
+	filename := fs.Arg(0)
+	if *output == "" {
+		*output = run.BytecodePath(filename)
+	}
+
+	return run.CompileFile(filename, *output, run.Options{Inline: *inline, Optimize: *optimize})
+}
+
+func disasmCommand(args []string) int {
+	fs := newFlagSet("disasm", "file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return run.ExitUsageError
+	}
+
+	return run.DisassembleFile(fs.Arg(0), run.Options{})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"monkey/dap"
+	"monkey/run"
+	"os"
+)
+
+func dapCommand(args []string) int {
+	fs := newFlagSet("dap", "")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	// The client talks to the debugger over stdin and stdout
+	if err := dap.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "debug session failed: %s\n", err)
+		return run.ExitRuntimeError
+	}
+
+	return run.ExitOK
+}
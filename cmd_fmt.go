@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"monkey/format"
+	"monkey/run"
+	"os"
+)
+
+func fmtCommand(args []string) int {
+	fs := newFlagSet("fmt", "file...")
+	write := fs.Bool("w", false, "write the result back to the file instead of stdout")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return run.ExitUsageError
+	}
+
+	code := run.ExitOK
+	for _, filename := range fs.Args() {
+		if c := formatFile(filename, *write); c != run.ExitOK {
+			code = c
+		}
+	}
+
+	return code
+}
+
+func formatFile(filename string, write bool) int {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read file: %s\n", err)
+		return run.ExitUsageError
+	}
+
+	formatted, err := format.Source(string(src))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s:\n%s\n", filename, err)
+		return run.ExitParseError
+	}
+
+	if !write {
+		fmt.Print(formatted)
+		return run.ExitOK
+	}
+
+	if formatted == string(src) {
+		return run.ExitOK
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stat file: %s\n", err)
+		return run.ExitUsageError
+	}
+
+	if err := os.WriteFile(filename, []byte(formatted), info.Mode().Perm()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write file: %s\n", err)
+		return run.ExitUsageError
+	}
+
+	return run.ExitOK
+}
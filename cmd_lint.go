@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/parser"
+	"monkey/run"
+	"os"
+)
+
+func lintCommand(args []string) int {
+	fs := newFlagSet("lint", "file...")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return run.ExitUsageError
+	}
+
+	code := run.ExitOK
+	for _, filename := range fs.Args() {
+		if c := lintFile(filename); c != run.ExitOK {
+			code = c
+		}
+	}
+
+	return code
+}
+
+func lintFile(filename string) int {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read file: %s\n", err)
+		return run.ExitUsageError
+	}
+
+	p := parser.New(lexer.NewWithFilename(filename, string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, err := range p.Errors() {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return run.ExitParseError
+	}
+
+	diagnostics := lint.Lint(program)
+	for _, d := range diagnostics {
+		if pos := d.Node.Pos(); pos.IsValid() {
+			fmt.Printf("%s: %s\n", pos, d)
+		} else {
+			fmt.Printf("%s: %s\n", filename, d)
+		}
+	}
+
+	if len(diagnostics) != 0 {
+		return run.ExitRuntimeError
+	}
+
+	return run.ExitOK
+}
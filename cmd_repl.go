@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"monkey/repl"
+	"monkey/run"
+	"os"
+)
+
+func replCommand(args []string) int {
+	fs := newFlagSet("repl", "")
+	noColor := fs.Bool("no-color", false, "disable colored output")
+	types := fs.Bool("types", false, "show the type of each result")
+	engine := fs.String("engine", string(run.EngineVM), "engine evaluating input: eval or vm")
+	prompt := fs.String("prompt", envOr("MONKEY_PROMPT", repl.PROMPT), "prompt printed before each line, also set with MONKEY_PROMPT")
+	banner := fs.String("banner", os.Getenv("MONKEY_BANNER"), "greeting printed on start, also set with MONKEY_BANNER (default a hello to the current user)")
+	werror := fs.Bool("Werror", false, "skip lines with warnings instead of running them")
+	quiet := fs.Bool("quiet", os.Getenv("MONKEY_QUIET") != "", "print neither the banner nor prompts, also set with MONKEY_QUIET")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	cfg := repl.DefaultConfig(os.Stdout)
+	cfg.HandleInterrupts = true
+	if *noColor {
+		cfg.Color = false
+	}
+	cfg.ShowTypes = *types
+	cfg.Prompt = *prompt
+	cfg.Banner = *banner
+	if cfg.Banner == "" {
+		cfg.Banner = repl.Greeting()
+	}
+	cfg.Quiet = *quiet
+	cfg.WarningsAsErrors = *werror
+
+	switch run.Engine(*engine) {
+	case run.EngineVM:
+		repl.StartVMReplWithConfig(os.Stdin, os.Stdout, cfg)
+	case run.EngineEval:
+		repl.StartWithConfig(os.Stdin, os.Stdout, cfg)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown engine %q\n", *engine)
+		return run.ExitUsageError
+	}
+
+	return run.ExitOK
+}
+
+// envOr returns the value of the environment variable called name, or def
+// if it's unset or empty.
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"monkey/object"
+	"monkey/repl"
+	"monkey/run"
+	"os"
+	"os/signal"
+)
+
+func runCommand(args []string) int {
+	fs := newFlagSet("run", "[file | directory | -] [script arguments]")
+	engine := fs.String("engine", string(run.EngineEval), "engine used to run files: eval or vm")
+	expr := fs.String("e", "", "evaluate the given program and print its result")
+	dumpTokens := fs.Bool("dump-tokens", false, "print the token stream instead of running")
+	dumpAST := fs.Bool("dump-ast", false, "print the parsed AST instead of running")
+	dumpASTJSON := fs.Bool("dump-ast-json", false, "print the parsed AST as JSON instead of running")
+	dumpBytecode := fs.Bool("dump-bytecode", false, "print compiled bytecode instead of running (vm engine)")
+	maxDepth := fs.Int("max-depth", object.DefaultMaxDepth, "how deeply functions may recurse")
+	watch := fs.Bool("watch", false, "run the file again whenever it changes")
+	strict := fs.Bool("strict", false, "fail on indexes out of range, non-boolean conditions and undeclared names in code that doesn't run")
+	checkTypes := fs.Bool("check-types", false, "check arguments and results of functions against their type annotations")
+	werror := fs.Bool("Werror", false, "treat warnings as errors, failing before the program runs")
+	optimize := fs.Bool("optimize", false, "fold constants and drop branches that can't run before running")
+	inline := fs.Bool("inline", false, "inline calls to small functions bound at the top level (vm engine)")
+	coverage := fs.Bool("coverage", false, "report which lines of the program ran once it finishes")
+	profile := fs.Bool("profile", false, "report how often each function was called and for how long once the program finishes")
+	cache := fs.Bool("cache", false, "cache compiled bytecode of files, reusing it until they change (vm engine)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	args = fs.Args()
+	opts := run.Options{
+		Engine:       run.Engine(*engine),
+		DumpTokens:   *dumpTokens,
+		DumpAST:      *dumpAST,
+		DumpASTJSON:  *dumpASTJSON,
+		DumpBytecode: *dumpBytecode,
+		MaxDepth:     *maxDepth,
+		Inline:       *inline,
+		Optimize:     *optimize,
+
+		WarningsAsErrors: *werror,
+		Strict:           *strict,
+		CheckTypes:       *checkTypes,
+	}
+
+	if *coverage {
+		opts.Coverage = os.Stderr
+	}
+
+	if *profile {
+		opts.Profile = os.Stderr
+	}
+
+	if *cache {
+		dir, err := run.DefaultCacheDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "no cache directory: %s\n", err)
+			return run.ExitUsageError
+		}
+		opts.CacheDir = dir
+	}
+
+	if *watch && (*expr != "" || len(args) == 0 || args[0] == "-") {
+		fmt.Fprintln(os.Stderr, "--watch needs a file to run")
+		return run.ExitUsageError
+	}
+
+	if *expr != "" {
+		opts.Args = args
+		return run.RunProgram(*expr, opts)
+	}
+
+	// "-" or piped input means the program comes from stdin
+	if (len(args) > 0 && args[0] == "-") || (len(args) == 0 && !repl.IsTerminal(os.Stdin)) {
+		if len(args) > 0 {
+			opts.Args = args[1:]
+		}
+		return run.RunProgramFromReader(os.Stdin, opts)
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return run.ExitUsageError
+	}
+
+	opts.Args = args[1:]
+	if *watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return run.WatchFile(ctx, args[0], run.DefaultWatchInterval, opts)
+	}
+
+	return run.RunProgramFromFile(args[0], opts)
+}
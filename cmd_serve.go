@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"monkey/repl"
+	"monkey/run"
+	"net"
+	"os"
+	"strconv"
+)
+
+func serveCommand(args []string) int {
+	fs := newFlagSet("serve", "")
+	host := fs.String("host", "127.0.0.1", "interface to listen on")
+	port := fs.Int("port", 7007, "TCP port to listen on")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(*host, strconv.Itoa(*port)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not listen: %s\n", err)
+		return run.ExitUsageError
+	}
+
+	fmt.Printf("Monkey REPL listening on %s\n", ln.Addr())
+	if err := repl.Serve(ln, repl.Config{}); err != nil {
+		fmt.Fprintf(os.Stderr, "server stopped: %s\n", err)
+		return run.ExitRuntimeError
+	}
+
+	return run.ExitOK
+}
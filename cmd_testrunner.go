@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"monkey/run"
+	"os"
+)
+
+func testCommand(args []string) int {
+	fs := newFlagSet("test", "[file or directory...]")
+	verbose := fs.Bool("v", false, "also list the tests that pass")
+	coverage := fs.Bool("coverage", false, "report which lines of the modules tested ran")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := run.FindTestFiles(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to find tests: %s\n", err)
+		return run.ExitUsageError
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "no %s files found\n", run.TestFileSuffix)
+		return run.ExitUsageError
+	}
+
+	opts := run.Options{}
+	if *coverage {
+		opts.Coverage = os.Stderr
+	}
+
+	return run.RunTests(files, *verbose, opts)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 )
 
 type Instructions []byte
@@ -52,6 +53,27 @@ const (
 
 	OpClosure
 	OpCurrentClosure
+
+	OpCallMethod
+
+	OpMatch
+
+	OpSpread
+	OpCallSpread
+	OpCallMethodSpread
+
+	OpCallNamed
+
+	OpCopyConstant
+
+	OpImport
+
+	OpJumpNull
+
+	OpConstantAdd
+	OpConstantSub
+	OpGetLocalGetLocalAdd
+	OpCompareJumpNotTruthy
 )
 
 type Definition struct {
@@ -109,6 +131,50 @@ var definitions = map[Opcode]*Definition{
 	OpClosure: {"OpClosure", []int{2, 1}},
 
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+
+	// First operand is the constant index of the method name, second is how
+	// many arguments follow the receiver on the stack.
+	OpCallMethod: {"OpCallMethod", []int{2, 1}},
+
+	// Operand is the constant index of the pattern to match the top of the
+	// stack against. Leaves it there, pushing what the pattern binds and true
+	// on a match and false otherwise.
+	OpMatch: {"OpMatch", []int{2}},
+
+	// Pops an array and appends its elements to the one below it, which is
+	// being built for an array literal or arguments with spreads in them.
+	OpSpread: {"OpSpread", []int{}},
+	// Like OpCall and OpCallMethod, with the arguments in an array on top of
+	// the stack
+	OpCallSpread:       {"OpCallSpread", []int{}},
+	OpCallMethodSpread: {"OpCallMethodSpread", []int{2}},
+
+	// First operand is the constant index of the array of argument names,
+	// second is how many positional arguments come before the named ones.
+	OpCallNamed: {"OpCallNamed", []int{2, 1}},
+
+	// Operand is the constant index of an array or hash literal made only of
+	// constants. Pushes a copy, as it may go on to be frozen.
+	OpCopyConstant: {"OpCopyConstant", []int{2}},
+
+	// Operand is the constant index of the function compiled from a module.
+	// Runs it the first time, and pushes the module of the exports it returns.
+	OpImport: {"OpImport", []int{2}},
+
+	// Jumps to the operand if the value on top of the stack is null, leaving
+	// it there as the result of a null-safe ?. or ?[.
+	OpJumpNull: {"OpJumpNull", []int{2}},
+
+	// Superinstructions, fused by the compiler from sequences that come up
+	// in arithmetic and conditions so the VM dispatches fewer instructions.
+	// OpConstantAdd and OpConstantSub are OpConstant followed by OpAdd or
+	// OpSub, OpGetLocalGetLocalAdd two OpGetLocals followed by OpAdd.
+	OpConstantAdd:         {"OpConstantAdd", []int{2}},
+	OpConstantSub:         {"OpConstantSub", []int{2}},
+	OpGetLocalGetLocalAdd: {"OpGetLocalGetLocalAdd", []int{1, 1}},
+	// First operand is the comparison opcode, second where OpJumpNotTruthy
+	// would jump to.
+	OpCompareJumpNotTruthy: {"OpCompareJumpNotTruthy", []int{1, 2}},
 }
 
 func Lookup(op byte) (*Definition, error) {
@@ -154,23 +220,88 @@ func Make(op Opcode, operands ...int) []byte {
 func (ins Instructions) String() string {
 	var out bytes.Buffer
 
+	ins.each(func(pos int, op Opcode, operands []int, text string) {
+		fmt.Fprintf(&out, "%04d %s\n\t", pos, text)
+	})
+
+	return out.String()
+}
+
+// Disassemble writes one line per instruction to w, each prefixed by indent.
+func (ins Instructions) Disassemble(w io.Writer, indent string) {
+	ins.DisassembleSymbols(w, indent, nil)
+}
+
+// DisassembleSymbols is Disassemble, with the names of the variables
+// instructions refer to in comments, e.g. OpSetGlobal 0 ; x
+func (ins Instructions) DisassembleSymbols(w io.Writer, indent string, symbols *Symbols) {
+	ins.each(func(pos int, op Opcode, operands []int, text string) {
+		if name := symbols.name(op, operands); name != "" {
+			text += " ; " + name
+		}
+		fmt.Fprintf(w, "%s%04d %s\n", indent, pos, text)
+	})
+}
+
+// Symbols holds the names of variables by index, for the instructions of a
+// single function or the main program.
+type Symbols struct {
+	Globals  []string
+	Locals   []string
+	Free     []string
+	Builtins []string
+}
+
+// name returns the name of the variable an instruction refers to, or "" if
+// it doesn't refer to one or its name isn't known.
+func (s *Symbols) name(op Opcode, operands []int) string {
+	if s == nil || len(operands) == 0 {
+		return ""
+	}
+
+	var names []string
+	switch op {
+	case OpGetGlobal, OpSetGlobal:
+		names = s.Globals
+	case OpGetLocal, OpSetLocal:
+		names = s.Locals
+	case OpGetFree:
+		names = s.Free
+	case OpGetBuiltin:
+		names = s.Builtins
+	case OpGetLocalGetLocalAdd:
+		if operands[0] < len(s.Locals) && operands[1] < len(s.Locals) {
+			return s.Locals[operands[0]] + ", " + s.Locals[operands[1]]
+		}
+		return ""
+	}
+
+	if operands[0] < len(names) {
+		return names[operands[0]]
+	}
+	return ""
+}
+
+// each decodes the instructions, calling fn with the position, opcode,
+// operands and formatted text of every one of them. Opcodes that can't be
+// decoded are passed with no operands.
+func (ins Instructions) each(fn func(pos int, op Opcode, operands []int, text string)) {
 	i := 0
 
 	for i < len(ins) {
 		def, err := Lookup(ins[i])
 		if err != nil {
-			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			fn(i, Opcode(ins[i]), nil, fmt.Sprintf("ERROR: %s", err))
+			i++
 			continue
 		}
 
 		operands, read := ReadOperands(def, ins[i+1:])
 
-		fmt.Fprintf(&out, "%04d %s\n\t", i, ins.fmtInstruction(def, operands))
+		fn(i, Opcode(ins[i]), operands, ins.fmtInstruction(def, operands))
 
 		i += 1 + read
 	}
-
-	return out.String()
 }
 
 func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
@@ -1,6 +1,8 @@
 package code
 
 import (
+	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -57,6 +59,44 @@ func TestInstructionsString(t *testing.T) {
 	}
 }
 
+func TestDisassembleSymbols(t *testing.T) {
+	instructions := Instructions{}
+	for _, ins := range []Instructions{
+		Make(OpSetGlobal, 1),
+		Make(OpGetLocal, 0),
+		Make(OpGetFree, 0),
+		Make(OpGetBuiltin, 0),
+		Make(OpGetLocal, 5),
+		Make(OpConstant, 0),
+		Make(OpGetLocalGetLocalAdd, 0, 0),
+	} {
+		instructions = append(instructions, ins...)
+	}
+
+	symbols := &Symbols{
+		Globals:  []string{"a", "b"},
+		Locals:   []string{"x"},
+		Free:     []string{"y"},
+		Builtins: []string{"len"},
+	}
+
+	expected := `  0000 OpSetGlobal 1 ; b
+  0003 OpGetLocal 0 ; x
+  0005 OpGetFree 0 ; y
+  0007 OpGetBuiltin 0 ; len
+  0009 OpGetLocal 5
+  0011 OpConstant 0
+  0014 OpGetLocalGetLocalAdd 0 0 ; x, x
+`
+
+	var out strings.Builder
+	instructions.DisassembleSymbols(&out, "  ", symbols)
+
+	if out.String() != expected {
+		t.Errorf("instructions wrongly disassembled. want %q got %q", expected, out.String())
+	}
+}
+
 func TestReadOperands(t *testing.T) {
 	tests := []struct {
 		op        Opcode
@@ -87,3 +127,43 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceMap(t *testing.T) {
+	pos := func(line, column int) token.Position {
+		return token.Position{Line: line, Column: column}
+	}
+
+	var m SourceMap
+	m = m.Add(0, pos(1, 1))
+	m = m.Add(3, pos(1, 1))
+	m = m.Add(4, pos(1, 5))
+	m = m.Add(7, pos(2, 1))
+	m = m.Add(9, pos(2, 3))
+
+	if len(m) != 4 {
+		t.Fatalf("mappings from the same position not merged. got=%v", m)
+	}
+
+	m = m.Truncate(9)
+	m = m.Add(9, pos(3, 1))
+
+	tests := []struct {
+		offset   int
+		expected string
+	}{
+		{-1, "-"},
+		{0, "1:1"},
+		{3, "1:1"},
+		{4, "1:5"},
+		{6, "1:5"},
+		{8, "2:1"},
+		{9, "3:1"},
+		{100, "3:1"},
+	}
+
+	for _, tt := range tests {
+		if got := m.Lookup(tt.offset).String(); got != tt.expected {
+			t.Errorf("wrong position for offset %d. expected=%s, got=%s", tt.offset, tt.expected, got)
+		}
+	}
+}
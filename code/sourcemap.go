@@ -0,0 +1,45 @@
+package code
+
+import (
+	"monkey/token"
+	"sort"
+)
+
+// SourceMap records where in the source instructions were compiled from.
+// Each mapping covers the instructions from its offset up to the next
+// mapping's, and mappings are ordered by offset.
+type SourceMap []SourceMapping
+
+type SourceMapping struct {
+	Offset int
+	Pos    token.Position
+}
+
+// Add maps the instructions starting at offset to pos. Consecutive
+// instructions from the same position share a mapping.
+func (m SourceMap) Add(offset int, pos token.Position) SourceMap {
+	if len(m) > 0 && m[len(m)-1].Pos == pos {
+		return m
+	}
+
+	return append(m, SourceMapping{Offset: offset, Pos: pos})
+}
+
+// Truncate drops the mappings of instructions from offset on, for when they
+// are removed.
+func (m SourceMap) Truncate(offset int) SourceMap {
+	n := sort.Search(len(m), func(i int) bool { return m[i].Offset >= offset })
+	return m[:n]
+}
+
+// Lookup returns the position of the instruction containing offset, which
+// may point at one of its operands. The position is invalid if the map
+// doesn't cover offset.
+func (m SourceMap) Lookup(offset int) token.Position {
+	n := sort.Search(len(m), func(i int) bool { return m[i].Offset > offset })
+	if n == 0 {
+		return token.Position{}
+	}
+
+	return m[n-1].Pos
+}
@@ -0,0 +1,264 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"monkey/code"
+	"monkey/object"
+)
+
+func init() {
+	// Concrete types that can show up in the constant pool
+	gob.Register(&object.Integer{})
+	gob.Register(&object.String{})
+	gob.Register(&object.Boolean{})
+	gob.Register(&object.Array{})
+	gob.Register(&object.Hash{})
+	gob.Register(&object.Pattern{})
+	gob.Register(&object.CompiledFunction{})
+}
+
+// Serialized bytecode starts with a header of the magic number, the format
+// version and a checksum of the encoded bytecode that follows it.
+var bytecodeMagic = []byte("MKBC")
+
+// BytecodeVersion is the version of the serialized format, which changes
+// whenever the opcodes or the way bytecode is encoded do. Bytecode of other
+// versions has to be compiled again.
+const BytecodeVersion = 2
+
+const headerSize = 4 + 2 + 4
+
+// Write serializes the bytecode so it can be run later without recompiling.
+func (b *Bytecode) Write(w io.Writer) error {
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(b); err != nil {
+		return err
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, bytecodeMagic)
+	binary.BigEndian.PutUint16(header[4:], BytecodeVersion)
+	binary.BigEndian.PutUint32(header[6:], crc32.ChecksumIEEE(body.Bytes()))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// ReadBytecode loads bytecode previously serialized with Write. Bytecode
+// written by other versions, that's been corrupted, or that would have the
+// VM read outside of its instructions or constants is rejected.
+func ReadBytecode(r io.Reader) (*Bytecode, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < headerSize || !bytes.Equal(data[:4], bytecodeMagic) {
+		return nil, errors.New("not Monkey bytecode")
+	}
+	if version := binary.BigEndian.Uint16(data[4:]); version != BytecodeVersion {
+		return nil, fmt.Errorf("bytecode version %d isn't supported, want %d; compile the source again", version, BytecodeVersion)
+	}
+
+	body := data[headerSize:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[6:]) {
+		return nil, errors.New("checksum mismatch, the bytecode is corrupt")
+	}
+
+	bytecode := &Bytecode{}
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(bytecode); err != nil {
+		return nil, err
+	}
+
+	if err := bytecode.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, constant := range bytecode.Constants {
+		if str, ok := constant.(*object.String); ok {
+			str.CacheHashKey()
+		}
+	}
+
+	return bytecode, nil
+}
+
+// constantOperands are the opcodes whose first operand is the index of a
+// constant.
+var constantOperands = map[code.Opcode]bool{
+	code.OpConstant:         true,
+	code.OpClosure:          true,
+	code.OpCallMethod:       true,
+	code.OpMatch:            true,
+	code.OpCallMethodSpread: true,
+	code.OpCallNamed:        true,
+	code.OpCopyConstant:     true,
+	code.OpImport:           true,
+	code.OpConstantAdd:      true,
+	code.OpConstantSub:      true,
+}
+
+// constantTypes are the types of the constants the opcodes among
+// constantOperands that the VM needs a particular one for refer to, with
+// how errors describe them.
+var constantTypes = map[code.Opcode]struct {
+	typ  object.ObjectType
+	name string
+}{
+	code.OpClosure:          {object.COMPILED_FUNCTION_OBJ, "a function"},
+	code.OpImport:           {object.COMPILED_FUNCTION_OBJ, "a function"},
+	code.OpCallMethod:       {object.STRING_OBJ, "a string"},
+	code.OpCallMethodSpread: {object.STRING_OBJ, "a string"},
+	code.OpMatch:            {object.PATTERN_OBJ, "a pattern"},
+	code.OpCallNamed:        {object.ARRAY_OBJ, "an array"},
+}
+
+// Validate checks that the instructions of the main program and of every
+// function are well formed: their opcodes are defined, their operands are
+// all there, the constants they refer to exist and are of the type needed,
+// and the builtins, locals, free variables and jump targets they refer to
+// exist.
+func (b *Bytecode) Validate() error {
+	// Fewest free variables each function is closed over with, which are
+	// all it can read
+	closures := map[int]int{}
+
+	if err := b.validateInstructions(b.Instructions, 0, closures); err != nil {
+		return fmt.Errorf("main program: %s", err)
+	}
+
+	for i, constant := range b.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		if err := b.validateInstructions(fn.Instructions, fn.NumLocals, closures); err != nil {
+			return fmt.Errorf("function in constant %d: %s", i, err)
+		}
+	}
+
+	// Only known once every closure has been seen. The main program and
+	// functions run as modules have none.
+	if err := validateFree(b.Instructions, 0); err != nil {
+		return fmt.Errorf("main program: %s", err)
+	}
+	for i, constant := range b.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			if err := validateFree(fn.Instructions, closures[i]); err != nil {
+				return fmt.Errorf("function in constant %d: %s", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateFree checks that the OpGetFrees in ins, which is otherwise valid,
+// only read the numFree free variables there are.
+func validateFree(ins code.Instructions, numFree int) error {
+	for pos := 0; pos < len(ins); {
+		def, _ := code.Lookup(ins[pos])
+		operands, read := code.ReadOperands(def, ins[pos+1:])
+
+		if code.Opcode(ins[pos]) == code.OpGetFree && operands[0] >= numFree {
+			return fmt.Errorf("%s at %04d refers to free variable %d of %d", def.Name, pos, operands[0], numFree)
+		}
+
+		pos += 1 + read
+	}
+
+	return nil
+}
+
+// validateInstructions checks ins, the instructions of the main program or
+// a function with numLocals locals, recording the number of free variables
+// each OpClosure closes the function it makes over in closures.
+func (b *Bytecode) validateInstructions(ins code.Instructions, numLocals int, closures map[int]int) error {
+	starts := map[int]bool{}
+	// Positions of jumps and their targets
+	jumps := [][2]int{}
+
+	for pos := 0; pos < len(ins); {
+		starts[pos] = true
+
+		op := code.Opcode(ins[pos])
+		def, err := code.Lookup(byte(op))
+		if err != nil {
+			return fmt.Errorf("%s at %04d", err, pos)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if pos+1+width > len(ins) {
+			return fmt.Errorf("%s at %04d is cut short", def.Name, pos)
+		}
+		operands, _ := code.ReadOperands(def, ins[pos+1:])
+
+		if constantOperands[op] {
+			if operands[0] >= len(b.Constants) {
+				return fmt.Errorf("%s at %04d refers to constant %d of %d", def.Name, pos, operands[0], len(b.Constants))
+			}
+
+			constant := b.Constants[operands[0]]
+			if want, ok := constantTypes[op]; ok && constant.Type() != want.typ {
+				return fmt.Errorf("%s at %04d refers to constant %d, which isn't %s", def.Name, pos, operands[0], want.name)
+			}
+		}
+
+		switch op {
+		case code.OpClosure:
+			if numFree, ok := closures[operands[0]]; !ok || operands[1] < numFree {
+				closures[operands[0]] = operands[1]
+			}
+		case code.OpCallNamed:
+			for _, name := range b.Constants[operands[0]].(*object.Array).Elements {
+				if _, ok := name.(*object.String); !ok {
+					return fmt.Errorf("%s at %04d names an argument with %s", def.Name, pos, name.Type())
+				}
+			}
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpNull:
+			jumps = append(jumps, [2]int{pos, operands[0]})
+		case code.OpCompareJumpNotTruthy:
+			switch code.Opcode(operands[0]) {
+			case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+			default:
+				return fmt.Errorf("%s at %04d has no comparison %d", def.Name, pos, operands[0])
+			}
+			jumps = append(jumps, [2]int{pos, operands[1]})
+		case code.OpGetBuiltin:
+			if operands[0] >= len(object.Builtins) {
+				return fmt.Errorf("%s at %04d refers to builtin %d of %d", def.Name, pos, operands[0], len(object.Builtins))
+			}
+		case code.OpGetLocal, code.OpSetLocal, code.OpGetLocalGetLocalAdd:
+			for _, index := range operands {
+				if index >= numLocals {
+					return fmt.Errorf("%s at %04d refers to local %d of %d", def.Name, pos, index, numLocals)
+				}
+			}
+		}
+
+		pos += 1 + width
+	}
+
+	// Jumps may go to the end, where the VM stops
+	starts[len(ins)] = true
+	for _, jump := range jumps {
+		if !starts[jump[1]] {
+			return fmt.Errorf("jump at %04d to %04d doesn't land on an instruction", jump[0], jump[1])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/code"
+	"monkey/object"
+	"testing"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	program := parse(`let add = fn(a, b) { a + b }; add(1, "two"); [1, {"a": ["b"]}]; match ([true]) { [true, ...r] => r, {a: -1} => 0 }`)
+
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	original := compiler.Bytecode()
+
+	var buf bytes.Buffer
+	if err := original.Write(&buf); err != nil {
+		t.Fatalf("could not write bytecode: %s", err)
+	}
+
+	decoded, err := ReadBytecode(&buf)
+	if err != nil {
+		t.Fatalf("could not read bytecode: %s", err)
+	}
+
+	if decoded.Instructions.String() != original.Instructions.String() {
+		t.Errorf("wrong instructions. want=%q, got=%q", original.Instructions, decoded.Instructions)
+	}
+
+	if len(decoded.Constants) != len(original.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d", len(original.Constants), len(decoded.Constants))
+	}
+
+	for i, constant := range original.Constants {
+		if decoded.Constants[i].Type() != constant.Type() {
+			t.Errorf("constant %d has wrong type. want=%s, got=%s", i, constant.Type(), decoded.Constants[i].Type())
+		}
+	}
+}
+
+func TestReadBytecodeRejects(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse(`let x = 1; x + 2`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := compiler.Bytecode().Write(&buf); err != nil {
+		t.Fatalf("could not write bytecode: %s", err)
+	}
+	written := buf.Bytes()
+
+	tests := []struct {
+		name     string
+		change   func(data []byte) []byte
+		expected string
+	}{
+		{"empty", func(data []byte) []byte { return nil }, "not Monkey bytecode"},
+		{"magic", func(data []byte) []byte { data[0] = 'X'; return data }, "not Monkey bytecode"},
+		{
+			"version",
+			func(data []byte) []byte { data[5] = BytecodeVersion + 1; return data },
+			fmt.Sprintf("bytecode version %d isn't supported, want %d; compile the source again", BytecodeVersion+1, BytecodeVersion),
+		},
+		{"corrupt", func(data []byte) []byte { data[len(data)-1] ^= 0xff; return data }, "checksum mismatch, the bytecode is corrupt"},
+	}
+
+	for _, tt := range tests {
+		data := tt.change(bytes.Clone(written))
+
+		_, err := ReadBytecode(bytes.NewReader(data))
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	compiler := New()
+	program := parse(`
+	let add = fn(a, b) { a + b };
+	let f = fn(xs, y) { match (xs) { [x, ...r] => add(x, len(r)), _ => y } };
+	f([1, 2], 3)?.len();
+	f(...[[1], 2]);
+	{"a": f(xs: [1], y: 2)}["a"]?["b"];
+	if (add(1, 2) == 3) { puts("three") } else { 0 - 1 };
+	`)
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	if err := compiler.Bytecode().Validate(); err != nil {
+		t.Errorf("compiled bytecode is invalid: %s", err)
+	}
+
+	function := &object.CompiledFunction{
+		Instructions: concatInstructions([]code.Instructions{code.Make(code.OpGetLocal, 1), code.Make(code.OpReturnValue)}),
+		NumLocals:    1,
+	}
+
+	names := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}
+
+	tests := []struct {
+		instructions []code.Instructions
+		expected     string
+	}{
+		{
+			[]code.Instructions{{255}},
+			"main program: opcode 255 undefined at 0000",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpConstant, 2)[:2]},
+			"main program: OpConstant at 0000 is cut short",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpConstant, 3)},
+			"main program: OpConstant at 0000 refers to constant 3 of 3",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpClosure, 0, 0)},
+			"main program: OpClosure at 0000 refers to constant 0, which isn't a function",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpCallMethod, 0, 0)},
+			"main program: OpCallMethod at 0000 refers to constant 0, which isn't a string",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpCallMethodSpread, 0)},
+			"main program: OpCallMethodSpread at 0000 refers to constant 0, which isn't a string",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpMatch, 0)},
+			"main program: OpMatch at 0000 refers to constant 0, which isn't a pattern",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpCallNamed, 2, 0)},
+			"main program: OpCallNamed at 0000 names an argument with INTEGER",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpGetBuiltin, 255)},
+			fmt.Sprintf("main program: OpGetBuiltin at 0000 refers to builtin 255 of %d", len(object.Builtins)),
+		},
+		{
+			[]code.Instructions{code.Make(code.OpJump, 4)},
+			"main program: jump at 0000 to 0004 doesn't land on an instruction",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpJumpNotTruthy, 2), code.Make(code.OpNull)},
+			"main program: jump at 0000 to 0002 doesn't land on an instruction",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpCompareJumpNotTruthy, int(code.OpAdd), 4)},
+			"main program: OpCompareJumpNotTruthy at 0000 has no comparison 2",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpClosure, 1, 0)},
+			"function in constant 1: OpGetLocal at 0000 refers to local 1 of 1",
+		},
+	}
+
+	for _, tt := range tests {
+		bytecode := &Bytecode{
+			Instructions: concatInstructions(tt.instructions),
+			Constants:    []object.Object{&object.Integer{Value: 1}, function, names},
+		}
+
+		err := bytecode.Validate()
+		if err == nil {
+			t.Errorf("expected an error validating %q", bytecode.Instructions)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestValidateFree(t *testing.T) {
+	// Reads the free variables at 0 and 1
+	function := &object.CompiledFunction{
+		Instructions: concatInstructions([]code.Instructions{code.Make(code.OpGetFree, 0), code.Make(code.OpGetFree, 1), code.Make(code.OpReturnValue)}),
+	}
+
+	tests := []struct {
+		instructions []code.Instructions
+		expected     string
+	}{
+		{
+			[]code.Instructions{code.Make(code.OpGetFree, 0)},
+			"main program: OpGetFree at 0000 refers to free variable 0 of 0",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpClosure, 0, 1)},
+			"function in constant 0: OpGetFree at 0002 refers to free variable 1 of 1",
+		},
+		{
+			// Functions closed over in several places can only count on the
+			// fewest free variables
+			[]code.Instructions{code.Make(code.OpClosure, 0, 2), code.Make(code.OpClosure, 0, 1)},
+			"function in constant 0: OpGetFree at 0002 refers to free variable 1 of 1",
+		},
+		{
+			[]code.Instructions{code.Make(code.OpClosure, 0, 2)},
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		bytecode := &Bytecode{
+			Instructions: concatInstructions(tt.instructions),
+			Constants:    []object.Object{function},
+		}
+
+		err := bytecode.Validate()
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("unexpected error validating %q: %s", bytecode.Instructions, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("expected an error validating %q", bytecode.Instructions)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/module"
 	"monkey/object"
+	"monkey/token"
 	"sort"
 )
 
@@ -19,43 +21,99 @@ type Compiler struct {
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	// Where the node being compiled starts, recorded in the source map of
+	// the instructions emitted for it
+	pos token.Position
+
+	// Resolves and parses imports. Each module is compiled once, its
+	// function's constant index kept by filename.
+	loader    *module.Loader
+	modules   map[string]int
+	importing map[string]bool
+
+	// Whether calls to small functions are inlined, the functions that
+	// can be by the index of the global they're bound to, and while one's
+	// body is being compiled, the slots its parameters stand for
+	inlining      bool
+	inlinables    map[int]*inlinable
+	substitutions map[string]Symbol
 }
 
 type CompilationScope struct {
 	instructions        code.Instructions
+	sourceMap           code.SourceMap
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+
+	// The calls the function being compiled makes to itself as the last
+	// thing it does, which jump back to its start rather than call it
+	tailCalls map[*ast.CallExpression]bool
+	// The slots the parameters of functions inlined here are kept in
+	inlineSlots map[*ast.FunctionLiteral][]Symbol
 }
 
 func New() *Compiler {
-	mainScope := CompilationScope{
-		instructions:        code.Instructions{},
-		lastInstruction:     EmittedInstruction{},
-		previousInstruction: EmittedInstruction{},
-	}
 	symbolTable := NewSymbolTable()
 
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
 
+	return NewWithState(symbolTable, []object.Object{})
+}
+
+// NewWithState returns a compiler that carries on from the symbol table and
+// constants of an earlier one, as the REPL does line by line. s must already
+// have the builtins defined.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
 	return &Compiler{
-		constants:   []object.Object{},
-		symbolTable: symbolTable,
+		constants:   constants,
+		symbolTable: s,
 		scopes:      []CompilationScope{mainScope},
 		scopeIndex:  0,
+		loader:      module.NewLoader(""),
+		modules:     map[string]int{},
+		importing:   map[string]bool{},
+		inlinables:  map[int]*inlinable{},
 	}
 }
 
-func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
-	compiler := New()
-	compiler.symbolTable = s
-	compiler.constants = constants
+// SetLoader makes the compiler resolve imports with l, rather than relative
+// to the current directory.
+func (c *Compiler) SetLoader(l *module.Loader) {
+	c.loader = l
+}
+
+// Imports returns the files of the modules compiled so far, sorted.
+func (c *Compiler) Imports() []string {
+	files := make([]string, 0, len(c.modules))
+	for filename := range c.modules {
+		files = append(files, filename)
+	}
+	sort.Strings(files)
+
+	return files
+}
 
-	return compiler
+// SymbolTable returns the table of global names the compiler has resolved,
+// including the builtins.
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
 }
 
 func (c *Compiler) Compile(node ast.Node) error {
+	if pos := node.Pos(); pos.IsValid() {
+		defer func(outer token.Position) { c.pos = outer }(c.pos)
+		c.pos = pos
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, s := range node.Statements {
@@ -64,6 +122,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 			if err != nil {
 				return err
 			}
+
+			if let, ok := s.(*ast.LetStatement); ok {
+				c.defineInlinable(let)
+			}
 		}
 	case *ast.FunctionLiteral:
 		// Create a new scope to add instructions to
@@ -73,6 +135,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// in the symbolTable via function scope
 		if node.Name != "" {
 			c.symbolTable.DefineFunctionName(node.Name)
+			c.scopes[c.scopeIndex].tailCalls = tailCalls(node)
 		}
 
 		for _, p := range node.Parameters {
@@ -94,10 +157,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
-
+		localNames := c.symbolTable.DefinedNames()
 		// Pop off that scope and take those instructions to place in a new CompiledFunction
 		// constant
-		instructions := c.leaveScope()
+		instructions, sourceMap := c.leaveScope()
 
 		for _, sym := range freeSymbols {
 			c.loadSymbol(sym)
@@ -107,28 +170,111 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			Parameters:    parameterNames(node.Parameters),
+			LocalNames:    localNames,
+			FreeNames:     symbolNames(freeSymbols),
+			Name:          node.Name,
+			SourceMap:     sourceMap,
 		}
+		compiledFn.ParameterTypes, compiledFn.ReturnType = typeNames(node)
 
 		fnIndex := c.addConstant(compiledFn)
 
 		// Emit a new closure with the instructions on it
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
 	case *ast.CallExpression:
+		if c.isTailCall(node) {
+			return c.compileTailCall(node)
+		}
+		if callee := c.inlinableCall(node); callee != nil {
+			return c.compileInlineCall(node, callee)
+		}
+
 		err := c.Compile(node.Function)
 		if err != nil {
 			return err
 		}
 
+		if hasSpread(node.Arguments) {
+			if err := c.compileSpreadArray(node.Arguments); err != nil {
+				return err
+			}
+			c.emit(code.OpCallSpread)
+			return nil
+		}
+
 		// Push arguments onto stack in order (popped in reverse)
+		names := []object.Object{}
 		for _, arg := range node.Arguments {
+			if named, ok := arg.(*ast.NamedArgument); ok {
+				names = append(names, &object.String{Value: named.Name.Value})
+				arg = named.Value
+			}
+
 			err := c.Compile(arg)
 			if err != nil {
 				return err
 			}
 		}
 
+		if len(names) != 0 {
+			index := c.addConstant(&object.Array{Elements: names})
+			c.emit(code.OpCallNamed, index, len(node.Arguments)-len(names))
+			return nil
+		}
+
 		// Emit constants for arguments
 		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.MethodCallExpression:
+		err := c.Compile(node.Object)
+		if err != nil {
+			return err
+		}
+		// A null receiver of ?. is left as the result, skipping the call
+		var jumpNullPos int
+		if node.Optional {
+			jumpNullPos = c.emit(code.OpJumpNull, 9999)
+		}
+
+		if hasSpread(node.Arguments) {
+			if err := c.compileSpreadArray(node.Arguments); err != nil {
+				return err
+			}
+			name := c.addConstant(&object.String{Value: node.Method.Value})
+			c.emit(code.OpCallMethodSpread, name)
+		} else {
+			for _, arg := range node.Arguments {
+				err := c.Compile(arg)
+				if err != nil {
+					return err
+				}
+			}
+			name := c.addConstant(&object.String{Value: node.Method.Value})
+			c.emit(code.OpCallMethod, name, len(node.Arguments))
+		}
+
+		if node.Optional {
+			c.changeOperand(jumpNullPos, len(c.currentInstructions()))
+		}
+	case *ast.FieldExpression:
+		err := c.Compile(node.Object)
+		if err != nil {
+			return err
+		}
+
+		var jumpNullPos int
+		if node.Optional {
+			jumpNullPos = c.emit(code.OpJumpNull, 9999)
+		}
+
+		// Compiled like an index expression with a string index
+		field := c.addConstant(&object.String{Value: node.Field.Value})
+		c.emit(code.OpConstant, field)
+		c.emit(code.OpIndex)
+
+		if node.Optional {
+			c.changeOperand(jumpNullPos, len(c.currentInstructions()))
+		}
 	case *ast.ReturnStatement:
 		err := c.Compile(node.ReturnValue)
 		if err != nil {
@@ -155,6 +301,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpSetLocal, symbol.Index)
 		}
 	case *ast.Identifier:
+		if symbol, ok := c.substitutions[node.Value]; ok {
+			c.loadSymbol(symbol)
+			return nil
+		}
+
 		// Look up in global symbol table
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
@@ -214,7 +365,23 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.ImportExpression:
+		fnIndex, err := c.compileModule(node)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpImport, fnIndex)
 	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) {
+			return c.compileSpreadArray(node.Elements)
+		}
+
+		if value, ok := constantComposite(node); ok {
+			c.emit(code.OpCopyConstant, c.addConstant(value))
+			return nil
+		}
+
 		size := len(node.Elements)
 
 		for _, el := range node.Elements {
@@ -225,6 +392,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		c.emit(code.OpArray, size)
 	case *ast.HashLiteral:
+		if value, ok := constantComposite(node); ok {
+			c.emit(code.OpCopyConstant, c.addConstant(value))
+			return nil
+		}
+
 		size := len(node.Pairs) * 2
 
 		keys := []ast.Expression{}
@@ -261,6 +433,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		var jumpNullPos int
+		if node.Optional {
+			jumpNullPos = c.emit(code.OpJumpNull, 9999)
+		}
+
 		err = c.Compile(node.Index)
 
 		if err != nil {
@@ -269,6 +446,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpIndex)
 
+		if node.Optional {
+			c.changeOperand(jumpNullPos, len(c.currentInstructions()))
+		}
+
 	case *ast.PrefixExpression:
 		err := c.Compile(node.Right)
 
@@ -304,9 +485,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		// This is necessary for the last expression value of the consequence block to not get popped
 		// Necessary for syntax like `let thing = if (true) { 1; 2; } (thing should be 2)
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
+		c.leaveBlockValue()
 
 		jumpPos := c.emit(code.OpJump, 9999)
 		// Update JMPNotTruthy to point to end of consequence instructions
@@ -325,14 +504,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 			// This is necessary for the last expression value of the consequence block to not get popped
 			// Necessary for syntax like `let thing = if (true) { 1; 2; } (thing should be 2)
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
+			c.leaveBlockValue()
 		}
 		endOfAlternativePos := len(c.currentInstructions())
 		c.changeOperand(jumpPos, endOfAlternativePos)
 
 		// If no alternative don't add a non-conditional jump
+	case *ast.MatchExpression:
+		return c.compileMatchExpression(node)
+	case *ast.SpreadExpression:
+		return fmt.Errorf("unexpected spread %s", node)
 	case *ast.BlockStatement:
 		// Compile all statements
 		for _, v := range node.Statements {
@@ -353,8 +534,361 @@ func (c *Compiler) Compile(node ast.Node) error {
 	return nil
 }
 
+// constantComposite builds the value of a non-empty array or hash literal
+// made only of integer and string literals and other such composites.
+// Booleans are left out, as the VM tells them apart by identity and they
+// don't keep it through Bytecode.Write.
+func constantComposite(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: exp.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: exp.Value}, true
+	case *ast.ArrayLiteral:
+		if len(exp.Elements) == 0 {
+			return nil, false
+		}
+
+		elements := []object.Object{}
+		for _, el := range exp.Elements {
+			value, ok := constantComposite(el)
+			if !ok {
+				return nil, false
+			}
+			elements = append(elements, value)
+		}
+		return &object.Array{Elements: elements}, true
+	case *ast.HashLiteral:
+		if len(exp.Pairs) == 0 {
+			return nil, false
+		}
+
+		// In the order the pairs are compiled otherwise, so with duplicate
+		// keys the same one wins
+		keys := []ast.Expression{}
+		for k := range exp.Pairs {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		pairs := map[object.HashKey]object.HashPair{}
+		for _, k := range keys {
+			key, ok := constantComposite(k)
+			if !ok {
+				return nil, false
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, false
+			}
+
+			value, ok := constantComposite(exp.Pairs[k])
+			if !ok {
+				return nil, false
+			}
+			pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, true
+	}
+
+	return nil, false
+}
+
+func symbolNames(symbols []Symbol) []string {
+	names := []string{}
+	for _, symbol := range symbols {
+		names = append(names, symbol.Name)
+	}
+	return names
+}
+
+func parameterNames(params []*ast.Identifier) []string {
+	names := []string{}
+	for _, param := range params {
+		names = append(names, param.Value)
+	}
+	return names
+}
+
+// typeNames returns the names of the types the parameters of fn are
+// annotated with, "" for those that aren't or nil if none are, and of the
+// type of its result.
+func typeNames(fn *ast.FunctionLiteral) ([]string, string) {
+	var params []string
+	for _, t := range fn.ParameterTypes {
+		if t != nil {
+			params = append(params, t.Name)
+		} else {
+			params = append(params, "")
+		}
+	}
+
+	if fn.ReturnType == nil {
+		return params, ""
+	}
+	return params, fn.ReturnType.Name
+}
+
+func hasSpread(exps []ast.Expression) bool {
+	for _, exp := range exps {
+		if _, ok := exp.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSpreadArray builds an array of exps, expanding spread arrays in
+// place. Runs of other elements are gathered into arrays of their own, which
+// are appended like spreads.
+func (c *Compiler) compileSpreadArray(exps []ast.Expression) error {
+	c.emit(code.OpArray, 0)
+
+	for i := 0; i < len(exps); {
+		if spread, ok := exps[i].(*ast.SpreadExpression); ok {
+			if err := c.Compile(spread.Value); err != nil {
+				return err
+			}
+			c.emit(code.OpSpread)
+			i++
+			continue
+		}
+
+		size := 0
+		for ; i < len(exps); i++ {
+			if _, ok := exps[i].(*ast.SpreadExpression); ok {
+				break
+			}
+			if err := c.Compile(exps[i]); err != nil {
+				return err
+			}
+			size++
+		}
+		c.emit(code.OpArray, size)
+		c.emit(code.OpSpread)
+	}
+
+	return nil
+}
+
+// isTailCall reports whether node is a call the function being compiled
+// makes to itself as the last thing it does, and its name isn't shadowed
+// there.
+func (c *Compiler) isTailCall(node *ast.CallExpression) bool {
+	if !c.scopes[c.scopeIndex].tailCalls[node] {
+		return false
+	}
+
+	symbol, ok := c.symbolTable.Resolve(node.Function.(*ast.Identifier).Value)
+	return ok && symbol.Scope == FunctionScope
+}
+
+// compileTailCall compiles a call the function makes to itself in tail
+// position into setting its parameters to the arguments and jumping back to
+// its start, so recursing that way runs in the one frame. Its other locals
+// keep their values from the time before, which only a let reading its own
+// name could tell.
+func (c *Compiler) compileTailCall(node *ast.CallExpression) error {
+	for _, arg := range node.Arguments {
+		if err := c.Compile(arg); err != nil {
+			return err
+		}
+	}
+
+	// The parameters are the first locals, and the last argument is on top
+	for i := len(node.Arguments) - 1; i >= 0; i-- {
+		c.emit(code.OpSetLocal, i)
+	}
+	c.emit(code.OpJump, 0)
+
+	return nil
+}
+
+// tailCalls returns the calls fn makes to itself by name as the last thing
+// it does: the value of its body, of a return statement, or of a branch of
+// an if or match in one of those places. Only calls passing each parameter
+// an argument in order are included, and none if the parameters are
+// annotated with types, so they're checked on every call.
+func tailCalls(fn *ast.FunctionLiteral) map[*ast.CallExpression]bool {
+	calls := map[*ast.CallExpression]bool{}
+	if fn.ParameterTypes != nil {
+		return calls
+	}
+
+	var block func(b *ast.BlockStatement, tail bool)
+	var expression func(exp ast.Expression, tail bool)
+
+	block = func(b *ast.BlockStatement, tail bool) {
+		if b == nil {
+			return
+		}
+
+		// A statement's value is popped before the next one runs, so
+		// returns can jump from any of them
+		for i, s := range b.Statements {
+			switch s := s.(type) {
+			case *ast.ReturnStatement:
+				expression(s.ReturnValue, true)
+			case *ast.ExpressionStatement:
+				expression(s.Expression, tail && i == len(b.Statements)-1)
+			}
+		}
+	}
+
+	expression = func(exp ast.Expression, tail bool) {
+		switch exp := exp.(type) {
+		case *ast.IfExpression:
+			block(exp.Consequence, tail)
+			block(exp.Alternative, tail)
+		case *ast.MatchExpression:
+			// The subject is popped before the body of an arm runs
+			for _, arm := range exp.Arms {
+				expression(arm.Body, tail)
+			}
+		case *ast.CallExpression:
+			name, ok := exp.Function.(*ast.Identifier)
+			if tail && ok && name.Value == fn.Name && len(exp.Arguments) == len(fn.Parameters) && !hasSpread(exp.Arguments) && !hasNamed(exp.Arguments) {
+				calls[exp] = true
+			}
+		}
+	}
+
+	block(fn.Body, true)
+	return calls
+}
+
+func hasNamed(exps []ast.Expression) bool {
+	for _, exp := range exps {
+		if _, ok := exp.(*ast.NamedArgument); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileMatchExpression keeps the subject on the stack while trying each
+// arm, popping it before the body of the one that matches. A pattern that
+// fails to match, or a falsy guard, jumps to the next arm.
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	if err := c.Compile(node.Subject); err != nil {
+		return err
+	}
+
+	jumpsToEnd := []int{}
+	for _, arm := range node.Arms {
+		pattern, err := object.NewPattern(arm.Pattern)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpMatch, c.addConstant(pattern))
+		jumpsToNext := []int{c.emit(code.OpJumpNotTruthy, 9999)}
+
+		// The bound values are on the stack in order, so set the last first
+		names := ast.PatternBindings(arm.Pattern)
+		symbols := make([]Symbol, len(names))
+		for i, name := range names {
+			symbols[i] = c.symbolTable.Define(name.Value)
+		}
+		for i := len(symbols) - 1; i >= 0; i-- {
+			if symbols[i].Scope == GlobalScope {
+				c.emit(code.OpSetGlobal, symbols[i].Index)
+			} else {
+				c.emit(code.OpSetLocal, symbols[i].Index)
+			}
+		}
+
+		if arm.Guard != nil {
+			if err := c.Compile(arm.Guard); err != nil {
+				return err
+			}
+			jumpsToNext = append(jumpsToNext, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		c.emit(code.OpPop)
+		if err := c.Compile(arm.Body); err != nil {
+			return err
+		}
+		jumpsToEnd = append(jumpsToEnd, c.emit(code.OpJump, 9999))
+
+		for _, pos := range jumpsToNext {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+	}
+
+	// Nothing matched
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+
+	for _, pos := range jumpsToEnd {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileModule compiles the file imported by node into a function that
+// runs its top level and returns a hash of its exports, and returns the
+// function's constant index.
+func (c *Compiler) compileModule(node *ast.ImportExpression) (int, error) {
+	filename := c.loader.Resolve(node.Path.Value, node.Pos().Filename)
+	if fnIndex, ok := c.modules[filename]; ok {
+		return fnIndex, nil
+	}
+	if c.importing[filename] {
+		return 0, fmt.Errorf("cannot import %q: import cycle through %s", node.Path.Value, filename)
+	}
+
+	program, err := c.loader.Parse(filename)
+	if err != nil {
+		return 0, fmt.Errorf("cannot import %q: %s", node.Path.Value, err)
+	}
+
+	c.importing[filename] = true
+	defer delete(c.importing, filename)
+
+	// The module's top level is compiled like a main program, with globals
+	// of its own
+	outer := c.symbolTable
+	c.enterScope()
+	c.symbolTable = NewModuleSymbolTable(outer, node.Path.Value)
+	defer func() { c.symbolTable = outer }()
+
+	if err := c.Compile(program); err != nil {
+		return 0, err
+	}
+
+	exports := module.Exports(program)
+	for _, name := range exports {
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: name}))
+		symbol, _ := c.symbolTable.Resolve(name)
+		c.loadSymbol(symbol)
+	}
+	c.emit(code.OpHash, len(exports)*2)
+	c.emit(code.OpReturnValue)
+
+	instructions, sourceMap := c.leaveScope()
+
+	fnIndex := c.addConstant(&object.CompiledFunction{
+		Instructions: instructions,
+		Name:         node.Path.Value,
+		SourceMap:    sourceMap,
+	})
+	c.modules[filename] = fnIndex
+
+	return fnIndex, nil
+}
+
 // append constant and return the index
 func (c *Compiler) addConstant(obj object.Object) int {
+	// String constants are often hash keys, as in h["name"], so they're
+	// hashed once here rather than on every lookup
+	if str, ok := obj.(*object.String); ok {
+		str.CacheHashKey()
+	}
+
 	c.constants = append(c.constants, obj)
 	return len(c.constants) - 1
 }
@@ -367,6 +901,18 @@ func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
 	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
 }
 
+// leaveBlockValue makes a compiled branch of an if expression leave its
+// value on the stack: that of its last expression statement, or null if it
+// ends with something else, like a let statement, or is empty.
+func (c *Compiler) leaveBlockValue() {
+	switch {
+	case c.lastInstructionIs(code.OpPop):
+		c.removeLastPop()
+	case !c.lastInstructionIs(code.OpReturnValue):
+		c.emit(code.OpNull)
+	}
+}
+
 func (c *Compiler) removeLastPop() {
 	last := c.scopes[c.scopeIndex].lastInstruction
 	previous := c.scopes[c.scopeIndex].previousInstruction
@@ -376,6 +922,7 @@ func (c *Compiler) removeLastPop() {
 	new := old[:last.Position]
 
 	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].sourceMap = c.scopes[c.scopeIndex].sourceMap.Truncate(last.Position)
 	// Set last instruction to 2nd to last
 	c.scopes[c.scopeIndex].lastInstruction = previous
 }
@@ -425,6 +972,7 @@ func (c *Compiler) addInstruction(ins []byte) int {
 	updatedInstructions := append(c.currentInstructions(), ins...)
 
 	c.scopes[c.scopeIndex].instructions = updatedInstructions
+	c.scopes[c.scopeIndex].sourceMap = c.scopes[c.scopeIndex].sourceMap.Add(posNewInstruction, c.pos)
 	return posNewInstruction
 }
 
@@ -445,21 +993,34 @@ func (c *Compiler) enterScope() {
 	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
 }
 
-func (c *Compiler) leaveScope() code.Instructions {
-	instructions := c.currentInstructions()
+// leaveScope returns to the enclosing scope, returning the optimized
+// instructions of the one left and their source map.
+func (c *Compiler) leaveScope() (code.Instructions, code.SourceMap) {
+	instructions, sourceMap := c.optimizedInstructions()
 	c.scopes = c.scopes[:len(c.scopes)-1]
 	c.scopeIndex--
 
 	// Remove local bindings scope
 	c.symbolTable = c.symbolTable.Outer
 
-	return instructions
+	return instructions, sourceMap
+}
+
+// optimizedInstructions returns the instructions of the current scope with
+// jumps threaded and superinstructions fused, and their source map.
+func (c *Compiler) optimizedInstructions() (code.Instructions, code.SourceMap) {
+	threadJumps(c.currentInstructions())
+	return fuseInstructions(c.currentInstructions(), c.scopes[c.scopeIndex].sourceMap)
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
+	instructions, sourceMap := c.optimizedInstructions()
+
 	return &Bytecode{
-		Instructions: c.currentInstructions(),
+		Instructions: instructions,
 		Constants:    c.constants,
+		SourceMap:    sourceMap,
+		Globals:      c.symbolTable.DefinedNames(),
 	}
 }
 
@@ -481,4 +1042,9 @@ func (c *Compiler) loadSymbol(s Symbol) {
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	// Where the main program's instructions came from, functions carry
+	// their own
+	SourceMap code.SourceMap
+	// Names of the globals by index, for debugging
+	Globals []string
 }
@@ -7,6 +7,7 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"reflect"
 	"testing"
 )
 
@@ -72,6 +73,25 @@ func testConstants(t *testing.T, expected []any, actual []object.Object) error {
 			if err != nil {
 				return fmt.Errorf("constant %d - testStringObject failed: %w", i, err)
 			}
+		case []string:
+			arr, ok := actual[i].(*object.Array)
+			if !ok || len(arr.Elements) != len(constant) {
+				return fmt.Errorf("constant %d - not an array of %d: %+v", i, len(constant), actual[i])
+			}
+
+			for j, s := range constant {
+				if err := testStringObject(s, arr.Elements[j]); err != nil {
+					return fmt.Errorf("constant %d - element %d: %w", i, j, err)
+				}
+			}
+		case *object.Pattern:
+			if !reflect.DeepEqual(constant, actual[i]) {
+				return fmt.Errorf("constant %d - wrong pattern. want=%+v, got=%+v", i, constant, actual[i])
+			}
+		case object.Object:
+			if !object.Equal(constant, actual[i]) {
+				return fmt.Errorf("constant %d - wrong value. want=%s, got=%s", i, constant.Inspect(), actual[i].Inspect())
+			}
 		case []code.Instructions:
 			fn, ok := actual[i].(*object.CompiledFunction)
 
@@ -90,6 +110,14 @@ func testConstants(t *testing.T, expected []any, actual []object.Object) error {
 	return nil
 }
 
+func array(elements ...int64) *object.Array {
+	arr := &object.Array{}
+	for _, el := range elements {
+		arr.Elements = append(arr.Elements, object.NewInteger(el))
+	}
+	return arr
+}
+
 func testIntegerObject(expected int64, actual object.Object) error {
 	result, ok := actual.(*object.Integer)
 
@@ -111,8 +139,7 @@ func TestIntegerArithmetic(t *testing.T) {
 			expectedConstants: []any{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpAdd),
+				code.Make(code.OpConstantAdd, 1),
 				// Pop value off stack since it's just an expression statement.
 				code.Make(code.OpPop),
 			},
@@ -132,8 +159,7 @@ func TestIntegerArithmetic(t *testing.T) {
 			expectedConstants: []any{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpSub),
+				code.Make(code.OpConstantSub, 1),
 				// Pop value off stack since it's just an expression statement.
 				code.Make(code.OpPop),
 			},
@@ -332,6 +358,93 @@ func TestConditionals(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			// The inner if's jump out goes straight past the outer one's
+			input: `
+			if (true) { if (false) { 10 } else { 20 } } else { 30 };
+			`,
+			expectedConstants: []any{10, 20, 30},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 20),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0008
+				code.Make(code.OpConstant, 0),
+				// 0011
+				code.Make(code.OpJump, 23),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpJump, 23),
+				// 0020
+				code.Make(code.OpConstant, 2),
+				// 0023
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSuperinstructions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn(a, b) { if (a == b) { a + b } else { b - 1 } }`,
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetLocal, 0),
+					// 0002
+					code.Make(code.OpGetLocal, 1),
+					// 0004
+					code.Make(code.OpCompareJumpNotTruthy, int(code.OpEqual), 14),
+					// 0008
+					code.Make(code.OpGetLocalGetLocalAdd, 0, 1),
+					// 0011
+					code.Make(code.OpJump, 19),
+					// 0014
+					code.Make(code.OpGetLocal, 1),
+					// 0016
+					code.Make(code.OpConstantSub, 0),
+					// 0019
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// The if jumps to the OpAdd, so it can't join the else's constant
+			input:             `1 + if (true) { 2 } else { 3 }`,
+			expectedConstants: []any{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpTrue),
+				// 0004
+				code.Make(code.OpJumpNotTruthy, 13),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpJump, 16),
+				// 0013
+				code.Make(code.OpConstant, 2),
+				// 0016
+				code.Make(code.OpAdd),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -401,8 +514,7 @@ func TestStringExpressions(t *testing.T) {
 			expectedConstants: []any{"mon", "key"},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpAdd),
+				code.Make(code.OpConstantAdd, 1),
 				code.Make(code.OpPop),
 			},
 		},
@@ -423,11 +535,31 @@ func TestArrayExpressions(t *testing.T) {
 		},
 		{
 			input:             `[1, 2, 3]`,
-			expectedConstants: []any{1, 2, 3},
+			expectedConstants: []any{array(1, 2, 3)},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpCopyConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `[1, ["two", []]]`,
+			expectedConstants: []any{1, "two"},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `[[1], {"a": [2]}, true]`,
+			expectedConstants: []any{array(1), &object.Hash{Pairs: map[object.HashKey]object.HashPair{(&object.String{Value: "a"}).HashKey(): {Key: &object.String{Value: "a"}, Value: array(2)}}}},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpCopyConstant, 0),
+				code.Make(code.OpCopyConstant, 1),
+				code.Make(code.OpTrue),
 				code.Make(code.OpArray, 3),
 				code.Make(code.OpPop),
 			},
@@ -437,11 +569,9 @@ func TestArrayExpressions(t *testing.T) {
 			expectedConstants: []any{1, 2, 3, 4, 5, 6},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpAdd),
+				code.Make(code.OpConstantAdd, 1),
 				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpSub),
+				code.Make(code.OpConstantSub, 3),
 				code.Make(code.OpConstant, 4),
 				code.Make(code.OpConstant, 5),
 				code.Make(code.OpMul),
@@ -465,16 +595,14 @@ func TestHashExpressions(t *testing.T) {
 			},
 		},
 		{
-			input:             "{1: 2, 3: 4, 5: 6}",
-			expectedConstants: []any{1, 2, 3, 4, 5, 6},
+			input: "{1: 2, 3: 4, 5: 6}",
+			expectedConstants: []any{&object.Hash{Pairs: map[object.HashKey]object.HashPair{
+				object.NewInteger(1).HashKey(): {Key: object.NewInteger(1), Value: object.NewInteger(2)},
+				object.NewInteger(3).HashKey(): {Key: object.NewInteger(3), Value: object.NewInteger(4)},
+				object.NewInteger(5).HashKey(): {Key: object.NewInteger(5), Value: object.NewInteger(6)},
+			}}},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
-				code.Make(code.OpHash, 6),
+				code.Make(code.OpCopyConstant, 0),
 				code.Make(code.OpPop),
 			},
 		},
@@ -484,8 +612,7 @@ func TestHashExpressions(t *testing.T) {
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpAdd),
+				code.Make(code.OpConstantAdd, 2),
 				code.Make(code.OpConstant, 3),
 				code.Make(code.OpConstant, 4),
 				code.Make(code.OpConstant, 5),
@@ -503,29 +630,27 @@ func TestIndexExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input:             "[1,2,3][1 + 1]",
-			expectedConstants: []any{1, 2, 3, 1, 1},
+			expectedConstants: []any{array(1, 2, 3), 1, 1},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCopyConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpArray, 3),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpAdd),
+				code.Make(code.OpConstantAdd, 2),
 				code.Make(code.OpIndex),
 				code.Make(code.OpPop),
 			},
 		},
 		{
-			input:             "{1: 2}[2 - 1]",
-			expectedConstants: []any{1, 2, 2, 1},
+			input: "{1: 2}[2 - 1]",
+			expectedConstants: []any{
+				&object.Hash{Pairs: map[object.HashKey]object.HashPair{
+					object.NewInteger(1).HashKey(): {Key: object.NewInteger(1), Value: object.NewInteger(2)},
+				}},
+				2, 1,
+			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCopyConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpHash, 2),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpSub),
+				code.Make(code.OpConstantSub, 2),
 				code.Make(code.OpIndex),
 				code.Make(code.OpPop),
 			},
@@ -543,8 +668,7 @@ func TestFunctions(t *testing.T) {
 				5, 10,
 				[]code.Instructions{
 					code.Make(code.OpConstant, 0),
-					code.Make(code.OpConstant, 1),
-					code.Make(code.OpAdd),
+					code.Make(code.OpConstantAdd, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -559,8 +683,7 @@ func TestFunctions(t *testing.T) {
 				5, 10,
 				[]code.Instructions{
 					code.Make(code.OpConstant, 0),
-					code.Make(code.OpConstant, 1),
-					code.Make(code.OpAdd),
+					code.Make(code.OpConstantAdd, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -890,9 +1013,7 @@ func TestLetStatementScopes(t *testing.T) {
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpConstant, 1),
 					code.Make(code.OpSetLocal, 1),
-					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpGetLocal, 1),
-					code.Make(code.OpAdd),
+					code.Make(code.OpGetLocalGetLocalAdd, 0, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -989,6 +1110,159 @@ func TestBuiltins(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestMethodCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `[].push(1);`,
+			expectedConstants: []any{1, "push"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCallMethod, 1, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `{}.name;`,
+			expectedConstants: []any{"name"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `{}?.name?.len();`,
+			expectedConstants: []any{"name", "len"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpHash, 0),
+				// 0003
+				code.Make(code.OpJumpNull, 10),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpIndex),
+				// 0010
+				code.Make(code.OpJumpNull, 17),
+				// 0013
+				code.Make(code.OpCallMethod, 1, 0),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `match (1) { x if x => x };`,
+			expectedConstants: []any{
+				1,
+				&object.Pattern{Kind: object.BindPattern, Name: "x"},
+			},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpMatch, 1),
+				// 0006
+				code.Make(code.OpJumpNotTruthy, 25),
+				// 0009
+				code.Make(code.OpSetGlobal, 0),
+				// 0012
+				code.Make(code.OpGetGlobal, 0),
+				// 0015
+				code.Make(code.OpJumpNotTruthy, 25),
+				// 0018
+				code.Make(code.OpPop),
+				// 0019
+				code.Make(code.OpGetGlobal, 0),
+				// 0022
+				code.Make(code.OpJump, 27),
+				// 0025
+				code.Make(code.OpPop),
+				// 0026
+				code.Make(code.OpNull),
+				// 0027
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `len(1, b: 2, a: 3);`,
+			expectedConstants: []any{1, 2, 3, []string{"b", "a"}},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCallNamed, 3, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSpread(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `[1, ...[2], 3];`,
+			expectedConstants: []any{1, array(2), 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread),
+				code.Make(code.OpCopyConstant, 1),
+				code.Make(code.OpSpread),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `len(...[]);`,
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSpread),
+				code.Make(code.OpCallSpread),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `[].push(...[]);`,
+			expectedConstants: []any{"push"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSpread),
+				code.Make(code.OpCallMethodSpread, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestClosures(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -1123,11 +1397,10 @@ func TestRecursiveFunctions(t *testing.T) {
 			expectedConstants: []any{
 				1,
 				[]code.Instructions{
-					code.Make(code.OpCurrentClosure),
 					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpConstant, 0),
-					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpConstantSub, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpJump, 0),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -1152,11 +1425,10 @@ func TestRecursiveFunctions(t *testing.T) {
 			expectedConstants: []any{
 				1,
 				[]code.Instructions{
-					code.Make(code.OpCurrentClosure),
 					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpConstant, 0),
-					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpConstantSub, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpJump, 0),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -1181,3 +1453,215 @@ func TestRecursiveFunctions(t *testing.T) {
 
 	runCompilerTests(t, tests)
 }
+
+func TestTailCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `let f = fn(a, b) { f(b, a) }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpJump, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input: `let f = fn(n) { if (n) { f(n) } else { n } }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpJumpNotTruthy, 15),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpJump, 0),
+					code.Make(code.OpJump, 17),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			// Not the last thing it does
+			input: `let f = fn(a) { 1 + f(a) }`,
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			// Called with the wrong number of arguments, which fails
+			input: `let f = fn(a) { f(a, a) }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 2),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			// The name is shadowed by a parameter
+			input: `let f = fn(f) { f(1) }`,
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestInlining(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `let sq = fn(x) { x * x }; sq(3)`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpMul),
+					code.Make(code.OpReturnValue),
+				},
+				3,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `let sq = fn(x) { x * x }; let f = fn(a) { sq(a) }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpMul),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpMul),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+		{
+			// Recursive
+			input: `let f = fn(x) { f(x) }; f(1)`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpJump, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// A name the body uses is shadowed where it's called
+			input: `let y = 1; let f = fn(x) { x + y }; let g = fn(y) { f(y) }`,
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 2),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		compiler.SetInlining(true)
+		if err := compiler.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+		if err := testInstructions(tt.expectedInstructions, bytecode.Instructions); err != nil {
+			t.Fatalf("%s: testInstructions failed: %s", tt.input, err)
+		}
+		if err := testConstants(t, tt.expectedConstants, bytecode.Constants); err != nil {
+			t.Fatalf("%s: testConstants failed: %s", tt.input, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+)
+
+// Functions with more nodes than this in their body aren't inlined
+const inlineThreshold = 40
+
+// inlinable is a function bound by a let at the top level of a program that
+// calls to it can be replaced by its body.
+type inlinable struct {
+	fn *ast.FunctionLiteral
+	// What the names the body refers to, other than its parameters,
+	// resolved to where it was defined
+	names map[string]Symbol
+}
+
+// SetInlining makes the compiler replace calls to small functions bound by a
+// let at the top level of a program with their bodies, saving the call. Such
+// calls no longer show in stack traces, nor count towards the recursion
+// depth. Off by default.
+func (c *Compiler) SetInlining(enabled bool) {
+	c.inlining = enabled
+}
+
+// defineInlinable records the function bound by let, just compiled at the
+// top level of a program, as one calls to can be inlined, if it's small and
+// simple enough.
+//
+// Each global is only ever set by its one let, so the calls compiled later
+// are sure to find the function there. Only bodies of expression statements
+// using nothing but the parameters, globals and builtins are inlined, so they
+// bind no names that would leak into the caller, can't return from it, and
+// mean the same wherever they're compiled.
+func (c *Compiler) defineInlinable(let *ast.LetStatement) {
+	fn, ok := let.Value.(*ast.FunctionLiteral)
+	if !c.inlining || !ok || fn.Locals == nil || len(fn.Locals) != len(fn.Parameters) {
+		return
+	}
+	if fn.ParameterTypes != nil || fn.ReturnType != nil || len(fn.Body.Statements) == 0 {
+		return
+	}
+
+	symbol, ok := c.symbolTable.Lookup(let.Name.Value)
+	if !ok || symbol.Scope != GlobalScope {
+		return
+	}
+
+	for _, s := range fn.Body.Statements {
+		if _, ok := s.(*ast.ExpressionStatement); !ok {
+			return
+		}
+	}
+
+	names := map[string]Symbol{}
+	size := 0
+	simple := true
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		size++
+
+		switch node := node.(type) {
+		case *ast.FunctionLiteral, *ast.ReturnStatement:
+			simple = false
+		case *ast.Identifier:
+			// Parameters are local, and names of methods, fields and named
+			// arguments are left unresolved
+			if node.Binding.Scope != ast.Global {
+				break
+			}
+			if node.Value == fn.Name {
+				simple = false
+				break
+			}
+
+			s, ok := c.symbolTable.Lookup(node.Value)
+			if !ok || (s.Scope != GlobalScope && s.Scope != BuiltinScope) {
+				simple = false
+			}
+			names[node.Value] = s
+		}
+
+		return simple
+	})
+
+	if simple && size <= inlineThreshold {
+		c.inlinables[symbol.Index] = &inlinable{fn: fn, names: names}
+	}
+}
+
+// inlinableCall returns the function node calls if the call can be replaced
+// by its body where it's being compiled, or nil.
+func (c *Compiler) inlinableCall(node *ast.CallExpression) *inlinable {
+	name, ok := node.Function.(*ast.Identifier)
+	if !c.inlining || !ok {
+		return nil
+	}
+
+	symbol, ok := c.symbolTable.Lookup(name.Value)
+	if !ok || symbol.Scope != GlobalScope {
+		return nil
+	}
+
+	callee := c.inlinables[symbol.Index]
+	if callee == nil || len(node.Arguments) != len(callee.fn.Parameters) {
+		return nil
+	}
+	if hasSpread(node.Arguments) || hasNamed(node.Arguments) {
+		return nil
+	}
+
+	// The names the body uses must not be shadowed here
+	for name, want := range callee.names {
+		if got, ok := c.symbolTable.Lookup(name); !ok || got != want {
+			return nil
+		}
+	}
+
+	return callee
+}
+
+// compileInlineCall compiles a call to callee into setting slots standing
+// for its parameters to the arguments and running its body, which leaves its
+// value on the stack like the call would.
+func (c *Compiler) compileInlineCall(node *ast.CallExpression, callee *inlinable) error {
+	for _, arg := range node.Arguments {
+		if err := c.Compile(arg); err != nil {
+			return err
+		}
+	}
+
+	slots := c.inlineSlots(callee.fn)
+	for i := len(slots) - 1; i >= 0; i-- {
+		if slots[i].Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, slots[i].Index)
+		} else {
+			c.emit(code.OpSetLocal, slots[i].Index)
+		}
+	}
+
+	outer := c.substitutions
+	c.substitutions = map[string]Symbol{}
+	for i, param := range callee.fn.Parameters {
+		c.substitutions[param.Value] = slots[i]
+	}
+	defer func() { c.substitutions = outer }()
+
+	if err := c.Compile(callee.fn.Body); err != nil {
+		return err
+	}
+	c.leaveBlockValue()
+
+	return nil
+}
+
+// inlineSlots returns the slots the parameters of fn are kept in when it's
+// inlined in the current scope. They're shared by every call to fn there:
+// its arguments are all worked out before any is set, and fn, being
+// inlined, can't be in the middle of running when they are.
+func (c *Compiler) inlineSlots(fn *ast.FunctionLiteral) []Symbol {
+	scope := &c.scopes[c.scopeIndex]
+	if slots, ok := scope.inlineSlots[fn]; ok {
+		return slots
+	}
+
+	slots := make([]Symbol, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		slots[i] = c.symbolTable.defineHidden(fmt.Sprintf("%s.%s", fn.Name, param.Value))
+	}
+
+	if scope.inlineSlots == nil {
+		scope.inlineSlots = map[*ast.FunctionLiteral][]Symbol{}
+	}
+	scope.inlineSlots[fn] = slots
+
+	return slots
+}
@@ -0,0 +1,155 @@
+package compiler
+
+import "monkey/code"
+
+// threadJumps points jumps that land on an OpJump at where that one goes,
+// following chains of them, so the VM doesn't jump more than once on its
+// way out of nested ifs. ins is changed in place.
+func threadJumps(ins code.Instructions) {
+	for pos := 0; pos < len(ins); {
+		op := code.Opcode(ins[pos])
+		def, err := code.Lookup(byte(op))
+		if err != nil {
+			return
+		}
+		operands, read := code.ReadOperands(def, ins[pos+1:])
+
+		if isJump(op) {
+			if target := jumpTarget(ins, operands[0]); target != operands[0] {
+				copy(ins[pos:], code.Make(op, target))
+			}
+		}
+
+		pos += 1 + read
+	}
+}
+
+// jumpTarget returns where the VM ends up after jumping to pos, once it's
+// through any OpJumps there.
+func jumpTarget(ins code.Instructions, pos int) int {
+	// Jumps back to the start of a function for tail calls can make a
+	// chain loop, so it's given up on after len(ins) steps
+	for range len(ins) {
+		if pos >= len(ins) || code.Opcode(ins[pos]) != code.OpJump {
+			break
+		}
+		pos = int(code.ReadUint16(ins[pos+1:]))
+	}
+
+	return pos
+}
+
+// instruction is a decoded instruction, at pos in the unfused instructions.
+type instruction struct {
+	pos      int
+	op       code.Opcode
+	operands []int
+	// Where the source position of the instruction is looked up, the pos
+	// of the instruction that can fail when several are fused
+	sourcePos int
+}
+
+// fuseInstructions replaces sequences of instructions that are common in
+// arithmetic and conditions with the superinstruction that does the same,
+// returning the new instructions and their source map. Sequences that are
+// jumped into part way are left alone, and jumps are moved to where their
+// targets end up.
+func fuseInstructions(ins code.Instructions, sourceMap code.SourceMap) (code.Instructions, code.SourceMap) {
+	decoded := []instruction{}
+	targets := map[int]bool{}
+
+	for pos := 0; pos < len(ins); {
+		op := code.Opcode(ins[pos])
+		def, err := code.Lookup(byte(op))
+		if err != nil {
+			return ins, sourceMap
+		}
+		operands, read := code.ReadOperands(def, ins[pos+1:])
+
+		if isJump(op) {
+			targets[operands[0]] = true
+		}
+
+		decoded = append(decoded, instruction{pos: pos, op: op, operands: operands, sourcePos: pos})
+		pos += 1 + read
+	}
+
+	fused := []instruction{}
+	for i := 0; i < len(decoded); {
+		in, n := fuse(decoded[i:], targets)
+		fused = append(fused, in)
+		i += n
+	}
+
+	// Where each instruction, and the end of them, moved to. The inner
+	// instructions of a fused sequence aren't jump targets, so only the
+	// first needs to be kept.
+	offsets := map[int]int{}
+	size := 0
+	for _, in := range fused {
+		offsets[in.pos] = size
+		def, _ := code.Lookup(byte(in.op))
+		size += 1
+		for _, w := range def.OperandWidths {
+			size += w
+		}
+	}
+	offsets[len(ins)] = size
+
+	out := make(code.Instructions, 0, size)
+	var outMap code.SourceMap
+	for _, in := range fused {
+		switch {
+		case isJump(in.op):
+			in.operands[0] = offsets[in.operands[0]]
+		case in.op == code.OpCompareJumpNotTruthy:
+			in.operands[1] = offsets[in.operands[1]]
+		}
+
+		outMap = outMap.Add(len(out), sourceMap.Lookup(in.sourcePos))
+		out = append(out, code.Make(in.op, in.operands...)...)
+	}
+
+	return out, outMap
+}
+
+// fuse returns the superinstruction the sequence starting ins makes and
+// how many instructions it stands for, or the first instruction and 1 if
+// none does.
+func fuse(ins []instruction, targets map[int]bool) (instruction, int) {
+	// Only the first of a fused sequence may be jumped to
+	fits := func(ops ...code.Opcode) bool {
+		if len(ins) < len(ops) {
+			return false
+		}
+		for i, op := range ops {
+			if ins[i].op != op || (i > 0 && targets[ins[i].pos]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	first := ins[0]
+
+	switch {
+	case fits(code.OpGetLocal, code.OpGetLocal, code.OpAdd):
+		operands := []int{first.operands[0], ins[1].operands[0]}
+		return instruction{first.pos, code.OpGetLocalGetLocalAdd, operands, ins[2].sourcePos}, 3
+	case fits(code.OpConstant, code.OpAdd):
+		return instruction{first.pos, code.OpConstantAdd, first.operands, ins[1].sourcePos}, 2
+	case fits(code.OpConstant, code.OpSub):
+		return instruction{first.pos, code.OpConstantSub, first.operands, ins[1].sourcePos}, 2
+	case fits(code.OpEqual, code.OpJumpNotTruthy),
+		fits(code.OpNotEqual, code.OpJumpNotTruthy),
+		fits(code.OpGreaterThan, code.OpJumpNotTruthy):
+		operands := []int{int(first.op), ins[1].operands[0]}
+		return instruction{first.pos, code.OpCompareJumpNotTruthy, operands, first.sourcePos}, 2
+	}
+
+	return first, 1
+}
+
+func isJump(op code.Opcode) bool {
+	return op == code.OpJump || op == code.OpJumpNotTruthy || op == code.OpJumpNull
+}
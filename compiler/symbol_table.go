@@ -1,5 +1,7 @@
 package compiler
 
+import "sort"
+
 type SymbolScope string
 
 const (
@@ -21,6 +23,16 @@ type SymbolTable struct {
 	FreeSymbols    []Symbol
 	store          map[string]Symbol
 	numDefinitions int
+	// Names of the globals or locals defined, by index
+	names []string
+	// Globals and builtins resolved through the enclosing tables, so each
+	// is only looked up through them once
+	resolved map[string]Symbol
+
+	// For the top level of an imported module, the main program's table,
+	// which its globals are allocated from, and the path it was imported as
+	main   *SymbolTable
+	module string
 }
 
 func NewSymbolTable() *SymbolTable {
@@ -29,6 +41,7 @@ func NewSymbolTable() *SymbolTable {
 	return &SymbolTable{
 		store:       s,
 		FreeSymbols: free,
+		resolved:    map[string]Symbol{},
 	}
 }
 
@@ -38,7 +51,38 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
+// NewModuleSymbolTable returns a table for the top level of the module
+// imported as path while compiling with table s. It sees only the builtins,
+// and its globals get indexes after those of the main program, which they're
+// listed among as path.name.
+func NewModuleSymbolTable(s *SymbolTable, path string) *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	if s.main != nil {
+		s = s.main
+	}
+
+	module := NewSymbolTable()
+	module.main = s
+	module.module = path
+	for name, symbol := range s.store {
+		if symbol.Scope == BuiltinScope {
+			module.store[name] = symbol
+		}
+	}
+
+	return module
+}
+
 func (s *SymbolTable) Define(name string) Symbol {
+	if s.main != nil {
+		symbol := s.main.Define(s.module + "." + name)
+		symbol.Name = name
+		s.store[name] = symbol
+		return symbol
+	}
+
 	var scope SymbolScope
 	if s.Outer == nil {
 		scope = GlobalScope
@@ -50,6 +94,27 @@ func (s *SymbolTable) Define(name string) Symbol {
 
 	s.store[name] = symbol
 	s.numDefinitions++
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// defineHidden defines a global or local like Define, but one that can't be
+// resolved by name. It's listed as name.
+func (s *SymbolTable) defineHidden(name string) Symbol {
+	if s.main != nil {
+		symbol := s.main.defineHidden(s.module + "." + name)
+		symbol.Name = name
+		return symbol
+	}
+
+	scope := GlobalScope
+	if s.Outer != nil {
+		scope = LocalScope
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: scope}
+	s.numDefinitions++
+	s.names = append(s.names, name)
 	return symbol
 }
 
@@ -69,6 +134,10 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
 
 	if !ok && s.Outer != nil {
+		if obj, ok := s.resolved[name]; ok {
+			return obj, true
+		}
+
 		obj, ok = s.Outer.Resolve(name)
 
 		if !ok {
@@ -76,6 +145,7 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 		}
 
 		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+			s.resolved[name] = obj
 			return obj, ok
 		}
 
@@ -87,6 +157,46 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	return obj, ok
 }
 
+// Lookup finds the symbol name refers to in s like Resolve, but leaves s as
+// it is. Names from enclosing functions are returned with the scope they have
+// there, rather than being captured as free variables.
+func (s *SymbolTable) Lookup(name string) (Symbol, bool) {
+	for table := s; table != nil; table = table.Outer {
+		if symbol, ok := table.store[name]; ok {
+			return symbol, true
+		}
+	}
+
+	return Symbol{}, false
+}
+
+// Symbols returns the symbols defined in s itself, sorted by name. Those of
+// enclosing tables are left out.
+func (s *SymbolTable) Symbols() []Symbol {
+	symbols := make([]Symbol, 0, len(s.store))
+	for _, symbol := range s.store {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Name < symbols[j].Name
+	})
+
+	return symbols
+}
+
+// DefinedNames returns the names of the globals or locals defined in s, by
+// index. A name defined twice appears at both indexes.
+func (s *SymbolTable) DefinedNames() []string {
+	names := make([]string, len(s.names))
+	copy(names, s.names)
+	return names
+}
+
+// NumDefinitions returns how many globals or locals have been defined in s.
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
+
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	// add original to FreeSymbols
 	// Create new one as a FreeSymbol scope in main symbols
@@ -1,6 +1,10 @@
 package compiler
 
-import "testing"
+import (
+	"monkey/object"
+	"reflect"
+	"testing"
+)
 
 func TestDefine(t *testing.T) {
 	expected := map[string]Symbol{
@@ -188,6 +192,34 @@ func TestDefineResolveBuiltins(t *testing.T) {
 	}
 }
 
+func TestResolveCached(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(NewEnclosedSymbolTable(global))
+
+	for _, name := range []string{"len", "a", "len", "a"} {
+		if _, ok := local.Resolve(name); !ok {
+			t.Fatalf("name %s not resolvable", name)
+		}
+	}
+
+	expected := map[string]Symbol{
+		"len": {Name: "len", Scope: BuiltinScope, Index: 0},
+		"a":   {Name: "a", Scope: GlobalScope, Index: 0},
+	}
+	if !reflect.DeepEqual(local.resolved, expected) {
+		t.Errorf("wrong resolutions cached. want=%+v, got=%+v", expected, local.resolved)
+	}
+
+	// A local defined later shadows the cached global
+	local.Define("a")
+	if symbol, _ := local.Resolve("a"); symbol.Scope != LocalScope {
+		t.Errorf("a resolved to %+v, want the local", symbol)
+	}
+}
+
 func TestResolveFree(t *testing.T) {
 	global := NewSymbolTable()
 	global.Define("a")
@@ -338,3 +370,117 @@ func TestFunctionShadowing(t *testing.T) {
 		t.Errorf("expected %s to resolve to %+v, got %+v", expected.Name, expected, result)
 	}
 }
+
+func TestLookup(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+
+	nested := NewEnclosedSymbolTable(local)
+	nested.Define("c")
+
+	expected := map[string]Symbol{
+		"a":   {Name: "a", Scope: GlobalScope, Index: 0},
+		"len": {Name: "len", Scope: BuiltinScope, Index: 0},
+		"b":   {Name: "b", Scope: LocalScope, Index: 0},
+		"c":   {Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for name, sym := range expected {
+		result, ok := nested.Lookup(name)
+		if !ok {
+			t.Errorf("name %s not resolvable", name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", name, sym, result)
+		}
+	}
+
+	if _, ok := nested.Lookup("d"); ok {
+		t.Errorf("name d resolved, but was never defined")
+	}
+
+	// Unlike Resolve, b isn't captured as a free variable
+	if len(nested.FreeSymbols) != 0 || len(nested.Symbols()) != 1 {
+		t.Errorf("Lookup changed the table: %+v", nested.Symbols())
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("b")
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 1},
+		{Name: "b", Scope: GlobalScope, Index: 0},
+		{Name: "len", Scope: BuiltinScope, Index: 0},
+	}
+
+	symbols := global.Symbols()
+	if len(symbols) != len(expected) {
+		t.Fatalf("wrong number of symbols. want=%d, got=%d", len(expected), len(symbols))
+	}
+	for i, sym := range expected {
+		if symbols[i] != sym {
+			t.Errorf("symbol %d wrong. want=%+v, got=%+v", i, sym, symbols[i])
+		}
+	}
+
+	if global.NumDefinitions() != 2 {
+		t.Errorf("wrong number of definitions. want=2, got=%d", global.NumDefinitions())
+	}
+}
+
+func TestCompilerSymbolTable(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse(`let a = 1; let f = fn(x) { let y = x; a + y };`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	f, ok := compiler.SymbolTable().Lookup("f")
+	if !ok || f != (Symbol{Name: "f", Scope: GlobalScope, Index: 1}) {
+		t.Errorf("wrong symbol for f: %+v", f)
+	}
+
+	// Locals of functions are gone once they're compiled
+	if _, ok := compiler.SymbolTable().Lookup("y"); ok {
+		t.Errorf("local y resolved globally")
+	}
+}
+
+func TestDebugNames(t *testing.T) {
+	compiler := New()
+	input := `let a = 1; let f = fn(x) { let y = x; fn() { a + x + y } }; let a = 2;`
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	if expected := []string{"a", "f", "a"}; !reflect.DeepEqual(bytecode.Globals, expected) {
+		t.Errorf("wrong global names. want=%v, got=%v", expected, bytecode.Globals)
+	}
+
+	functions := []*object.CompiledFunction{}
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			functions = append(functions, fn)
+		}
+	}
+	if len(functions) != 2 {
+		t.Fatalf("expected 2 functions, got=%d", len(functions))
+	}
+
+	inner, outer := functions[0], functions[1]
+	if expected := []string{"x", "y"}; !reflect.DeepEqual(outer.LocalNames, expected) {
+		t.Errorf("wrong local names. want=%v, got=%v", expected, outer.LocalNames)
+	}
+	if expected := []string{"x", "y"}; !reflect.DeepEqual(inner.FreeNames, expected) {
+		t.Errorf("wrong free names. want=%v, got=%v", expected, inner.FreeNames)
+	}
+}
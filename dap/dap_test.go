@@ -0,0 +1,287 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// client drives a session the way an editor would.
+type client struct {
+	t   *testing.T
+	w   io.Writer
+	r   *bufio.Reader
+	seq int
+	// Output events received so far
+	output string
+}
+
+type message struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	Event      string          `json:"event"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	RequestSeq int             `json:"request_seq"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func startSession(t *testing.T) *client {
+	t.Helper()
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	done := make(chan error)
+	go func() {
+		done <- Serve(serverReader, serverWriter)
+		serverWriter.Close()
+	}()
+
+	t.Cleanup(func() {
+		clientWriter.Close()
+		go io.Copy(io.Discard, clientReader)
+		if err := <-done; err != nil {
+			t.Errorf("session failed: %s", err)
+		}
+	})
+
+	return &client{t: t, w: clientWriter, r: bufio.NewReader(clientReader)}
+}
+
+func (c *client) send(command string, args any) {
+	c.t.Helper()
+
+	c.seq++
+	msg := map[string]any{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		msg["arguments"] = args
+	}
+	if err := writeMessage(c.w, msg); err != nil {
+		c.t.Fatalf("failed to send %s: %s", command, err)
+	}
+}
+
+func (c *client) read() *message {
+	c.t.Helper()
+
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatalf("failed to read header: %s", err)
+	}
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		c.t.Fatalf("failed to read body: %s", err)
+	}
+
+	msg := &message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		c.t.Fatalf("invalid message %s: %s", body, err)
+	}
+
+	return msg
+}
+
+// expect reads messages up to the response or event called name, which it
+// decodes the body of into body, collecting output along the way.
+func (c *client) expect(name string, body any) *message {
+	c.t.Helper()
+
+	for {
+		msg := c.read()
+		if msg.Type == "event" && msg.Event == "output" {
+			var output struct{ Output string }
+			json.Unmarshal(msg.Body, &output)
+			c.output += output.Output
+		}
+
+		if msg.Command == name || msg.Event == name {
+			if body != nil {
+				if err := json.Unmarshal(msg.Body, body); err != nil {
+					c.t.Fatalf("invalid body of %s: %s", name, err)
+				}
+			}
+			return msg
+		}
+	}
+}
+
+// request sends command and returns its response, failing the test unless
+// it succeeded.
+func (c *client) request(command string, args any, body any) {
+	c.t.Helper()
+
+	c.send(command, args)
+	if msg := c.expect(command, body); !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+}
+
+// expectStop waits for the program to stop and returns why and its stack
+// frames.
+func (c *client) expectStop() (string, []stackFrame) {
+	c.t.Helper()
+
+	var stopped struct{ Reason string }
+	c.expect("stopped", &stopped)
+
+	var trace struct{ StackFrames []stackFrame }
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+
+	return stopped.Reason, trace.StackFrames
+}
+
+// variables returns the variables of the frame's first scope as name=value.
+func (c *client) variables(frameID int) map[string]string {
+	c.t.Helper()
+
+	var scopes struct{ Scopes []scope }
+	c.request("scopes", map[string]int{"frameId": frameID}, &scopes)
+
+	var vars struct{ Variables []variable }
+	c.request("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference}, &vars)
+
+	values := map[string]string{}
+	for _, v := range vars.Variables {
+		values[v.Name] = v.Value
+	}
+
+	return values
+}
+
+// launch writes program to a file and launches it, returning the file's
+// name.
+func (c *client) launch(program string, args map[string]any) string {
+	c.t.Helper()
+
+	filename := filepath.Join(c.t.TempDir(), "program.monkey")
+	if err := os.WriteFile(filename, []byte(program), 0644); err != nil {
+		c.t.Fatal(err)
+	}
+
+	c.request("initialize", map[string]string{"adapterID": "monkey"}, nil)
+	c.expect("initialized", nil)
+
+	if args == nil {
+		args = map[string]any{}
+	}
+	args["program"] = filename
+	c.request("launch", args, nil)
+
+	return filename
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	program := `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+puts(x);`
+
+	c := startSession(t)
+	filename := c.launch(program, nil)
+
+	var breakpoints struct{ Breakpoints []breakpoint }
+	c.request("setBreakpoints", map[string]any{
+		"source":      map[string]string{"path": filename},
+		"breakpoints": []map[string]int{{"line": 2}, {"line": 4}},
+	}, &breakpoints)
+
+	expected := []breakpoint{{Verified: true, Line: 2}, {Verified: false, Line: 4, Message: "no code on this line"}}
+	if fmt.Sprint(breakpoints.Breakpoints) != fmt.Sprint(expected) {
+		t.Fatalf("wrong breakpoints. want=%v, got=%v", expected, breakpoints.Breakpoints)
+	}
+
+	c.request("configurationDone", nil, nil)
+
+	reason, frames := c.expectStop()
+	if reason != "breakpoint" || len(frames) != 2 {
+		t.Fatalf("expected a stop at a breakpoint two frames deep, got=%s %v", reason, frames)
+	}
+	if frames[0].Name != "add" || frames[0].Line != 2 || frames[1].Name != "main" || frames[1].Line != 5 {
+		t.Errorf("wrong stack trace: %v", frames)
+	}
+	if vars := c.variables(frames[0].ID); fmt.Sprint(vars) != "map[a:1 b:2]" {
+		t.Errorf("wrong locals: %v", vars)
+	}
+
+	c.request("next", map[string]int{"threadId": threadID}, nil)
+	reason, frames = c.expectStop()
+	if reason != "step" || frames[0].Line != 3 {
+		t.Fatalf("expected a step to line 3, got=%s %v", reason, frames)
+	}
+	if vars := c.variables(frames[0].ID); fmt.Sprint(vars) != "map[a:1 b:2 sum:3]" {
+		t.Errorf("wrong locals: %v", vars)
+	}
+
+	c.request("stepOut", map[string]int{"threadId": threadID}, nil)
+	reason, frames = c.expectStop()
+	if reason != "step" || len(frames) != 1 || frames[0].Line != 5 {
+		t.Fatalf("expected a step out to line 5, got=%s %v", reason, frames)
+	}
+	if vars := c.variables(frames[0].ID); vars["add"] == "" {
+		t.Errorf("expected add among the globals, got=%v", vars)
+	}
+
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+
+	var exited struct{ ExitCode int }
+	c.expect("exited", &exited)
+	c.expect("terminated", nil)
+	if exited.ExitCode != 0 || c.output != "3\n" {
+		t.Errorf("wrong exit. code=%d, output=%q", exited.ExitCode, c.output)
+	}
+
+	c.request("disconnect", nil, nil)
+}
+
+func TestStopOnEntryAndErrors(t *testing.T) {
+	c := startSession(t)
+	filename := c.launch("let xs = [1, [2]];\nxs[0] + true;", map[string]any{"stopOnEntry": true})
+	c.request("configurationDone", nil, nil)
+
+	reason, frames := c.expectStop()
+	if reason != "entry" || frames[0].Line != 1 {
+		t.Fatalf("expected a stop on entry, got=%s %v", reason, frames)
+	}
+
+	c.request("stepIn", map[string]int{"threadId": threadID}, nil)
+	if reason, frames = c.expectStop(); reason != "step" || frames[0].Line != 2 {
+		t.Fatalf("expected a step to line 2, got=%s %v", reason, frames)
+	}
+
+	var xs struct {
+		Result             string
+		VariablesReference int
+	}
+	c.request("evaluate", map[string]any{"expression": "xs", "frameId": frames[0].ID}, &xs)
+
+	var elements struct{ Variables []variable }
+	c.request("variables", map[string]int{"variablesReference": xs.VariablesReference}, &elements)
+	if xs.Result != "[1,[2]]" || len(elements.Variables) != 2 || elements.Variables[1].VariablesReference == 0 {
+		t.Errorf("wrong value of xs: %v %v", xs, elements.Variables)
+	}
+
+	c.send("evaluate", map[string]any{"expression": "ys", "frameId": frames[0].ID})
+	if msg := c.expect("evaluate", nil); msg.Success || msg.Message != "ys is not a variable in scope" {
+		t.Errorf("expected ys not to be found, got=%+v", msg)
+	}
+
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+
+	var exited struct{ ExitCode int }
+	c.expect("exited", &exited)
+	if exited.ExitCode != 1 || c.output != "executing bytecode failed: Unsupported types for binary operation: INTEGER BOOLEAN at "+filename+":2:1\n" {
+		t.Errorf("wrong exit. code=%d, output=%q", exited.ExitCode, c.output)
+	}
+
+	c.request("disconnect", nil, nil)
+}
@@ -0,0 +1,662 @@
+// Package dap implements the Debug Adapter Protocol, so editors like VS Code
+// can debug Monkey programs running on the VM.
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/run"
+	"monkey/token"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Programs have a single thread as far as the client is concerned
+const threadID = 1
+
+// stepMode says where a program that was stopped should stop next, besides
+// at breakpoints.
+type stepMode int
+
+const (
+	stepNone stepMode = iota
+	// Stop at the first line, for stopOnEntry
+	stepEntry
+	stepIn
+	stepOver
+	stepOut
+)
+
+// Serve runs a debug session with the client at the other end of r and w,
+// e.g. an editor that started `monkey dap` and talks to its stdin and
+// stdout, until the client disconnects.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &session{out: w, resume: make(chan struct{}, 1), done: make(chan struct{})}
+	in := bufio.NewReader(r)
+
+	for {
+		req, err := readMessage(in)
+		if err != nil {
+			s.terminate()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if s.handle(req) {
+			return nil
+		}
+	}
+}
+
+type session struct {
+	// Guards writing to out and seq
+	writeMu sync.Mutex
+	out     io.Writer
+	seq     int
+
+	// Guards the fields below, which the program's goroutine reads when it
+	// calls back
+	mu sync.Mutex
+
+	bytecode    *compiler.Bytecode
+	args        []string
+	noDebug     bool
+	stopOnEntry bool
+
+	launched, configured, started bool
+
+	// Lines to stop at, by the path of their source file
+	breakpoints map[string]map[int]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// What the program was last told to do, and the depth and line it was
+	// at when told
+	mode                stepMode
+	stepDepth, stepLine int
+	pausing             bool
+	stopped             *snapshot
+	// Signalled to let the stopped program go on
+	resume chan struct{}
+	// Closed once the program finishes
+	done chan struct{}
+
+	// Serializes stops, as builtins like spawn run functions on other
+	// goroutines
+	stopMu sync.Mutex
+}
+
+// snapshot is the state of a stopped program, which the client asks for a
+// piece at a time.
+type snapshot struct {
+	frames []stackFrame
+	// Locals of each frame, in the same order
+	locals  [][]vm.Variable
+	globals []vm.Variable
+	// What each variablesReference points at, less one: a []vm.Variable or
+	// an array or hash to list the elements of
+	refs []any
+}
+
+// handle answers req, reporting whether the session is over.
+func (s *session) handle(req *request) bool {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsTerminateRequest":         true,
+			"supportsEvaluateForHovers":        true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		var args launchArguments
+		if err := s.decode(req, &args); err == nil {
+			if err := s.launch(args); err != nil {
+				s.fail(req, err.Error())
+			} else {
+				s.respond(req, nil)
+				s.maybeStart()
+			}
+		}
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := s.decode(req, &args); err == nil {
+			s.respond(req, map[string][]breakpoint{"breakpoints": s.setBreakpoints(args)})
+		}
+	case "configurationDone":
+		s.mu.Lock()
+		s.configured = true
+		s.mu.Unlock()
+
+		s.respond(req, nil)
+		s.maybeStart()
+	case "threads":
+		s.respond(req, map[string][]thread{"threads": {{ID: threadID, Name: "main"}}})
+	case "stackTrace":
+		frames := []stackFrame{}
+		if stopped := s.snapshot(); stopped != nil {
+			frames = stopped.frames
+		}
+		s.respond(req, map[string]any{"stackFrames": frames, "totalFrames": len(frames)})
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		if err := s.decode(req, &args); err == nil {
+			s.respond(req, map[string][]scope{"scopes": s.scopes(args.FrameID)})
+		}
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err := s.decode(req, &args); err == nil {
+			s.respond(req, map[string][]variable{"variables": s.variables(args.VariablesReference)})
+		}
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+		}
+		if err := s.decode(req, &args); err == nil {
+			if v, ok := s.evaluate(strings.TrimSpace(args.Expression), args.FrameID); ok {
+				s.respond(req, map[string]any{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference})
+			} else {
+				s.fail(req, fmt.Sprintf("%s is not a variable in scope", args.Expression))
+			}
+		}
+	case "continue":
+		s.respond(req, map[string]bool{"allThreadsContinued": true})
+		s.continueWith(stepNone)
+	case "next":
+		s.respond(req, nil)
+		s.continueWith(stepOver)
+	case "stepIn":
+		s.respond(req, nil)
+		s.continueWith(stepIn)
+	case "stepOut":
+		s.respond(req, nil)
+		s.continueWith(stepOut)
+	case "pause":
+		s.mu.Lock()
+		s.pausing = true
+		s.mu.Unlock()
+
+		s.respond(req, nil)
+	case "terminate":
+		s.respond(req, nil)
+		if !s.terminate() {
+			s.event("terminated", nil)
+		}
+	case "disconnect":
+		s.terminate()
+		s.respond(req, nil)
+		return true
+	default:
+		s.fail(req, fmt.Sprintf("unsupported request %q", req.Command))
+	}
+
+	return false
+}
+
+// decode unmarshals the arguments of req into v, failing the request if
+// they're invalid.
+func (s *session) decode(req *request, v any) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+
+	err := json.Unmarshal(req.Arguments, v)
+	if err != nil {
+		s.fail(req, fmt.Sprintf("invalid arguments: %s", err))
+	}
+
+	return err
+}
+
+// launch reads and compiles the program, which starts running once the
+// client is done configuring the session.
+func (s *session) launch(args launchArguments) error {
+	if args.Program == "" {
+		return fmt.Errorf("no program to debug")
+	}
+
+	filename, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %s", err)
+	}
+
+	p := parser.New(lexer.NewWithFilename(filename, string(text)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		messages := []string{}
+		for _, err := range p.Errors() {
+			messages = append(messages, err.Error())
+		}
+		return fmt.Errorf("parsing failed: %s", strings.Join(messages, "; "))
+	}
+
+	c := compiler.New()
+	c.SetLoader(module.NewLoader(filepath.Dir(filename)))
+	if err := c.Compile(program); err != nil {
+		return fmt.Errorf("compilation failed: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bytecode = c.Bytecode()
+	s.args = args.Args
+	s.noDebug = args.NoDebug
+	s.stopOnEntry = args.StopOnEntry
+	s.launched = true
+
+	return nil
+}
+
+// maybeStart runs the program once it's been launched and configured.
+func (s *session) maybeStart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.launched || !s.configured || s.started {
+		return
+	}
+	s.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ctx, s.cancel = ctx, cancel
+
+	ctx = object.WithOutput(ctx, &output{s: s, category: "stdout"})
+	ctx = object.WithArgs(ctx, s.args)
+	if !s.noDebug {
+		ctx = vm.WithHooks(ctx, &vm.Hooks{Line: s.line})
+	}
+	if s.stopOnEntry {
+		s.mode = stepEntry
+	}
+
+	machine := vm.New(s.bytecode)
+	go func() {
+		defer close(s.done)
+
+		exitCode := run.ExitOK
+		if err := machine.RunContext(ctx); err != nil {
+			exitCode = run.ExitRuntimeError
+			if ctx.Err() == nil {
+				message := fmt.Sprintf("executing bytecode failed: %s\n", err)
+				if err, ok := err.(*object.Error); ok {
+					message += err.StackTrace()
+				}
+				s.output("stderr", message)
+			}
+		}
+
+		s.event("exited", map[string]int{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// terminate stops the program if it's running and waits for it to finish,
+// reporting whether it had been started.
+func (s *session) terminate() bool {
+	s.mu.Lock()
+	started := s.started
+	if started {
+		s.cancel()
+	}
+	s.mu.Unlock()
+
+	if started {
+		<-s.done
+	}
+
+	return started
+}
+
+// line is the VM's Line hook. It stops the program if it reached a
+// breakpoint or the end of a step, and waits for the client to let it go on.
+func (s *session) line(machine *vm.VM, pos token.Position) {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+
+	frames := machine.Frames()
+	reason := s.stopReason(len(frames), pos)
+	if reason == "" {
+		s.mu.Unlock()
+		return
+	}
+
+	s.stopped = s.takeSnapshot(machine, frames)
+	s.pausing = false
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]any{"reason": reason, "threadId": threadID, "allThreadsStopped": true})
+
+	select {
+	case <-s.resume:
+	case <-ctx.Done():
+	}
+}
+
+// stopReason returns why the program should stop at pos, depth frames deep,
+// or "" if it shouldn't.
+func (s *session) stopReason(depth int, pos token.Position) string {
+	if s.pausing {
+		return "pause"
+	}
+
+	if s.breakpoints[pos.Filename][pos.Line] {
+		return "breakpoint"
+	}
+
+	switch s.mode {
+	case stepEntry:
+		return "entry"
+	case stepIn:
+		return "step"
+	case stepOver:
+		if depth < s.stepDepth || (depth == s.stepDepth && pos.Line != s.stepLine) {
+			return "step"
+		}
+	case stepOut:
+		if depth < s.stepDepth {
+			return "step"
+		}
+	}
+
+	return ""
+}
+
+// continueWith lets a stopped program go on until the next stop mode says
+// otherwise.
+func (s *session) continueWith(mode stepMode) {
+	s.mu.Lock()
+	stopped := s.stopped
+	if stopped != nil {
+		s.mode = mode
+		s.stepDepth, s.stepLine = len(stopped.frames), stopped.frames[0].Line
+		s.stopped = nil
+	}
+	s.mu.Unlock()
+
+	if stopped != nil {
+		s.resume <- struct{}{}
+	}
+}
+
+func (s *session) takeSnapshot(machine *vm.VM, frames []*vm.Frame) *snapshot {
+	snap := &snapshot{globals: machine.Globals()}
+
+	for i, frame := range frames {
+		name := frame.Function()
+		if i == len(frames)-1 {
+			name = "main"
+		} else if name == "" {
+			name = "anonymous function"
+		}
+
+		pos := frame.Pos()
+		snap.frames = append(snap.frames, stackFrame{
+			ID:     i + 1,
+			Name:   name,
+			Source: source{Name: filepath.Base(pos.Filename), Path: pos.Filename},
+			Line:   pos.Line,
+			Column: pos.Column,
+		})
+		snap.locals = append(snap.locals, machine.Locals(frame))
+	}
+
+	return snap
+}
+
+func (s *session) snapshot() *snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stopped
+}
+
+// scopes returns the variables visible in the frame with id: its locals,
+// unless it's the main program's, and the globals.
+func (s *session) scopes(id int) []scope {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scopes := []scope{}
+	stopped := s.stopped
+	if stopped == nil || id < 1 || id > len(stopped.frames) {
+		return scopes
+	}
+
+	if id < len(stopped.frames) {
+		scopes = append(scopes, scope{Name: "Locals", VariablesReference: stopped.ref(stopped.locals[id-1])})
+	}
+	scopes = append(scopes, scope{Name: "Globals", VariablesReference: stopped.ref(stopped.globals)})
+
+	return scopes
+}
+
+// variables lists what the variablesReference ref points at.
+func (s *session) variables(ref int) []variable {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vars := []variable{}
+	stopped := s.stopped
+	if stopped == nil || ref < 1 || ref > len(stopped.refs) {
+		return vars
+	}
+
+	switch container := stopped.refs[ref-1].(type) {
+	case []vm.Variable:
+		for _, v := range container {
+			vars = append(vars, stopped.variable(v.Name, v.Value))
+		}
+	case *object.Array:
+		for i, el := range container.Elements {
+			vars = append(vars, stopped.variable(strconv.Itoa(i), el))
+		}
+	case *object.Hash:
+		pairs := []object.HashPair{}
+		for _, pair := range container.Pairs {
+			pairs = append(pairs, pair)
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+		})
+
+		for _, pair := range pairs {
+			vars = append(vars, stopped.variable(pair.Key.Inspect(), pair.Value))
+		}
+	}
+
+	return vars
+}
+
+// evaluate looks up the variable name as the frame with id sees it. Only
+// variables can be evaluated, which is enough for hovers.
+func (s *session) evaluate(name string, id int) (variable, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stopped := s.stopped
+	if stopped == nil {
+		return variable{}, false
+	}
+
+	scopes := [][]vm.Variable{stopped.globals}
+	if id >= 1 && id <= len(stopped.frames) {
+		scopes = append([][]vm.Variable{stopped.locals[id-1]}, scopes...)
+	}
+
+	for _, vars := range scopes {
+		for _, v := range vars {
+			if v.Name == name {
+				return stopped.variable(v.Name, v.Value), true
+			}
+		}
+	}
+
+	return variable{}, false
+}
+
+// ref returns a new variablesReference pointing at container.
+func (snap *snapshot) ref(container any) int {
+	snap.refs = append(snap.refs, container)
+	return len(snap.refs)
+}
+
+// variable describes value to the client, with a reference to its elements
+// if it's an array or hash that has any.
+func (snap *snapshot) variable(name string, value object.Object) variable {
+	v := variable{Name: name, Value: value.Inspect(), Type: string(value.Type())}
+
+	switch value := value.(type) {
+	case *object.Array:
+		if len(value.Elements) > 0 {
+			v.VariablesReference = snap.ref(value)
+		}
+	case *object.Hash:
+		if len(value.Pairs) > 0 {
+			v.VariablesReference = snap.ref(value)
+		}
+	}
+
+	return v
+}
+
+// setBreakpoints replaces the breakpoints in a source file. Breakpoints on
+// lines nothing was compiled from can never be hit, and aren't verified.
+func (s *session) setBreakpoints(args setBreakpointsArguments) []breakpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := args.Source.Path
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	var lines map[int]bool
+	if s.bytecode != nil {
+		lines = compiledLines(s.bytecode, path)
+	}
+
+	if s.breakpoints == nil {
+		s.breakpoints = map[string]map[int]bool{}
+	}
+	set := map[int]bool{}
+	s.breakpoints[path] = set
+
+	breakpoints := []breakpoint{}
+	for _, bp := range args.Breakpoints {
+		verified := lines == nil || lines[bp.Line]
+		if verified {
+			set[bp.Line] = true
+		}
+
+		b := breakpoint{Verified: verified, Line: bp.Line}
+		if !verified {
+			b.Message = "no code on this line"
+		}
+		breakpoints = append(breakpoints, b)
+	}
+
+	return breakpoints
+}
+
+// compiledLines returns the lines of filename the program's instructions
+// were compiled from.
+func compiledLines(bytecode *compiler.Bytecode, filename string) map[int]bool {
+	lines := map[int]bool{}
+
+	maps := []code.SourceMap{bytecode.SourceMap}
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			maps = append(maps, fn.SourceMap)
+		}
+	}
+
+	for _, m := range maps {
+		for _, mapping := range m {
+			if mapping.Pos.Filename == filename {
+				lines[mapping.Pos.Line] = true
+			}
+		}
+	}
+
+	return lines
+}
+
+func (s *session) respond(req *request, body any) {
+	s.send(&response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: true, Body: body})
+}
+
+func (s *session) fail(req *request, message string) {
+	s.send(&response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: message})
+}
+
+func (s *session) event(name string, body any) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+func (s *session) output(category, text string) {
+	s.event("output", map[string]string{"category": category, "output": text})
+}
+
+// send numbers msg and writes it to the client. Writing only fails once the
+// client has gone, which the session finds out when reading.
+func (s *session) send(msg any) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+
+	writeMessage(s.out, msg)
+}
+
+// output sends what the program prints to the client, as the session's
+// stdout is taken by the protocol.
+type output struct {
+	s        *session
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.s.output(o.category, string(p))
+	return len(p), nil
+}
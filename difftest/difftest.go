@@ -0,0 +1,159 @@
+// Package difftest runs programs on both the tree-walking evaluator and the
+// compiler and VM, and reports where they disagree, to catch the engines
+// drifting apart as features are added to one and not the other.
+//
+// Some differences are known and left as they are. Names are resolved when
+// the VM's programs are compiled, so an undefined one fails even in a branch
+// that never runs. And a builtin that fails stops the evaluator, but leaves
+// an error value the VM's program carries on with.
+package difftest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"path/filepath"
+	"strings"
+)
+
+// Outcome is what running a program on one engine came to.
+type Outcome struct {
+	// The value the program finished with, as printed by Inspect
+	Result string
+	// What it wrote with puts
+	Output string
+	// Why it failed, empty if it didn't
+	Err string
+}
+
+func (o Outcome) String() string {
+	if o.Err != "" {
+		return fmt.Sprintf("error %q, output %q", o.Err, o.Output)
+	}
+	return fmt.Sprintf("result %s, output %q", o.Result, o.Output)
+}
+
+// Eval runs program on the evaluator. filename is the file it was read
+// from, which its imports are relative to, or empty.
+func Eval(ctx context.Context, program *ast.Program, filename string) Outcome {
+	var out bytes.Buffer
+	ctx = object.WithOutput(ctx, &out)
+	ctx = module.WithLoader(ctx, loader(filename))
+
+	result := evaluator.EvalContext(ctx, program, object.NewEnvironment())
+	if err, ok := result.(*object.Error); ok {
+		return Outcome{Output: out.String(), Err: err.Message}
+	}
+
+	return Outcome{Result: inspect(result), Output: out.String()}
+}
+
+// VM compiles program and runs it on the VM. filename is as for Eval.
+func VM(ctx context.Context, program *ast.Program, filename string) Outcome {
+	var out bytes.Buffer
+	ctx = object.WithOutput(ctx, &out)
+	ctx = module.WithLoader(ctx, loader(filename))
+
+	c := compiler.New()
+	c.SetLoader(loader(filename))
+	if err := c.Compile(program); err != nil {
+		return Outcome{Err: err.Error()}
+	}
+
+	machine := vm.New(c.Bytecode())
+	if err := machine.RunContext(ctx); err != nil {
+		if err, ok := err.(*object.Error); ok {
+			return Outcome{Output: out.String(), Err: err.Message}
+		}
+		return Outcome{Output: out.String(), Err: err.Error()}
+	}
+
+	// Builtins fail by returning errors, which the VM leaves on the stack
+	result := machine.LastPoppedStackElem()
+	if err, ok := result.(*object.Error); ok {
+		return Outcome{Output: out.String(), Err: err.Message}
+	}
+
+	return Outcome{Result: inspect(result), Output: out.String()}
+}
+
+// Compare runs source on both engines and returns an error describing how
+// they disagree, or nil if they don't. They agree when they finish with the
+// same result and output, or both fail after the same output. The engines
+// word their errors differently, so what the errors say isn't compared.
+func Compare(ctx context.Context, source, filename string) error {
+	p := parser.New(lexer.NewWithFilename(filename, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		msgs := []string{}
+		for _, err := range p.Errors() {
+			msgs = append(msgs, err.Error())
+		}
+		return fmt.Errorf("could not parse: %s", strings.Join(msgs, "; "))
+	}
+
+	eval := Eval(ctx, program, filename)
+	machine := VM(ctx, program, filename)
+
+	// After a let or other statement that leaves no value, the VM's result
+	// is whatever it last popped, so there's nothing to compare
+	if !endsWithExpression(program) {
+		eval.Result, machine.Result = "", ""
+	}
+
+	if !agree(eval, machine) {
+		return fmt.Errorf("engines disagree:\n\teval: %s\n\tvm:   %s", eval, machine)
+	}
+
+	return nil
+}
+
+func agree(a, b Outcome) bool {
+	if a.Output != b.Output {
+		return false
+	}
+	if a.Err != "" || b.Err != "" {
+		return a.Err != "" && b.Err != ""
+	}
+
+	return a.Result == b.Result
+}
+
+func endsWithExpression(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+
+	_, ok := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func loader(filename string) *module.Loader {
+	if filename == "" {
+		return module.NewLoader("")
+	}
+	return module.NewLoader(filepath.Dir(filename))
+}
+
+// inspect returns obj as printed by Inspect, except for functions, which
+// each engine prints its own way.
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "null"
+	}
+
+	switch obj.Type() {
+	case object.FUNC_OBJ, object.CLOSURE_OBJ, object.BUILTIN_OBJ:
+		return "function"
+	}
+
+	return obj.Inspect()
+}
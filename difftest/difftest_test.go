@@ -0,0 +1,95 @@
+package difftest
+
+import (
+	"context"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.monkey"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no programs in testdata: %v", err)
+	}
+
+	for _, filename := range files {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("could not read %s: %s", filename, err)
+		}
+
+		if err := Compare(context.Background(), string(source), filename); err != nil {
+			t.Errorf("%s: %s", filename, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 + 2`, ""},
+		{`puts("a"); 1 + true`, ""},
+		{`let x = ;`, "could not parse"},
+	}
+
+	for _, tt := range tests {
+		err := Compare(context.Background(), tt.input, "")
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("%q: expected the engines to agree, got %s", tt.input, err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestAgree(t *testing.T) {
+	tests := []struct {
+		a, b     Outcome
+		expected bool
+	}{
+		{Outcome{Result: "1"}, Outcome{Result: "1"}, true},
+		{Outcome{Result: "1"}, Outcome{Result: "2"}, false},
+		{Outcome{Result: "1", Output: "a\n"}, Outcome{Result: "1"}, false},
+		{Outcome{Err: "type mismatch"}, Outcome{Err: "unsupported types"}, true},
+		{Outcome{Err: "type mismatch"}, Outcome{Result: "null"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := agree(tt.a, tt.b); got != tt.expected {
+			t.Errorf("agree(%s, %s) = %t, want %t", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func FuzzCompare(f *testing.F) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "*.monkey"))
+	for _, filename := range files {
+		source, _ := os.ReadFile(filename)
+		f.Add(string(source))
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		// Programs may recurse without end or wait on channels forever
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		ctx = object.WithMaxDepth(ctx, 100)
+
+		err := Compare(ctx, input, "")
+		if err == nil || strings.HasPrefix(err.Error(), "could not parse") || ctx.Err() != nil {
+			return
+		}
+
+		t.Errorf("%q: %s", input, err)
+	})
+}
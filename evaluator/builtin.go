@@ -5,10 +5,62 @@ import (
 )
 
 var builtins = map[string]*object.Builtin{
-	"puts":  object.GetBuiltinByName("puts"),
-	"first": object.GetBuiltinByName("first"),
-	"last":  object.GetBuiltinByName("last"),
-	"rest":  object.GetBuiltinByName("rest"),
-	"push":  object.GetBuiltinByName("push"),
-	"len":   object.GetBuiltinByName("len"),
+	"puts":     object.GetBuiltinByName("puts"),
+	"first":    object.GetBuiltinByName("first"),
+	"last":     object.GetBuiltinByName("last"),
+	"rest":     object.GetBuiltinByName("rest"),
+	"push":     object.GetBuiltinByName("push"),
+	"len":      object.GetBuiltinByName("len"),
+	"args":     object.GetBuiltinByName("args"),
+	"assert":   object.GetBuiltinByName("assert"),
+	"spawn":    object.GetBuiltinByName("spawn"),
+	"wait":     object.GetBuiltinByName("wait"),
+	"chan":     object.GetBuiltinByName("chan"),
+	"send":     object.GetBuiltinByName("send"),
+	"recv":     object.GetBuiltinByName("recv"),
+	"close":    object.GetBuiltinByName("close"),
+	"bigint":   object.GetBuiltinByName("bigint"),
+	"freeze":   object.GetBuiltinByName("freeze"),
+	"frozen":   object.GetBuiltinByName("frozen"),
+	"memoize":  object.GetBuiltinByName("memoize"),
+	"keys":     object.GetBuiltinByName("keys"),
+	"values":   object.GetBuiltinByName("values"),
+	"builtins": object.GetBuiltinByName("builtins"),
+
+	"startsWith": object.GetBuiltinByName("startsWith"),
+	"endsWith":   object.GetBuiltinByName("endsWith"),
+	"reverse":    object.GetBuiltinByName("reverse"),
+	"concat":     object.GetBuiltinByName("concat"),
+	"slice":      object.GetBuiltinByName("slice"),
+	"flatten":    object.GetBuiltinByName("flatten"),
+	"zip":        object.GetBuiltinByName("zip"),
+	"parse_int":  object.GetBuiltinByName("parse_int"),
+	"now":        object.GetBuiltinByName("now"),
+	"parse_time": object.GetBuiltinByName("parse_time"),
+	"format":     object.GetBuiltinByName("format"),
+	"add":        object.GetBuiltinByName("add"),
+	"open":       object.GetBuiltinByName("open"),
+	"read_line":  object.GetBuiltinByName("read_line"),
+	"write":      object.GetBuiltinByName("write"),
+	"list_dir":   object.GetBuiltinByName("list_dir"),
+	"exists":     object.GetBuiltinByName("exists"),
+	"mkdir":      object.GetBuiltinByName("mkdir"),
+	"path_join":  object.GetBuiltinByName("path_join"),
+	"cwd":        object.GetBuiltinByName("cwd"),
+	"pid":        object.GetBuiltinByName("pid"),
+	"hostname":   object.GetBuiltinByName("hostname"),
+	"platform":   object.GetBuiltinByName("platform"),
+	"csv_parse":  object.GetBuiltinByName("csv_parse"),
+	"csv_encode": object.GetBuiltinByName("csv_encode"),
+	"b64_encode": object.GetBuiltinByName("b64_encode"),
+	"b64_decode": object.GetBuiltinByName("b64_decode"),
+	"hex_encode": object.GetBuiltinByName("hex_encode"),
+	"hex_decode": object.GetBuiltinByName("hex_decode"),
+	"uuid":       object.GetBuiltinByName("uuid"),
+	"sleep":      object.GetBuiltinByName("sleep"),
+	"error":      object.GetBuiltinByName("error"),
+	"is_error":   object.GetBuiltinByName("is_error"),
+	"chars":      object.GetBuiltinByName("chars"),
+
+	"memory_stats": object.GetBuiltinByName("memory_stats"),
 }
@@ -1,15 +1,17 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"monkey/ast"
+	"monkey/module"
 	"monkey/object"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
 func nativeBoolToBooleanObject(value bool) *object.Boolean {
@@ -20,17 +22,115 @@ func nativeBoolToBooleanObject(value bool) *object.Boolean {
 }
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return EvalContext(context.Background(), node, env)
+}
+
+// EvalContext evaluates node like Eval, but stops and returns an error once
+// ctx is cancelled. Cancellation is checked before every statement and
+// function call, so runaway recursion can be interrupted.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	return newEvaluation(ctx).eval(node, env)
+}
+
+// Apply calls fn, a function value or builtin, with args and returns its
+// result. It lets Go code call back into Monkey functions.
+func Apply(ctx context.Context, fn object.Object, args []object.Object) object.Object {
+	return newEvaluation(ctx).applyFunction(fn, args)
+}
+
+// evaluation holds the state shared by a single call to EvalContext.
+type evaluation struct {
+	ctx context.Context
+
+	// Number of active function calls, limited to maxDepth so deep recursion
+	// fails before it overflows the Go stack
+	depth    int
+	maxDepth int
+	// Set with object.WithStrict
+	strict bool
+	// Set with object.WithCheckTypes
+	checkTypes bool
+
+	// Set with WithHooks, nil if nothing is observing the evaluation
+	hooks *Hooks
+
+	// Resolves imports and caches the modules they evaluate to
+	loader *module.Loader
+}
+
+func newEvaluation(ctx context.Context) *evaluation {
+	// Forks share the loader through the context, so modules are only
+	// evaluated once
+	loader := module.LoaderFrom(ctx)
+	if loader == nil {
+		loader = module.NewLoader("")
+		ctx = module.WithLoader(ctx, loader)
+	}
+
+	e := &evaluation{maxDepth: object.MaxDepth(ctx), strict: object.Strict(ctx), checkTypes: object.CheckTypes(ctx), hooks: hooksFrom(ctx), loader: loader}
+	// Builtins like spawn call functions back through the evaluation
+	e.ctx = object.WithCaller(ctx, e)
+	return e
+}
+
+// Call implements object.FunctionCaller.
+func (e *evaluation) Call(fn object.Object, args ...object.Object) object.Object {
+	return e.applyFunction(fn, args)
+}
+
+// Fork implements object.FunctionCaller. Environments are safe to share
+// between goroutines, but the forked evaluation runs on its own stack and
+// counts its own depth.
+func (e *evaluation) Fork() object.FunctionCaller {
+	return newEvaluation(e.ctx)
+}
+
+// interrupted returns an error object if the evaluation has been cancelled.
+func (e *evaluation) interrupted() *object.Error {
+	select {
+	case <-e.ctx.Done():
+		return newError("execution interrupted: %s", e.ctx.Err())
+	default:
+		return nil
+	}
+}
+
+func (e *evaluation) eval(node ast.Node, env *object.Environment) object.Object {
+	if e.hooks != nil && e.hooks.Enter != nil {
+		e.hooks.Enter(node, env)
+	}
+
+	result := e.evalNode(node, env)
+
+	// Errors are positioned at the innermost node they came out of
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+
+	if e.hooks != nil && e.hooks.Exit != nil {
+		e.hooks.Exit(node, result)
+	}
+
+	return result
+}
+
+func (e *evaluation) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		if e.strict {
+			if err := undeclared(node, env); err != nil {
+				return err
+			}
+		}
+		return e.evalProgram(node.Statements, env)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 	case *ast.IndexExpression:
 		// Eval left hand,
 		// if it's not an array do an error
@@ -39,23 +139,28 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		// Does the parsing help out with this at all?
 		// Probably not because the left hand is an expression
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 
 		if isError(left) {
 			return left
 		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
 
-		index := Eval(node.Index, env)
+		index := e.eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 
-		return evalIndexExpression(left, index)
+		return e.evalIndexExpression(left, index)
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.ImportExpression:
+		return e.evalImportExpression(node)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
@@ -66,47 +171,80 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		params := node.Parameters
 		body := node.Body
 
-		return &object.FunctionValue{Parameters: params, Env: env, Body: body}
+		return &object.FunctionValue{
+			Name:           node.Name,
+			Parameters:     params,
+			ParameterTypes: node.ParameterTypes,
+			ReturnType:     node.ReturnType,
+			Locals:         node.Locals,
+			Env:            env,
+			Body:           body,
+		}
 	case *ast.CallExpression:
 		// evaluate identifier
-		function := Eval(node.Function, env)
+		function := e.eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
 
 		// Evaluate arguments
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalArguments(function, node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
-		// Add arguments to extended new environment and evaluate the body
+		result := e.applyFunction(function, args)
+
+		// Record the call in the trace of errors coming out of it
+		if fn, ok := function.(*object.FunctionValue); ok {
+			if err, ok := result.(*object.Error); ok {
+				err.Stack = append(err.Stack, object.StackFrame{Function: fn.Name, Pos: node.Pos()})
+			}
+		}
+
+		return result
+	case *ast.MethodCallExpression:
+		return e.evalMethodCall(node, env)
+	case *ast.FieldExpression:
+		// Evaluated like an index expression with a string index
+		left := e.eval(node.Object, env)
+		if isError(left) {
+			return left
+		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
+
+		return e.evalIndexExpression(left, &object.String{Value: node.Field.Value})
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		return e.evalMatchExpression(node, env)
+	case *ast.SpreadExpression:
+		return newError("unexpected spread %s", node)
 	case *ast.LetStatement:
-		return evalLetStatement(node, env)
+		return e.evalLetStatement(node, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node.Statements, env)
+		return e.evalBlockStatement(node.Statements, env)
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return object.NewInteger(node.Value)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.ReturnStatement:
-		evaluated := Eval(node.ReturnValue, env)
+		evaluated := e.eval(node.ReturnValue, env)
 		if isError(evaluated) {
 			return evaluated
 		}
@@ -123,11 +261,30 @@ func newError(format string, a ...any) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+// evalExpressions evaluates exps in order, expanding the elements of spread
+// arrays in place.
+func (e *evaluation) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		if spread, ok := exp.(*ast.SpreadExpression); ok {
+			value := e.eval(spread.Value, env)
+			if isError(value) {
+				return []object.Object{value}
+			}
+
+			arr, ok := value.(*object.Array)
+			if !ok {
+				err := newError("spread operator not supported: %s", value.Type())
+				err.Pos = spread.Pos()
+				return []object.Object{err}
+			}
+
+			result = append(result, arr.Elements...)
+			continue
+		}
+
+		evaluated := e.eval(exp, env)
 
 		// Error response is single list with an error
 		// Error in first param evaluation stops evaluation on furhter params
@@ -141,17 +298,146 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalArguments evaluates the arguments of a call to fn. Named arguments
+// are moved to the position of their parameter.
+func (e *evaluation) evalArguments(fn object.Object, exps []ast.Expression, env *object.Environment) []object.Object {
+	positional := []ast.Expression{}
+	names := []string{}
+	values := []ast.Expression{}
+	for _, exp := range exps {
+		if arg, ok := exp.(*ast.NamedArgument); ok {
+			names = append(names, arg.Name.Value)
+			values = append(values, arg.Value)
+		} else {
+			positional = append(positional, exp)
+		}
+	}
+
+	if len(names) == 0 {
+		return e.evalExpressions(exps, env)
+	}
+
+	function, ok := fn.(*object.FunctionValue)
+	if !ok {
+		return []object.Object{newError("named arguments not supported: %s", fn.Type())}
+	}
+
+	// Named arguments always come last, so this is source order
+	args := e.evalExpressions(positional, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args
+	}
+	named := e.evalExpressions(values, env)
+	if len(named) == 1 && isError(named[0]) {
+		return named
+	}
+
+	params := []string{}
+	for _, param := range function.Parameters {
+		params = append(params, param.Value)
+	}
+
+	args, err := object.ArrangeArguments(params, args, names, named)
+	if err != nil {
+		return []object.Object{newError("%s", err)}
+	}
+	return args
+}
+
+// evalMethodCall calls the method of the value's type, or the function in
+// the field of a hash, with the value as the first argument.
+func (e *evaluation) evalMethodCall(node *ast.MethodCallExpression, env *object.Environment) object.Object {
+	receiver := e.eval(node.Object, env)
+	if isError(receiver) {
+		return receiver
+	}
+	if node.Optional && receiver == NULL {
+		return NULL
+	}
+
+	args := e.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	args = append([]object.Object{receiver}, args...)
+
+	// The functions a module exports aren't passed the module
+	if m, ok := receiver.(*object.Module); ok {
+		fn, ok := m.Export(node.Method.Value)
+		if !ok {
+			return newError("%s has no export %s", m.Inspect(), node.Method.Value)
+		}
+		return e.applyFunction(fn, args[1:])
+	}
+
+	if hash, ok := receiver.(*object.Hash); ok {
+		if fn, ok := hash.Method(node.Method.Value); ok {
+			result := e.applyFunction(fn, args)
+
+			if fn, ok := fn.(*object.FunctionValue); ok {
+				if err, ok := result.(*object.Error); ok {
+					err.Stack = append(err.Stack, object.StackFrame{Function: fn.Name, Pos: node.Pos()})
+				}
+			}
+
+			return result
+		}
+	}
+
+	method, ok := object.LookupMethod(receiver, node.Method.Value)
+	if !ok {
+		return newError("%s has no method %s", receiver.Type(), node.Method.Value)
+	}
+
+	return e.applyFunction(method, args)
+}
+
+func (e *evaluation) applyFunction(fn object.Object, args []object.Object) object.Object {
 	// Could also be a builtin
 	switch fn := fn.(type) {
 	case *object.FunctionValue:
+		if err := e.interrupted(); err != nil {
+			return err
+		}
+
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+
+		if e.checkTypes && fn.ParameterTypes != nil {
+			if err := checkArguments(fn, args); err != nil {
+				return err
+			}
+		}
+
+		if e.depth >= e.maxDepth {
+			return newError("maximum recursion depth exceeded")
+		}
+		e.depth++
+		defer func() { e.depth-- }()
+
+		if e.hooks != nil && e.hooks.Call != nil {
+			if returned := e.hooks.Call(fn); returned != nil {
+				defer returned()
+			}
+		}
+
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := e.eval(fn.Body, extendedEnv)
+		if evaluated == nil {
+			evaluated = NULL
+		}
 
-		return unwrapReturnValue(evaluated)
+		result := unwrapReturnValue(evaluated)
+		if e.checkTypes && fn.ReturnType != nil && !isError(result) {
+			if err := object.CheckResult(fn.Name, fn.ReturnType.Name, result); err != nil {
+				return err
+			}
+		}
+		return result
 
 	case *object.Builtin:
-		res := fn.Fn(args...)
+		res := fn.Call(e.ctx, args...)
 
 		if res == nil {
 			return NULL
@@ -163,14 +449,34 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
-func extendFunctionEnv(fn *object.FunctionValue, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+// checkArguments returns an error if an argument to fn isn't of the type its
+// parameter is annotated with.
+func checkArguments(fn *object.FunctionValue, args []object.Object) *object.Error {
+	params := make([]string, len(fn.Parameters))
+	types := make([]string, len(fn.ParameterTypes))
+	for i, param := range fn.Parameters {
+		params[i] = param.Value
+	}
+	for i, t := range fn.ParameterTypes {
+		if t != nil {
+			types[i] = t.Name
+		}
+	}
+
+	return object.CheckArguments(fn.Name, params, types, args)
+}
 
-	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+func extendFunctionEnv(fn *object.FunctionValue, args []object.Object) *object.Environment {
+	if fn.Locals == nil {
+		env := object.NewEnclosedEnvironment(fn.Env)
+		for paramIdx, param := range fn.Parameters {
+			env.Set(param.Value, args[paramIdx])
+		}
+		return env
 	}
 
-	return env
+	// Parameters take the first slots
+	return object.NewFunctionEnvironment(fn.Env, fn.Locals, args)
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -181,7 +487,21 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	val, ok := env.Get(node.Value)
+	var val object.Object
+	var ok bool
+
+	if node.Binding.Scope == ast.Local {
+		var scope *object.Environment
+		val, scope = env.GetSlot(node.Binding.Depth, node.Binding.Slot)
+		ok = val != nil
+
+		// Its let binding hasn't run yet, so look further out
+		if !ok && scope.Outer() != nil {
+			val, ok = scope.Outer().Get(node.Value)
+		}
+	} else {
+		val, ok = env.Get(node.Value)
+	}
 
 	if !ok {
 		if builtin, ok := builtins[node.Value]; ok {
@@ -194,21 +514,75 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return val
 }
 
-func evalLetStatement(node *ast.LetStatement, env *object.Environment) object.Object {
-	value := Eval(node.Value, env)
+func (e *evaluation) evalLetStatement(node *ast.LetStatement, env *object.Environment) object.Object {
+	value := e.eval(node.Value, env)
 	if isError(value) {
 		return value
 	}
 
-	env.Set(node.Name.Value, value)
+	bind(node.Name, value, env)
 	return nil
 }
 
-func evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
+// bind sets the variable name declares, which lives in a slot if it's local
+// to a function.
+func bind(name *ast.Identifier, value object.Object, env *object.Environment) {
+	if name.Binding.Scope == ast.Local {
+		env.SetSlot(name.Binding.Slot, value)
+	} else {
+		env.Set(name.Value, value)
+	}
+}
+
+// evalImportExpression evaluates the imported file in an environment of its
+// own the first time it's imported, and returns the module of its exports.
+func (e *evaluation) evalImportExpression(node *ast.ImportExpression) object.Object {
+	filename := e.loader.Resolve(node.Path.Value, node.Pos().Filename)
+
+	// Errors in the module itself are returned as they are, positioned in
+	// its file
+	var failed *object.Error
+	m, err := e.loader.Import(filename, func() (object.Object, error) {
+		program, err := e.loader.Parse(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		env := object.NewEnvironment()
+		if result := e.eval(program, env); isError(result) {
+			failed = result.(*object.Error)
+			return nil, failed
+		}
+
+		exports := map[string]object.Object{}
+		for _, name := range module.Exports(program) {
+			if value, ok := env.Get(name); ok {
+				exports[name] = value
+			}
+		}
+
+		return &object.Module{Name: node.Path.Value, Exports: exports}, nil
+	})
+
+	if failed != nil {
+		return failed
+	}
+	if err != nil {
+		return newError("cannot import %q: %s", node.Path.Value, err)
+	}
+
+	return m
+}
+
+func (e *evaluation) evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range statements {
-		result = Eval(statement, env)
+		if err := e.interrupted(); err != nil {
+			return err
+		}
+
+		result = e.eval(statement, env)
 
 		// If we encounter a return or error value, do not continue evaluating
 		// further expressions in the block.
@@ -224,11 +598,15 @@ func evalProgram(statements []ast.Statement, env *object.Environment) object.Obj
 	return result
 }
 
-func evalBlockStatement(statements []ast.Statement, env *object.Environment) object.Object {
+func (e *evaluation) evalBlockStatement(statements []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range statements {
-		result = Eval(statement, env)
+		if err := e.interrupted(); err != nil {
+			return err
+		}
+
+		result = e.eval(statement, env)
 
 		// If we encounter a return value, do not continue evaluating
 		// further expressions in the block. Do not unwrap though.
@@ -266,27 +644,25 @@ func evalBangOperatorExpression(expr object.Object) object.Object {
 
 func evalMinusPrefixOperatorExpression(expr object.Object) object.Object {
 	// - prefix doesn't work on non-integer types
-	if expr.Type() != object.INTEGER_OBJ {
+	if !object.IsNumber(expr) {
 		return newError("unknown operator: -%s", expr.Type())
 	}
 
-	value := expr.(*object.Integer).Value
-
-	return &object.Integer{
-		Value: -value,
-	}
+	return object.Negate(expr)
 }
 
 func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return evalNumberInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
@@ -302,9 +678,9 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	case "+":
 		return &object.String{Value: leftVal + rightVal}
 	case "==":
-		return &object.Boolean{Value: leftVal == rightVal}
+		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
-		return &object.Boolean{Value: leftVal != rightVal}
+		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -315,14 +691,12 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	rightVal := right.(*object.Integer).Value
 
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+	case "+", "-", "*", "/":
+		result, err := object.IntegerArithmetic(operator, leftVal, rightVal)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return result
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -336,32 +710,126 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+// evalNumberInfixExpression handles operations where at least one operand is
+// a BigInt.
+func evalNumberInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	switch operator {
+	case "+", "-", "*", "/":
+		result, err := object.Arithmetic(operator, left, right)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return result
+	case "<":
+		return nativeBoolToBooleanObject(object.CompareNumbers(left, right) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(object.CompareNumbers(left, right) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(object.CompareNumbers(left, right) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(object.CompareNumbers(left, right) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
 // Eval conditional block
 // Based on that object result, eval and return consequence or alternative
 // Alternative may be nil
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (e *evaluation) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
-	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+	truthy, err := e.condition(condition)
+	if err != nil {
+		return err
 	}
 
-	if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
-	} else {
+	var result object.Object
+	if truthy {
+		result = e.eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		result = e.eval(ie.Alternative, env)
+	}
+
+	// Branches that are empty or end with a let statement have no value
+	if result == nil {
 		return NULL
 	}
+	return result
 }
 
-func evalIndexExpression(left object.Object, index object.Object) object.Object {
+// evalMatchExpression evaluates the body of the first arm whose pattern
+// matches the subject and whose guard, if any, is truthy. It's null if none
+// do.
+func (e *evaluation) evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := e.eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		pattern, err := object.NewPattern(arm.Pattern)
+		if err != nil {
+			return newError("%s", err)
+		}
+
+		values, ok := pattern.Match(subject)
+		if !ok {
+			continue
+		}
+		for i, name := range ast.PatternBindings(arm.Pattern) {
+			bind(name, values[i], env)
+		}
+
+		if arm.Guard != nil {
+			guard := e.eval(arm.Guard, env)
+			if isError(guard) {
+				return guard
+			}
+			truthy, err := e.condition(guard)
+			if err != nil {
+				return err
+			}
+			if !truthy {
+				continue
+			}
+		}
+
+		return e.eval(arm.Body, env)
+	}
+
+	return NULL
+}
+
+func (e *evaluation) evalIndexExpression(left object.Object, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		if err := e.outOfRange(left, index.(*object.Integer).Value); err != nil {
+			return err
+		}
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		if err := e.outOfRange(left, index.(*object.Integer).Value); err != nil {
+			return err
+		}
+		char, ok := left.(*object.String).Char(index.(*object.Integer).Value)
+		if !ok {
+			return NULL
+		}
+		return char
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		m := left.(*object.Module)
+		name := index.(*object.String).Value
+		value, ok := m.Export(name)
+		if !ok {
+			return newError("%s has no export %s", m.Inspect(), name)
+		}
+		return value
 	default:
 		return newError("index operator not supported: %s", left.Type())
 
@@ -399,11 +867,11 @@ func evalArrayIndexExpression(array object.Object, index object.Object) object.O
 	return arrayObj.Elements[idx]
 }
 
-func evalHashLiteral(hashLiteral *ast.HashLiteral, env *object.Environment) object.Object {
+func (e *evaluation) evalHashLiteral(hashLiteral *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range hashLiteral.Pairs {
-		key := Eval(keyNode, env)
+		key := e.eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
@@ -414,7 +882,7 @@ func evalHashLiteral(hashLiteral *ast.HashLiteral, env *object.Environment) obje
 			return newError("Key must be a hashable type. Expected String, boolean, integer, got %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := e.eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
@@ -1,10 +1,22 @@
 package evaluator
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/ast"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -84,6 +96,20 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[] == {}", false},
+		{`{"a": [1], 2: true} == {2: true, "a": [1]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`"a" == "a"`, true},
+		{`["a"] == ["a"]`, true},
+		{"[1] == [bigint(1)]", true},
+		{"let f = fn() {}; [f] == [f]", true},
+		{"[fn() {}] == [fn() {}]", false},
 	}
 
 	for _, tt := range tests {
@@ -122,7 +148,13 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 < 2) { 10 }", 10},
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{`if ("a" == "b") { 10 } else { 20 }`, 20},
+		{`if ("a" != "a") { 10 }`, nil},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) { }", nil},
+		{"if (true) { let a = 1; }", nil},
+		{"if (false) { 10 } else { }", nil},
+		{"fn() { }()", nil},
 	}
 
 	for _, tt := range tests {
@@ -202,6 +234,9 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "test"}[fn(x) { x }]`,
 			"unusable as hash key: FUNCTION",
 		},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{`bigint(1) / 0`, "division by zero: 1 / 0"},
+		{`bigint("12x")`, `could not parse "12x" as an integer`},
 	}
 
 	for _, tt := range tests {
@@ -298,6 +333,51 @@ func TestClosures(t *testing.T) {
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestLocalScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// Before its let runs, a name still refers to the outer binding
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f();", 3},
+		{"let f = fn(a) { if (a) { let b = 5; }; b }; let b = 7; f(false);", 7},
+		{"let f = fn(a) { if (a) { let b = 5; }; b }; let b = 7; f(true);", 5},
+		{"let f = fn(a) { let a = a * 2; a }; f(21);", 42},
+		{"let f = fn(a, a) { a }; f(1, 2);", 2},
+		{"let f = fn() { let n = 3; let g = fn() { n * 2 }; g() }; f();", 6},
+		{"let f = fn() { let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5) }; f();", 120},
+		{"let f = fn(x) { fn() { let x = x + 1; x } }; f(1)();", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestUnresolvedProgram(t *testing.T) {
+	input := `
+		let newAdder = fn(x) {
+			let y = 1;
+			fn(z) { x + y + z };
+		};
+		newAdder(2)(3);
+	`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	data, err := ast.ToJSON(program)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %s", err)
+	}
+
+	// Trees decoded from JSON haven't been through Resolve
+	node, err := ast.FromJSON(data)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %s", err)
+	}
+
+	testIntegerObject(t, Eval(node, object.NewEnvironment()), 6)
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -346,6 +426,53 @@ func TestStringComparison(t *testing.T) {
 	}
 }
 
+func TestHooks(t *testing.T) {
+	program := parser.New(lexer.New("let f = fn(x) { x * 2 }; f(1 + 2);")).ParseProgram()
+
+	trace := []string{}
+	hooks := &Hooks{
+		Enter: func(node ast.Node, env *object.Environment) {
+			trace = append(trace, fmt.Sprintf("enter %s", node))
+		},
+		Exit: func(node ast.Node, result object.Object) {
+			if _, ok := node.(*ast.InfixExpression); ok {
+				trace = append(trace, fmt.Sprintf("exit %s = %s", node, result.Inspect()))
+			}
+		},
+		Call: func(fn *object.FunctionValue) func() {
+			trace = append(trace, "call "+fn.Name)
+			return func() { trace = append(trace, "return "+fn.Name) }
+		},
+	}
+
+	result := EvalContext(WithHooks(context.Background(), hooks), program, object.NewEnvironment())
+	testIntegerObject(t, result, 6)
+
+	expected := []string{
+		"enter let f = fn<f>(x) { (x * 2) };f((1 + 2))",
+		"enter let f = fn<f>(x) { (x * 2) };",
+		"enter fn<f>(x) { (x * 2) }",
+		"enter f((1 + 2))",
+		"enter f((1 + 2))",
+		"enter f",
+		"enter (1 + 2)",
+		"enter 1",
+		"enter 2",
+		"exit (1 + 2) = 3",
+		"call f",
+		"enter (x * 2)",
+		"enter (x * 2)",
+		"enter (x * 2)",
+		"enter x",
+		"enter 2",
+		"exit (x * 2) = 6",
+		"return f",
+	}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("wrong trace.\nexpected=%q\ngot=     %q", expected, trace)
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -354,8 +481,77 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`startsWith("monkey", "mon")`, true},
+		{`startsWith("monkey", "key")`, false},
+		{`startsWith("", "")`, true},
+		{`endsWith("monkey", "key")`, true},
+		{`endsWith("monkey", "monkeys")`, false},
+		{`startsWith("monkey", 1)`, "arguments to `startsWith` must be STRING, got INTEGER"},
+		{`endsWith(["key"], "key")`, "arguments to `endsWith` must be STRING, got ARRAY"},
+		{`endsWith("key")`, "wrong number of arguments. got=1, want=2"},
+		{`reverse([1, 2, 3]) == [3, 2, 1]`, true},
+		{`let a = [1, 2]; reverse(a); a == [1, 2]`, true},
+		{`reverse("abc")`, "argument to `reverse` must be ARRAY, got STRING"},
+		{`concat([1], [2, 3]) == [1, 2, 3]`, true},
+		{`concat([], []) == []`, true},
+		{`concat([1], 2)`, "arguments to `concat` must be ARRAY, got INTEGER"},
+		{`slice([1, 2, 3, 4], 1, 3) == [2, 3]`, true},
+		{`slice([1, 2, 3, 4], -2, 10) == [3, 4]`, true},
+		{`slice([1, 2, 3], 2, 1) == []`, true},
+		{`slice([1, 2, 3], "a", 1)`, "indexes to `slice` must be INTEGER, got STRING"},
+		{`slice([1, 2, 3], 1)`, "wrong number of arguments. got=2, want=3"},
+		{`flatten([1, [2, [3, [4]]], []]) == [1, 2, [3, [4]]]`, true},
+		{`flatten([1, [2, [3, [4]]]], 2) == [1, 2, 3, [4]]`, true},
+		{`flatten([[1], [2]], 0) == [[1], [2]]`, true},
+		{`flatten(1)`, "argument to `flatten` must be ARRAY, got INTEGER"},
+		{`flatten([1], "all")`, "depth of `flatten` must be INTEGER, got STRING"},
+		{`zip([1, 2, 3], ["a", "b"]) == [[1, "a"], [2, "b"]]`, true},
+		{`zip([], [1]) == []`, true},
+		{`zip([1], "a")`, "arguments to `zip` must be ARRAY, got STRING"},
+		{`parse_int("0x10") == {"ok": true, "value": 16, "error": parse_int("1").error}`, true},
+		{`parse_int("ten") == {"ok": false, "value": parse_int("1").error, "error": 'invalid integer "ten"'}`, true},
+		{`parse_int("-12").value + 2`, -10},
+		{`parse_int(12)`, "argument to `parse_int` must be STRING, got INTEGER"},
+		{`let t = parse_time("2024-03-05 14:07:09", "2006-01-02 15:04:05"); [t.year(), t.month(), t.day(), t.hour(), t.minute(), t.second(), t.weekday()] == [2024, 3, 5, 14, 7, 9, 2]`, true},
+		{`let t = parse_time("2024-03-05", "2006-01-02"); format(add(t, 86400000), "Jan 2, 2006") == "Mar 6, 2024"`, true},
+		{`let t = parse_time("2024-03-05", "2006-01-02"); t.add(-1500).millisecond()`, 500},
+		{`parse_time("1970-01-01T00:00:01Z", "2006-01-02T15:04:05Z07:00").unix()`, 1000},
+		{`parse_time("2024", "2006") == parse_time("2024-01-01", "2006-01-02")`, true},
+		{`now().year() > 2000`, true},
+		{`parse_time("soon", "2006")`, "could not parse time: parsing time \"soon\" as \"2006\": cannot parse \"soon\" as \"2006\""},
+		{`add(1, 2)`, "argument to `add` must be TIME, got INTEGER"},
+		{`now().format(1)`, "layout of `format` must be STRING, got INTEGER"},
+		{`csv_parse('a,b
+1,"x, y"') == [["a", "b"], ["1", "x, y"]]`, true},
+		{`csv_parse("name,age
+ann,3
+bob,4", {"header": true}) == [{"name": "ann", "age": "3"}, {"name": "bob", "age": "4"}]`, true},
+		{`csv_parse("", {"header": true}) == []`, true},
+		{`csv_parse("a,b
+1")`, "could not parse CSV: record on line 2: wrong number of fields"},
+		{`csv_parse("a", "header")`, "options to `csv_parse` must be HASH, got STRING"},
+		{`csv_encode([["a", "b c"], [1, true, "x,y"]]) == 'a,b c
+1,true,"x,y"
+'`, true},
+		{`csv_encode([1])`, "rows to `csv_encode` must be ARRAY, got INTEGER"},
+		{`b64_encode("hello, monkey") == "aGVsbG8sIG1vbmtleQ=="`, true},
+		{`b64_decode(b64_encode("")) == ""`, true},
+		{`b64_decode("aGk=") == "hi"`, true},
+		{`b64_decode("a")`, "invalid base64: illegal base64 data at input byte 0"},
+		{`hex_encode("hi!") == "686921"`, true},
+		{`hex_decode("686921") == "hi!"`, true},
+		{`hex_decode("6z")`, "invalid hex: encoding/hex: invalid byte: U+007A 'z'"},
+		{`hex_encode(1)`, "argument to `hex_encode` must be STRING, got INTEGER"},
+		{`chars("héllo") == ["h", "é", "l", "l", "o"]`, true},
+		{`chars("") == []`, true},
+		{`"ab".chars().reverse() == ["b", "a"]`, true},
+		{`chars(1)`, "argument to `chars` must be STRING, got INTEGER"},
+		{`slice("héllo", 1, 3) == "él"`, true},
+		{`"héllo".slice(-2, 10) == "lo"`, true},
+		{`slice(1, 0, 1)`, "argument to `slice` must be ARRAY or STRING, got INTEGER"},
 	}
 
 	for _, tt := range tests {
@@ -364,6 +560,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
 		case string:
 			errObj, ok := evaluated.(*object.Error)
 			if !ok {
@@ -378,202 +576,1222 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
-func TestArrayLiterals(t *testing.T) {
-	input := "[1 + 2, 10, true]"
+func TestFiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.txt")
+	os.WriteFile(filename, []byte("one\r\ntwo\nthree"), 0644)
 
-	evaluated := testEval(input)
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let f = open(path); [f.read_line(), f.read_line(), read_line(f), f.read_line()] == ["one", "two", "three", f.read_line()]`, true},
+		{"let f = open(path, \"a\"); f.write(\"\nfour\"); f.close(); let f = open(path); f.read_line(); f.read_line(); f.read_line(); f.read_line()", "four"},
+		{`let f = open(path, "w"); write(f, "new"); close(f); open(path).read_line()`, "new"},
+		{`let f = open(path); f.close(); f.read_line()`, "read from closed file " + filename},
+		{`let f = open(path); f.close(); f.close()`, "close of closed file " + filename},
+		{`open(path, "x")`, `unknown file mode "x", want r, w or a`},
+		{`open(path + "/missing")`, "open " + filename + "/missing: not a directory"},
+		{`open(path).write(1)`, "text to `write` must be STRING, got INTEGER"},
+		{`close(1)`, "argument to `close` must be CHANNEL or FILE, got INTEGER"},
+	}
 
-	array, ok := evaluated.(*object.Array)
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Set("path", &object.String{Value: filename})
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
 
-	if !ok {
-		t.Fatalf("Expected array object, got %T (%+v)", evaluated, evaluated)
-	}
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, expected, str.Value)
+				}
+				continue
+			}
 
-	if len(array.Elements) != 3 {
-		t.Fatalf("Expected three elements, got %d", len(array.Elements))
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: expected %q, got %T (%+v)", tt.input, expected, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. want=%q, got=%q", expected, errObj.Message)
+			}
+		}
 	}
-
-	testIntegerObject(t, array.Elements[0], 3)
-	testIntegerObject(t, array.Elements[1], 10)
-	testBooleanObject(t, array.Elements[2], true)
 }
 
-func TestArrayIndexExpressions(t *testing.T) {
+func TestDirectories(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "b.txt"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "a.txt"), nil, 0644)
+
 	tests := []struct {
 		input    string
 		expected any
 	}{
-		{
-			"[1,2,3][0]",
-			1,
-		},
-		{
-			"[1,2,3][1]",
-			2,
-		},
-		{
-			"[1,2,3][2]",
-			3,
-		},
-		{
-			"[1,2,3][1 + 1]",
-			3,
-		},
-		{
-			"let x = [1,2,3]; x[2]",
-			3,
-		},
-		{
-			"let x = [1,2,3]; x[0] + x[1] + x[2];",
-			6,
-		},
-		{
-			"let i = 0; [1][i]",
-			1,
-		},
-		{
-			// Invalid access over
-			"[1,2,3][3]",
-			nil,
-		},
-		{
-			// Invalid access under
-			"[1,2,3][-1]",
-			nil,
-		},
+		{`list_dir(dir) == ["a.txt", "b.txt"]`, true},
+		{`exists(path_join(dir, "a.txt"))`, true},
+		{`exists(path_join(dir, "c.txt"))`, false},
+		{`mkdir(path_join(dir, "x", "y")); mkdir(path_join(dir, "x", "y")); list_dir(path_join(dir, "x")) == ["y"]`, true},
+		{`path_join("a", "b/", "../c") == "a/c"`, true},
+		{`path_join() == ""`, true},
+		{`path_join("a", 1)`, "arguments to `path_join` must be STRING, got INTEGER"},
+		{`list_dir(path_join(dir, "missing"))`, "open " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		{`exists(1)`, "argument to `exists` must be STRING, got INTEGER"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-
-		integer, ok := tt.expected.(int)
+		env := object.NewEnvironment()
+		env.Set("dir", &object.String{Value: dir})
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
 
-		if ok {
-			// Check for integer value
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			// check for nil
-			if evaluated != NULL {
-				testNullObject(t, evaluated)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: expected error, got %T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. want=%q, got=%q", expected, errObj.Message)
 			}
 		}
 	}
 }
 
-func TestHashIndexExpressions(t *testing.T) {
+func TestProcessInfo(t *testing.T) {
+	wd, _ := os.Getwd()
+	hostname, _ := os.Hostname()
+
 	tests := []struct {
 		input    string
-		expected any
+		expected string
 	}{
-		{
-			`{"foo": 5}["foo"]`,
-			5,
-		},
-		{
-			`{"foo": 5}["bar"]`,
-			nil,
-		},
-		{
-			`let key = "foo"; {"foo": 5}[key]`,
-			5,
-		},
-		{
-			`{}["foo"]`,
-			nil,
-		},
-		{
-			`{5: 5}[5]`,
-			5,
-		},
-		{
-			`{true: 5}[true]`,
-			5,
-		},
-		{
-			`{false: 5}[false]`,
-			5,
-		},
+		{`cwd()`, wd},
+		{`hostname()`, hostname},
+		{`platform()`, runtime.GOOS},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		str, ok := testEval(tt.input).(*object.String)
+		if !ok {
+			t.Errorf("%s: expected a STRING", tt.input)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
 
-		integer, ok := tt.expected.(int)
+	testIntegerObject(t, testEval(`pid()`), int64(os.Getpid()))
+}
 
-		if ok {
-			// int test case
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			// null test case
-			testNullObject(t, evaluated)
+func TestUUID(t *testing.T) {
+	format := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
 
-		}
+	str, ok := testEval(`uuid()`).(*object.String)
+	if !ok || !format.MatchString(str.Value) {
+		t.Fatalf("expected a version 4 UUID, got %+v", str)
 	}
+
+	testBooleanObject(t, testEval(`uuid() == uuid()`), false)
 }
 
-func TestHashLiterals(t *testing.T) {
-	input := `let two = "two";
-		{
-			"one": 10 - 9,
-			two: 1 + 1,
-			"thr" + "ee": 6 / 2,
-			4: 4,
-			true: 5,
-			false: 6
-		}`
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let find = fn(x) { if (x > 0) { x } else { error("not found") } }; is_error(find(0))`, true},
+		{`let find = fn(x) { if (x > 0) { x } else { error("not found") } }; find(0).message()`, "not found"},
+		{`let e = error("bad"); let x = 1; x + 1`, 2},
+		{`is_error(1)`, false},
+		{`error("a") == error("a")`, true},
+		{`error("a") == error("b")`, false},
+		{`error(1)`, "argument to `error` must be STRING, got INTEGER"},
+	}
 
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Hash)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
 
-	if !ok {
-		t.Fatalf("Expected hash, got %T (%+v)", evaluated, evaluated)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. want=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("%s: expected %q, got %T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	if got := testEval(`error("oops")`).Inspect(); got != "error: oops" {
+		t.Errorf("wrong inspection. want=%q, got=%q", "error: oops", got)
 	}
+}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Expected %d pairs, got %d", len(expected), len(result.Pairs))
+func TestListBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`builtins()[0] == {"name": "len", "arity": 1}`, true},
+		{`builtins()[1].arity == -1`, true},
+		{`len(builtins()) > 20`, true},
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
 
-		if !ok {
-			t.Errorf("no pair for key in Pairs")
+	// Every builtin is available to programs
+	for _, def := range object.Builtins {
+		if builtins[def.Name] != def.Builtin {
+			t.Errorf("builtin %s is not registered", def.Name)
 		}
-
-		testIntegerObject(t, pair.Value, expectedValue)
 	}
 }
 
-func testNullObject(t *testing.T, evaluated object.Object) bool {
-	if evaluated != NULL {
-		t.Errorf("object is not NULL. Got %T (%+v)", evaluated, evaluated)
-		return false
+func TestFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`frozen([1])`, false},
+		{`frozen({})`, false},
+		{`frozen(freeze([1]))`, true},
+		{`let a = [{"b": [1]}]; freeze(a); frozen(a[0]["b"])`, true},
+		{`let h = {"a": [2]}; freeze(h); frozen(h["a"])`, true},
+		{`frozen(push(freeze([1]), 2))`, false},
+		{`frozen(1)`, true},
+		{`freeze([1, 2]) == [1, 2]`, true},
 	}
-	return true
-}
 
-func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
-	result, ok := obj.(*object.Boolean)
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
 
-	if !ok {
-		t.Errorf("Expected object to be Boolean, got %T", obj)
-		return false
+func TestMemoize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		// Far too slow without the cache
+		{`let fib = memoize(fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } }); fib(80)`, 23416728348467685},
+		{`let calls = chan(10); let f = memoize(fn(x) { send(calls, x); x }); f(1); f(1); f(2); close(calls); let seen = [recv(calls), recv(calls)]; if (recv(calls)) { false } else { seen == [1, 2] }`, true},
+		{`let f = memoize(fn(a, b) { a - b }); f(3, 1) + f(1, 3)`, 0},
+		{`memoize(len)("abc")`, 3},
+		{`memoize(1)`, "argument to `memoize` must be FUNCTION, got INTEGER"},
+		{`memoize(fn(x) { x })([1])`, "unusable as memoize key: ARRAY"},
 	}
 
-	if result.Value != expected {
-		t.Errorf("Expected value to be %t, got %t", expected, result.Value)
-		return false
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
 
-	}
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`"four".len()`, 4},
+		{`"four".startsWith("fo") == "four".endsWith("ur")`, true},
+		{`[1, 2, 3].len()`, 3},
+		{`[1, 2].push(3) == [1, 2, 3]`, true},
+		{`[1, 2, 3].slice(0, 2).concat([0]).reverse() == [0, 2, 1]`, true},
+		{`[1, 2].zip([3, 4]).flatten() == [1, 3, 2, 4]`, true},
+		{`[1, 2, 3].rest().first()`, 2},
+		{`let a = [1, 2]; a.push(3).last() + a.len()`, 5},
+		{`{"b": 2, "a": 1}.keys() == ["a", "b"]`, true},
+		{`{2: "b", 1: "a", true: "c"}.values() == ["c", "a", "b"]`, true},
+		{`{"a": 1}.len()`, 1},
+		{`let f = fn(x) { x.len() }; f("ab") + f([1])`, 3},
+		{`let person = {"name": "Ada"}; person.name == "Ada"`, true},
+		{`let person = {"langs": ["go", "monkey"]}; person.langs[1].len()`, 6},
+		{`{"a": {"b": 1}}.a.b`, 1},
+		{`[1].a`, "index operator not supported: ARRAY at 1:1"},
+		{`let Point = fn(x, y) { {"x": x, "y": y, "norm": fn(self) { self.x * self.x + self.y * self.y }} }; Point(3, 4).norm()`, 25},
+		{`let counter = {"n": 2, "add": fn(self, k) { self.n + k }, "twice": fn(self) { self.add(self.n) }}; counter.twice()`, 4},
+		{`{"len": fn(self) { 42 }}.len()`, 42},
+		{`{"keys": 1}.keys().len()`, 1},
+		{`{"size": len}.size()`, 1},
+		{`{"f": fn() { 1 }}.f()`, "wrong number of arguments: want=0, got=1"},
+		{`"s".len(1)`, "wrong number of arguments. got=2, want=1"},
+		{"let x = 1;\nx.len()", "INTEGER has no method len at 2:1"},
+		{`let f = fn() { 1 }; f.len()`, "FUNCTION has no method len at 1:21"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Error() != expected && errObj.Message != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Error())
+			}
+		}
+	}
+}
+
+func TestNullSafeAccess(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let config = {"server": {"port": 80}}; config?.server?.port`, 80},
+		{`let config = {}; config?.server?.port`, nil},
+		{`let config = {}; config.server?.port`, nil},
+		{`let xs = {}.items; xs?[0]`, nil},
+		{`let xs = [[1, 2]]; xs?[0]?[1]`, 2},
+		{`{}.name?.len()`, nil},
+		{`"four"?.len()`, 4},
+		{`{}.x?[missing]`, nil},
+		{`{}.x?.len(missing)`, nil},
+		{`{}.a?.b.c`, "index operator not supported: NULL at 1:1"},
+		{`1?.len()`, "INTEGER has no method len at 1:1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Error() != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Error())
+			}
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`match (2) { 1 => 10, 2 => 20 }`, 20},
+		{`match (-1) { -1 => 1, _ => 2 }`, 1},
+		{`match ("b") { "a" => 1, x => x.len() }`, 1},
+		{`match (true) { false => 1, true => 2 }`, 2},
+		{`match (3) { 1 => 10 }`, nil},
+		{`match ([1, 2, 3]) { [a, b] => 0, [a, b, c] => a + b + c }`, 6},
+		{`match ([1, 2, 3]) { [h, ...t] => t.len() * 10 + h }`, 21},
+		{`match ([]) { [h, ...t] => 1, [] => 2 }`, 2},
+		{`match ([1, [2, 3]]) { [_, [x, 3]] => x }`, 2},
+		{`match ({"name": "Ada", "age": 36}) { {name: n} => n.len() }`, 3},
+		{`match ({"name": "Ada"}) { {"age": a} => a, {name: "Ada"} => 1 }`, 1},
+		{`match ({1: 2}) { {1: x} => x }`, 2},
+		{`match (5) { n if n > 10 => 1, n if n > 1 => 2, _ => 3 }`, 2},
+		{`match ("s") { [x] => x, {x: x} => x }`, nil},
+		{`match (1) { x => x }; x`, 1},
+		{`let sum = fn(arr) { match (arr) { [] => 0, [h, ...t] => h + sum(t) } }; sum([1, 2, 3, 4])`, 10},
+		{`let f = fn(p) { match (p) { [x, y] if x == y => "same", [x, _] => x } }; f([1, 1]).len() + f([2, 3])`, 6},
+		{`match (1) { x if y => x }`, `identifier not found: "y" at 1:18`},
+		{`...[1]`, "unexpected spread ...[1] at 1:1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Error() != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Error())
+			}
+		}
+	}
+}
+
+func TestSpread(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let xs = [2, 3]; [1, ...xs, 4] == [1, 2, 3, 4]`, true},
+		{`[...[], ...[1], ...[]] == [1]`, true},
+		{`let xs = [1]; let ys = [...xs]; push(ys, 2); len(xs)`, 1},
+		{`let add = fn(a, b, c) { a + b * c }; add(...[1, 2, 3])`, 7},
+		{`let add = fn(a, b, c) { a + b * c }; add(1, ...[2], 3)`, 7},
+		{`len(...["four"])`, 4},
+		{`[1].push(...[2]) == [1, 2]`, true},
+		{`let f = fn(a, b) { a }; f(...[1])`, "wrong number of arguments: want=2, got=1 at 1:25"},
+		{`[1, ...2]`, "spread operator not supported: INTEGER at 1:5"},
+		{`{"a": ...[1]}`, "unexpected spread ...[1] at 1:7"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Error() != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Error())
+			}
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let sub = fn(a, b) { a - b }; sub(b: 1, a: 5)`, 4},
+		{`let sub = fn(a, b) { a - b }; sub(5, b: 1)`, 4},
+		{`let greet = fn(name, excited) { if (excited) { name + "!" } else { name } }; greet("Ann", excited: true) == "Ann!"`, true},
+		{`let f = fn(a, b, c) { [a, b, c] }; f(1, c: 3, b: 2) == [1, 2, 3]`, true},
+		{`let f = fn(a) { a }; f(b: 1)`, "unknown argument b at 1:22"},
+		{`let f = fn(a) { a }; f(1, a: 2)`, "duplicate argument a at 1:22"},
+		{`let f = fn(a, b) { a }; f(b: 2)`, "missing argument a at 1:25"},
+		{`let f = fn(a) { a }; f(1, 2, a: 3)`, "wrong number of arguments: want=1, got=3 at 1:22"},
+		{`len(x: "s")`, "named arguments not supported: BUILTIN at 1:1"},
+		{`let f = fn(a) { a }; f(a: g)`, `identifier not found: "g" at 1:27`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Error() != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Error())
+			}
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1 + 2, 10, true]"
+
+	evaluated := testEval(input)
+
+	array, ok := evaluated.(*object.Array)
+
+	if !ok {
+		t.Fatalf("Expected array object, got %T (%+v)", evaluated, evaluated)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("Expected three elements, got %d", len(array.Elements))
+	}
+
+	testIntegerObject(t, array.Elements[0], 3)
+	testIntegerObject(t, array.Elements[1], 10)
+	testBooleanObject(t, array.Elements[2], true)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{
+			"[1,2,3][0]",
+			1,
+		},
+		{
+			"[1,2,3][1]",
+			2,
+		},
+		{
+			"[1,2,3][2]",
+			3,
+		},
+		{
+			"[1,2,3][1 + 1]",
+			3,
+		},
+		{
+			"let x = [1,2,3]; x[2]",
+			3,
+		},
+		{
+			"let x = [1,2,3]; x[0] + x[1] + x[2];",
+			6,
+		},
+		{
+			"let i = 0; [1][i]",
+			1,
+		},
+		{
+			// Invalid access over
+			"[1,2,3][3]",
+			nil,
+		},
+		{
+			// Invalid access under
+			"[1,2,3][-1]",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			// Check for integer value
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			// check for nil
+			if evaluated != NULL {
+				testNullObject(t, evaluated)
+			}
+		}
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`"héllo"[1] == "é"`, true},
+		{`"héllo"[4] == "o"`, true},
+		{`let s = "abc"; s[len(s) - 1] == "c"`, true},
+		{`"héllo"[5]`, nil},
+		{`"abc"[-1]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if expected, ok := tt.expected.(bool); ok {
+			testBooleanObject(t, evaluated, expected)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{
+			`{"foo": 5}["foo"]`,
+			5,
+		},
+		{
+			`{"foo": 5}["bar"]`,
+			nil,
+		},
+		{
+			`let key = "foo"; {"foo": 5}[key]`,
+			5,
+		},
+		{
+			`{}["foo"]`,
+			nil,
+		},
+		{
+			`{5: 5}[5]`,
+			5,
+		},
+		{
+			`{true: 5}[true]`,
+			5,
+		},
+		{
+			`{false: 5}[false]`,
+			5,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			// int test case
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			// null test case
+			testNullObject(t, evaluated)
+
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+		{
+			"one": 10 - 9,
+			two: 1 + 1,
+			"thr" + "ee": 6 / 2,
+			4: 4,
+			true: 5,
+			false: 6
+		}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+
+	if !ok {
+		t.Fatalf("Expected hash, got %T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Expected %d pairs, got %d", len(expected), len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+
+		if !ok {
+			t.Errorf("no pair for key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func testNullObject(t *testing.T, evaluated object.Object) bool {
+	if evaluated != NULL {
+		t.Errorf("object is not NULL. Got %T (%+v)", evaluated, evaluated)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+
+	if !ok {
+		t.Errorf("Expected object to be Boolean, got %T", obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("Expected value to be %t, got %t", expected, result.Value)
+		return false
+
+	}
 
 	return true
 }
+
+func TestSleep(t *testing.T) {
+	start := time.Now()
+	if evaluated := testEval(`sleep(20)`); evaluated != NULL {
+		t.Fatalf("expected NULL, got %+v", evaluated)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("slept for only %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	evaluated := EvalContext(ctx, parser.New(lexer.New(`sleep(60000); 1`)).ParseProgram(), object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("Expected error, got %T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "execution interrupted: context deadline exceeded" {
+		t.Errorf("wrong error message, got %q", errObj.Message)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("sleep wasn't interrupted, took %s", elapsed)
+	}
+}
+
+func TestEvalContextCancellation(t *testing.T) {
+	input := `
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	fib(30);
+	`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	evaluated := EvalContext(ctx, program, object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("Expected error, got %T(%+v)", evaluated, evaluated)
+	}
+
+	if errObj.Message != "execution interrupted: context canceled" {
+		t.Errorf("wrong error message, got %q", errObj.Message)
+	}
+}
+
+func TestMaxRecursionDepth(t *testing.T) {
+	input := `
+	let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } };
+	depth(%d);
+	`
+
+	tests := []struct {
+		n        int
+		maxDepth int
+		expected any
+	}{
+		{50, 100, 50},
+		{99, 100, 99},
+		{100, 100, "maximum recursion depth exceeded"},
+		{5000, 0, 5000},
+		{object.DefaultMaxDepth, 0, "maximum recursion depth exceeded"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(fmt.Sprintf(input, tt.n))).ParseProgram()
+
+		ctx := context.Background()
+		if tt.maxDepth > 0 {
+			ctx = object.WithMaxDepth(ctx, tt.maxDepth)
+		}
+
+		evaluated := EvalContext(ctx, program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`[1, 2][1]`, 2},
+		{`[1, 2][2]`, "index 2 out of range for ARRAY of length 2"},
+		{`"ab"[-1]`, "index -1 out of range for STRING of length 2"},
+		{`{"a": 1}["b"]`, nil},
+		{`if (1 < 2) { 1 } else { 2 }`, 1},
+		{`if (1) { 1 }`, "condition must be BOOLEAN in strict mode, got INTEGER"},
+		{`match (1) { x if x => x }`, "condition must be BOOLEAN in strict mode, got INTEGER"},
+		{`if (false) { missing }`, `identifier not found: "missing"`},
+		{`let f = fn() { later }; let later = 3; f()`, 3},
+		{`match ([1]) { [x] => x }`, 1},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(object.WithStrict(context.Background()), program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+
+	// Programs that aren't strict carry on
+	testNullObject(t, testEval(`[1, 2][2]`))
+	testIntegerObject(t, testEval(`if (1) { 1 }`), 1)
+}
+
+func TestCheckTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let add = fn(x: int, y: int) -> int { x + y }; add(1, 2)`, 3},
+		{`let add = fn(x: int, y: int) -> int { x + y }; add(1, "2")`, "argument y to `add` must be int, got STRING"},
+		{`let f = fn(x, s: string) { x }; f(true, "s"); f(1, 2)`, "argument s to `f` must be string, got INTEGER"},
+		{`fn(x: any, f: fn, n: null) { x }(1, len, puts())`, 1},
+		{`let f = fn(x) -> int { if (x) { return "a" } 1 }; f(false)`, 1},
+		{`let f = fn(x) -> int { if (x) { return "a" } 1 }; f(true)`, "`f` must return int, got STRING"},
+		{`fn() -> int { }()`, "function must return int, got NULL"},
+		{`fn(x: number) { x }(1)`, "unknown type number"},
+		{`fn(x: int) { x + true }(1)`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(object.WithCheckTypes(context.Background()), program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+
+	// Annotations aren't checked unless asked to be
+	testIntegerObject(t, testEval(`fn(x: string) -> string { x }(1)`), 1)
+}
+
+func TestBigInt(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType object.ObjectType
+		expected     string
+	}{
+		{"9223372036854775807 + 1", object.BIGINT_OBJ, "9223372036854775808"},
+		{"-9223372036854775807 - 2", object.BIGINT_OBJ, "-9223372036854775809"},
+		{"4294967296 * 4294967296", object.BIGINT_OBJ, "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", object.BIGINT_OBJ, "9223372036854775808"},
+		{"(9223372036854775807 + 1) - 1", object.BIGINT_OBJ, "9223372036854775807"},
+		{`bigint(5)`, object.BIGINT_OBJ, "5"},
+		{`bigint("-123456789012345678901234567890") / 10`, object.BIGINT_OBJ, "-12345678901234567890123456789"},
+		{`bigint(5) == 5`, object.BOOLEAN_OBJ, "true"},
+		{`5 != bigint(5)`, object.BOOLEAN_OBJ, "false"},
+		{`9223372036854775807 < 9223372036854775807 + 1`, object.BOOLEAN_OBJ, "true"},
+		{`bigint(2) > 3`, object.BOOLEAN_OBJ, "false"},
+		{`{bigint(1): "one"}[1]`, object.STRING_OBJ, `"one"`},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)`,
+			object.BIGINT_OBJ, "15511210043330985984000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Type() != tt.expectedType || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s %s, got=%s %s",
+				tt.input, tt.expectedType, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN at 1:1"},
+		{"let x = 1;\nlet y = -true;", "unknown operator: -BOOLEAN at 2:9"},
+		{"let f = fn() {\n  foobar\n};\nf()", `identifier not found: "foobar" at 2:3`},
+		{`len(1)`, "argument to `len` not supported, got INTEGER at 1:1"},
+		{`{"a": 1}[fn(x) { x }]`, "unusable as hash key: FUNCTION at 1:1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errObj.Error())
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	input := `let inner = fn(x) { x + true };
+let outer = fn(x) {
+  inner(x)
+};
+let apply = fn(f) { f(1) };
+apply(outer);`
+
+	evaluated := testEval(input)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.StackFrame{
+		{Function: "inner", Pos: token.Position{Line: 3, Column: 3}},
+		{Function: "outer", Pos: token.Position{Line: 5, Column: 21}},
+		{Function: "apply", Pos: token.Position{Line: 6, Column: 1}},
+	}
+
+	if !reflect.DeepEqual(errObj.Stack, expected) {
+		t.Errorf("wrong stack.\nexpected=%v\ngot=     %v", expected, errObj.Stack)
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/math.monkey":   `let square = fn(x) { x * x }; let _secret = 1; let table = {"one": 1}; let scale = (import "./scale").factor;`,
+		"lib/scale.monkey":  `let factor = 3;`,
+		"cycle/a.monkey":    `let b = import "./b";`,
+		"cycle/b.monkey":    `let a = import "./a";`,
+		"broken.monkey":     `let x = 1 + true;`,
+		"unparsed.monkey":   `let = 1;`,
+		"lib/shadow.monkey": `let len = fn(x) { 0 }; let n = len("abc");`,
+	}
+	for name, text := range files {
+		filename := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(filename), 0755)
+		if err := os.WriteFile(filename, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let m = import "lib/math"; m.square(4)`, 16},
+		{`let m = import "lib/math.monkey"; m.scale`, 3},
+		{`let m = import "lib/math"; m["table"].one`, 1},
+		{`import "lib/math" == import "./lib/math"`, true},
+		{`(import "lib/shadow").n + len("abc")`, 3},
+		{`(import "lib/math")._secret`, "module lib/math has no export _secret"},
+		{`(import "lib/math").cube(2)`, "module lib/math has no export cube"},
+		{`import "cycle/a"`, "cannot import \"./a\": import cycle through " + filepath.Join(dir, "cycle/a.monkey")},
+		{`import "broken"`, "type mismatch: INTEGER + BOOLEAN"},
+		{`import "missing"`, "cannot import \"missing\": open " + filepath.Join(dir, "missing.monkey") + ": no such file or directory"},
+		{`import "unparsed"`, "cannot import \"unparsed\": " + filepath.Join(dir, "unparsed.monkey") + ":1:5: unexpected '=', expected a name"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.NewWithFilename(filepath.Join(dir, "main.monkey"), tt.input)).ParseProgram()
+		ctx := module.WithLoader(context.Background(), module.NewLoader(dir))
+		evaluated := EvalContext(ctx, program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error returned. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestAssert(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`assert(true); 1`, 1},
+		{`assert(1); 2`, 2},
+		{`assert(false); 1`, "assertion failed"},
+		{`assert(1 > 2, "one is not bigger"); 1`, "assertion failed: one is not bigger"},
+		{`let f = fn() { assert(false, "in f"); 1 }; f(); 2`, "assertion failed: in f"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestWrongNumberOfArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn(a, b) { a }(1)`, "wrong number of arguments: want=2, got=1"},
+		{`fn() { 1 }(1, 2)`, "wrong number of arguments: want=0, got=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. Expected %q, got %q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestMemoryStats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let f = fn(x) { x }; let before = memory_stats().environments; f(1); f(2); memory_stats().environments - before`, 2},
+		{`if (memory_stats().objects_allocated > 0) { 1 } else { 0 }`, 1},
+		// The evaluator has no stack or constants to report on
+		{`memory_stats().stack_high_water`, 0},
+		{`memory_stats().constants`, 0},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`wait(spawn(fn() { 1 + 2 }))`, 3},
+		{`let add = fn(a, b) { a + b }; wait(spawn(add, 40, 2))`, 42},
+		{
+			`
+			let fib = fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } };
+			let tasks = [spawn(fib, 10), spawn(fib, 15), spawn(fib, 20)];
+			wait(tasks[0]) + wait(tasks[1]) + wait(tasks[2]);
+			`,
+			55 + 610 + 6765,
+		},
+		{`wait(spawn(fn(a) { a }))`, "wrong number of arguments: want=1, got=0"},
+		{`wait(1)`, "argument to `wait` must be TASK, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let ch = chan(1); send(ch, 5); recv(ch)`, 5},
+		{
+			`
+			let ch = chan();
+			let produce = fn(n) { if (n > 0) { send(ch, n); produce(n - 1) } else { close(ch) } };
+			let sum = fn(total) { let v = recv(ch); if (v) { sum(total + v) } else { total } };
+			spawn(produce, 10);
+			sum(0);
+			`,
+			55,
+		},
+		{`let ch = chan(); close(ch); recv(ch)`, nil},
+		{`let ch = chan(1); close(ch); send(ch, 1)`, "send on closed channel"},
+		{`let ch = chan(); close(ch); close(ch)`, "close of closed channel"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. Expected %q, got %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	program := parser.New(lexer.New(`
+	let fib = fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } };
+	fib(20);
+	`)).ParseProgram()
+
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
+// Counting stays within the small Integers, which aren't allocated
+func BenchmarkCount(b *testing.B) {
+	program := parser.New(lexer.New(`
+	let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };
+	count(1000);
+	`)).ParseProgram()
+
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
+func FuzzEval(f *testing.F) {
+	seeds := []string{
+		`let x = 5; let y = x * (2 + 3); return -y;`,
+		`if (x < 10) { "small" } else { "big" }`,
+		`let f = fn(a, b) { a + b }; f(1, b: 2); f(...[1, 2]);`,
+		`[1, 2, 3][0]; {"a": 1, true: [2]}["a"]; "s".len();`,
+		`match ([1, 2]) { [a, ...rest] if a > 1 -> rest, {"k": v} -> v, _ -> null }`,
+		`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10);`,
+		`let c = chan(1); send(c, "x"); recv(c); wait(spawn(fn() { 1 }));`,
+		`first(rest(push([1], 2))); keys(freeze({"a": 1})); bigint("123") * 2;`,
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+
+		// Programs may recurse without end or wait on channels forever
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		ctx = object.WithOutput(object.WithMaxDepth(ctx, 100), io.Discard)
+
+		EvalContext(ctx, program, object.NewEnvironment())
+	})
+}
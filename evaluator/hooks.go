@@ -0,0 +1,34 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Hooks observe an evaluation as it runs, for tools like profilers,
+// debuggers and coverage reports. Any may be nil. Functions started with
+// spawn call them from their own goroutines.
+type Hooks struct {
+	// Enter is called before node is evaluated in env
+	Enter func(node ast.Node, env *object.Environment)
+	// Exit is called once node has been evaluated to result, which is an
+	// *object.ReturnValue while a return statement unwinds
+	Exit func(node ast.Node, result object.Object)
+	// Call is called as fn starts running, and the function it returns, if
+	// not nil, once fn has returned
+	Call func(fn *object.FunctionValue) func()
+}
+
+type hooksKey struct{}
+
+// WithHooks returns a copy of ctx in which EvalContext and Apply call hooks
+// on entry to and exit from each node.
+func WithHooks(ctx context.Context, hooks *Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, hooks)
+}
+
+func hooksFrom(ctx context.Context) *Hooks {
+	hooks, _ := ctx.Value(hooksKey{}).(*Hooks)
+	return hooks
+}
@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// condition reports whether obj, the value of an if condition or match
+// guard, counts as true. In strict mode it must be a boolean.
+func (e *evaluation) condition(obj object.Object) (bool, *object.Error) {
+	if e.strict && obj.Type() != object.BOOLEAN_OBJ {
+		return false, newError("condition must be BOOLEAN in strict mode, got %s", obj.Type())
+	}
+
+	return isTruthy(obj), nil
+}
+
+// outOfRange returns the error indexing left, an array or string, with
+// index fails with in strict mode, or nil if the index is in range or the
+// mode is off.
+func (e *evaluation) outOfRange(left object.Object, index int64) *object.Error {
+	if !e.strict {
+		return nil
+	}
+
+	length := 0
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = left.Len()
+	}
+
+	if index < 0 || index >= int64(length) {
+		return newError("index %d out of range for %s of length %d", index, left.Type(), length)
+	}
+	return nil
+}
+
+// undeclared returns an error for the first name program uses that's bound
+// neither by it nor in env, nor is a builtin, so strict mode catches it even
+// in code that doesn't run, as the compiler does.
+func undeclared(program *ast.Program, env *object.Environment) *object.Error {
+	declared := map[string]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			// Names bound inside functions are local to them
+			return false
+		case *ast.LetStatement:
+			if node.Name != nil {
+				declared[node.Name.Value] = true
+			}
+		case *ast.MatchExpression:
+			for _, arm := range node.Arms {
+				for _, name := range ast.PatternBindings(arm.Pattern) {
+					declared[name.Value] = true
+				}
+			}
+		}
+		return true
+	})
+
+	var err *object.Error
+	ast.Inspect(program, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Identifier)
+		if err != nil || !ok || ident.Binding.Scope != ast.Global || declared[ident.Value] {
+			return err == nil
+		}
+
+		if _, ok := env.Get(ident.Value); ok {
+			return true
+		}
+		if _, ok := builtins[ident.Value]; ok {
+			return true
+		}
+
+		err = newError("identifier not found: %q", ident.Value)
+		err.Pos = ident.Pos()
+		return false
+	})
+
+	return err
+}
@@ -0,0 +1,405 @@
+// Package format prints Monkey programs in their canonical layout.
+package format
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"strconv"
+	"strings"
+)
+
+const indentUnit = "  "
+
+// Blocks holding a single expression stay on one line up to this width,
+// e.g. fn(a, b) { a + b }
+const maxInlineBlock = 50
+
+// Operator precedences, mirroring the parser's
+const (
+	_ int = iota
+	lowest
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	call
+	index
+	atom
+)
+
+var precedences = map[string]int{
+	"==": equals,
+	"!=": equals,
+	"<":  lessGreater,
+	">":  lessGreater,
+	"+":  sum,
+	"-":  sum,
+	"/":  product,
+	"*":  product,
+}
+
+// Source parses src and returns it in canonical form. Parse errors are
+// returned instead of a partially formatted program.
+func Source(src string) (string, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if errs := p.Errors(); len(errs) != 0 {
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return "", fmt.Errorf("%s", strings.Join(msgs, "\n"))
+	}
+
+	return Program(program), nil
+}
+
+// Program prints program with one statement per line, two space indentation,
+// and a semicolon after every statement not ending in a block. Comments in
+// program.Comments are printed on their own line before the node they were
+// attached to.
+func Program(program *ast.Program) string {
+	p := &printer{comments: program.Comments}
+
+	for i, stmt := range program.Statements {
+		// Separate multi-line statements from their neighbours
+		if i > 0 && (p.multiline(program.Statements[i-1]) || p.multiline(stmt)) {
+			p.out.WriteString("\n")
+		}
+
+		p.statement(stmt)
+	}
+
+	p.printComments(program)
+	return p.out.String()
+}
+
+// Node prints any node as source that parses back to the same tree. Programs
+// print as with Program; statements and expressions print without a trailing
+// newline, e.g. let x = a * (b + c);
+func Node(node ast.Node) string {
+	p := &printer{}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		return Program(node)
+	case ast.Statement:
+		p.statement(node)
+	case ast.Expression:
+		p.expression(node, lowest)
+	}
+
+	return strings.TrimSuffix(p.out.String(), "\n")
+}
+
+type printer struct {
+	out      strings.Builder
+	depth    int
+	comments ast.CommentMap
+}
+
+func (p *printer) indent() {
+	p.out.WriteString(strings.Repeat(indentUnit, p.depth))
+}
+
+func (p *printer) printComments(node ast.Node) {
+	for _, c := range p.comments[node] {
+		p.indent()
+		p.out.WriteString(c.Text())
+		p.out.WriteString("\n")
+	}
+}
+
+// multiline reports whether stmt doesn't fit on a single line.
+func (p *printer) multiline(stmt ast.Statement) bool {
+	sub := &printer{comments: p.comments}
+	sub.statement(stmt)
+
+	return strings.Count(sub.out.String(), "\n") > 1
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	p.printComments(stmt)
+	p.indent()
+
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.out.WriteString("let " + stmt.Name.Value + " = ")
+		p.expression(stmt.Value, lowest)
+		p.out.WriteString(";")
+	case *ast.ReturnStatement:
+		p.out.WriteString("return")
+		if stmt.ReturnValue != nil {
+			p.out.WriteString(" ")
+			p.expression(stmt.ReturnValue, lowest)
+		}
+		p.out.WriteString(";")
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, lowest)
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.MatchExpression:
+		default:
+			p.out.WriteString(";")
+		}
+	case *ast.BlockStatement:
+		p.block(stmt, false)
+	}
+
+	p.out.WriteString("\n")
+}
+
+// block prints b starting at the current position. With inline set, a block
+// made of one short expression is printed as { expr }.
+func (p *printer) block(b *ast.BlockStatement, inline bool) {
+	if len(b.Statements) == 0 && len(p.comments[b]) == 0 {
+		p.out.WriteString("{}")
+		return
+	}
+
+	if inline {
+		if s, ok := p.inlineBlock(b); ok {
+			p.out.WriteString("{ " + s + " }")
+			return
+		}
+	}
+
+	p.out.WriteString("{\n")
+	p.depth++
+	for _, stmt := range b.Statements {
+		p.statement(stmt)
+	}
+	p.printComments(b)
+	p.depth--
+	p.indent()
+	p.out.WriteString("}")
+}
+
+// inlineIf reports whether both branches of an if/else fit on one line, as
+// in if (x) { a } else { b }. An if without else always gets its own lines.
+func (p *printer) inlineIf(exp *ast.IfExpression) bool {
+	if exp.Alternative == nil {
+		return false
+	}
+
+	consequence, ok := p.inlineBlock(exp.Consequence)
+	if !ok {
+		return false
+	}
+
+	alternative, ok := p.inlineBlock(exp.Alternative)
+	if !ok {
+		return false
+	}
+
+	return len(consequence)+len(alternative) <= maxInlineBlock
+}
+
+func (p *printer) inlineBlock(b *ast.BlockStatement) (string, bool) {
+	if len(b.Statements) != 1 || len(p.comments[b]) != 0 {
+		return "", false
+	}
+
+	stmt, ok := b.Statements[0].(*ast.ExpressionStatement)
+	if !ok || len(p.comments[stmt]) != 0 {
+		return "", false
+	}
+
+	sub := &printer{comments: p.comments}
+	sub.expression(stmt.Expression, lowest)
+	s := sub.out.String()
+
+	if strings.Contains(s, "\n") || len(s) > maxInlineBlock {
+		return "", false
+	}
+
+	return s, true
+}
+
+// expression prints exp, wrapping it in parentheses if it binds looser than
+// the surrounding context requires.
+func (p *printer) expression(exp ast.Expression, minPrecedence int) {
+	prec := precedenceOf(exp)
+	if prec < minPrecedence {
+		p.out.WriteString("(")
+		defer p.out.WriteString(")")
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.out.WriteString(exp.Value)
+	case *ast.IntegerLiteral:
+		// Trees built by hand may not carry the literal
+		if exp.Token.Literal != "" {
+			p.out.WriteString(exp.Token.Literal)
+		} else {
+			p.out.WriteString(strconv.FormatInt(exp.Value, 10))
+		}
+	case *ast.Boolean:
+		p.out.WriteString(fmt.Sprintf("%t", exp.Value))
+	case *ast.StringLiteral:
+		p.out.WriteString(quote(exp.Value))
+	case *ast.PrefixExpression:
+		p.out.WriteString(exp.Operator)
+		p.expression(exp.Right, prefix)
+	case *ast.InfixExpression:
+		// Operators are left associative, so only the right operand needs
+		// parentheses at the same precedence.
+		p.expression(exp.Left, prec)
+		p.out.WriteString(" " + exp.Operator + " ")
+		p.expression(exp.Right, prec+1)
+	case *ast.IfExpression:
+		p.out.WriteString("if (")
+		p.expression(exp.Condition, lowest)
+		p.out.WriteString(") ")
+
+		inline := p.inlineIf(exp)
+		p.block(exp.Consequence, inline)
+		if exp.Alternative != nil {
+			p.out.WriteString(" else ")
+			p.block(exp.Alternative, inline)
+		}
+	case *ast.MatchExpression:
+		p.match(exp)
+	case *ast.SpreadExpression:
+		p.out.WriteString("...")
+		p.expression(exp.Value, lowest)
+	case *ast.ImportExpression:
+		p.out.WriteString("import " + quote(exp.Path.Value))
+	case *ast.NamedArgument:
+		p.out.WriteString(exp.Name.Value + ": ")
+		p.expression(exp.Value, lowest)
+	case *ast.FunctionLiteral:
+		params := []string{}
+		for i, param := range exp.Parameters {
+			if t := exp.ParameterType(i); t != nil {
+				params = append(params, param.Value+": "+t.Name)
+			} else {
+				params = append(params, param.Value)
+			}
+		}
+		p.out.WriteString("fn(" + strings.Join(params, ", ") + ") ")
+		if exp.ReturnType != nil {
+			p.out.WriteString("-> " + exp.ReturnType.Name + " ")
+		}
+		p.block(exp.Body, true)
+	case *ast.CallExpression:
+		p.expression(exp.Function, call)
+		p.out.WriteString("(")
+		p.list(exp.Arguments)
+		p.out.WriteString(")")
+	case *ast.MethodCallExpression:
+		p.expression(exp.Object, call)
+		p.out.WriteString(dot(exp.Optional) + exp.Method.Value + "(")
+		p.list(exp.Arguments)
+		p.out.WriteString(")")
+	case *ast.FieldExpression:
+		p.expression(exp.Object, call)
+		p.out.WriteString(dot(exp.Optional) + exp.Field.Value)
+	case *ast.ArrayLiteral:
+		p.out.WriteString("[")
+		p.list(exp.Elements)
+		p.out.WriteString("]")
+	case *ast.IndexExpression:
+		p.expression(exp.Left, index)
+		if exp.Optional {
+			p.out.WriteString("?")
+		}
+		p.out.WriteString("[")
+		p.expression(exp.Index, lowest)
+		p.out.WriteString("]")
+	case *ast.HashLiteral:
+		p.hash(exp)
+	default:
+		p.out.WriteString(exp.String())
+	}
+}
+
+func (p *printer) list(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(exp, lowest)
+	}
+}
+
+// match prints each arm of m on its own line, followed by a comma.
+func (p *printer) match(m *ast.MatchExpression) {
+	p.out.WriteString("match (")
+	p.expression(m.Subject, lowest)
+	p.out.WriteString(") {\n")
+
+	p.depth++
+	for _, arm := range m.Arms {
+		p.indent()
+		p.expression(arm.Pattern, lowest)
+		if arm.Guard != nil {
+			p.out.WriteString(" if ")
+			p.expression(arm.Guard, lowest)
+		}
+		p.out.WriteString(" => ")
+		p.expression(arm.Body, lowest)
+		p.out.WriteString(",\n")
+	}
+	p.depth--
+
+	p.indent()
+	p.out.WriteString("}")
+}
+
+func (p *printer) hash(h *ast.HashLiteral) {
+	p.out.WriteString("{")
+	for i, key := range h.SortedKeys() {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(key, lowest)
+		p.out.WriteString(": ")
+		p.expression(h.Pairs[key], lowest)
+	}
+	p.out.WriteString("}")
+}
+
+func precedenceOf(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		if prec, ok := precedences[exp.Operator]; ok {
+			return prec
+		}
+		return lowest
+	case *ast.PrefixExpression:
+		return prefix
+	case *ast.SpreadExpression:
+		// Takes everything after it, so it's parenthesized inside operators
+		return lowest
+	case *ast.CallExpression:
+		return call
+	case *ast.IndexExpression, *ast.MethodCallExpression, *ast.FieldExpression:
+		return index
+	default:
+		return atom
+	}
+}
+
+// dot returns the operator of a method call or field.
+func dot(optional bool) string {
+	if optional {
+		return "?."
+	}
+	return "."
+}
+
+// quote wraps a string literal in double quotes, or single quotes if it
+// contains a double quote. Strings have no escape sequences.
+func quote(s string) string {
+	if strings.Contains(s, `"`) {
+		return "'" + s + "'"
+	}
+
+	return `"` + s + `"`
+}
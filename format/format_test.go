@@ -0,0 +1,164 @@
+package format
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"1+2*3;(1+2)*3", "1 + 2 * 3;\n(1 + 2) * 3;\n"},
+		{"1-(2-3); (1-2)-3", "1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(a+b); !-a", "-(a + b);\n!-a;\n"},
+		{`{"b":1,"a":[1,2]}`, "{\"b\": 1, \"a\": [1, 2]};\n"},
+		{`'say "hi"'`, "'say \"hi\"';\n"},
+		{"let add = fn(a,b){a+b}", "let add = fn(a, b) { a + b };\n"},
+		{"let add = fn(a:int,b)->int{a+b}", "let add = fn(a: int, b) -> int { a + b };\n"},
+		{"let f = fn() { let y = 1; return y }", "let f = fn() {\n  let y = 1;\n  return y;\n};\n"},
+		{"if (x) { 1 } else { 2 }", "if (x) { 1 } else { 2 }\n"},
+		{"if (x) { 1 }", "if (x) {\n  1;\n}\n"},
+		{"fn(){}()", "fn() {}();\n"},
+		{"a[1][2]; f(1)(2)", "a[1][2];\nf(1)(2);\n"},
+		{"a.push( 1 ).len(); (-a).len(); f().rest()", "a.push(1).len();\n(-a).len();\nf().rest();\n"},
+		{"a.b . c; (-a).b; f().b[0]", "a.b.c;\n(-a).b;\nf().b[0];\n"},
+		{"a ?. b?.c( 1 ); a?[ 0 ]", "a?.b?.c(1);\na?[0];\n"},
+		{"greet(\"Ann\",excited:!quiet)", "greet(\"Ann\", excited: !quiet);\n"},
+		{"f(... args);[1,...xs,-1]", "f(...args);\n[1, ...xs, -1];\n"},
+		{"let lib=import 'lib/strings';(import \"x\").y", "let lib = import \"lib/strings\";\nimport \"x\".y;\n"},
+		{"[...a+b, (...a)+b]", "[...a + b, (...a) + b];\n"},
+		{"match(x){[h,...t] if h>0=>h,{name:n}=>n}", "match (x) {\n  [h, ...t] if h > 0 => h,\n  {name: n} => n,\n}\n"},
+		{
+			"let a = 1;\nlet f = fn(x) {\nlet y = x; y\n}\nf(a)",
+			"let a = 1;\n\nlet f = fn(x) {\n  let y = x;\n  y;\n};\n\nf(a);\n",
+		},
+		{
+			"// head\nlet x = 1 // about x\nlet f = fn() {\n// inside\n1\n// tail\n}\n// end",
+			"// head\nlet x = 1;\n\n// about x\nlet f = fn() {\n  // inside\n  1;\n  // tail\n};\n// end\n",
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("Source(%q) failed: %s", tt.input, err)
+			continue
+		}
+
+		if formatted != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=     %q", tt.input, tt.expected, formatted)
+		}
+
+		again, err := Source(formatted)
+		if err != nil {
+			t.Errorf("formatted output of %q does not parse: %s", tt.input, err)
+			continue
+		}
+
+		if again != formatted {
+			t.Errorf("formatting %q is not idempotent.\nfirst= %q\nsecond=%q", tt.input, formatted, again)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	_, err := Source("let = 5")
+	if err == nil {
+		t.Fatalf("expected an error for invalid source")
+	}
+}
+
+func TestNode(t *testing.T) {
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{
+			&ast.InfixExpression{
+				Left: &ast.InfixExpression{
+					Left:     &ast.Identifier{Value: "a"},
+					Operator: "+",
+					Right:    &ast.Identifier{Value: "b"},
+				},
+				Operator: "*",
+				Right:    &ast.IntegerLiteral{Value: 3},
+			},
+			"(a + b) * 3",
+		},
+		{
+			&ast.LetStatement{
+				Name: &ast.Identifier{Value: "xs"},
+				Value: &ast.IndexExpression{
+					Left: &ast.PrefixExpression{
+						Operator: "-",
+						Right:    &ast.Identifier{Value: "ys"},
+					},
+					Index: &ast.StringLiteral{Value: "k"},
+				},
+			},
+			`let xs = (-ys)["k"];`,
+		},
+		{
+			&ast.ExpressionStatement{
+				Expression: &ast.CallExpression{
+					Function: &ast.FunctionLiteral{
+						Parameters: []*ast.Identifier{{Value: "x"}},
+						Body: &ast.BlockStatement{Statements: []ast.Statement{
+							&ast.ExpressionStatement{Expression: &ast.Identifier{Value: "x"}},
+						}},
+					},
+					Arguments: []ast.Expression{&ast.Boolean{Value: true}},
+				},
+			},
+			"fn(x) { x }(true);",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Node(tt.node); got != tt.expected {
+			t.Errorf("Node(%s) wrong.\nexpected=%q\ngot=     %q", tt.node, tt.expected, got)
+		}
+	}
+}
+
+func TestNodeRoundTrip(t *testing.T) {
+	input := `
+let add = fn(a, b) { return a + b; };
+let values = [1, -2, "three", true, {"k": add(1, 2)[0]}];
+if (!(values[0] == 1)) { puts("no") } else { values };
+(1 - (2 - 3)) * -(4 + 5) / 6;
+fn(f) { f(f) }(fn(f) { 1 });
+match (values) { [a, ...rest] if a > 0 => rest, _ => -1 };
+`
+
+	program := parse(t, input)
+
+	for _, stmt := range program.Statements {
+		src := Node(stmt)
+
+		again := parse(t, src)
+		if len(again.Statements) != 1 {
+			t.Errorf("%q parsed to %d statements", src, len(again.Statements))
+			continue
+		}
+
+		if again.Statements[0].String() != stmt.String() {
+			t.Errorf("tree changed in round trip through %q.\nexpected=%s\ngot=     %s",
+				src, stmt.String(), again.Statements[0].String())
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parsing %q failed: %v", input, p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,164 @@
+package interp
+
+import (
+	"fmt"
+	"monkey/object"
+	"reflect"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterBuiltin makes fn callable from Monkey code as name. Returning an
+// *object.Error from fn fails the calling program the same way the language's
+// own builtins do.
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.env.Set(name, &object.Builtin{Fn: fn})
+}
+
+// RegisterFunc makes an ordinary Go function callable from Monkey code as
+// name. Arguments are converted to the parameter types of fn, which may be
+// integers, strings, bools, slices and string keyed maps of those,
+// object.Object, or any, which receives the result of object.ToGoValue. fn
+// may return nothing, a value, an error, or a value and an error; a non-nil
+// error fails the calling program.
+func (i *Interpreter) RegisterFunc(name string, fn any) error {
+	builtin, err := wrapFunc(name, fn)
+	if err != nil {
+		return err
+	}
+
+	i.env.Set(name, builtin)
+	return nil
+}
+
+func wrapFunc(name string, fn any) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s: expected a function, got %s", name, t)
+	}
+
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("%s: variadic functions are not supported", name)
+	}
+
+	switch t.NumOut() {
+	case 0, 1:
+	case 2:
+		if t.Out(1) != errorType {
+			return nil, fmt.Errorf("%s: second result must be an error, got %s", name, t.Out(1))
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected at most 2 results, got %d", name, t.NumOut())
+	}
+
+	call := func(args ...object.Object) object.Object {
+		if len(args) != t.NumIn() {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), t.NumIn())
+		}
+
+		in := make([]reflect.Value, len(args))
+		for n, arg := range args {
+			value, err := toGo(arg, t.In(n))
+			if err != nil {
+				return newError("argument %d to `%s`: %s", n+1, name, err)
+			}
+			in[n] = value
+		}
+
+		return fromResults(v.Call(in))
+	}
+
+	return &object.Builtin{Fn: call}, nil
+}
+
+// fromResults converts what a registered function returned to a Monkey value.
+func fromResults(out []reflect.Value) object.Object {
+	if len(out) == 0 {
+		return object.NULL
+	}
+
+	last := out[len(out)-1]
+	if last.Type() == errorType {
+		if !last.IsNil() {
+			return newError("%s", last.Interface().(error))
+		}
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return object.NULL
+	}
+
+	result, err := object.FromGoValue(out[0].Interface())
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return result
+}
+
+// toGo converts obj to a Go value of type t.
+func toGo(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			if value := object.ToGoValue(obj); value != nil {
+				return reflect.ValueOf(value), nil
+			}
+			return reflect.Zero(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*object.Integer); ok {
+			v := reflect.New(t).Elem()
+			v.SetInt(i.Value)
+			return v, nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		}
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+	case reflect.Slice:
+		if arr, ok := obj.(*object.Array); ok {
+			v := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+			for n, el := range arr.Elements {
+				elem, err := toGo(el, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				v.Index(n).Set(elem)
+			}
+			return v, nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok && t.Key().Kind() == reflect.String {
+			v := reflect.MakeMapWithSize(t, len(hash.Pairs))
+			for _, pair := range hash.Pairs {
+				key, err := toGo(pair.Key, t.Key())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				value, err := toGo(pair.Value, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				v.SetMapIndex(key, value)
+			}
+			return v, nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+}
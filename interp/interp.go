@@ -0,0 +1,148 @@
+// Package interp embeds the Monkey interpreter in Go programs. Host
+// applications evaluate source, extend the language with builtins written
+// in Go, and read results back.
+package interp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"strings"
+)
+
+// Interpreter runs Monkey programs with the tree-walking evaluator. Bindings
+// made by one call to Eval are visible to the next.
+type Interpreter struct {
+	env *object.Environment
+	// Modules imported so far, so each is only evaluated once
+	loader *module.Loader
+
+	// Where puts writes, defaults to os.Stdout
+	Stdout io.Writer
+}
+
+func New() *Interpreter {
+	return &Interpreter{env: object.NewEnvironment(), loader: module.NewLoader("")}
+}
+
+// Value is a Monkey value handed back to Go code. Use object.ToGoValue to
+// turn it into a plain Go value.
+type Value = object.Object
+
+// ParseError is returned by Eval for programs that don't parse.
+type ParseError struct {
+	Errors []*parser.ParseError
+}
+
+func (e *ParseError) Error() string {
+	msgs := []string{}
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// RuntimeError is returned by Eval for programs that fail while running.
+type RuntimeError struct {
+	Message string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+// Eval runs src and returns the value it finished with.
+func (i *Interpreter) Eval(src string) (Value, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// EvalContext is Eval, stopping early once ctx is done.
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (Value, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	result := evaluator.EvalContext(i.context(ctx), program, i.env)
+	return unwrap(result)
+}
+
+// Call looks up the function bound to fnName, or the builtin of that name,
+// and calls it. Arguments are converted with object.FromGoValue, so both Go
+// values and Values are accepted.
+func (i *Interpreter) Call(fnName string, args ...any) (Value, error) {
+	return i.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is Call, stopping early once ctx is done.
+func (i *Interpreter) CallContext(ctx context.Context, fnName string, args ...any) (Value, error) {
+	fn, ok := i.env.Get(fnName)
+	if !ok {
+		builtin := object.GetBuiltinByName(fnName)
+		if builtin == nil {
+			return nil, fmt.Errorf("undefined function: %s", fnName)
+		}
+		fn = builtin
+	}
+
+	switch fn.(type) {
+	case *object.FunctionValue, *object.Builtin:
+	default:
+		return nil, fmt.Errorf("not a function: %s is %s", fnName, fn.Type())
+	}
+
+	objs := make([]object.Object, len(args))
+	for n, arg := range args {
+		obj, err := object.FromGoValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d to %s: %s", n+1, fnName, err)
+		}
+		objs[n] = obj
+	}
+
+	return unwrap(evaluator.Apply(i.context(ctx), fn, objs))
+}
+
+// Set binds name to value in the global environment.
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.env.Set(name, value)
+}
+
+// Get looks up a global binding.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+func (i *Interpreter) context(ctx context.Context) context.Context {
+	out := i.Stdout
+	if out == nil {
+		out = os.Stdout
+	}
+
+	ctx = module.WithLoader(ctx, i.loader)
+	return object.WithOutput(ctx, out)
+}
+
+// unwrap turns Monkey errors into Go errors.
+func unwrap(result object.Object) (object.Object, error) {
+	if result == nil {
+		return object.NULL, nil
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Message: err.Message}
+	}
+
+	return result, nil
+}
+
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,229 @@
+package interp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	var out bytes.Buffer
+	i := New()
+	i.Stdout = &out
+
+	if _, err := i.Eval(`let x = 2; puts("hi")`); err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	// Bindings carry over between calls
+	result, err := i.Eval(`x * 21`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	if result.Inspect() != "42" {
+		t.Errorf("expected 42, got %s", result.Inspect())
+	}
+
+	if out.String() != "hi\n" {
+		t.Errorf("expected puts output, got %q", out.String())
+	}
+}
+
+func TestImportsCachedPerInterpreter(t *testing.T) {
+	i := New()
+	if _, err := i.Eval(`let list = import "std/list"`); err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	result, err := i.Eval(`list == import "std/list"`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+	if result != object.TRUE {
+		t.Errorf("expected the module to be imported once, got %s", result.Inspect())
+	}
+
+	other, err := New().Eval(`import "std/list"`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+	if list, _ := i.Get("list"); other == list {
+		t.Errorf("expected interpreters not to share modules")
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	_, err := New().Eval(`let = 1`)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("expected a ParseError, got %T (%v)", err, err)
+	}
+
+	_, err = New().Eval(`1 + true`)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a RuntimeError, got %T (%v)", err, err)
+	}
+
+	if runtimeErr.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message %q", runtimeErr.Message)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	i := New()
+	i.RegisterBuiltin("double", func(args ...object.Object) object.Object {
+		n := args[0].(*object.Integer)
+		return &object.Integer{Value: n.Value * 2}
+	})
+
+	result, err := i.Eval(`double(21)`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	if result.Inspect() != "42" {
+		t.Errorf("expected 42, got %s", result.Inspect())
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	i := New()
+
+	funcs := map[string]any{
+		"repeat": strings.Repeat,
+		"sum": func(ns []int) int {
+			total := 0
+			for _, n := range ns {
+				total += n
+			}
+			return total
+		},
+		"even":    func(n int) bool { return n%2 == 0 },
+		"keys":    func(m map[string]int) int { return len(m) },
+		"fail":    func(msg string) (int, error) { return 0, errors.New(msg) },
+		"nothing": func() {},
+		"kind":    func(v any) string { return fmt.Sprintf("%T", v) },
+	}
+
+	for name, fn := range funcs {
+		if err := i.RegisterFunc(name, fn); err != nil {
+			t.Fatalf("RegisterFunc(%s) failed: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`repeat("ab", 3)`, `"ababab"`},
+		{`sum([1, 2, 3])`, "6"},
+		{`even(4) == true`, "true"},
+		{`keys({"a": 1, "b": 2})`, "2"},
+		{`nothing()`, "null"},
+		{`kind([1, "a"])`, `"[]interface {}"`},
+		{`kind({"a": 1})`, `"map[string]interface {}"`},
+	}
+
+	for _, tt := range tests {
+		result, err := i.Eval(tt.input)
+		if err != nil {
+			t.Errorf("%s: Eval failed: %s", tt.input, err)
+			continue
+		}
+
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`fail("boom")`, "boom"},
+		{`repeat(1, 2)`, "argument 1 to `repeat`: cannot use INTEGER as string"},
+		{`sum([1, "a"])`, "argument 1 to `sum`: cannot use STRING as int"},
+		{`even()`, "wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := i.Eval(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestRegisterFuncRejects(t *testing.T) {
+	tests := []any{
+		42,
+		func(a ...int) {},
+		func() (int, int) { return 0, 0 },
+		func() (int, int, error) { return 0, 0, nil },
+	}
+
+	for _, fn := range tests {
+		if err := New().RegisterFunc("f", fn); err == nil {
+			t.Errorf("expected RegisterFunc to reject %T", fn)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	i := New()
+	_, err := i.Eval(`
+let greet = fn(name, times) { if (times == 0) { return "" }; name + greet(name, times - 1) };
+let total = fn(xs) { if (len(xs) == 0) { 0 } else { first(xs) + total(rest(xs)) } };
+let notFn = 1;
+`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	result, err := i.Call("greet", "ab", 2)
+	if err != nil {
+		t.Fatalf("Call failed: %s", err)
+	}
+	if result.Inspect() != `"abab"` {
+		t.Errorf("expected \"abab\", got %s", result.Inspect())
+	}
+
+	result, err = i.Call("total", []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("Call failed: %s", err)
+	}
+	if object.ToGoValue(result) != int64(6) {
+		t.Errorf("expected 6, got %s", result.Inspect())
+	}
+
+	result, err = i.Call("len", "four")
+	if err != nil {
+		t.Fatalf("Call failed: %s", err)
+	}
+	if result.Inspect() != "4" {
+		t.Errorf("expected 4, got %s", result.Inspect())
+	}
+
+	errorTests := []struct {
+		name     string
+		args     []any
+		expected string
+	}{
+		{"missing", nil, "undefined function: missing"},
+		{"notFn", nil, "not a function: notFn is INTEGER"},
+		{"greet", []any{"a"}, "wrong number of arguments: want=2, got=1"},
+		{"greet", []any{"a", 1.5}, "argument 2 to greet: cannot convert float64 to a Monkey value"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := i.Call(tt.name, tt.args...)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Call(%s): expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package lexer
 
 import (
+	"bufio"
+	"io"
 	"monkey/token"
+	"strings"
 )
 
 type Lexer struct {
-	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	input *bufio.Reader
+	ch    byte // current char under examination
+	err   error
+
+	filename string
+	line     int // line of the current char
+	column   int // column of the current char
 }
 
 // Read characters until we've read past the whitespace
@@ -20,10 +26,18 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
 
+	pos := token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+	tok := l.readToken()
+	tok.Pos = pos
+
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peakChar() == '=' {
@@ -34,6 +48,9 @@ func (l *Lexer) NextToken() token.Token {
 				Type:    token.EQ,
 				Literal: literal,
 			}
+		} else if l.peakChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, '=')
 		}
@@ -50,6 +67,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, '!')
 		}
 	case '/':
+		if l.peakChar() == '/' {
+			tok.Type = token.COMMENT
+			tok.Literal = l.readComment()
+			return tok
+		}
 		tok = newToken(token.SLASH, '/')
 	case '*':
 		tok = newToken(token.ASTERISK, '*')
@@ -67,10 +89,34 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.COMMA, ',')
 	case ':':
 		tok = newToken(token.COLON, ':')
+	case '.':
+		if next, _ := l.input.Peek(2); string(next) == ".." {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, '.')
+		}
+	case '?':
+		switch l.peakChar() {
+		case '.':
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_DOT, Literal: "?."}
+		case '[':
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_LBRACKET, Literal: "?["}
+		default:
+			tok = newToken(token.ILLEGAL, '?')
+		}
 	case '+':
 		tok = newToken(token.PLUS, '+')
 	case '-':
-		tok = newToken(token.MINUS, '-')
+		if l.peakChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.THIN_ARROW, Literal: "->"}
+		} else {
+			tok = newToken(token.MINUS, '-')
+		}
 	case '{':
 		tok = newToken(token.LBRACE, '{')
 	case '}':
@@ -106,47 +152,41 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-// Read until it's not a letter
+// Read until it's not a letter or digit. Identifiers start with a letter,
+// so digits only count after the first character.
 func (l *Lexer) readIdentifier() string {
-	// this is the start index of our identifier
-	position := l.position
-
-	// advance isLetter
-	for isLetter(l.ch) {
-		l.readChar()
-	}
-
-	// At this point readPosition is the character
-	// past the end of the identifer, but position is
-	// at the end of the identifier
-
-	return l.input[position:l.position]
+	return l.readWhile(func(ch byte) bool { return isLetter(ch) || isDigit(ch) })
 }
 
 func (l *Lexer) readNumber() string {
-	// Need an index to start
-	position := l.position
-
-	for isDigit(l.ch) {
-		l.readChar()
-	}
+	return l.readWhile(isDigit)
+}
 
-	return l.input[position:l.position]
+// Read a // comment up to, but not including, the end of the line
+func (l *Lexer) readComment() string {
+	return l.readWhile(func(ch byte) bool { return ch != '\n' })
 }
 
 func (l *Lexer) readString(delimiter byte) string {
-	// Record start position of the string
-	position := l.position + 1
+	// Skip the opening delimiter
+	l.readChar()
 
 	// Advance lexer until we get the next delimiter (' or ") or EOF
-	for {
+	return l.readWhile(func(ch byte) bool { return ch != delimiter })
+}
+
+// readWhile reads characters up to the first that doesn't satisfy accept or
+// the end of the input, returning them. ch is left on the first character
+// not read.
+func (l *Lexer) readWhile(accept func(byte) bool) string {
+	var literal strings.Builder
+
+	for l.ch != 0 && accept(l.ch) {
+		literal.WriteByte(l.ch)
 		l.readChar()
-		if l.ch == delimiter || l.ch == 0 {
-			break
-		}
 	}
 
-	return l.input[position:l.position]
+	return literal.String()
 }
 
 func newToken(tokenType token.TokenType, literal byte) token.Token {
@@ -166,29 +206,73 @@ func isDigit(char byte) bool {
 
 // Read the next character into ch and update existing state
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.column++
+	}
+
+	ch, err := l.input.ReadByte()
+	if err != nil {
+		if err != io.EOF && l.err == nil {
+			l.err = err
+		}
+		ch = 0
 	}
 
-	l.position = l.readPosition
-	l.readPosition += 1
+	l.ch = ch
 }
 
 func (l *Lexer) peakChar() byte {
-	if l.readPosition >= len(l.input) {
+	next, err := l.input.Peek(1)
+	if err != nil {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+
+	return next[0]
 }
 
 func New(input string) *Lexer {
+	return NewWithFilename("", input)
+}
+
+// NewWithFilename is New for source read from filename, which is recorded in
+// the position of every token.
+func NewWithFilename(filename, input string) *Lexer {
+	return NewReader(filename, strings.NewReader(input))
+}
+
+// NewReader lexes source as it's read from r, so large files and network
+// streams don't have to be loaded into memory first. filename is recorded in
+// the position of every token and may be empty. Read errors end the token
+// stream early and are reported by Err, which the parser reports as an
+// error of the program.
+func NewReader(filename string, r io.Reader) *Lexer {
 	l := &Lexer{
-		input: input,
+		input:    bufio.NewReader(r),
+		filename: filename,
+		line:     1,
 	}
 	l.readChar()
+	l.skipShebang()
 
 	return l
 }
+
+// Scripts may start with a "#!/usr/bin/env monkey" line so they can be run
+// directly. Skip it, leaving the newline in place.
+func (l *Lexer) skipShebang() {
+	if l.ch != '#' || l.peakChar() != '!' {
+		return
+	}
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// Err returns the first error reading the input, other than io.EOF.
+func (l *Lexer) Err() error {
+	return l.err
+}
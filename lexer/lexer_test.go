@@ -1,9 +1,13 @@
 package lexer_test
 
 import (
+	"errors"
+	"io"
 	"monkey/lexer"
 	"monkey/token"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -32,6 +36,11 @@ if (5 < 10) {
 'foo bar'
 [1,2,3]
 { "foo": "bar" }
+a.b()
+match (x) { [h, ...t] => h }
+b64_encode 2x
+a?.b?[0]
+) -> int - >
 `
 
 	tests := []struct {
@@ -128,6 +137,39 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "h"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "t"},
+		{token.RBRACKET, "]"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "h"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "b64_encode"},
+		{token.INT, "2"},
+		{token.IDENT, "x"},
+		{token.IDENT, "a"},
+		{token.OPTIONAL_DOT, "?."},
+		{token.IDENT, "b"},
+		{token.OPTIONAL_LBRACKET, "?["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.RPAREN, ")"},
+		{token.THIN_ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.MINUS, "-"},
+		{token.GT, ">"},
 		{token.EOF, ""},
 	}
 
@@ -144,3 +186,149 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestShebangLine(t *testing.T) {
+	input := "#!/usr/bin/env monkey\nlet x = 1;"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := lexer.New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 10 / 2; // trailing
+//`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// leading"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "//"},
+		{token.EOF, ""},
+	}
+
+	l := lexer.New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	input := "let x = 5;\n  x + \"ab\"; // done\n\n==\n"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IDENT, 2, 3},
+		{token.PLUS, 2, 5},
+		{token.STRING, 2, 7},
+		{token.SEMICOLON, 2, 11},
+		{token.COMMENT, 2, 13},
+		{token.EQ, 4, 1},
+		{token.EOF, 5, 1},
+	}
+
+	l := lexer.NewWithFilename("test.monkey", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position of %q wrong. expected=%d:%d, got=%d:%d",
+				i, tok.Literal, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+
+		if tok.Pos.Filename != "test.monkey" {
+			t.Errorf("tests[%d] - filename wrong. got=%q", i, tok.Pos.Filename)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	input := "let add = fn(x, y) { x + y; };\n// sum\nadd(1, 22) == \"three\";"
+
+	expected := lexer.New(input)
+	l := lexer.NewReader("", iotest.OneByteReader(strings.NewReader(input)))
+
+	for {
+		want := expected.NextToken()
+		got := l.NextToken()
+
+		if got != want {
+			t.Fatalf("token wrong. expected=%+v, got=%+v", want, got)
+		}
+
+		if want.Type == token.EOF {
+			break
+		}
+	}
+
+	if l.Err() != nil {
+		t.Errorf("unexpected error: %s", l.Err())
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(errors.New("connection reset")))
+	l := lexer.NewReader("", r)
+
+	for _, expected := range []token.TokenType{token.LET, token.IDENT, token.EOF} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("tokentype wrong. expected=%q, got=%q", expected, tok.Type)
+		}
+	}
+
+	if l.Err() == nil || l.Err().Error() != "connection reset" {
+		t.Errorf("expected the read error, got %v", l.Err())
+	}
+}
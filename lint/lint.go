@@ -0,0 +1,292 @@
+// Package lint finds likely mistakes in Monkey programs without running them.
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Diagnostic is a single problem found in a program.
+type Diagnostic struct {
+	Node    ast.Node
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return d.Message
+}
+
+// Lint reports unused let bindings, references to identifiers that are never
+// defined, unreachable statements after a return, and if conditions that are
+// always true or false.
+func Lint(program *ast.Program) []Diagnostic {
+	l := &linter{}
+
+	global := l.newScope(nil)
+	l.statements(program.Statements, global)
+	l.flush()
+
+	for _, s := range l.scopes {
+		for _, b := range s.order {
+			if !b.used && b.name[0] != '_' {
+				l.report(b.node, "%s declared and not used", b.name)
+			}
+		}
+	}
+
+	return l.diagnostics
+}
+
+type binding struct {
+	name string
+	node ast.Node
+	used bool
+}
+
+type scope struct {
+	outer    *scope
+	bindings map[string]*binding
+	order    []*binding
+}
+
+func (s *scope) define(name string, node ast.Node) {
+	b := &binding{name: name, node: node}
+	s.bindings[name] = b
+	s.order = append(s.order, b)
+}
+
+func (s *scope) resolve(name string) *binding {
+	for current := s; current != nil; current = current.outer {
+		if b, ok := current.bindings[name]; ok {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// A function body waiting to be checked once its enclosing scope is complete
+type pendingFunction struct {
+	fn    *ast.FunctionLiteral
+	outer *scope
+}
+
+type linter struct {
+	diagnostics []Diagnostic
+	scopes      []*scope
+	pending     []pendingFunction
+}
+
+func (l *linter) report(node ast.Node, format string, a ...any) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{Node: node, Message: fmt.Sprintf(format, a...)})
+}
+
+func (l *linter) newScope(outer *scope) *scope {
+	s := &scope{outer: outer, bindings: map[string]*binding{}}
+	l.scopes = append(l.scopes, s)
+	return s
+}
+
+// flush checks function bodies. They run only when called, so they may refer
+// to bindings defined after the function itself and are checked after the
+// scope they were written in.
+func (l *linter) flush() {
+	for len(l.pending) > 0 {
+		next := l.pending[0]
+		l.pending = l.pending[1:]
+
+		s := l.newScope(next.outer)
+		if next.fn.Name != "" {
+			// Named functions can call themselves, which doesn't count as a use
+			s.bindings[next.fn.Name] = &binding{name: next.fn.Name, node: next.fn, used: true}
+		}
+		for _, param := range next.fn.Parameters {
+			s.bindings[param.Value] = &binding{name: param.Value, node: param, used: true}
+		}
+
+		l.statements(next.fn.Body.Statements, s)
+	}
+}
+
+func (l *linter) statements(statements []ast.Statement, s *scope) {
+	for i, stmt := range statements {
+		l.statement(stmt, s)
+
+		if _, ok := stmt.(*ast.ReturnStatement); ok && i < len(statements)-1 {
+			l.report(statements[i+1], "unreachable code after return")
+		}
+	}
+}
+
+func (l *linter) statement(stmt ast.Statement, s *scope) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		l.expression(stmt.Value, s)
+		s.define(stmt.Name.Value, stmt)
+	case *ast.ReturnStatement:
+		l.expression(stmt.ReturnValue, s)
+	case *ast.ExpressionStatement:
+		l.expression(stmt.Expression, s)
+	case *ast.BlockStatement:
+		l.statements(stmt.Statements, s)
+	}
+}
+
+func (l *linter) expression(exp ast.Expression, s *scope) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if b := s.resolve(exp.Value); b != nil {
+			b.used = true
+		} else if object.GetBuiltinByName(exp.Value) == nil {
+			l.report(exp, "undefined: %s", exp.Value)
+		}
+	case *ast.PrefixExpression:
+		l.expression(exp.Right, s)
+	case *ast.InfixExpression:
+		l.expression(exp.Left, s)
+		l.expression(exp.Right, s)
+	case *ast.IfExpression:
+		l.expression(exp.Condition, s)
+		if value, ok := constantTruth(exp.Condition); ok {
+			l.report(exp.Condition, "condition is always %t", value)
+		}
+
+		// Blocks don't introduce a scope, lets inside them are visible after
+		l.statements(exp.Consequence.Statements, s)
+		if exp.Alternative != nil {
+			l.statements(exp.Alternative.Statements, s)
+		}
+	case *ast.MatchExpression:
+		l.expression(exp.Subject, s)
+		for _, arm := range exp.Arms {
+			// Like lets, pattern bindings are visible after the match
+			for _, name := range ast.PatternBindings(arm.Pattern) {
+				s.define(name.Value, name)
+			}
+			l.expression(arm.Guard, s)
+			l.expression(arm.Body, s)
+		}
+	case *ast.SpreadExpression:
+		l.expression(exp.Value, s)
+	case *ast.NamedArgument:
+		l.expression(exp.Value, s)
+	case *ast.FunctionLiteral:
+		l.pending = append(l.pending, pendingFunction{fn: exp, outer: s})
+	case *ast.CallExpression:
+		l.expression(exp.Function, s)
+		for _, arg := range exp.Arguments {
+			l.expression(arg, s)
+		}
+	case *ast.MethodCallExpression:
+		l.expression(exp.Object, s)
+		for _, arg := range exp.Arguments {
+			l.expression(arg, s)
+		}
+	case *ast.FieldExpression:
+		l.expression(exp.Object, s)
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			l.expression(el, s)
+		}
+	case *ast.IndexExpression:
+		l.expression(exp.Left, s)
+		l.expression(exp.Index, s)
+	case *ast.HashLiteral:
+		for _, key := range exp.SortedKeys() {
+			l.expression(key, s)
+			l.expression(exp.Pairs[key], s)
+		}
+	}
+}
+
+// constantTruth reports whether exp is made only of literals, and if so
+// whether it's truthy.
+func constantTruth(exp ast.Expression) (bool, bool) {
+	value, ok := constantValue(exp)
+	if !ok {
+		return false, false
+	}
+
+	switch value := value.(type) {
+	case bool:
+		return value, true
+	default:
+		// Integers and strings are always truthy
+		return true, true
+	}
+}
+
+func constantValue(exp ast.Expression) (any, bool) {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Value, true
+	case *ast.IntegerLiteral:
+		return exp.Value, true
+	case *ast.StringLiteral:
+		return exp.Value, true
+	case *ast.PrefixExpression:
+		right, ok := constantValue(exp.Right)
+		if !ok {
+			return nil, false
+		}
+
+		switch exp.Operator {
+		case "!":
+			truthy, _ := constantTruth(exp.Right)
+			return !truthy, true
+		case "-":
+			if n, ok := right.(int64); ok {
+				return -n, true
+			}
+		}
+	case *ast.InfixExpression:
+		left, ok := constantValue(exp.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := constantValue(exp.Right)
+		if !ok {
+			return nil, false
+		}
+
+		return compareConstants(exp.Operator, left, right)
+	}
+
+	return nil, false
+}
+
+func compareConstants(operator string, left, right any) (any, bool) {
+	switch operator {
+	case "==":
+		return left == right, true
+	case "!=":
+		return left != right, true
+	}
+
+	l, lok := left.(int64)
+	r, rok := right.(int64)
+	if !lok || !rok {
+		return nil, false
+	}
+
+	switch operator {
+	case "<":
+		return l < r, true
+	case ">":
+		return l > r, true
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		if r == 0 {
+			return nil, false
+		}
+		return l / r, true
+	}
+
+	return nil, false
+}
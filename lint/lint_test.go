@@ -0,0 +1,59 @@
+package lint
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 1; x`, nil},
+		{`let x = 1;`, []string{"x declared and not used"}},
+		{`let _x = 1;`, nil},
+		{`puts(y)`, []string{"undefined: y"}},
+		{`let f = fn(a) { a + b }; f(1)`, []string{"undefined: b"}},
+		// Function bodies can use bindings defined after them
+		{`let f = fn() { g() }; let g = fn() { 1 }; f()`, nil},
+		// Recursion doesn't count as using the function
+		{`let f = fn(n) { f(n - 1) };`, []string{"f declared and not used"}},
+		{`let f = fn() { return 1; 2 }; f()`, []string{"unreachable code after return"}},
+		{`if (true) { 1 }`, []string{"condition is always true"}},
+		{`if (1 > 2) { 1 }`, []string{"condition is always false"}},
+		{`if (!"a") { 1 }`, []string{"condition is always false"}},
+		{`let x = 1; if (x > 2) { 1 }`, nil},
+		{`let a = [1]; a.push(b)`, []string{"undefined: b"}},
+		{`let h = {}; h.name`, nil},
+		{`let f = fn(a) { a }; f(a: b)`, []string{"undefined: b"}},
+		{`let x = [1]; match (x) { [h, ..._t] if h > 0 => h, {a: y} => z }`, []string{"undefined: z", "y declared and not used"}},
+		{
+			`let f = fn(a) { let unused = a; return a; a }; f(b)`,
+			[]string{"undefined: b", "unreachable code after return", "unused declared and not used"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		diagnostics := Lint(program)
+
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%q: expected %d diagnostics, got %d: %v", tt.input, len(tt.expected), len(diagnostics), diagnostics)
+			continue
+		}
+
+		for i, d := range diagnostics {
+			if d.Message != tt.expected[i] {
+				t.Errorf("%q: diagnostic %d wrong. expected %q, got %q", tt.input, i, tt.expected[i], d.Message)
+			}
+		}
+	}
+}
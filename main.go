@@ -1,31 +1,101 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"monkey/repl"
 	"monkey/run"
 	"os"
-	"os/user"
 )
 
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"repl", "start an interactive session", replCommand},
+		{"run", "run a source or compiled file", runCommand},
+		{"compile", "compile a file to bytecode", compileCommand},
+		{"disasm", "print the bytecode of a source or compiled file", disasmCommand},
+		{"fmt", "format source files", fmtCommand},
+		{"lint", "report likely mistakes in source files", lintCommand},
+		{"test", "run the test_ functions in *_test.monkey files", testCommand},
+		{"bench", "compare the evaluator and VM on a script", benchCommand},
+		{"serve", "expose the REPL over TCP", serveCommand},
+		{"dap", "debug programs from an editor over the Debug Adapter Protocol", dapCommand},
+	}
+}
+
 func main() {
-	args := os.Args[1:]
+	os.Exit(dispatch(os.Args[1:]))
+}
 
+// dispatch runs the command args name and returns its exit code.
+func dispatch(args []string) int {
 	if len(args) == 0 {
-		replMode()
-	} else {
-		run.RunProgramFromFile(args[0])
+		// Piped input is a program to run rather than lines for the REPL
+		if !repl.IsTerminal(os.Stdin) {
+			return runCommand(args)
+		}
+		return replCommand(args)
+	}
+
+	for _, cmd := range commands {
+		if args[0] == cmd.name {
+			return cmd.run(args[1:])
+		}
+	}
+
+	// Kept from before there were subcommands, when the REPL took it
+	// without one
+	if args[0] == "-no-color" || args[0] == "--no-color" {
+		return replCommand(args)
 	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage()
+		return 0
+	}
+
+	// Anything else, e.g. `monkey script.monkey` or `monkey -e ...`, is run
+	return runCommand(args)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: monkey <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nWithout a command, files are run and no arguments start the REPL,\nor run the program piped to stdin.\n")
 }
 
-func replMode() {
-	user, err := user.Current()
+// newFlagSet creates the flags of a subcommand with a usage line naming it.
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: monkey %s [flags] %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
 
+	return fs
+}
+
+// parseFlags parses args with fs. If they're invalid, or only ask for help,
+// ok is false and code is what to exit with: ExitUsageError, so bad flags
+// can't be mistaken for a program failing to parse, or ExitOK for help.
+func parseFlags(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		return run.ExitOK, false
+	}
 	if err != nil {
-		panic(err)
+		return run.ExitUsageError, false
 	}
 
-	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.StartVMRepl(os.Stdin, os.Stdout)
+	return run.ExitOK, true
 }
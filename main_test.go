@@ -0,0 +1,83 @@
+package main
+
+import (
+	"monkey/run"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMain runs monkey with args, with input piped to stdin, and returns its
+// exit code and output.
+func runMain(t *testing.T, input string, args ...string) (int, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.monkey")
+	if err := os.WriteFile(in, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdin, err := os.Open(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	stdout, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(filepath.Join(dir, "err"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+
+	oldStdin, oldStdout, oldStderr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = stdin, stdout, stderr
+	code := dispatch(args)
+	os.Stdin, os.Stdout, os.Stderr = oldStdin, oldStdout, oldStderr
+
+	out, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code, string(out)
+}
+
+func TestPipedProgram(t *testing.T) {
+	// Without arguments, a program piped in runs instead of the REPL
+	code, out := runMain(t, "puts(1 + 1)\n")
+
+	if code != run.ExitOK {
+		t.Errorf("wrong exit code. want=%d, got=%d", run.ExitOK, code)
+	}
+	if out != "2\n" {
+		t.Errorf("wrong output. want=%q, got=%q", "2\n", out)
+	}
+}
+
+func TestBadFlags(t *testing.T) {
+	// Not ExitParseError, which programs with syntax errors exit with
+	if code, _ := runMain(t, "", "run", "--bogus", "x.monkey"); code != run.ExitUsageError {
+		t.Errorf("wrong exit code for a bad flag. want=%d, got=%d", run.ExitUsageError, code)
+	}
+
+	if code, _ := runMain(t, "", "run", "-h"); code != run.ExitOK {
+		t.Errorf("wrong exit code for -h. want=%d, got=%d", run.ExitOK, code)
+	}
+}
+
+func TestNoColorAlias(t *testing.T) {
+	code, out := runMain(t, "1 + 1\n", "--no-color")
+
+	if code != run.ExitOK {
+		t.Errorf("wrong exit code. want=%d, got=%d", run.ExitOK, code)
+	}
+	if !strings.Contains(out, "2\n") || strings.Contains(out, "\x1b[") {
+		t.Errorf("expected an uncolored REPL result, got %q", out)
+	}
+}
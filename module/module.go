@@ -0,0 +1,167 @@
+// Package module finds and parses the files programs import with
+// import "path", and keeps the modules loaded so each is only evaluated
+// once.
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/std"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Ext is the extension of source files, which import paths may leave out.
+const Ext = ".monkey"
+
+// StdPrefix starts the import paths of the standard library's modules,
+// which are built into the interpreter rather than read from disk.
+const StdPrefix = "std/"
+
+// Loader resolves import paths and holds the modules imported so far. It's
+// safe for concurrent use.
+type Loader struct {
+	// Directory that import paths not starting with ./ or ../ are relative
+	// to, the current directory if empty
+	Root string
+
+	mu      sync.Mutex
+	modules map[string]object.Object
+	// Modules being evaluated, to catch ones that import themselves
+	loading map[string]bool
+}
+
+func NewLoader(root string) *Loader {
+	return &Loader{Root: root, modules: map[string]object.Object{}, loading: map[string]bool{}}
+}
+
+// Resolve returns the file imported as path by the file named from, which
+// is empty for programs that weren't read from a file. Paths starting with
+// ./ or ../ are relative to the importing file's directory, others to the
+// root, except those of the standard library, which are left as they are.
+func (l *Loader) Resolve(path, from string) string {
+	if filepath.Ext(path) == "" {
+		path += Ext
+	}
+
+	if strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") {
+		return filepath.Join(filepath.Dir(from), path)
+	}
+
+	if strings.HasPrefix(path, StdPrefix) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(l.Root, path)
+}
+
+// Read returns the source of the module in filename, a name returned by
+// Resolve, from the standard library or disk.
+func Read(filename string) ([]byte, error) {
+	name, ok := strings.CutPrefix(filepath.ToSlash(filename), StdPrefix)
+	if !ok {
+		return os.ReadFile(filename)
+	}
+
+	text, err := fs.ReadFile(std.FS, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no module %s in the standard library", StdPrefix+strings.TrimSuffix(name, Ext))
+	}
+	return text, err
+}
+
+// Parse reads and parses the module in filename, a name returned by
+// Resolve.
+func (l *Loader) Parse(filename string) (*ast.Program, error) {
+	text, err := Read(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.NewWithFilename(filename, string(text)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		msgs := []string{}
+		for _, err := range p.Errors() {
+			msgs = append(msgs, err.Error())
+		}
+		return nil, fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+
+	return program, nil
+}
+
+// Import returns the module in filename, calling load to evaluate it the
+// first time it's imported. Importing a module while it's being evaluated,
+// because it imports itself through others, is an error.
+func (l *Loader) Import(filename string, load func() (object.Object, error)) (object.Object, error) {
+	l.mu.Lock()
+	if m, ok := l.modules[filename]; ok {
+		l.mu.Unlock()
+		return m, nil
+	}
+	if l.loading[filename] {
+		l.mu.Unlock()
+		return nil, fmt.Errorf("import cycle through %s", filename)
+	}
+	l.loading[filename] = true
+	l.mu.Unlock()
+
+	m, err := load()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.loading, filename)
+	if err == nil {
+		l.modules[filename] = m
+	}
+
+	return m, err
+}
+
+// Exports returns the names a module makes available to the files that
+// import it: those bound by its top level let statements, in order, except
+// ones starting with an underscore, which are private.
+func Exports(program *ast.Program) []string {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			continue
+		}
+
+		name := let.Name.Value
+		if strings.HasPrefix(name, "_") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names
+}
+
+type loaderKey struct{}
+
+// WithLoader returns a copy of ctx in which imports are resolved and cached
+// by l.
+func WithLoader(ctx context.Context, l *Loader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+// LoaderFrom returns the loader set with WithLoader, or nil.
+func LoaderFrom(ctx context.Context) *Loader {
+	l, _ := ctx.Value(loaderKey{}).(*Loader)
+	return l
+}
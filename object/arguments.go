@@ -0,0 +1,42 @@
+package object
+
+import "fmt"
+
+// ArrangeArguments puts the arguments of a call with named arguments in
+// parameter order. The positional arguments come first, each named one goes
+// to the parameter with its name, and every parameter must end up with
+// exactly one argument.
+func ArrangeArguments(params []string, positional []Object, names []string, named []Object) ([]Object, error) {
+	if len(positional) > len(params) {
+		return nil, fmt.Errorf("wrong number of arguments: want=%d, got=%d", len(params), len(positional)+len(named))
+	}
+
+	args := make([]Object, len(params))
+	copy(args, positional)
+
+	for i, name := range names {
+		slot := -1
+		for j, param := range params {
+			if param == name {
+				slot = j
+				break
+			}
+		}
+
+		if slot == -1 {
+			return nil, fmt.Errorf("unknown argument %s", name)
+		}
+		if args[slot] != nil {
+			return nil, fmt.Errorf("duplicate argument %s", name)
+		}
+		args[slot] = named[i]
+	}
+
+	for i, arg := range args {
+		if arg == nil {
+			return nil, fmt.Errorf("missing argument %s", params[i])
+		}
+	}
+
+	return args, nil
+}
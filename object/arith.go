@@ -0,0 +1,140 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// IntegerArithmetic applies operator, one of + - * /, to a and b. Results
+// that don't fit in an int64 are promoted to a BigInt instead of wrapping
+// around. Division by zero is an error.
+func IntegerArithmetic(operator string, a, b int64) (Object, error) {
+	result, ok, err := CheckedArithmetic(operator, a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return BigArithmetic(operator, big.NewInt(a), big.NewInt(b))
+	}
+
+	return NewInteger(result), nil
+}
+
+// CheckedArithmetic is IntegerArithmetic without allocating the result. It
+// reports whether the result fit in an int64, rather than promoting it.
+func CheckedArithmetic(operator string, a, b int64) (int64, bool, error) {
+	var result int64
+	overflow := false
+
+	switch operator {
+	case "+":
+		result = a + b
+		overflow = (b > 0 && result < a) || (b < 0 && result > a)
+	case "-":
+		result = a - b
+		overflow = (b > 0 && result > a) || (b < 0 && result < a)
+	case "*":
+		result = a * b
+		overflow = a != 0 && (result/a != b || (a == -1 && b == math.MinInt64))
+	case "/":
+		if b == 0 {
+			return 0, false, fmt.Errorf("division by zero: %d / %d", a, b)
+		}
+		overflow = a == math.MinInt64 && b == -1
+		if !overflow {
+			result = a / b
+		}
+	default:
+		return 0, false, fmt.Errorf("unknown integer operator: %s", operator)
+	}
+
+	return result, !overflow, nil
+}
+
+// BigArithmetic applies operator, one of + - * /, to a and b. Division
+// truncates towards zero, like it does for Integers.
+func BigArithmetic(operator string, a, b *big.Int) (Object, error) {
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		result.Add(a, b)
+	case "-":
+		result.Sub(a, b)
+	case "*":
+		result.Mul(a, b)
+	case "/":
+		if b.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero: %s / %s", a, b)
+		}
+		result.Quo(a, b)
+	default:
+		return nil, fmt.Errorf("unknown integer operator: %s", operator)
+	}
+
+	return &BigInt{Value: result}, nil
+}
+
+// IsNumber reports whether obj is an Integer or a BigInt.
+func IsNumber(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *BigInt:
+		return true
+	default:
+		return false
+	}
+}
+
+// Arithmetic applies operator, one of + - * /, to the numbers a and b. The
+// result is a BigInt if either operand is one.
+func Arithmetic(operator string, a, b Object) (Object, error) {
+	if a, ok := a.(*Integer); ok {
+		if b, ok := b.(*Integer); ok {
+			return IntegerArithmetic(operator, a.Value, b.Value)
+		}
+	}
+
+	return BigArithmetic(operator, toBig(a), toBig(b))
+}
+
+// CompareNumbers returns -1, 0 or +1 depending on whether the number a is
+// less than, equal to or greater than the number b.
+func CompareNumbers(a, b Object) int {
+	if a, ok := a.(*Integer); ok {
+		if b, ok := b.(*Integer); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1
+			case a.Value > b.Value:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return toBig(a).Cmp(toBig(b))
+}
+
+// Negate returns -a for the number a, promoting the one int64 with no
+// negation to a BigInt.
+func Negate(a Object) Object {
+	if a, ok := a.(*Integer); ok && a.Value != math.MinInt64 {
+		return NewInteger(-a.Value)
+	}
+
+	return &BigInt{Value: new(big.Int).Neg(toBig(a))}
+}
+
+func toBig(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInt:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
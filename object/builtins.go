@@ -1,16 +1,32 @@
 package object
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 )
 
 var Builtins = []struct {
-	Name    string
+	Name string
+	// Number of arguments taken, or -1 if it varies
+	Arity   int
 	Builtin *Builtin
 }{
 	{
-		"len",
-		&Builtin{
+		Name:  "len",
+		Arity: 1,
+		Builtin: &Builtin{
 			Fn: func(args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments. got=%d, want=1", len(args))
@@ -18,9 +34,11 @@ var Builtins = []struct {
 
 				switch arg := args[0].(type) {
 				case *String:
-					return &Integer{Value: int64(len(arg.Value))}
+					return NewInteger(int64(arg.Len()))
 				case *Array:
-					return &Integer{Value: int64(len(arg.Elements))}
+					return NewInteger(int64(len(arg.Elements)))
+				case *Hash:
+					return NewInteger(int64(len(arg.Pairs)))
 				default:
 					return newError("argument to `len` not supported, got %s", args[0].Type())
 
@@ -29,11 +47,18 @@ var Builtins = []struct {
 		},
 	},
 	{
-		"puts",
-		&Builtin{
-			Fn: func(args ...Object) Object {
+		Name:  "puts",
+		Arity: -1,
+		Builtin: &Builtin{
+			CtxFn: func(ctx context.Context, args ...Object) Object {
+				out := Output(ctx)
 				for _, arg := range args {
-					fmt.Println(arg.Inspect())
+					// Strings are printed as they are, not quoted
+					if str, ok := arg.(*String); ok {
+						fmt.Fprintln(out, str.Value)
+						continue
+					}
+					fmt.Fprintln(out, arg.Inspect())
 				}
 
 				return nil
@@ -41,7 +66,8 @@ var Builtins = []struct {
 		},
 	},
 	{
-		Name: "first",
+		Name:  "first",
+		Arity: 1,
 		Builtin: &Builtin{
 			Fn: func(args ...Object) Object {
 				if len(args) != 1 {
@@ -63,7 +89,8 @@ var Builtins = []struct {
 		},
 	},
 	{
-		Name: "last",
+		Name:  "last",
+		Arity: 1,
 		Builtin: &Builtin{
 			Fn: func(args ...Object) Object {
 				if len(args) != 1 {
@@ -85,7 +112,8 @@ var Builtins = []struct {
 		},
 	},
 	{
-		Name: "rest",
+		Name:  "rest",
+		Arity: 1,
 		Builtin: &Builtin{
 			Fn: func(args ...Object) Object {
 				if len(args) != 1 {
@@ -110,7 +138,8 @@ var Builtins = []struct {
 		},
 	},
 	{
-		Name: "push",
+		Name:  "push",
+		Arity: 2,
 		Builtin: &Builtin{
 			Fn: func(args ...Object) Object {
 				if len(args) != 2 {
@@ -132,18 +161,1252 @@ var Builtins = []struct {
 			},
 		},
 	},
-}
+	{
+		Name:  "args",
+		Arity: 0,
+		Builtin: &Builtin{
+			CtxFn: func(ctx context.Context, args ...Object) Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
 
-func GetBuiltinByName(name string) *Builtin {
-	for _, def := range Builtins {
-		if def.Name == name {
-			return def.Builtin
-		}
-	}
+				elements := []Object{}
+				for _, arg := range Args(ctx) {
+					elements = append(elements, &String{Value: arg})
+				}
 
-	return nil
-}
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		Name:  "assert",
+		Arity: -1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
 
-func newError(format string, a ...any) *Error {
-	return &Error{Message: fmt.Sprintf(format, a...)}
+				switch cond := args[0].(type) {
+				case *Null:
+					// falsy
+				case *Boolean:
+					if cond.Value {
+						return nil
+					}
+				default:
+					return nil
+				}
+
+				if len(args) == 2 {
+					if msg, ok := args[1].(*String); ok {
+						return newError("assertion failed: %s", msg.Value)
+					}
+					return newError("assertion failed: %s", args[1].Inspect())
+				}
+
+				return newError("assertion failed")
+			},
+		},
+	},
+	{
+		Name:  "spawn",
+		Arity: -1,
+		Builtin: &Builtin{
+			CtxFn: func(ctx context.Context, args ...Object) Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=0, want at least 1")
+				}
+
+				caller := Caller(ctx)
+				if caller == nil {
+					return newError("spawn is not supported here")
+				}
+
+				// Forked before starting the goroutine, so the task sees the
+				// program as it is now
+				forked := caller.Fork()
+				fn, fnArgs := args[0], append([]Object{}, args[1:]...)
+
+				return StartTask(func() Object {
+					return forked.Call(fn, fnArgs...)
+				})
+			},
+		},
+	},
+	{
+		Name:  "wait",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				task, ok := args[0].(*Task)
+				if !ok {
+					return newError("argument to `wait` must be TASK, got %s", args[0].Type())
+				}
+
+				return task.Wait()
+			},
+		},
+	},
+	{
+		Name:  "chan",
+		Arity: -1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) > 1 {
+					return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+				}
+
+				if len(args) == 0 {
+					return NewChannel(0)
+				}
+
+				size, ok := args[0].(*Integer)
+				if !ok {
+					return newError("argument to `chan` must be INTEGER, got %s", args[0].Type())
+				}
+				if size.Value < 0 {
+					return newError("negative channel size: %d", size.Value)
+				}
+
+				return NewChannel(int(size.Value))
+			},
+		},
+	},
+	{
+		Name:  "send",
+		Arity: 2,
+		Builtin: &Builtin{
+			CtxFn: func(ctx context.Context, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				ch, ok := args[0].(*Channel)
+				if !ok {
+					return newError("argument to `send` must be CHANNEL, got %s", args[0].Type())
+				}
+
+				if err := ch.Send(ctx, args[1]); err != nil {
+					return newError("%s", err)
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		Name:  "recv",
+		Arity: 1,
+		Builtin: &Builtin{
+			CtxFn: func(ctx context.Context, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				ch, ok := args[0].(*Channel)
+				if !ok {
+					return newError("argument to `recv` must be CHANNEL, got %s", args[0].Type())
+				}
+
+				// Closed channels give null, like the zero value in Go
+				v, _, err := ch.Receive(ctx)
+				if err != nil {
+					return newError("%s", err)
+				}
+
+				return v
+			},
+		},
+	},
+	{
+		Name:  "close",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				var err error
+				switch arg := args[0].(type) {
+				case *Channel:
+					err = arg.Close()
+				case *File:
+					err = arg.Close()
+				default:
+					return newError("argument to `close` must be CHANNEL or FILE, got %s", args[0].Type())
+				}
+
+				if err != nil {
+					return newError("%s", err)
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		Name:  "bigint",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer:
+					return &BigInt{Value: big.NewInt(arg.Value)}
+				case *BigInt:
+					return arg
+				case *String:
+					value, ok := new(big.Int).SetString(arg.Value, 10)
+					if !ok {
+						return newError("could not parse %q as an integer", arg.Value)
+					}
+					return &BigInt{Value: value}
+				default:
+					return newError("argument to `bigint` must be INTEGER or STRING, got %s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		Name:  "freeze",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				return Freeze(args[0])
+			},
+		},
+	},
+	{
+		Name:  "frozen",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				if IsFrozen(args[0]) {
+					return TRUE
+				}
+				return FALSE
+			},
+		},
+	},
+	{
+		Name:  "memoize",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch args[0].(type) {
+				case *FunctionValue, *Closure, *Builtin:
+					return Memoize(args[0])
+				default:
+					return newError("argument to `memoize` must be FUNCTION, got %s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		Name:  "keys",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				hash, ok := args[0].(*Hash)
+				if !ok {
+					return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+				}
+
+				keys := []Object{}
+				for _, pair := range SortedPairs(hash) {
+					keys = append(keys, pair.Key)
+				}
+				return &Array{Elements: keys}
+			},
+		},
+	},
+	{
+		Name:  "values",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				hash, ok := args[0].(*Hash)
+				if !ok {
+					return newError("argument to `values` must be HASH, got %s", args[0].Type())
+				}
+
+				values := []Object{}
+				for _, pair := range SortedPairs(hash) {
+					values = append(values, pair.Value)
+				}
+				return &Array{Elements: values}
+			},
+		},
+	},
+	{
+		Name:  "builtins",
+		Arity: 0,
+		// Fn is set in init, as it refers to Builtins
+		Builtin: &Builtin{},
+	},
+	{
+		Name:    "startsWith",
+		Arity:   2,
+		Builtin: &Builtin{Fn: stringPredicate("startsWith", strings.HasPrefix)},
+	},
+	{
+		Name:    "endsWith",
+		Arity:   2,
+		Builtin: &Builtin{Fn: stringPredicate("endsWith", strings.HasSuffix)},
+	},
+	{
+		Name:  "reverse",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				arr, ok := args[0].(*Array)
+				if !ok {
+					return newError("argument to `reverse` must be ARRAY, got %s", args[0].Type())
+				}
+
+				elements := make([]Object, len(arr.Elements))
+				for i, el := range arr.Elements {
+					elements[len(elements)-1-i] = el
+				}
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		Name:  "concat",
+		Arity: 2,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				elements := []Object{}
+				for _, arg := range args {
+					arr, ok := arg.(*Array)
+					if !ok {
+						return newError("arguments to `concat` must be ARRAY, got %s", arg.Type())
+					}
+					elements = append(elements, arr.Elements...)
+				}
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		Name:  "slice",
+		Arity: 3,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=3", len(args))
+				}
+
+				// Strings are sliced by characters
+				var length int
+				switch arg := args[0].(type) {
+				case *Array:
+					length = len(arg.Elements)
+				case *String:
+					length = arg.Len()
+				default:
+					return newError("argument to `slice` must be ARRAY or STRING, got %s", args[0].Type())
+				}
+
+				// Negative indexes count back from the end, and ones out of
+				// range are clamped to it
+				bounds := [2]int{}
+				for i, arg := range args[1:] {
+					index, ok := arg.(*Integer)
+					if !ok {
+						return newError("indexes to `slice` must be INTEGER, got %s", arg.Type())
+					}
+
+					n := int(index.Value)
+					if n < 0 {
+						n += length
+					}
+					bounds[i] = min(max(n, 0), length)
+				}
+
+				start, end := bounds[0], max(bounds[0], bounds[1])
+				if str, ok := args[0].(*String); ok {
+					return &String{Value: string([]rune(str.Value)[start:end])}
+				}
+
+				elements := make([]Object, end-start)
+				copy(elements, args[0].(*Array).Elements[start:end])
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		Name:  "flatten",
+		Arity: -1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+
+				arr, ok := args[0].(*Array)
+				if !ok {
+					return newError("argument to `flatten` must be ARRAY, got %s", args[0].Type())
+				}
+
+				depth := int64(1)
+				if len(args) == 2 {
+					n, ok := args[1].(*Integer)
+					if !ok {
+						return newError("depth of `flatten` must be INTEGER, got %s", args[1].Type())
+					}
+					depth = n.Value
+				}
+
+				return &Array{Elements: flatten(arr.Elements, depth)}
+			},
+		},
+	},
+	{
+		Name:  "zip",
+		Arity: 2,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				for _, arg := range args {
+					if arg.Type() != ARRAY_OBJ {
+						return newError("arguments to `zip` must be ARRAY, got %s", arg.Type())
+					}
+				}
+				a, b := args[0].(*Array).Elements, args[1].(*Array).Elements
+
+				// Extra elements of the longer array are left out
+				pairs := make([]Object, min(len(a), len(b)))
+				for i := range pairs {
+					pairs[i] = &Array{Elements: []Object{a[i], b[i]}}
+				}
+				return &Array{Elements: pairs}
+			},
+		},
+	},
+	{
+		Name:  "parse_int",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				str, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `parse_int` must be STRING, got %s", args[0].Type())
+				}
+
+				value, err := ParseInt(str.Value)
+				if err != nil {
+					return stringHash("ok", FALSE, "value", NULL, "error", &String{Value: err.Error()})
+				}
+				return stringHash("ok", TRUE, "value", value, "error", NULL)
+			},
+		},
+	},
+	{
+		Name:  "now",
+		Arity: 0,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+
+				return &Time{Value: time.Now()}
+			},
+		},
+	},
+	{
+		Name:  "parse_time",
+		Arity: 2,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				for _, arg := range args {
+					if arg.Type() != STRING_OBJ {
+						return newError("arguments to `parse_time` must be STRING, got %s", arg.Type())
+					}
+				}
+
+				// Layouts are written like Go's, as the reference time
+				// 2006-01-02 15:04:05
+				t, err := time.Parse(args[1].(*String).Value, args[0].(*String).Value)
+				if err != nil {
+					return newError("could not parse time: %s", err)
+				}
+				return &Time{Value: t}
+			},
+		},
+	},
+	{
+		Name:  "format",
+		Arity: 2,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				t, ok := args[0].(*Time)
+				if !ok {
+					return newError("argument to `format` must be TIME, got %s", args[0].Type())
+				}
+				layout, ok := args[1].(*String)
+				if !ok {
+					return newError("layout of `format` must be STRING, got %s", args[1].Type())
+				}
+
+				return &String{Value: t.Value.Format(layout.Value)}
+			},
+		},
+	},
+	{
+		Name:  "add",
+		Arity: 2,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				t, ok := args[0].(*Time)
+				if !ok {
+					return newError("argument to `add` must be TIME, got %s", args[0].Type())
+				}
+				ms, ok := args[1].(*Integer)
+				if !ok {
+					return newError("milliseconds to `add` must be INTEGER, got %s", args[1].Type())
+				}
+
+				return &Time{Value: t.Value.Add(time.Duration(ms.Value) * time.Millisecond)}
+			},
+		},
+	},
+	{
+		Name:  "open",
+		Arity: -1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+
+				for _, arg := range args {
+					if arg.Type() != STRING_OBJ {
+						return newError("arguments to `open` must be STRING, got %s", arg.Type())
+					}
+				}
+
+				mode := "r"
+				if len(args) == 2 {
+					mode = args[1].(*String).Value
+				}
+
+				file, err := OpenFile(args[0].(*String).Value, mode)
+				if err != nil {
+					return newError("%s", err)
+				}
+				return file
+			},
+		},
+	},
+	{
+		Name:  "read_line",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				file, ok := args[0].(*File)
+				if !ok {
+					return newError("argument to `read_line` must be FILE, got %s", args[0].Type())
+				}
+
+				// Null once there are no more lines
+				line, ok, err := file.ReadLine()
+				if err != nil {
+					return newError("%s", err)
+				}
+				if !ok {
+					return nil
+				}
+				return &String{Value: line}
+			},
+		},
+	},
+	{
+		Name:  "write",
+		Arity: 2,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				file, ok := args[0].(*File)
+				if !ok {
+					return newError("argument to `write` must be FILE, got %s", args[0].Type())
+				}
+				str, ok := args[1].(*String)
+				if !ok {
+					return newError("text to `write` must be STRING, got %s", args[1].Type())
+				}
+
+				if err := file.Write(str.Value); err != nil {
+					return newError("%s", err)
+				}
+				return nil
+			},
+		},
+	},
+	{
+		Name:  "list_dir",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				path, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `list_dir` must be STRING, got %s", args[0].Type())
+				}
+
+				// Sorted by name
+				entries, err := os.ReadDir(path.Value)
+				if err != nil {
+					return newError("%s", err)
+				}
+
+				names := make([]Object, len(entries))
+				for i, entry := range entries {
+					names[i] = &String{Value: entry.Name()}
+				}
+				return &Array{Elements: names}
+			},
+		},
+	},
+	{
+		Name:  "exists",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				path, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `exists` must be STRING, got %s", args[0].Type())
+				}
+
+				_, err := os.Stat(path.Value)
+				if errors.Is(err, fs.ErrNotExist) {
+					return FALSE
+				}
+				if err != nil {
+					return newError("%s", err)
+				}
+				return TRUE
+			},
+		},
+	},
+	{
+		Name:  "mkdir",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				path, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `mkdir` must be STRING, got %s", args[0].Type())
+				}
+
+				// Parents are created too, and directories that already exist
+				// are left alone
+				if err := os.MkdirAll(path.Value, 0755); err != nil {
+					return newError("%s", err)
+				}
+				return nil
+			},
+		},
+	},
+	{
+		Name:  "path_join",
+		Arity: -1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				elems := make([]string, len(args))
+				for i, arg := range args {
+					str, ok := arg.(*String)
+					if !ok {
+						return newError("arguments to `path_join` must be STRING, got %s", arg.Type())
+					}
+					elems[i] = str.Value
+				}
+
+				return &String{Value: filepath.Join(elems...)}
+			},
+		},
+	},
+	{
+		Name:    "cwd",
+		Arity:   0,
+		Builtin: &Builtin{Fn: processInfo(os.Getwd)},
+	},
+	{
+		Name:  "pid",
+		Arity: 0,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+
+				return NewInteger(int64(os.Getpid()))
+			},
+		},
+	},
+	{
+		Name:    "hostname",
+		Arity:   0,
+		Builtin: &Builtin{Fn: processInfo(os.Hostname)},
+	},
+	{
+		Name:  "platform",
+		Arity: 0,
+		Builtin: &Builtin{
+			Fn: processInfo(func() (string, error) {
+				return runtime.GOOS, nil
+			}),
+		},
+	},
+	{
+		Name:  "csv_parse",
+		Arity: -1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+
+				text, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `csv_parse` must be STRING, got %s", args[0].Type())
+				}
+
+				// Options are a hash, {"header": true} to make each record
+				// after the first a hash keyed by the first's fields
+				header := false
+				if len(args) == 2 {
+					options, ok := args[1].(*Hash)
+					if !ok {
+						return newError("options to `csv_parse` must be HASH, got %s", args[1].Type())
+					}
+
+					key := &String{Value: "header"}
+					if pair, ok := options.Pairs[key.HashKey()]; ok {
+						header = pair.Value == TRUE
+					}
+				}
+
+				rows, err := parseCSV(text.Value, header)
+				if err != nil {
+					return newError("could not parse CSV: %s", err)
+				}
+				return rows
+			},
+		},
+	},
+	{
+		Name:  "csv_encode",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				rows, ok := args[0].(*Array)
+				if !ok {
+					return newError("argument to `csv_encode` must be ARRAY, got %s", args[0].Type())
+				}
+
+				text, err := encodeCSV(rows)
+				if err != nil {
+					return newError("%s", err)
+				}
+				return &String{Value: text}
+			},
+		},
+	},
+	{
+		Name:  "b64_encode",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: stringConversion("b64_encode", func(s string) (string, error) {
+				return base64.StdEncoding.EncodeToString([]byte(s)), nil
+			}),
+		},
+	},
+	{
+		Name:  "b64_decode",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: stringConversion("b64_decode", func(s string) (string, error) {
+				b, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return "", fmt.Errorf("invalid base64: %s", err)
+				}
+				return string(b), nil
+			}),
+		},
+	},
+	{
+		Name:  "hex_encode",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: stringConversion("hex_encode", func(s string) (string, error) {
+				return hex.EncodeToString([]byte(s)), nil
+			}),
+		},
+	},
+	{
+		Name:  "hex_decode",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: stringConversion("hex_decode", func(s string) (string, error) {
+				b, err := hex.DecodeString(s)
+				if err != nil {
+					return "", fmt.Errorf("invalid hex: %s", err)
+				}
+				return string(b), nil
+			}),
+		},
+	},
+	{
+		Name:  "uuid",
+		Arity: 0,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+
+				// A random, version 4 UUID as described in RFC 4122
+				var b [16]byte
+				rand.Read(b[:])
+				b[6] = b[6]&0x0f | 0x40
+				b[8] = b[8]&0x3f | 0x80
+
+				return &String{Value: fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])}
+			},
+		},
+	},
+	{
+		Name:  "sleep",
+		Arity: 1,
+		Builtin: &Builtin{
+			CtxFn: func(ctx context.Context, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				ms, ok := args[0].(*Integer)
+				if !ok {
+					return newError("argument to `sleep` must be INTEGER, got %s", args[0].Type())
+				}
+
+				// Cut short when the program is interrupted
+				timer := time.NewTimer(time.Duration(ms.Value) * time.Millisecond)
+				defer timer.Stop()
+
+				select {
+				case <-timer.C:
+					return nil
+				case <-ctx.Done():
+					return newError("execution interrupted: %s", ctx.Err())
+				}
+			},
+		},
+	},
+	{
+		Name:  "error",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				msg, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `error` must be STRING, got %s", args[0].Type())
+				}
+
+				return &Exception{Message: msg.Value}
+			},
+		},
+	},
+	{
+		Name:  "is_error",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				if args[0].Type() == EXCEPTION_OBJ {
+					return TRUE
+				}
+				return FALSE
+			},
+		},
+	},
+	{
+		Name:  "chars",
+		Arity: 1,
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				str, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `chars` must be STRING, got %s", args[0].Type())
+				}
+
+				// One string per character, not byte, so "é" isn't split
+				elements := []Object{}
+				for _, r := range str.Value {
+					elements = append(elements, &String{Value: string(r)})
+				}
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		Name:  "memory_stats",
+		Arity: 0,
+		Builtin: &Builtin{
+			CtxFn: memoryStats,
+		},
+	},
+}
+
+func init() {
+	GetBuiltinByName("builtins").Fn = listBuiltins
+}
+
+// listBuiltins returns a hash of the name and arity of each builtin, in the
+// order they're registered.
+func listBuiltins(args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+
+	elements := []Object{}
+	for _, def := range Builtins {
+		name := &String{Value: "name"}
+		arity := &String{Value: "arity"}
+
+		elements = append(elements, &Hash{Pairs: map[HashKey]HashPair{
+			name.HashKey():  {Key: name, Value: &String{Value: def.Name}},
+			arity.HashKey(): {Key: arity, Value: NewInteger(int64(def.Arity))},
+		}})
+	}
+
+	return &Array{Elements: elements}
+}
+
+// flatten returns elements with the elements of arrays among them in their
+// place, down to depth levels of nesting.
+func flatten(elements []Object, depth int64) []Object {
+	flat := []Object{}
+	for _, el := range elements {
+		if arr, ok := el.(*Array); ok && depth > 0 {
+			flat = append(flat, flatten(arr.Elements, depth-1)...)
+		} else {
+			flat = append(flat, el)
+		}
+	}
+
+	return flat
+}
+
+// ParseInt parses s as a decimal integer, or a hexadecimal, octal or binary
+// one with a 0x, 0o or 0b prefix, after an optional sign. Integers too big
+// for an Integer are returned as a BigInt.
+func ParseInt(s string) (Object, error) {
+	digits, negative := s, false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			digits = digits[2:]
+		}
+	}
+
+	// SetString would accept another sign after the prefix
+	value, ok := new(big.Int).SetString(digits, base)
+	if !ok || digits[0] == '-' || digits[0] == '+' {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	if negative {
+		value.Neg(value)
+	}
+
+	if value.IsInt64() {
+		return NewInteger(value.Int64()), nil
+	}
+	return &BigInt{Value: value}, nil
+}
+
+// stringHash returns a hash of alternating string keys and values.
+func stringHash(pairs ...any) *Hash {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for i := 0; i < len(pairs); i += 2 {
+		key := &String{Value: pairs[i].(string)}
+		hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: pairs[i+1].(Object)}
+	}
+
+	return hash
+}
+
+// stringPredicate returns the function of a builtin called name, which
+// checks two strings with pred.
+func stringPredicate(name string, pred func(s, t string) bool) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+
+		for _, arg := range args {
+			if arg.Type() != STRING_OBJ {
+				return newError("arguments to `%s` must be STRING, got %s", name, arg.Type())
+			}
+		}
+
+		if pred(args[0].(*String).Value, args[1].(*String).Value) {
+			return TRUE
+		}
+		return FALSE
+	}
+}
+
+// processInfo returns the function of a builtin taking no arguments, which
+// returns the string got from the operating system by get.
+func processInfo(get func() (string, error)) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+
+		s, err := get()
+		if err != nil {
+			return newError("%s", err)
+		}
+		return &String{Value: s}
+	}
+}
+
+// stringConversion returns the function of a builtin called name, which
+// turns a string into another with convert.
+func stringConversion(name string, convert func(s string) (string, error)) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		str, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+		}
+
+		s, err := convert(str.Value)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return &String{Value: s}
+	}
+}
+
+// builtinsByName indexes Builtins by name for GetBuiltinByName, which hosts
+// call on every call to a builtin through interp.
+var builtinsByName = indexBuiltins()
+
+func indexBuiltins() map[string]*Builtin {
+	index := make(map[string]*Builtin, len(Builtins))
+	for _, def := range Builtins {
+		index[def.Name] = def.Builtin
+	}
+
+	return index
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	return builtinsByName[name]
+}
+
+func newError(format string, a ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+type outputKey struct{}
+
+// WithOutput returns a copy of ctx in which builtins like puts write to w.
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// Output returns the writer builtins should print to, defaulting to stdout.
+func Output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
+	}
+
+	return os.Stdout
+}
+
+type argsKey struct{}
+
+// WithArgs returns a copy of ctx in which the args builtin returns args.
+func WithArgs(ctx context.Context, args []string) context.Context {
+	return context.WithValue(ctx, argsKey{}, args)
+}
+
+// Args returns the command-line arguments passed to the running script.
+func Args(ctx context.Context) []string {
+	args, _ := ctx.Value(argsKey{}).([]string)
+	return args
+}
+
+// DefaultMaxDepth is how deeply Monkey functions may recurse unless the
+// context says otherwise.
+const DefaultMaxDepth = 10000
+
+type maxDepthKey struct{}
+
+// WithMaxDepth returns a copy of ctx in which programs fail with "maximum
+// recursion depth exceeded" once more than n function calls are active.
+func WithMaxDepth(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxDepthKey{}, n)
+}
+
+// MaxDepth returns the recursion limit set with WithMaxDepth, or
+// DefaultMaxDepth.
+func MaxDepth(ctx context.Context) int {
+	if n, ok := ctx.Value(maxDepthKey{}).(int); ok && n > 0 {
+		return n
+	}
+
+	return DefaultMaxDepth
+}
+
+type strictKey struct{}
+
+// WithStrict returns a copy of ctx in which programs run in strict mode,
+// failing where they'd otherwise carry on: on indexes out of range, on
+// conditions that aren't booleans, and on undeclared names even in code
+// that doesn't run.
+func WithStrict(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strictKey{}, true)
+}
+
+// Strict reports whether programs run in strict mode.
+func Strict(ctx context.Context) bool {
+	strict, _ := ctx.Value(strictKey{}).(bool)
+	return strict
+}
+
+// FunctionCaller lets builtins call Monkey functions passed to them. Each
+// engine puts its own in the context handed to builtins.
+type FunctionCaller interface {
+	// Call calls fn with args and returns its result, or an error object.
+	Call(fn Object, args ...Object) Object
+	// Fork returns a FunctionCaller that can be used from another goroutine
+	// while the program carries on.
+	Fork() FunctionCaller
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx in which builtins call functions with c.
+func WithCaller(ctx context.Context, c FunctionCaller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// Caller returns the FunctionCaller of the running engine, or nil outside of
+// one.
+func Caller(ctx context.Context) FunctionCaller {
+	c, _ := ctx.Value(callerKey{}).(FunctionCaller)
+	return c
 }
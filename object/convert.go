@@ -0,0 +1,142 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// ToGoValue converts obj to a plain Go value: integers become int64 or, for
+// BigInts, *big.Int, strings string, booleans bool, null nil, times
+// time.Time, arrays []any, and hashes map[string]any when every key is a
+// string or map[any]any otherwise. Values with no Go counterpart, e.g.
+// functions, are returned unchanged.
+func ToGoValue(obj Object) any {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value
+	case *BigInt:
+		return new(big.Int).Set(obj.Value)
+	case *String:
+		return obj.Value
+	case *Boolean:
+		return obj.Value
+	case *Time:
+		return obj.Value
+	case *Null:
+		return nil
+	case *Array:
+		values := make([]any, len(obj.Elements))
+		for i, el := range obj.Elements {
+			values[i] = ToGoValue(el)
+		}
+		return values
+	case *Hash:
+		return hashToGoValue(obj)
+	default:
+		return obj
+	}
+}
+
+func hashToGoValue(hash *Hash) any {
+	stringKeys := true
+	for _, pair := range hash.Pairs {
+		if _, ok := pair.Key.(*String); !ok {
+			stringKeys = false
+			break
+		}
+	}
+
+	if stringKeys {
+		values := make(map[string]any, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			values[pair.Key.(*String).Value] = ToGoValue(pair.Value)
+		}
+		return values
+	}
+
+	values := make(map[any]any, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		values[ToGoValue(pair.Key)] = ToGoValue(pair.Value)
+	}
+	return values
+}
+
+// FromGoValue converts a Go value to a Monkey object. It accepts nil, bools,
+// signed and unsigned integers, *big.Int, strings, time.Time, slices and
+// arrays, maps with keys of those types, and Objects, which are returned
+// unchanged.
+// Unsigned integers too big for an int64 become BigInts.
+func FromGoValue(value any) (Object, error) {
+	if value == nil {
+		return NULL, nil
+	}
+
+	switch value := value.(type) {
+	case Object:
+		return value, nil
+	case *big.Int:
+		return &BigInt{Value: new(big.Int).Set(value)}, nil
+	case time.Time:
+		return &Time{Value: value}, nil
+	}
+
+	return fromReflectValue(reflect.ValueOf(value))
+}
+
+func fromReflectValue(v reflect.Value) (Object, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return &BigInt{Value: new(big.Int).SetUint64(v.Uint())}, nil
+		}
+		return NewInteger(int64(v.Uint())), nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			el, err := FromGoValue(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		pairs := make(map[HashKey]HashPair, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := FromGoValue(iter.Key().Interface())
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := FromGoValue(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return FromGoValue(v.Elem().Interface())
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Monkey value", v.Type())
+}
@@ -0,0 +1,22 @@
+package object
+
+// Copy returns obj with the arrays and hashes in it copied, so the copy can
+// be frozen without affecting obj. Other values are shared.
+func Copy(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := make([]Object, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = Copy(el)
+		}
+		return &Array{Elements: elements}
+	case *Hash:
+		pairs := make(map[HashKey]HashPair, len(obj.Pairs))
+		for key, pair := range obj.Pairs {
+			pairs[key] = HashPair{Key: Copy(pair.Key), Value: Copy(pair.Value)}
+		}
+		return &Hash{Pairs: pairs}
+	default:
+		return obj
+	}
+}
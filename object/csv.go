@@ -0,0 +1,79 @@
+package object
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// parseCSV returns the records of text as arrays of strings, or, if header
+// is set, as hashes keyed by the fields of the first record.
+func parseCSV(text string, header bool) (Object, error) {
+	records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []Object{}
+	if !header {
+		for _, record := range records {
+			rows = append(rows, csvRecord(record))
+		}
+		return &Array{Elements: rows}, nil
+	}
+
+	if len(records) == 0 {
+		return &Array{Elements: rows}, nil
+	}
+
+	names := records[0]
+	for _, record := range records[1:] {
+		row := &Hash{Pairs: map[HashKey]HashPair{}}
+		for i, field := range record {
+			key := &String{Value: names[i]}
+			row.Pairs[key.HashKey()] = HashPair{Key: key, Value: &String{Value: field}}
+		}
+		rows = append(rows, row)
+	}
+
+	return &Array{Elements: rows}, nil
+}
+
+func csvRecord(record []string) *Array {
+	fields := make([]Object, len(record))
+	for i, field := range record {
+		fields[i] = &String{Value: field}
+	}
+
+	return &Array{Elements: fields}
+}
+
+// encodeCSV returns rows, an array of arrays, as CSV text. Strings are
+// written as they are and other values as they're inspected.
+func encodeCSV(rows *Array) (string, error) {
+	var out strings.Builder
+	w := csv.NewWriter(&out)
+
+	for _, el := range rows.Elements {
+		row, ok := el.(*Array)
+		if !ok {
+			return "", fmt.Errorf("rows to `csv_encode` must be ARRAY, got %s", el.Type())
+		}
+
+		record := make([]string, len(row.Elements))
+		for i, field := range row.Elements {
+			if str, ok := field.(*String); ok {
+				record[i] = str.Value
+			} else {
+				record[i] = field.Inspect()
+			}
+		}
+
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+
+	w.Flush()
+	return out.String(), w.Error()
+}
@@ -0,0 +1,78 @@
+package object
+
+// Equal reports whether a and b are the same value. Numbers, strings, times
+// and exceptions are compared by value, arrays and hashes element by
+// element, and anything else, like functions, by identity.
+func Equal(a, b Object) bool {
+	return equal(a, b, map[[2]Object]bool{})
+}
+
+// seen holds the pairs of arrays and hashes being compared further up, so
+// ones that contain themselves don't recurse forever. Such a pair is taken
+// to be equal, leaving the outer comparison to decide.
+func equal(a, b Object, seen map[[2]Object]bool) bool {
+	if a == b {
+		return true
+	}
+
+	if IsNumber(a) && IsNumber(b) {
+		return CompareNumbers(a, b) == 0
+	}
+
+	switch a := a.(type) {
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Time:
+		b, ok := b.(*Time)
+		return ok && a.Value.Equal(b.Value)
+	case *Exception:
+		b, ok := b.(*Exception)
+		return ok && a.Message == b.Message
+	case *Boolean:
+		// Booleans decoded from compiled programs aren't TRUE and FALSE
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+
+		pair := [2]Object{a, b}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+
+		for i, el := range a.Elements {
+			if !equal(el, b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+
+		pair := [2]Object{a, b}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+
+		for key, ap := range a.Pairs {
+			bp, ok := b.Pairs[key]
+			if !ok || !equal(ap.Key, bp.Key, seen) || !equal(ap.Value, bp.Value, seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
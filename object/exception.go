@@ -0,0 +1,29 @@
+package object
+
+// Exception is an error made by a program with error("message"). Unlike
+// Error, which stops the program, it's an ordinary value, so functions can
+// return one for their callers to check with is_error.
+type Exception struct {
+	Message string
+}
+
+func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (e *Exception) Inspect() string  { return "error: " + e.Message }
+
+// exceptionMethods are the methods of EXCEPTION.
+var exceptionMethods = map[string]*Builtin{
+	"message": {
+		Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			e, ok := args[0].(*Exception)
+			if !ok {
+				return newError("argument to `message` must be EXCEPTION, got %s", args[0].Type())
+			}
+
+			return &String{Value: e.Message}
+		},
+	},
+}
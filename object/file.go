@@ -0,0 +1,96 @@
+package object
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// File is a file opened with open, read a line at a time or written to. The
+// underlying os.File closes itself once it's garbage collected, so files a
+// program forgets to close are only held on to until then.
+type File struct {
+	Name string
+
+	mu     sync.Mutex
+	file   *os.File
+	reader *bufio.Reader
+	closed bool
+}
+
+func (f *File) Type() ObjectType { return FILE_OBJ }
+func (f *File) Inspect() string  { return fmt.Sprintf("File[%s]", f.Name) }
+
+// OpenFile opens name for reading with mode "r", writing from the start
+// with "w", or appending with "a". The last two create the file if needed.
+func OpenFile(name, mode string) (*File, error) {
+	flags := map[string]int{
+		"r": os.O_RDONLY,
+		"w": os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+		"a": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	}
+
+	flag, ok := flags[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown file mode %q, want r, w or a", mode)
+	}
+
+	file, err := os.OpenFile(name, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{Name: name, file: file, reader: bufio.NewReader(file)}, nil
+}
+
+// ReadLine returns the next line without its line ending, and false once
+// the end of the file has been reached.
+func (f *File) ReadLine() (string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return "", false, fmt.Errorf("read from closed file %s", f.Name)
+	}
+
+	line, err := f.reader.ReadString('\n')
+	if err == io.EOF {
+		// The last line needn't end with a newline
+		return line, line != "", nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), true, nil
+}
+
+// Write writes s to the file.
+func (f *File) Write(s string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("write to closed file %s", f.Name)
+	}
+
+	_, err := io.WriteString(f.file, s)
+	return err
+}
+
+// Close closes the file. Closing it again is an error.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("close of closed file %s", f.Name)
+	}
+	f.closed = true
+
+	return f.file.Close()
+}
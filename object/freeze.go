@@ -0,0 +1,41 @@
+package object
+
+// Freeze marks obj, and the arrays and hashes it contains, as frozen, and
+// returns it. No builtin changes arrays or hashes in place yet, so being
+// frozen is only seen through IsFrozen, which the frozen builtin reports.
+func Freeze(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			break
+		}
+		obj.Frozen = true
+		for _, el := range obj.Elements {
+			Freeze(el)
+		}
+	case *Hash:
+		if obj.Frozen {
+			break
+		}
+		obj.Frozen = true
+		for _, pair := range obj.Pairs {
+			Freeze(pair.Key)
+			Freeze(pair.Value)
+		}
+	}
+
+	return obj
+}
+
+// IsFrozen reports whether obj can't be changed. Values other than arrays
+// and hashes never can, so they count as frozen.
+func IsFrozen(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Frozen
+	case *Hash:
+		return obj.Frozen
+	default:
+		return true
+	}
+}
@@ -0,0 +1,67 @@
+package object
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+)
+
+// Memoize returns a builtin that calls fn through the running engine's
+// FunctionCaller and caches the results by argument, so fn should be pure.
+// Arguments must be hashable. Errors aren't cached.
+func Memoize(fn Object) *Builtin {
+	var mu sync.Mutex
+	cache := map[string]Object{}
+
+	return &Builtin{
+		CtxFn: func(ctx context.Context, args ...Object) Object {
+			key, err := memoKey(args)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			result, ok := cache[key]
+			mu.Unlock()
+			if ok {
+				return result
+			}
+
+			caller := Caller(ctx)
+			if caller == nil {
+				return newError("memoized functions are not supported here")
+			}
+
+			// The lock isn't held during the call, as fn is usually
+			// recursive. Concurrent calls may both compute a result.
+			result = caller.Call(fn, args...)
+			if _, ok := result.(*Error); ok {
+				return result
+			}
+
+			mu.Lock()
+			cache[key] = result
+			mu.Unlock()
+
+			return result
+		},
+	}
+}
+
+// memoKey builds a cache key from the hash keys of args.
+func memoKey(args []Object) (string, *Error) {
+	key := make([]byte, 0, 16*len(args))
+	for _, arg := range args {
+		hashable, ok := arg.(Hashable)
+		if !ok {
+			return "", newError("unusable as memoize key: %s", arg.Type())
+		}
+
+		hk := hashable.HashKey()
+		key = append(key, hk.Type...)
+		key = append(key, 0)
+		key = binary.LittleEndian.AppendUint64(key, hk.Value)
+	}
+
+	return string(key), nil
+}
@@ -0,0 +1,110 @@
+package object
+
+import (
+	"sort"
+	"strings"
+)
+
+// Methods lists the methods of each type, called as value.name(args). They
+// are builtins taking the value as their first argument, so arr.push(1) is
+// push(arr, 1).
+var Methods = map[ObjectType]map[string]*Builtin{
+	STRING_OBJ: {
+		"len":        GetBuiltinByName("len"),
+		"startsWith": GetBuiltinByName("startsWith"),
+		"endsWith":   GetBuiltinByName("endsWith"),
+		"chars":      GetBuiltinByName("chars"),
+		"slice":      GetBuiltinByName("slice"),
+	},
+	ARRAY_OBJ: {
+		"len":   GetBuiltinByName("len"),
+		"first": GetBuiltinByName("first"),
+		"last":  GetBuiltinByName("last"),
+		"rest":  GetBuiltinByName("rest"),
+		"push":  GetBuiltinByName("push"),
+
+		"reverse": GetBuiltinByName("reverse"),
+		"concat":  GetBuiltinByName("concat"),
+		"slice":   GetBuiltinByName("slice"),
+		"flatten": GetBuiltinByName("flatten"),
+		"zip":     GetBuiltinByName("zip"),
+	},
+	HASH_OBJ: {
+		"len":    GetBuiltinByName("len"),
+		"keys":   GetBuiltinByName("keys"),
+		"values": GetBuiltinByName("values"),
+	},
+	TIME_OBJ: timeMethods,
+	FILE_OBJ: {
+		"read_line": GetBuiltinByName("read_line"),
+		"write":     GetBuiltinByName("write"),
+		"close":     GetBuiltinByName("close"),
+	},
+	EXCEPTION_OBJ: exceptionMethods,
+}
+
+// LookupMethod returns the method called name of obj's type.
+func LookupMethod(obj Object, name string) (*Builtin, bool) {
+	method, ok := Methods[obj.Type()][name]
+	return method, ok
+}
+
+// Method returns the function in the field called name of h, if there is
+// one. Hashes holding functions act as objects: h.name(args) calls the
+// function with h as its first argument, conventionally called self, and
+// takes precedence over the methods of HASH.
+func (h *Hash) Method(name string) (Object, bool) {
+	pair, ok := h.Pairs[(&String{Value: name}).HashKey()]
+	if !ok {
+		return nil, false
+	}
+
+	switch pair.Value.(type) {
+	case *FunctionValue, *Closure, *Builtin:
+		return pair.Value, true
+	default:
+		return nil, false
+	}
+}
+
+// SortedPairs returns the pairs of h ordered by key, so they come out the
+// same way every time. Numbers, strings and booleans are each in their
+// natural order, and grouped by type.
+func SortedPairs(h *Hash) []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return compareKeys(pairs[i].Key, pairs[j].Key) < 0
+	})
+
+	return pairs
+}
+
+func compareKeys(a, b Object) int {
+	if IsNumber(a) && IsNumber(b) {
+		return CompareNumbers(a, b)
+	}
+
+	if a.Type() != b.Type() {
+		return strings.Compare(string(a.Type()), string(b.Type()))
+	}
+
+	switch a := a.(type) {
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value)
+	case *Boolean:
+		switch {
+		case a.Value == b.(*Boolean).Value:
+			return 0
+		case a.Value:
+			return 1
+		default:
+			return -1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,19 @@
+package object
+
+// Module is what importing a file evaluates to. Its exports are read like
+// the fields of a hash, m.name, and called like methods, m.name(args), but
+// unlike a hash's functions they aren't passed the module.
+type Module struct {
+	// The path the module was imported as
+	Name    string
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "module " + m.Name }
+
+// Export returns the value the module exports as name.
+func (m *Module) Export(name string) (Object, bool) {
+	value, ok := m.Exports[name]
+	return value, ok
+}
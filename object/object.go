@@ -2,17 +2,24 @@ package object
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"hash/fnv"
+	"math/big"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/token"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	BIGINT_OBJ       = "BIGINT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -22,9 +29,16 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	TASK_OBJ         = "TASK"
+	CHANNEL_OBJ      = "CHANNEL"
+	TIME_OBJ         = "TIME"
+	FILE_OBJ         = "FILE"
+	EXCEPTION_OBJ    = "EXCEPTION"
 	// Specifically for VM
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
 	CLOSURE_OBJ           = "CLOSURE"
+	PATTERN_OBJ           = "PATTERN"
+	MODULE_OBJ            = "MODULE"
 )
 
 type Object interface {
@@ -46,6 +60,20 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
 
+// BigInt is an integer of any size. Integer arithmetic that overflows an
+// int64 results in one, as does the bigint builtin.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+func (b *BigInt) Type() ObjectType {
+	return BIGINT_OBJ
+}
+
 // Booleans
 
 type Boolean struct {
@@ -71,6 +99,45 @@ func (n *Null) Type() ObjectType {
 	return NULL_OBJ
 }
 
+// Booleans and null are compared by identity, so both engines and host code
+// converting Go values share these instances.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
+// Integers in this range are allocated once and shared, as loop counters,
+// indices and the like make up most of the arithmetic programs do.
+const (
+	minSmallInteger = -128
+	maxSmallInteger = 1024
+)
+
+var smallIntegers = func() []Integer {
+	ints := make([]Integer, maxSmallInteger-minSmallInteger+1)
+	for i := range ints {
+		ints[i].Value = int64(i + minSmallInteger)
+	}
+	return ints
+}()
+
+// NewInteger returns an Integer holding value, shared if it's a small one.
+// Integers are never modified, so sharing them is safe.
+func NewInteger(value int64) *Integer {
+	if IsSmallInteger(value) {
+		return &smallIntegers[value-minSmallInteger]
+	}
+
+	return &Integer{Value: value}
+}
+
+// IsSmallInteger reports whether NewInteger shares the Integer for value
+// rather than allocating one.
+func IsSmallInteger(value int64) bool {
+	return value >= minSmallInteger && value <= maxSmallInteger
+}
+
 // Return
 type ReturnValue struct {
 	Value Object
@@ -82,14 +149,88 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 // Errors
 type Error struct {
 	Message string
+	// Where the error happened, invalid if not known
+	Pos token.Position
+	// The function calls the error passed through on its way up, innermost
+	// first
+	Stack []StackFrame
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Error() }
+
+// Error returns the message followed by the position, if known. It lets
+// engines hand Monkey errors back to Go code as they are.
+func (e *Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+
+	return e.Message + " at " + e.Pos.String()
+}
+
+// Traces longer than this many lines are cut short, leaving out the
+// outermost calls
+const maxStackTrace = 20
+
+// StackTrace returns one line per call the error passed through, innermost
+// first, e.g.
+//
+//	in inner (called at 3:5)
+//	in outer (called at 7:1)
+//
+// Runs of the same call, as in deep recursion, are printed once.
+func (e *Error) StackTrace() string {
+	var out strings.Builder
+
+	lines := 0
+	for i := 0; i < len(e.Stack); lines++ {
+		if lines == maxStackTrace {
+			fmt.Fprintf(&out, "  ... %d more calls\n", len(e.Stack)-i)
+			break
+		}
+
+		frame := e.Stack[i]
+		out.WriteString("  in " + frame.String() + "\n")
+
+		repeats := 0
+		for i++; i < len(e.Stack) && e.Stack[i] == frame; i++ {
+			repeats++
+		}
+		if repeats > 0 {
+			fmt.Fprintf(&out, "  ... repeated %d more times\n", repeats)
+			lines++
+		}
+	}
+
+	return out.String()
+}
+
+// StackFrame is a call to a Monkey function.
+type StackFrame struct {
+	// Empty for anonymous functions
+	Function string
+	// Where the function was called from, invalid if not known
+	Pos token.Position
+}
+
+func (f StackFrame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "anonymous function"
+	}
+
+	if !f.Pos.IsValid() {
+		return name
+	}
+
+	return fmt.Sprintf("%s (called at %s)", name, f.Pos)
+}
 
 // Environment
 
 func NewEnvironment() *Environment {
+	environments.Add(1)
 	s := make(map[string]Object)
 	return &Environment{store: s, outer: nil}
 }
@@ -100,13 +241,42 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewFunctionEnvironment returns the environment for a call of a function
+// with the given local variables, as listed in ast.FunctionLiteral.Locals.
+// They are kept in slots, indexed like names, instead of a map. The first
+// slots are bound to args.
+func NewFunctionEnvironment(outer *Environment, names []string, args []Object) *Environment {
+	environments.Add(1)
+	slots := make([]Object, max(len(names), len(args)))
+	copy(slots, args)
+
+	return &Environment{slots: slots, names: names, outer: outer}
+}
+
+// Environments may be shared by tasks started with spawn, so access to the
+// store is locked.
 type Environment struct {
+	mu    sync.RWMutex
 	store map[string]Object
 	outer *Environment
+
+	// Function environments have slots instead of a store. A nil slot hasn't
+	// been bound yet.
+	slots []Object
+	names []string
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	val, ok := e.store[name]
+	e.mu.RLock()
+	var val Object
+	ok := false
+	if e.store != nil {
+		val, ok = e.store[name]
+	} else if i := e.slotOf(name); i >= 0 {
+		val = e.slots[i]
+		ok = val != nil
+	}
+	e.mu.RUnlock()
 
 	// Recurse up environment chain to find outer scopes
 	if !ok && e.outer != nil {
@@ -117,15 +287,72 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
-	e.store[name] = val
+	e.mu.Lock()
+	if e.store != nil {
+		e.store[name] = val
+	} else if i := e.slotOf(name); i >= 0 {
+		e.slots[i] = val
+	} else {
+		// The names may be shared with other calls of the function, so
+		// they're copied rather than appended to
+		e.names = append(e.names[:len(e.names):len(e.names)], name)
+		e.slots = append(e.slots, val)
+	}
+	e.mu.Unlock()
 	return val
 }
 
+func (e *Environment) slotOf(name string) int {
+	for i := len(e.names) - 1; i >= 0; i-- {
+		if e.names[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetSlot returns the value in slot of the function environment depth levels
+// out from e, or nil if it hasn't been bound yet. The second result is the
+// environment the slot is in.
+func (e *Environment) GetSlot(depth, slot int) (Object, *Environment) {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+
+	e.mu.RLock()
+	val := e.slots[slot]
+	e.mu.RUnlock()
+
+	return val, e
+}
+
+// SetSlot binds slot of the function environment e to val.
+func (e *Environment) SetSlot(slot int, val Object) {
+	e.mu.Lock()
+	e.slots[slot] = val
+	e.mu.Unlock()
+}
+
+// Outer returns the environment e is enclosed by, nil for the global one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
 // Functions
 type FunctionValue struct {
+	// Name the function was bound to with let, if any
+	Name       string
 	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	// Type annotations of the parameters and result, see
+	// ast.FunctionLiteral
+	ParameterTypes []*ast.TypeAnnotation
+	ReturnType     *ast.TypeAnnotation
+	// Local variables of the function, see ast.FunctionLiteral.Locals. Nil
+	// if the function literal wasn't resolved, in which case locals are
+	// bound by name.
+	Locals []string
+	Body   *ast.BlockStatement
+	Env    *Environment
 }
 
 func (f *FunctionValue) Type() ObjectType { return FUNC_OBJ }
@@ -151,15 +378,59 @@ func (f *FunctionValue) Inspect() string {
 // Strings
 type String struct {
 	Value string
+
+	// Set by CacheHashKey
+	hashKey *HashKey
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
-func (s *String) Inspect() string  { return s.Value }
+
+// Inspect returns s quoted, with control characters escaped, so strings are
+// told apart from other values and each other in arrays like ["a,b", "c"].
+// puts prints strings as they are.
+func (s *String) Inspect() string { return strconv.Quote(s.Value) }
+
+// Len returns the number of characters in s. Like indexes into strings, it
+// counts characters rather than bytes, so len("é") is 1.
+func (s *String) Len() int {
+	return utf8.RuneCountInString(s.Value)
+}
+
+// Char returns the character at index i of s, or false if i is out of range.
+func (s *String) Char(i int64) (*String, bool) {
+	if i < 0 {
+		return nil, false
+	}
+
+	for _, r := range s.Value {
+		if i == 0 {
+			return &String{Value: string(r)}, true
+		}
+		i--
+	}
+
+	return nil, false
+}
 
 type BuiltinFunction func(args ...Object) Object
 
+// ContextBuiltinFunction is a builtin that needs the context of the running
+// evaluation, e.g. to find where output should go.
+type ContextBuiltinFunction func(ctx context.Context, args ...Object) Object
+
 type Builtin struct {
 	Fn BuiltinFunction
+	// CtxFn is called instead of Fn when set.
+	CtxFn ContextBuiltinFunction
+}
+
+// Call invokes the builtin with the context of the calling evaluation.
+func (b *Builtin) Call(ctx context.Context, args ...Object) Object {
+	if b.CtxFn != nil {
+		return b.CtxFn(ctx, args...)
+	}
+
+	return b.Fn(args...)
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -168,6 +439,8 @@ func (b *Builtin) Inspect() string  { return "builtin function" }
 // Array
 type Array struct {
 	Elements []Object
+	// Set by Freeze
+	Frozen bool
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
@@ -190,6 +463,8 @@ func (a *Array) Inspect() string {
 // Hashes
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	// Set by Freeze
+	Frozen bool
 }
 
 type HashPair struct {
@@ -241,18 +516,59 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey of a BigInt small enough to be an Integer is the Integer's, so
+// equal numbers find the same hash entries.
+func (b *BigInt) HashKey() HashKey {
+	if b.Value.IsInt64() {
+		return (&Integer{Value: b.Value.Int64()}).HashKey()
+	}
+
+	h := fnv.New64a()
+	h.Write(b.Value.Bytes())
+	if b.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
 func (s *String) HashKey() HashKey {
+	if s.hashKey != nil {
+		return *s.hashKey
+	}
+
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
 
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// CacheHashKey works out the hash key of s once, for strings like constants
+// that are used as keys over and over. It must be called before s is
+// shared, as it isn't safe to call while s is in use.
+func (s *String) CacheHashKey() {
+	key := s.HashKey()
+	s.hashKey = &key
+}
+
 type CompiledFunction struct {
 	Instructions code.Instructions
 	NumLocals    int
 	// Needed for argument length validation during calls.
 	NumParameters int
+	// Parameter names, for calls with named arguments
+	Parameters []string
+	// Types the parameters are annotated with, "" for those that aren't, or
+	// nil if none are, and the type of the result, if annotated
+	ParameterTypes []string
+	ReturnType     string
+	// Names of the locals and free variables by index, for debugging
+	LocalNames []string
+	FreeNames  []string
+	// Name the function was bound to with let, if any
+	Name string
+	// Where the instructions came from, for runtime error positions
+	SourceMap code.SourceMap
 }
 
 func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
@@ -274,3 +590,82 @@ func (c *Closure) Type() ObjectType {
 func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
+
+// Task is a function running concurrently with the code that spawned it.
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+// StartTask runs run on a new goroutine.
+func StartTask(run func() Object) *Task {
+	t := &Task{done: make(chan struct{})}
+
+	go func() {
+		defer close(t.done)
+		t.result = run()
+	}()
+
+	return t
+}
+
+// Wait blocks until the task has finished and returns its result.
+func (t *Task) Wait() Object {
+	<-t.done
+	return t.result
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string  { return fmt.Sprintf("Task[%p]", t) }
+
+// Channel passes values between tasks, with the semantics of a Go channel.
+type Channel struct {
+	ch chan Object
+}
+
+func NewChannel(size int) *Channel {
+	return &Channel{ch: make(chan Object, size)}
+}
+
+// Send blocks until v has been sent or ctx is done. Sending on a closed
+// channel is an error.
+func (c *Channel) Send(ctx context.Context, v Object) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("send on closed channel")
+		}
+	}()
+
+	select {
+	case c.ch <- v:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("execution interrupted: %s", ctx.Err())
+	}
+}
+
+// Receive blocks until a value is available or ctx is done. ok is false once
+// the channel has been closed and drained.
+func (c *Channel) Receive(ctx context.Context) (v Object, ok bool, err error) {
+	select {
+	case v, ok := <-c.ch:
+		return v, ok, nil
+	case <-ctx.Done():
+		return nil, false, fmt.Errorf("execution interrupted: %s", ctx.Err())
+	}
+}
+
+// Close closes the channel, closing it twice is an error.
+func (c *Channel) Close() (err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("close of closed channel")
+		}
+	}()
+
+	close(c.ch)
+	return nil
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string  { return fmt.Sprintf("Channel[%p]", c) }
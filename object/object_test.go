@@ -1,6 +1,14 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"monkey/token"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,4 +25,352 @@ func TestStringHashKey(t *testing.T) {
 	if hello1.HashKey() == diff1.HashKey() {
 		t.Errorf("strings with same content have different hash keys")
 	}
+
+	hello1.CacheHashKey()
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("cached hash key differs from the one worked out")
+	}
+}
+
+func TestStringInspect(t *testing.T) {
+	tests := []struct {
+		input    Object
+		expected string
+	}{
+		{&String{Value: "hi"}, `"hi"`},
+		{&String{Value: "a\tb\n\"c\"\\"}, `"a\tb\n\"c\"\\"`},
+		{&Array{Elements: []Object{&String{Value: "a,b"}, &String{Value: "c"}}}, `["a,b","c"]`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.input.Inspect(); got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestToGoValue(t *testing.T) {
+	hash := func(pairs ...Object) *Hash {
+		h := &Hash{Pairs: map[HashKey]HashPair{}}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Pairs[pairs[i].(Hashable).HashKey()] = HashPair{Key: pairs[i], Value: pairs[i+1]}
+		}
+		return h
+	}
+
+	tests := []struct {
+		input    Object
+		expected any
+	}{
+		{&Integer{Value: 5}, int64(5)},
+		{&BigInt{Value: big.NewInt(6)}, big.NewInt(6)},
+		{&String{Value: "a"}, "a"},
+		{TRUE, true},
+		{NULL, nil},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "b"}}}, []any{int64(1), "b"}},
+		{hash(&String{Value: "a"}, &Integer{Value: 1}), map[string]any{"a": int64(1)}},
+		{hash(&Integer{Value: 1}, TRUE), map[any]any{int64(1): true}},
+		{&Time{Value: time.Unix(60, 0).UTC()}, time.Unix(60, 0).UTC()},
+	}
+
+	for _, tt := range tests {
+		value := ToGoValue(tt.input)
+		if !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("ToGoValue(%s): expected %#v, got %#v", tt.input.Inspect(), tt.expected, value)
+		}
+	}
+}
+
+func TestFromGoValue(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{"hi", `"hi"`},
+		{true, "true"},
+		{[]int{1, 2}, "[1,2]"},
+		{[]any{1, "a", []string{"b"}}, `[1,"a",["b"]]`},
+		{map[string]int{"a": 1}, `{"a": 1}`},
+		{&Integer{Value: 3}, "3"},
+		{uint64(1 << 63), "9223372036854775808"},
+		{new(big.Int).Lsh(big.NewInt(1), 70), "1180591620717411303424"},
+		{time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC), "2024-03-05T14:07:09Z"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGoValue(tt.input)
+		if err != nil {
+			t.Errorf("FromGoValue(%#v) failed: %s", tt.input, err)
+			continue
+		}
+
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGoValue(%#v): expected %s, got %s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	if obj, _ := FromGoValue(false); obj != FALSE {
+		t.Errorf("expected the shared FALSE instance, got %#v", obj)
+	}
+
+	for _, input := range []any{1.5, map[[2]int]int{{1, 2}: 3}, struct{}{}} {
+		if _, err := FromGoValue(input); err == nil {
+			t.Errorf("expected FromGoValue(%#v) to fail", input)
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	pos := func(line, column int) token.Position {
+		return token.Position{Line: line, Column: column}
+	}
+
+	tests := []struct {
+		stack    []StackFrame
+		expected string
+	}{
+		{nil, ""},
+		{
+			[]StackFrame{{"inner", pos(3, 5)}, {"", pos(7, 1)}},
+			"  in inner (called at 3:5)\n  in anonymous function (called at 7:1)\n",
+		},
+		{
+			[]StackFrame{{Function: "inner"}, {Function: "outer"}},
+			"  in inner\n  in outer\n",
+		},
+		{
+			[]StackFrame{{"f", pos(1, 2)}, {"f", pos(1, 2)}, {"f", pos(1, 2)}, {"main", pos(2, 1)}},
+			"  in f (called at 1:2)\n  ... repeated 2 more times\n  in main (called at 2:1)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		err := &Error{Message: "boom", Stack: tt.stack}
+		if trace := err.StackTrace(); trace != tt.expected {
+			t.Errorf("wrong trace.\nexpected=%q\ngot=     %q", tt.expected, trace)
+		}
+	}
+
+	long := &Error{Message: "boom"}
+	for i := 0; i < 30; i++ {
+		long.Stack = append(long.Stack, StackFrame{Function: "f", Pos: pos(i+1, 1)})
+	}
+
+	lines := strings.Split(strings.TrimSuffix(long.StackTrace(), "\n"), "\n")
+	if len(lines) != maxStackTrace+1 {
+		t.Fatalf("expected %d lines, got=%d", maxStackTrace+1, len(lines))
+	}
+	if last := lines[len(lines)-1]; last != "  ... 10 more calls" {
+		t.Errorf("wrong last line. got=%q", last)
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []struct {
+		operator     string
+		a, b         int64
+		expectedType ObjectType
+		expected     string
+	}{
+		{"+", 1, 2, INTEGER_OBJ, "3"},
+		{"+", math.MaxInt64, 1, BIGINT_OBJ, "9223372036854775808"},
+		{"+", math.MinInt64, -1, BIGINT_OBJ, "-9223372036854775809"},
+		{"+", math.MaxInt64, math.MinInt64, INTEGER_OBJ, "-1"},
+		{"-", math.MinInt64, 1, BIGINT_OBJ, "-9223372036854775809"},
+		{"-", 0, math.MinInt64, BIGINT_OBJ, "9223372036854775808"},
+		{"-", -1, math.MinInt64, INTEGER_OBJ, "9223372036854775807"},
+		{"*", 1 << 31, 1 << 31, INTEGER_OBJ, "4611686018427387904"},
+		{"*", 1 << 32, 1 << 32, BIGINT_OBJ, "18446744073709551616"},
+		{"*", -1, math.MinInt64, BIGINT_OBJ, "9223372036854775808"},
+		{"*", math.MinInt64, -1, BIGINT_OBJ, "9223372036854775808"},
+		{"*", 0, math.MinInt64, INTEGER_OBJ, "0"},
+		{"/", 7, 2, INTEGER_OBJ, "3"},
+		{"/", -7, 2, INTEGER_OBJ, "-3"},
+		{"/", math.MinInt64, -1, BIGINT_OBJ, "9223372036854775808"},
+		{"/", 7, 0, ERROR_OBJ, "division by zero: 7 / 0"},
+	}
+
+	for _, tt := range tests {
+		result, err := IntegerArithmetic(tt.operator, tt.a, tt.b)
+
+		if tt.expectedType == ERROR_OBJ {
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("%d %s %d: expected error %q, got=%v", tt.a, tt.operator, tt.b, tt.expected, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d %s %d: unexpected error %s", tt.a, tt.operator, tt.b, err)
+			continue
+		}
+
+		if result.Type() != tt.expectedType || result.Inspect() != tt.expected {
+			t.Errorf("%d %s %d: expected %s %s, got=%s %s",
+				tt.a, tt.operator, tt.b, tt.expectedType, tt.expected, result.Type(), result.Inspect())
+		}
+	}
+}
+
+func TestBigArithmetic(t *testing.T) {
+	big := func(s string) *BigInt {
+		v, _ := new(big.Int).SetString(s, 10)
+		return &BigInt{Value: v}
+	}
+
+	tests := []struct {
+		operator string
+		a, b     Object
+		expected string
+	}{
+		{"+", big("100000000000000000000"), &Integer{Value: 1}, "100000000000000000001"},
+		{"-", &Integer{Value: 1}, big("100000000000000000000"), "-99999999999999999999"},
+		{"*", big("100000000000000000000"), big("-3"), "-300000000000000000000"},
+		{"/", big("-100000000000000000001"), &Integer{Value: 10}, "-10000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		result, err := Arithmetic(tt.operator, tt.a, tt.b)
+		if err != nil {
+			t.Errorf("%s %s %s: unexpected error %s", tt.a.Inspect(), tt.operator, tt.b.Inspect(), err)
+			continue
+		}
+
+		if result.Type() != BIGINT_OBJ || result.Inspect() != tt.expected {
+			t.Errorf("%s %s %s: expected BIGINT %s, got=%s %s",
+				tt.a.Inspect(), tt.operator, tt.b.Inspect(), tt.expected, result.Type(), result.Inspect())
+		}
+	}
+
+	if _, err := Arithmetic("/", big("1"), &Integer{Value: 0}); err == nil {
+		t.Errorf("expected an error dividing by zero")
+	}
+
+	if CompareNumbers(big("5"), &Integer{Value: 5}) != 0 {
+		t.Errorf("bigint 5 != integer 5")
+	}
+	if CompareNumbers(&Integer{Value: math.MaxInt64}, big("9223372036854775808")) != -1 {
+		t.Errorf("MaxInt64 not less than MaxInt64 + 1")
+	}
+
+	if big("5").HashKey() != (&Integer{Value: 5}).HashKey() {
+		t.Errorf("bigint 5 and integer 5 have different hash keys")
+	}
+	if big("9223372036854775808").HashKey() == big("-9223372036854775808").HashKey() {
+		t.Errorf("hash key ignores sign")
+	}
+
+	if negated := Negate(&Integer{Value: math.MinInt64}); negated.Inspect() != "9223372036854775808" {
+		t.Errorf("wrong negation of MinInt64, got=%s", negated.Inspect())
+	}
+}
+
+func TestNewInteger(t *testing.T) {
+	for _, value := range []int64{minSmallInteger, -1, 0, 1, maxSmallInteger} {
+		if NewInteger(value) != NewInteger(value) {
+			t.Errorf("expected %d to be shared", value)
+		}
+		if got := NewInteger(value).Value; got != value {
+			t.Errorf("NewInteger(%d) holds %d", value, got)
+		}
+	}
+
+	for _, value := range []int64{minSmallInteger - 1, maxSmallInteger + 1} {
+		if NewInteger(value) == NewInteger(value) {
+			t.Errorf("expected %d to be allocated", value)
+		}
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"42", "42"},
+		{"-42", "-42"},
+		{"+7", "7"},
+		{"007", "7"},
+		{"0x1F", "31"},
+		{"-0b101", "-5"},
+		{"0o17", "15"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"", `invalid integer ""`},
+		{"12a", `invalid integer "12a"`},
+		{"0x", `invalid integer "0x"`},
+		{"0x-1", `invalid integer "0x-1"`},
+		{"1_000", `invalid integer "1_000"`},
+		{" 1", `invalid integer " 1"`},
+	}
+
+	for _, tt := range tests {
+		value, err := ParseInt(tt.input)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = value.Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("ParseInt(%q) wrong. want=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+
+	if value, _ := ParseInt("99999999999999999999"); value.Type() != BIGINT_OBJ {
+		t.Errorf("expected a BigInt, got %s", value.Type())
+	}
+}
+
+func TestEqualCyclic(t *testing.T) {
+	a := &Array{}
+	a.Elements = []Object{NewInteger(1), a}
+	b := &Array{}
+	b.Elements = []Object{NewInteger(1), b}
+	c := &Array{}
+	c.Elements = []Object{NewInteger(2), c}
+
+	if !Equal(a, b) {
+		t.Errorf("expected arrays containing themselves to be equal")
+	}
+	if Equal(a, c) {
+		t.Errorf("expected arrays containing themselves with different elements not to be equal")
+	}
+}
+
+func TestCopy(t *testing.T) {
+	key := &String{Value: "k"}
+	original := &Array{Elements: []Object{
+		NewInteger(1),
+		&Hash{Pairs: map[HashKey]HashPair{key.HashKey(): {Key: key, Value: &Array{}}}},
+	}}
+
+	copied := Copy(original).(*Array)
+	if !Equal(original, copied) {
+		t.Fatalf("copy differs: %s", copied.Inspect())
+	}
+
+	Freeze(copied)
+	if IsFrozen(original) || IsFrozen(original.Elements[1]) {
+		t.Errorf("freezing the copy froze the original")
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	inner := &Array{Elements: []Object{NewInteger(1)}}
+	outer := &Array{Elements: []Object{inner}}
+	outer.Elements = append(outer.Elements, outer)
+
+	if IsFrozen(outer) {
+		t.Errorf("expected a new array not to be frozen")
+	}
+
+	Freeze(outer)
+
+	if !IsFrozen(outer) || !IsFrozen(inner) {
+		t.Errorf("expected nested arrays to be frozen")
+	}
 }
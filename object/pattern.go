@@ -0,0 +1,176 @@
+package object
+
+import (
+	"fmt"
+	"monkey/ast"
+)
+
+type PatternKind int
+
+const (
+	// Matches anything without binding it, written _
+	WildcardPattern PatternKind = iota
+	// Matches anything and binds it to Name
+	BindPattern
+	// Matches values equal to Value
+	LiteralPattern
+	// Matches arrays whose elements match Elements, and if Rest is set any
+	// elements after those
+	ArrayPattern
+	// Matches hashes with all of Keys, whose values match Elements
+	HashPattern
+)
+
+// Pattern is a compiled match pattern, see ast.MatchArm. Patterns live in
+// the constant pool of compiled programs.
+type Pattern struct {
+	Kind     PatternKind
+	Name     string
+	Value    Object
+	Elements []*Pattern
+	Keys     []Object
+	// Matches the rest of an array, a bind or wildcard pattern
+	Rest *Pattern
+}
+
+func (p *Pattern) Type() ObjectType { return PATTERN_OBJ }
+func (p *Pattern) Inspect() string  { return "pattern" }
+
+// NewPattern compiles the pattern written as exp. The names it binds come in
+// the order of ast.PatternBindings.
+func NewPattern(exp ast.Expression) (*Pattern, error) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if exp.Value == "_" {
+			return &Pattern{Kind: WildcardPattern}, nil
+		}
+		return &Pattern{Kind: BindPattern, Name: exp.Value}, nil
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean, *ast.PrefixExpression:
+		value, ok := literalValue(exp)
+		if !ok {
+			break
+		}
+		return &Pattern{Kind: LiteralPattern, Value: value}, nil
+	case *ast.ArrayLiteral:
+		p := &Pattern{Kind: ArrayPattern}
+		for i, el := range exp.Elements {
+			if spread, ok := el.(*ast.SpreadExpression); ok && i == len(exp.Elements)-1 {
+				rest, err := NewPattern(spread.Value)
+				if err != nil {
+					return nil, err
+				}
+				if rest.Kind != BindPattern && rest.Kind != WildcardPattern {
+					return nil, fmt.Errorf("invalid pattern %s", spread)
+				}
+				p.Rest = rest
+				continue
+			}
+
+			el, err := NewPattern(el)
+			if err != nil {
+				return nil, err
+			}
+			p.Elements = append(p.Elements, el)
+		}
+		return p, nil
+	case *ast.HashLiteral:
+		p := &Pattern{Kind: HashPattern}
+		for _, key := range exp.SortedKeys() {
+			var k Object
+			if name, ok := key.(*ast.Identifier); ok {
+				k = &String{Value: name.Value}
+			} else if k, ok = literalValue(key); !ok {
+				return nil, fmt.Errorf("invalid pattern key %s", key)
+			}
+
+			value, err := NewPattern(exp.Pairs[key])
+			if err != nil {
+				return nil, err
+			}
+
+			p.Keys = append(p.Keys, k)
+			p.Elements = append(p.Elements, value)
+		}
+		return p, nil
+	}
+
+	return nil, fmt.Errorf("invalid pattern %s", exp)
+}
+
+func literalValue(exp ast.Expression) (Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return NewInteger(exp.Value), true
+	case *ast.StringLiteral:
+		return &String{Value: exp.Value}, true
+	case *ast.Boolean:
+		if exp.Value {
+			return TRUE, true
+		}
+		return FALSE, true
+	case *ast.PrefixExpression:
+		if n, ok := exp.Right.(*ast.IntegerLiteral); ok && exp.Operator == "-" {
+			return Negate(NewInteger(n.Value)), true
+		}
+	}
+
+	return nil, false
+}
+
+// Match reports whether value matches p, and if so returns the values of the
+// names p binds, in order.
+func (p *Pattern) Match(value Object) ([]Object, bool) {
+	bound := []Object{}
+	if !p.match(value, &bound) {
+		return nil, false
+	}
+	return bound, true
+}
+
+func (p *Pattern) match(value Object, bound *[]Object) bool {
+	switch p.Kind {
+	case WildcardPattern:
+		return true
+	case BindPattern:
+		*bound = append(*bound, value)
+		return true
+	case LiteralPattern:
+		return Equal(p.Value, value)
+	case ArrayPattern:
+		arr, ok := value.(*Array)
+		if !ok || len(arr.Elements) < len(p.Elements) {
+			return false
+		}
+		if p.Rest == nil && len(arr.Elements) != len(p.Elements) {
+			return false
+		}
+
+		for i, el := range p.Elements {
+			if !el.match(arr.Elements[i], bound) {
+				return false
+			}
+		}
+
+		if p.Rest != nil {
+			rest := make([]Object, len(arr.Elements)-len(p.Elements))
+			copy(rest, arr.Elements[len(p.Elements):])
+			return p.Rest.match(&Array{Elements: rest}, bound)
+		}
+		return true
+	case HashPattern:
+		hash, ok := value.(*Hash)
+		if !ok {
+			return false
+		}
+
+		for i, key := range p.Keys {
+			pair, ok := hash.Pairs[key.(Hashable).HashKey()]
+			if !ok || !p.Elements[i].match(pair.Value, bound) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
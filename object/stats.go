@@ -0,0 +1,48 @@
+package object
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+)
+
+// Environments created so far, by any engine
+var environments atomic.Int64
+
+// Stats are figures on the resources used by the engine running a program.
+type Stats struct {
+	// Most values the VM's stack has held at once
+	StackHighWater int
+	// Constants the program was compiled to
+	Constants int
+}
+
+// StatsReporter is implemented by the FunctionCaller of engines that keep
+// Stats. The evaluator has neither a stack nor constants, so it doesn't.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// memoryStats returns a hash of the objects the process has allocated, the
+// environments created and, for the VM, the Stats of the program running, all
+// so far. Benchmarks can compare them from before and after what they measure.
+func memoryStats(ctx context.Context, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+
+	var stats Stats
+	if reporter, ok := Caller(ctx).(StatsReporter); ok {
+		stats = reporter.Stats()
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return stringHash(
+		"objects_allocated", NewInteger(int64(mem.Mallocs)),
+		"environments", NewInteger(environments.Load()),
+		"stack_high_water", NewInteger(int64(stats.StackHighWater)),
+		"constants", NewInteger(int64(stats.Constants)),
+	)
+}
@@ -0,0 +1,52 @@
+package object
+
+import (
+	"time"
+)
+
+// Time is an instant, as returned by now and parse_time. Its components are
+// read with methods, t.year(), in the time zone it was made in.
+type Time struct {
+	Value time.Time
+}
+
+func (t *Time) Type() ObjectType { return TIME_OBJ }
+func (t *Time) Inspect() string  { return t.Value.Format(time.RFC3339Nano) }
+
+// timeMethods are the methods of TIME. Those reading its components aren't
+// builtins, to keep names like year free for programs.
+var timeMethods = map[string]*Builtin{
+	"format": GetBuiltinByName("format"),
+	"add":    GetBuiltinByName("add"),
+
+	"year":        timeComponent("year", func(t time.Time) int64 { return int64(t.Year()) }),
+	"month":       timeComponent("month", func(t time.Time) int64 { return int64(t.Month()) }),
+	"day":         timeComponent("day", func(t time.Time) int64 { return int64(t.Day()) }),
+	"hour":        timeComponent("hour", func(t time.Time) int64 { return int64(t.Hour()) }),
+	"minute":      timeComponent("minute", func(t time.Time) int64 { return int64(t.Minute()) }),
+	"second":      timeComponent("second", func(t time.Time) int64 { return int64(t.Second()) }),
+	"millisecond": timeComponent("millisecond", func(t time.Time) int64 { return int64(t.Nanosecond() / int(time.Millisecond)) }),
+	// Days since Sunday
+	"weekday": timeComponent("weekday", func(t time.Time) int64 { return int64(t.Weekday()) }),
+	// Milliseconds since 1970-01-01 UTC
+	"unix": timeComponent("unix", func(t time.Time) int64 { return t.UnixMilli() }),
+}
+
+// timeComponent returns the method called name returning the component of
+// a time read by get.
+func timeComponent(name string, get func(t time.Time) int64) *Builtin {
+	return &Builtin{
+		Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			t, ok := args[0].(*Time)
+			if !ok {
+				return newError("argument to `%s` must be TIME, got %s", name, args[0].Type())
+			}
+
+			return NewInteger(get(t.Value))
+		},
+	}
+}
@@ -0,0 +1,107 @@
+package object
+
+import (
+	"context"
+	"fmt"
+)
+
+// annotationTypes are the types annotations, as in fn(x: int) -> int, may
+// name, and the object types of the values each stands for. any stands for
+// every value.
+var annotationTypes = map[string][]ObjectType{
+	"int":       {INTEGER_OBJ, BIGINT_OBJ},
+	"string":    {STRING_OBJ},
+	"bool":      {BOOLEAN_OBJ},
+	"array":     {ARRAY_OBJ},
+	"hash":      {HASH_OBJ},
+	"fn":        {FUNC_OBJ, CLOSURE_OBJ, COMPILED_FUNCTION_OBJ, BUILTIN_OBJ},
+	"null":      {NULL_OBJ},
+	"task":      {TASK_OBJ},
+	"channel":   {CHANNEL_OBJ},
+	"time":      {TIME_OBJ},
+	"file":      {FILE_OBJ},
+	"exception": {EXCEPTION_OBJ},
+	"module":    {MODULE_OBJ},
+}
+
+// HasType reports whether obj is of the type an annotation names, failing
+// if no type has that name.
+func HasType(obj Object, name string) (bool, error) {
+	if name == "any" {
+		return true, nil
+	}
+
+	types, ok := annotationTypes[name]
+	if !ok {
+		return false, fmt.Errorf("unknown type %s", name)
+	}
+
+	for _, t := range types {
+		if obj.Type() == t {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CheckArguments returns an error for the first of args that isn't of the
+// type its parameter is annotated with, or nil if they all are. types has
+// an annotation for each of params, "" for those without one, or is empty
+// if none have one.
+func CheckArguments(name string, params, types []string, args []Object) *Error {
+	for i, typ := range types {
+		if typ == "" || i >= len(args) {
+			continue
+		}
+
+		ok, err := HasType(args[i], typ)
+		if err != nil {
+			return newError("%s", err)
+		}
+		if !ok {
+			return newError("argument %s to %s must be %s, got %s", params[i], describeFunction(name), typ, args[i].Type())
+		}
+	}
+
+	return nil
+}
+
+// CheckResult returns an error if result isn't of the type typ the function
+// is annotated to return, or nil if it is or typ is "".
+func CheckResult(name, typ string, result Object) *Error {
+	if typ == "" {
+		return nil
+	}
+
+	ok, err := HasType(result, typ)
+	if err != nil {
+		return newError("%s", err)
+	}
+	if !ok {
+		return newError("%s must return %s, got %s", describeFunction(name), typ, result.Type())
+	}
+
+	return nil
+}
+
+func describeFunction(name string) string {
+	if name == "" {
+		return "function"
+	}
+	return "`" + name + "`"
+}
+
+type checkTypesKey struct{}
+
+// WithCheckTypes returns a copy of ctx in which the arguments and results of
+// functions with type annotations are checked against them when they're
+// called, failing on a mismatch.
+func WithCheckTypes(ctx context.Context) context.Context {
+	return context.WithValue(ctx, checkTypesKey{}, true)
+}
+
+// CheckTypes reports whether type annotations are checked.
+func CheckTypes(ctx context.Context) bool {
+	check, _ := ctx.Value(checkTypesKey{}).(bool)
+	return check
+}
@@ -0,0 +1,309 @@
+// Package optimizer rewrites parsed programs into simpler ones that give the
+// same results, for both the evaluator and the compiler to run. Expressions
+// of constants are folded into the constant they make, negated comparisons
+// are turned around, and branches of ifs whose condition is a constant are
+// dropped when they can't run.
+//
+// The rules only rewrite what both engines agree on, and leave anything that
+// would fail, like dividing by zero, for the failure to happen when the
+// program runs. Code that's dropped isn't checked at all though, so names in
+// it that aren't defined go unreported.
+package optimizer
+
+import (
+	"math"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"strconv"
+)
+
+// Optimize rewrites program in place and returns it.
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = statements(program.Statements)
+	return program
+}
+
+// statements optimizes each of a list of statements, the body of a program
+// or block. Ifs whose value is unused and whose condition is a constant are
+// replaced by the statements of the branch that runs. Blocks don't have
+// scopes of their own, so the names they bind are the same either way.
+func statements(list []ast.Statement) []ast.Statement {
+	out := make([]ast.Statement, 0, len(list))
+
+	for i, s := range list {
+		s = statement(s)
+
+		if es, ok := s.(*ast.ExpressionStatement); ok && i < len(list)-1 {
+			if ie, ok := es.Expression.(*ast.IfExpression); ok {
+				if branch, ok := constantBranch(ie); ok {
+					if branch != nil {
+						out = append(out, branch.Statements...)
+					}
+					continue
+				}
+			}
+		}
+
+		out = append(out, s)
+	}
+
+	return out
+}
+
+func statement(s ast.Statement) ast.Statement {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		s.Value = expression(s.Value)
+	case *ast.ReturnStatement:
+		s.ReturnValue = expression(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		s.Expression = expression(s.Expression)
+	case *ast.BlockStatement:
+		block(s)
+	}
+
+	return s
+}
+
+func block(b *ast.BlockStatement) {
+	if b != nil {
+		b.Statements = statements(b.Statements)
+	}
+}
+
+// expression returns exp optimized, with its subexpressions optimized first.
+func expression(exp ast.Expression) ast.Expression {
+	switch e := exp.(type) {
+	case *ast.PrefixExpression:
+		e.Right = expression(e.Right)
+		return prefix(e)
+	case *ast.InfixExpression:
+		e.Left = expression(e.Left)
+		e.Right = expression(e.Right)
+		return infix(e)
+	case *ast.IfExpression:
+		e.Condition = expression(e.Condition)
+		block(e.Consequence)
+		block(e.Alternative)
+		return ifExpression(e)
+	case *ast.MatchExpression:
+		// Patterns aren't evaluated, so they're left as they are
+		e.Subject = expression(e.Subject)
+		for _, arm := range e.Arms {
+			arm.Guard = expression(arm.Guard)
+			arm.Body = expression(arm.Body)
+		}
+	case *ast.SpreadExpression:
+		e.Value = expression(e.Value)
+	case *ast.NamedArgument:
+		e.Value = expression(e.Value)
+	case *ast.FunctionLiteral:
+		block(e.Body)
+	case *ast.CallExpression:
+		e.Function = expression(e.Function)
+		expressions(e.Arguments)
+	case *ast.MethodCallExpression:
+		e.Object = expression(e.Object)
+		expressions(e.Arguments)
+	case *ast.FieldExpression:
+		e.Object = expression(e.Object)
+	case *ast.ArrayLiteral:
+		expressions(e.Elements)
+	case *ast.IndexExpression:
+		e.Left = expression(e.Left)
+		e.Index = expression(e.Index)
+	case *ast.HashLiteral:
+		keys := e.SortedKeys()
+		pairs := make(map[ast.Expression]ast.Expression, len(keys))
+		for i, key := range keys {
+			value := e.Pairs[key]
+			keys[i] = expression(key)
+			pairs[keys[i]] = expression(value)
+		}
+		e.Keys, e.Pairs = keys, pairs
+	}
+
+	return exp
+}
+
+func expressions(list []ast.Expression) {
+	for i, exp := range list {
+		list[i] = expression(exp)
+	}
+}
+
+// prefix folds the negation of a constant, and the double negation of a
+// comparison, which is a boolean already.
+func prefix(e *ast.PrefixExpression) ast.Expression {
+	switch e.Operator {
+	case "!":
+		if b, ok := e.Right.(*ast.Boolean); ok {
+			return boolean(e, !b.Value)
+		}
+		if inner, ok := e.Right.(*ast.PrefixExpression); ok && inner.Operator == "!" && isComparison(inner.Right) {
+			return inner.Right
+		}
+		if negated := negate(e.Right); negated != nil {
+			return negated
+		}
+	case "-":
+		if i, ok := e.Right.(*ast.IntegerLiteral); ok && i.Value != math.MinInt64 {
+			return integer(e, -i.Value)
+		}
+	}
+
+	return e
+}
+
+// negate returns the comparison that's true when exp, an equality
+// comparison, is false, or nil if exp isn't one.
+func negate(exp ast.Expression) ast.Expression {
+	infix, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		return nil
+	}
+
+	negated := *infix
+	switch infix.Operator {
+	case "==":
+		negated.Operator = "!="
+	case "!=":
+		negated.Operator = "=="
+	default:
+		return nil
+	}
+	negated.Token.Type = token.TokenType(negated.Operator)
+	negated.Token.Literal = negated.Operator
+
+	return &negated
+}
+
+// infix folds operations on two constants of the same type, and comparisons
+// of comparisons with true or false.
+func infix(e *ast.InfixExpression) ast.Expression {
+	switch left := e.Left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := e.Right.(*ast.IntegerLiteral); ok {
+			return integerInfix(e, left.Value, right.Value)
+		}
+	case *ast.StringLiteral:
+		if right, ok := e.Right.(*ast.StringLiteral); ok {
+			switch e.Operator {
+			case "+":
+				return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: left.Value + right.Value, Pos: e.Pos()}, Value: left.Value + right.Value}
+			case "==":
+				return boolean(e, left.Value == right.Value)
+			case "!=":
+				return boolean(e, left.Value != right.Value)
+			}
+		}
+	case *ast.Boolean:
+		if right, ok := e.Right.(*ast.Boolean); ok {
+			switch e.Operator {
+			case "==":
+				return boolean(e, left.Value == right.Value)
+			case "!=":
+				return boolean(e, left.Value != right.Value)
+			}
+		}
+	}
+
+	if e.Operator != "==" && e.Operator != "!=" {
+		return e
+	}
+
+	// x == true is x when x is a comparison, and x == false is !x
+	exp, b := e.Left, e.Right
+	if _, ok := exp.(*ast.Boolean); ok {
+		exp, b = b, exp
+	}
+	if c, ok := b.(*ast.Boolean); ok && isComparison(exp) {
+		if c.Value == (e.Operator == "==") {
+			return exp
+		}
+		return prefix(&ast.PrefixExpression{Token: token.Token{Type: token.BANG, Literal: "!", Pos: e.Pos()}, Operator: "!", Right: exp})
+	}
+
+	return e
+}
+
+func integerInfix(e *ast.InfixExpression, left, right int64) ast.Expression {
+	switch e.Operator {
+	case "+", "-", "*", "/":
+		result, err := object.IntegerArithmetic(e.Operator, left, right)
+		if i, ok := result.(*object.Integer); ok && err == nil {
+			return integer(e, i.Value)
+		}
+	case "<":
+		return boolean(e, left < right)
+	case ">":
+		return boolean(e, left > right)
+	case "==":
+		return boolean(e, left == right)
+	case "!=":
+		return boolean(e, left != right)
+	}
+
+	return e
+}
+
+// ifExpression drops the branch of e that can't run when its condition is a
+// constant.
+func ifExpression(e *ast.IfExpression) ast.Expression {
+	if branch, ok := constantBranch(e); ok {
+		if branch == nil {
+			branch = &ast.BlockStatement{Token: e.Token}
+		}
+		e.Condition = boolean(e.Condition, true)
+		e.Consequence, e.Alternative = branch, nil
+	}
+
+	return e
+}
+
+// constantBranch returns the branch of e that runs, nil if there's none, when
+// its condition is a constant. ok is false if it isn't.
+func constantBranch(e *ast.IfExpression) (branch *ast.BlockStatement, ok bool) {
+	condition, ok := e.Condition.(*ast.Boolean)
+	if !ok {
+		return nil, false
+	}
+
+	if condition.Value {
+		return e.Consequence, true
+	}
+	return e.Alternative, true
+}
+
+// isComparison reports whether exp is an operation whose value is always a
+// boolean, if it has one.
+func isComparison(exp ast.Expression) bool {
+	switch e := exp.(type) {
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "<", ">", "==", "!=":
+			return true
+		}
+	case *ast.PrefixExpression:
+		return e.Operator == "!"
+	case *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+// integer returns a literal of value in place of the expression at node.
+func integer(node ast.Node, value int64) *ast.IntegerLiteral {
+	literal := strconv.FormatInt(value, 10)
+	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal, Pos: node.Pos()}, Value: value}
+}
+
+// boolean returns a literal of value in place of the expression at node.
+func boolean(node ast.Node, value bool) *ast.Boolean {
+	t := token.Token{Type: token.FALSE, Literal: "false", Pos: node.Pos()}
+	if value {
+		t = token.Token{Type: token.TRUE, Literal: "true", Pos: node.Pos()}
+	}
+	return &ast.Boolean{Token: t, Value: value}
+}
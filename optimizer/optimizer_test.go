@@ -0,0 +1,53 @@
+package optimizer
+
+import (
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Constant folding
+		{"1 + 2 * 3", "7;\n"},
+		{"(10 - 4) / 3; 7 / 2", "2;\n3;\n"},
+		{"x + 2 * 3", "x + 6;\n"},
+		{"-(2 + 3)", "-5;\n"},
+		{`"mon" + "key"`, "\"monkey\";\n"},
+		{`"a" == "b"; 1 < 2; true != false`, "false;\ntrue;\ntrue;\n"},
+		{"[1 + 1, {1 + 1: 2 * 2}]", "[2, {2: 4}];\n"},
+		{"let f = fn(x) { x * (2 + 2) }", "let f = fn(x) { x * 4 };\n"},
+		// Left for the program to fail or promote to a big integer
+		{"1 / 0", "1 / 0;\n"},
+		{"9223372036854775807 + 1", "9223372036854775807 + 1;\n"},
+		{`1 + "a"; 1 == true`, "1 + \"a\";\n1 == true;\n"},
+		// Boolean simplification
+		{"!true; !!false", "false;\nfalse;\n"},
+		{"!(a == b); !(a != b)", "a != b;\na == b;\n"},
+		{"!!(a < b); !!a", "a < b;\n!!a;\n"},
+		{"(a > b) == true; false == (a > b); a == true", "a > b;\n!(a > b);\na == true;\n"},
+		// Pruning ifs
+		{"if (1 > 2) { a } else { b }", "if (true) {\n  b;\n}\n"},
+		{"if (true) { a } else { b }", "if (true) {\n  a;\n}\n"},
+		{"if (false) { a }", "if (true) {}\n"},
+		{"if (2 > 1) { let a = 1; puts(a) }; a", "let a = 1;\nputs(a);\na;\n"},
+		{"if (false) { puts(1) }; 2", "2;\n"},
+		{"if (x) { 1 + 1 }", "if (x) {\n  2;\n}\n"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%q: parser errors: %v", tt.input, p.Errors())
+		}
+
+		if got := format.Program(Optimize(program)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
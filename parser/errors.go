@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+// Diagnostic is a problem found in a program, a *ParseError or a *Warning.
+type Diagnostic interface {
+	String() string
+	// Snippet returns the line of src the problem is about with a caret
+	// under where, or "" if the position isn't in src.
+	Snippet(src string) string
+}
+
+// FormatDiagnostic returns d followed by its Snippet of src, each on lines
+// indented by a tab. paint, if not nil, is applied to the line with d, to
+// color it.
+func FormatDiagnostic(d Diagnostic, src string, paint func(string) string) string {
+	line := "\t" + d.String()
+	if paint != nil {
+		line = paint(line)
+	}
+
+	out := line + "\n"
+	if snippet := d.Snippet(src); snippet != "" {
+		out += "\t" + strings.ReplaceAll(snippet, "\n", "\n\t") + "\n"
+	}
+
+	return out
+}
+
+// ParseError is a syntax error found at a position in the source.
+type ParseError struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+
+	return e.Pos.String() + ": " + e.Message
+}
+
+func (e *ParseError) String() string {
+	return e.Error()
+}
+
+// Snippet returns the line of src the error is on with a caret under the
+// offending token, or "" if the position isn't in src.
+func (e *ParseError) Snippet(src string) string {
+	return e.Pos.Excerpt(src)
+}
+
+// describe names tok the way it reads in the source, for error messages.
+func describe(tok token.Token) string {
+	switch tok.Type {
+	case token.EOF:
+		return "end of input"
+	case token.IDENT:
+		return "name " + tok.Literal
+	case token.INT:
+		return "number " + tok.Literal
+	case token.STRING:
+		return fmt.Sprintf("string %q", tok.Literal)
+	case token.ILLEGAL:
+		return fmt.Sprintf("character %q", tok.Literal)
+	default:
+		return "'" + tok.Literal + "'"
+	}
+}
+
+// describeType names the kind of token the parser expected.
+func describeType(t token.TokenType) string {
+	switch t {
+	case token.IDENT:
+		return "a name"
+	case token.STRING:
+		return "a string"
+	case token.EOF:
+		return "end of input"
+	default:
+		return "'" + string(t) + "'"
+	}
+}
+
+func isCloser(t token.TokenType) bool {
+	return t == token.RPAREN || t == token.RBRACKET || t == token.RBRACE
+}
+
+func closerOf(t token.TokenType) token.TokenType {
+	switch t {
+	case token.LPAREN:
+		return token.RPAREN
+	case token.LBRACKET, token.OPTIONAL_LBRACKET:
+		return token.RBRACKET
+	case token.LBRACE:
+		return token.RBRACE
+	}
+
+	return ""
+}
+
+// startsExpression reports whether t usually begins an expression, so a
+// token of that type following a complete one suggests a missing comma. A
+// brace more likely starts a block after an unclosed bracket.
+func startsExpression(t token.TokenType) bool {
+	switch t {
+	case token.IDENT, token.INT, token.STRING, token.TRUE, token.FALSE, token.BANG,
+		token.LBRACKET, token.FUNCTION, token.IF, token.MATCH, token.IMPORT:
+		return true
+	}
+
+	return false
+}
+
+// Warning is a likely mistake found in a program that doesn't stop it from
+// running.
+type Warning struct {
+	Pos     token.Position
+	Message string
+}
+
+func (w *Warning) String() string {
+	if !w.Pos.IsValid() {
+		return "warning: " + w.Message
+	}
+
+	return w.Pos.String() + ": warning: " + w.Message
+}
+
+// Snippet returns the line of src the warning is about with a caret under
+// where, or "" if the position isn't in src.
+func (w *Warning) Snippet(src string) string {
+	return w.Pos.Excerpt(src)
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	match := &ast.MatchExpression{Token: p.curToken, Arms: []*ast.MatchArm{}}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	match.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := &ast.MatchArm{Pattern: p.parseExpression(LOWEST)}
+		// A pattern that failed to parse may have been left incomplete, and
+		// the error is reported already, or held back as the statement has
+		// one before it
+		if len(p.errors) <= p.synced {
+			p.checkPattern(arm.Pattern)
+		}
+
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+		match.Arms = append(match.Arms, arm)
+
+		// Arms are separated by commas, the last may have one too
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	match.Rbrace = p.curToken.Pos
+
+	return match
+}
+
+func (p *Parser) parseSpreadExpression() ast.Expression {
+	spread := &ast.SpreadExpression{Token: p.curToken}
+
+	// Like a let value, the spread takes the whole expression after it
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+
+	return spread
+}
+
+// checkPattern reports an error if exp can't be used as a match pattern.
+func (p *Parser) checkPattern(exp ast.Expression) {
+	if exp == nil {
+		return
+	}
+
+	if bad := invalidPattern(exp); bad != nil {
+		p.errorAt(token.Token{Pos: bad.Pos()}, "invalid pattern %s", bad.String())
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, name := range ast.PatternBindings(exp) {
+		if seen[name.Value] {
+			p.errorAt(name.Token, "%s bound more than once in pattern", name.Value)
+			return
+		}
+		seen[name.Value] = true
+	}
+}
+
+// invalidPattern returns the part of exp that isn't a valid pattern, or nil
+// if it's all valid.
+func invalidPattern(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return nil
+	case *ast.PrefixExpression:
+		if _, ok := exp.Right.(*ast.IntegerLiteral); ok && exp.Operator == "-" {
+			return nil
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range exp.Elements {
+			// Only the last element may match the rest of the array
+			if spread, ok := el.(*ast.SpreadExpression); ok {
+				if _, ok := spread.Value.(*ast.Identifier); !ok || i != len(exp.Elements)-1 {
+					return spread
+				}
+				continue
+			}
+
+			if bad := invalidPattern(el); bad != nil {
+				return bad
+			}
+		}
+		return nil
+	case *ast.HashLiteral:
+		for _, key := range exp.SortedKeys() {
+			switch key.(type) {
+			case *ast.Identifier, *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+			default:
+				return key
+			}
+
+			if bad := invalidPattern(exp.Pairs[key]); bad != nil {
+				return bad
+			}
+		}
+		return nil
+	}
+
+	return exp
+}
@@ -31,15 +31,36 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+
+	token.OPTIONAL_DOT:      INDEX,
+	token.OPTIONAL_LBRACKET: INDEX,
 }
 
 type Parser struct {
 	l *lexer.Lexer
 
+	prevToken token.Token
 	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	// Brackets opened up to curToken that haven't been closed yet, for
+	// pointing at the one missing its partner
+	open []token.Token
+
+	errors []*ParseError
+	// Number of errors when the parser last synchronized, errors before
+	// that have already been recovered from
+	synced int
+
+	// Likely mistakes in a program parsed without errors
+	warnings []*Warning
+
+	// Comments lexed so far that haven't been attached to a node yet, the
+	// last peekComments of which come after curToken.
+	comments     []*ast.Comment
+	peekComments int
+	commentMap   ast.CommentMap
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -47,8 +68,9 @@ type Parser struct {
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:          l,
+		errors:     []*ParseError{},
+		commentMap: ast.CommentMap{},
 	}
 
 	// Read two tokens, so that curToken and peekToken are set
@@ -70,6 +92,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.ELLIPSIS, p.parseSpreadExpression)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -82,6 +107,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseDotExpression)
+	p.registerInfix(token.OPTIONAL_DOT, p.parseDotExpression)
+	p.registerInfix(token.OPTIONAL_LBRACKET, p.parseIndexExpression)
 
 	return p
 }
@@ -101,6 +129,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 		value := p.parseExpression(LOWEST)
 		hashLiteral.Pairs[key] = value
+		hashLiteral.Keys = append(hashLiteral.Keys, key)
 
 		// consume comma and fail otherwise (unless we're at the end of the hash)
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
@@ -111,12 +140,13 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hashLiteral.Rbrace = p.curToken.Pos
 
 	return hashLiteral
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left, Optional: p.curTokenIs(token.OPTIONAL_LBRACKET)}
 
 	// consume [
 	p.nextToken()
@@ -126,6 +156,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbracket = p.curToken.Pos
 
 	return exp
 }
@@ -134,6 +165,17 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseImportExpression() ast.Expression {
+	imp := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	imp.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	return imp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -155,7 +197,18 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.ParameterTypes = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.THIN_ARROW) {
+		p.nextToken()
+		if lit.ReturnType = p.parseTypeAnnotation(); lit.ReturnType == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -164,34 +217,63 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses the parameters and the types they're
+// annotated with, which are nil if none are.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []*ast.TypeAnnotation) {
 	identifiers := []*ast.Identifier{}
+	var types []*ast.TypeAnnotation
 
 	// Empty param case
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, nil
 	}
 
-	p.nextToken()
+	annotated := false
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil, nil
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		identifiers = append(identifiers, ident)
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		var t *ast.TypeAnnotation
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			if t = p.parseTypeAnnotation(); t == nil {
+				return nil, nil
+			}
+			annotated = true
+		}
+		types = append(types, t)
 
-	for p.peekTokenIs(token.COMMA) {
-		// Two nextToken calls is to load the consume the comma we just peeked
-		// and then load the next token into curToken
-		p.nextToken()
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		// Consume the comma we just peeked, so the parameter's name is next
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+
+	if !annotated {
+		return identifiers, nil
+	}
+	return identifiers, types
+}
+
+// parseTypeAnnotation parses the type name after the ':' or '->' in
+// curToken. Types are named like variables, except for fn.
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	if !p.peekTokenIs(token.IDENT) && !p.peekTokenIs(token.FUNCTION) {
+		p.errorAt(p.peekToken, "unexpected %s, expected a type", describe(p.peekToken))
 		return nil
 	}
 
-	return identifiers
+	p.nextToken()
+	return &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -200,7 +282,85 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 
 	expr := &ast.CallExpression{Token: p.curToken, Function: function}
 
+	expr.Arguments = p.parseCallArguments()
+	expr.Rparen = p.curToken.Pos
+	return expr
+}
+
+// parseCallArguments parses the arguments of a call, where positional
+// arguments may be followed by named ones, name: value.
+func (p *Parser) parseCallArguments() []ast.Expression {
+	args := []ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return args
+	}
+
+	named := map[string]bool{}
+	spread := false
+	for {
+		p.nextToken()
+
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			p.nextToken()
+			arg := &ast.NamedArgument{Token: p.curToken, Name: name}
+			p.nextToken()
+			arg.Value = p.parseExpression(LOWEST)
+
+			if named[name.Value] {
+				p.errorAt(name.Token, "duplicate argument %s", name.Value)
+			} else if spread {
+				p.errorAt(name.Token, "named argument %s can't follow a spread", name.Value)
+			}
+			named[name.Value] = true
+			args = append(args, arg)
+		} else {
+			start := p.curToken
+			arg := p.parseExpression(LOWEST)
+			if _, ok := arg.(*ast.SpreadExpression); ok {
+				spread = true
+			}
+
+			if len(named) != 0 {
+				p.errorAt(start, "positional argument after named arguments")
+			}
+			args = append(args, arg)
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return args
+}
+
+// parseDotExpression parses a method call, value.name(args), or a field,
+// value.name, or their null-safe forms with ?. in place of the dot.
+func (p *Parser) parseDotExpression(object ast.Expression) ast.Expression {
+	dot := p.curToken
+	optional := dot.Type == token.OPTIONAL_DOT
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.peekTokenIs(token.LPAREN) {
+		return &ast.FieldExpression{Token: dot, Object: object, Field: name, Optional: optional}
+	}
+	p.nextToken()
+
+	expr := &ast.MethodCallExpression{Token: dot, Object: object, Method: name, Optional: optional}
 	expr.Arguments = p.parseExpressionList(token.RPAREN)
+	expr.Rparen = p.curToken.Pos
 	return expr
 }
 
@@ -255,14 +415,21 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	// Until we get EOF or RBRACE, parse statements
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		comments := p.takeComments()
+		if stmt := p.parseStatementOrRecover(); stmt != nil {
 			block.Statements = append(block.Statements, stmt)
+			p.attachComments(stmt, comments)
 		}
 
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.EOF) {
+		p.errorAt(p.curToken, "unexpected end of input, expected '}'%s", p.unclosedHint())
+	}
+	block.Rbrace = p.curToken.Pos
+
+	p.attachComments(block, p.takeComments())
 	return block
 }
 
@@ -304,8 +471,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(lit.Token.Literal, 0, 64)
 
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", lit.Token.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken, "could not parse %q as integer", lit.Token.Literal)
 		return nil
 	}
 
@@ -347,18 +513,88 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	p.trackBrackets()
+
+	// Comments aren't part of the grammar, set them aside so they can be
+	// attached to the next statement.
+	p.peekComments = 0
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		p.peekComments++
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// takeComments removes the pending comments that come before curToken.
+func (p *Parser) takeComments() []*ast.Comment {
+	n := len(p.comments) - p.peekComments
+	if n == 0 {
+		return nil
+	}
+
+	taken := p.comments[:n:n]
+	p.comments = p.comments[n:]
+	return taken
 }
 
-func (p *Parser) Errors() []string {
+// attachComments records comments as preceding node.
+func (p *Parser) attachComments(node ast.Node, comments []*ast.Comment) {
+	if len(comments) > 0 {
+		p.commentMap[node] = append(p.commentMap[node], comments...)
+	}
+}
+
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
+// errorAt records a syntax error found at tok. Until the parser has
+// synchronized, further errors are most likely caused by the first one and
+// are dropped.
+func (p *Parser) errorAt(tok token.Token, format string, a ...any) {
+	if len(p.errors) > p.synced {
+		return
+	}
+
+	p.errors = append(p.errors, &ParseError{Pos: tok.Pos, Message: fmt.Sprintf(format, a...)})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.curToken.Type)
+	msg := fmt.Sprintf("unexpected %s, expected %s", describe(p.peekToken), describeType(t))
+
+	switch {
+	case isCloser(t) && startsExpression(p.peekToken.Type):
+		msg += " (missing ','?)"
+	case isCloser(t) || p.peekTokenIs(token.EOF):
+		msg += p.unclosedHint()
+	}
+
+	p.errorAt(p.peekToken, "%s", msg)
+}
+
+// trackBrackets keeps p.open up to date with curToken.
+func (p *Parser) trackBrackets() {
+	switch p.curToken.Type {
+	case token.LPAREN, token.LBRACKET, token.OPTIONAL_LBRACKET, token.LBRACE:
+		p.open = append(p.open, p.curToken)
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		if n := len(p.open); n > 0 && closerOf(p.open[n-1].Type) == p.curToken.Type {
+			p.open = p.open[:n-1]
+		}
+	}
+}
+
+// unclosedHint points at the innermost bracket that hasn't been closed.
+func (p *Parser) unclosedHint() string {
+	if len(p.open) == 0 {
+		return ""
+	}
 
-	p.errors = append(p.errors, msg)
+	opener := p.open[len(p.open)-1]
+	return fmt.Sprintf(" ('%s' at %d:%d is never closed)", opener.Literal, opener.Pos.Line, opener.Pos.Column)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -366,16 +602,80 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		comments := p.takeComments()
+		if stmt := p.parseStatementOrRecover(); stmt != nil {
 			program.Statements = append(program.Statements, stmt)
+			p.attachComments(stmt, comments)
 		}
 
 		p.nextToken()
 	}
+
+	// The input ending early because it couldn't be read isn't the end of
+	// the program
+	if err := p.l.Err(); err != nil {
+		p.errors = append(p.errors, &ParseError{Pos: p.curToken.Pos, Message: fmt.Sprintf("failed to read source: %s", err)})
+	}
+
+	p.attachComments(program, p.takeComments())
+	program.Comments = p.commentMap
+
+	ast.Resolve(program)
+	if len(p.errors) == 0 {
+		p.warnings = findWarnings(program)
+	}
 	return program
 }
 
+// parseStatementOrRecover parses a statement, returning nil if it has errors.
+// After an error the rest of the statement is skipped, so one mistake doesn't
+// cascade into misleading errors about the tokens that follow.
+func (p *Parser) parseStatementOrRecover() ast.Statement {
+	before := len(p.errors)
+	stmt := p.parseStatement()
+
+	if len(p.errors) == before {
+		return stmt
+	}
+
+	// Errors inside a nested block may already have been recovered from
+	if len(p.errors) > p.synced {
+		p.synchronize()
+	}
+
+	return nil
+}
+
+// synchronize skips to the end of the statement the parser is in, leaving
+// curToken on its last token: a semicolon, or the token before a closing
+// brace or the start of a let or return statement. Brackets opened along the
+// way must be closed first.
+func (p *Parser) synchronize() {
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		if depth <= 0 {
+			if p.curTokenIs(token.SEMICOLON) {
+				break
+			}
+			if p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.LET) || p.peekTokenIs(token.RETURN) || p.peekTokenIs(token.EOF) {
+				break
+			}
+		}
+
+		p.nextToken()
+
+		switch p.curToken.Type {
+		case token.LBRACE, token.LPAREN, token.LBRACKET, token.OPTIONAL_LBRACKET:
+			depth++
+		case token.RBRACE, token.RPAREN, token.RBRACKET:
+			depth--
+		}
+	}
+
+	p.synced = len(p.errors)
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
@@ -466,6 +766,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken.Pos
 	return array
 }
 
@@ -484,8 +785,16 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	msg := fmt.Sprintf("unexpected %s, expected an expression", describe(p.curToken))
+
+	switch {
+	case t == token.ASSIGN && p.prevToken.Type == token.IDENT:
+		msg += fmt.Sprintf(" (names are bound with let, e.g. let %s = ...)", p.prevToken.Literal)
+	case t == token.EOF:
+		msg += p.unclosedHint()
+	}
+
+	p.errorAt(p.curToken, "%s", msg)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
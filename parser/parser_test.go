@@ -1,10 +1,15 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"monkey/ast"
 	"monkey/lexer"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -460,6 +465,24 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+
+		// Method calls bind like index expressions
+		{
+			"-a.len() * b.push(1)[0]",
+			"((-a.len()) * (b.push(1)[0]))",
+		},
+		{
+			"a.rest().first() + f(1).len()",
+			"(a.rest().first() + f(1).len())",
+		},
+		{
+			"-a.b.c * d.e[0].f()",
+			"((-a.b.c) * (d.e[0]).f())",
+		},
+		{
+			"config?.server?.port + a?[0]?.f(1)",
+			"(config?.server?.port + (a?[0])?.f(1))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -670,6 +693,52 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedTypes  []string
+		expectedReturn string
+		expectedString string
+	}{
+		{"fn(x, y) { x }", nil, "", "fn(x, y) { x }"},
+		{"fn(x: int, y: string) -> int { x }", []string{"int", "string"}, "int", "fn(x: int, y: string) -> int { x }"},
+		{"fn(x, f: fn) { x }", []string{"", "fn"}, "", "fn(x, f: fn) { x }"},
+		{"fn() -> bool { true }", nil, "bool", "fn() -> bool { true }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+
+		types := []string{}
+		for _, annotation := range function.ParameterTypes {
+			if annotation == nil {
+				types = append(types, "")
+			} else {
+				types = append(types, annotation.Name)
+			}
+		}
+		if strings.Join(types, ",") != strings.Join(tt.expectedTypes, ",") || (tt.expectedTypes == nil) != (function.ParameterTypes == nil) {
+			t.Errorf("%s: wrong parameter types. expected=%q, got=%q", tt.input, tt.expectedTypes, types)
+		}
+
+		returns := ""
+		if function.ReturnType != nil {
+			returns = function.ReturnType.Name
+		}
+		if returns != tt.expectedReturn {
+			t.Errorf("%s: wrong return type. expected=%q, got=%q", tt.input, tt.expectedReturn, returns)
+		}
+
+		if function.String() != tt.expectedString {
+			t.Errorf("%s: wrong string. expected=%q, got=%q", tt.input, tt.expectedString, function.String())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5)`
 
@@ -709,6 +778,175 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, expr.Arguments[2], 4, "+", 5)
 }
 
+func TestMethodCallExpressionParsing(t *testing.T) {
+	input := `arr.push(1, 2 * 3)`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected program to have 1 statement, got %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected program to be an expression statment, got %T", program.Statements[0])
+	}
+
+	expr, ok := stmt.Expression.(*ast.MethodCallExpression)
+	if !ok {
+		t.Fatalf("Expected a method call expression, got %T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, expr.Object, "arr") || !testIdentifier(t, expr.Method, "push") {
+		return
+	}
+
+	if len(expr.Arguments) != 2 {
+		t.Fatalf("Expected two arguments, got %d", len(expr.Arguments))
+	}
+
+	testLiteralExpression(t, expr.Arguments[0], 1)
+	testInfixExpression(t, expr.Arguments[1], 2, "*", 3)
+}
+
+func TestFieldExpressionParsing(t *testing.T) {
+	p := New(lexer.New(`person.name`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	expr, ok := stmt.Expression.(*ast.FieldExpression)
+	if !ok {
+		t.Fatalf("Expected a field expression, got %T", stmt.Expression)
+	}
+
+	testIdentifier(t, expr.Object, "person")
+	testIdentifier(t, expr.Field, "name")
+}
+
+func TestImportExpressionParsing(t *testing.T) {
+	p := New(lexer.New(`let lib = import "lib/strings";`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	imp, ok := stmt.Value.(*ast.ImportExpression)
+	if !ok {
+		t.Fatalf("Expected an import expression, got %T", stmt.Value)
+	}
+
+	if imp.Path.Value != "lib/strings" {
+		t.Errorf("wrong path. want=%q, got=%q", "lib/strings", imp.Path.Value)
+	}
+	if imp.String() != "import lib/strings" {
+		t.Errorf("wrong string. got=%s", imp.String())
+	}
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	input := `match (x) { [h, ...t] if h > 0 => h, {name: n} => n, _ => 0, }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	expr, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("Expected a match expression, got %T", stmt.Expression)
+	}
+
+	testIdentifier(t, expr.Subject, "x")
+
+	if len(expr.Arms) != 3 {
+		t.Fatalf("Expected three arms, got %d", len(expr.Arms))
+	}
+
+	expected := []struct {
+		pattern string
+		guard   string
+		body    string
+	}{
+		{"[h, ...t]", "(h > 0)", "h"},
+		{"{name:n}", "", "n"},
+		{"_", "", "0"},
+	}
+
+	for i, arm := range expr.Arms {
+		if arm.Pattern.String() != expected[i].pattern {
+			t.Errorf("arm %d has wrong pattern. want=%q, got=%q", i, expected[i].pattern, arm.Pattern.String())
+		}
+
+		guard := ""
+		if arm.Guard != nil {
+			guard = arm.Guard.String()
+		}
+		if guard != expected[i].guard {
+			t.Errorf("arm %d has wrong guard. want=%q, got=%q", i, expected[i].guard, guard)
+		}
+
+		if arm.Body.String() != expected[i].body {
+			t.Errorf("arm %d has wrong body. want=%q, got=%q", i, expected[i].body, arm.Body.String())
+		}
+	}
+}
+
+func TestNamedArgumentParsing(t *testing.T) {
+	p := New(lexer.New(`greet(name, excited: true, times: 1 + 2)`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("Expected a call expression, got %T", stmt.Expression)
+	}
+
+	if len(call.Arguments) != 3 {
+		t.Fatalf("Expected three arguments, got %d", len(call.Arguments))
+	}
+	testLiteralExpression(t, call.Arguments[0], "name")
+
+	excited, ok := call.Arguments[1].(*ast.NamedArgument)
+	if !ok {
+		t.Fatalf("Expected a named argument, got %T", call.Arguments[1])
+	}
+	testIdentifier(t, excited.Name, "excited")
+	testLiteralExpression(t, excited.Value, true)
+
+	times, ok := call.Arguments[2].(*ast.NamedArgument)
+	if !ok {
+		t.Fatalf("Expected a named argument, got %T", call.Arguments[2])
+	}
+	testIdentifier(t, times.Name, "times")
+	testInfixExpression(t, times.Value, 1, "+", 2)
+}
+
+func TestSpreadExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, ...xs, 4]", "[1, ...xs, 4]"},
+		{"f(...args)", "f(...args)"},
+		{"f(a, ...b.c, ...d[0])", "f(a, ...b.c, ...(d[0]))"},
+		{"[...a + b]", "[...(a + b)]"},
+		{"(...a) + b", "(...a + b)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong parse of %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, fn(x) { 10 + x }]"
 
@@ -1012,3 +1250,457 @@ func testInfixExpression(t *testing.T, exp ast.Expression, left any, operator st
 
 	return true
 }
+
+func TestComments(t *testing.T) {
+	input := `// about x
+let x = 5; // about f
+let f = fn() {
+	// inside
+	x
+	// end of body
+};
+// end of file`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	fn := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+
+	tests := []struct {
+		node     ast.Node
+		expected []string
+	}{
+		{program.Statements[0], []string{"// about x"}},
+		{program.Statements[1], []string{"// about f"}},
+		{fn.Body.Statements[0], []string{"// inside"}},
+		{fn.Body, []string{"// end of body"}},
+		{program, []string{"// end of file"}},
+	}
+
+	for i, tt := range tests {
+		comments := program.Comments[tt.node]
+		if len(comments) != len(tt.expected) {
+			t.Errorf("tests[%d] - wrong number of comments. expected=%d, got=%d", i, len(tt.expected), len(comments))
+			continue
+		}
+
+		for j, c := range comments {
+			if c.Text() != tt.expected[j] {
+				t.Errorf("tests[%d] - comment wrong. expected=%q, got=%q", i, tt.expected[j], c.Text())
+			}
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedError   string
+		expectedSnippet string
+	}{
+		{
+			"let x = ;",
+			"1:9: unexpected ';', expected an expression",
+			"let x = ;\n        ^",
+		},
+		{
+			"let x = 1;\n\tlet 5 = 2;",
+			"2:6: unexpected number 5, expected a name",
+			"\tlet 5 = 2;\n\t    ^",
+		},
+		{
+			"fn(x { x }",
+			"1:6: unexpected '{', expected ')' ('(' at 1:3 is never closed)",
+			"fn(x { x }\n     ^",
+		},
+		{
+			"fn(x, 0) { x }",
+			"1:7: unexpected number 0, expected a name",
+			"fn(x, 0) { x }\n      ^",
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, errors[0].Error())
+		}
+
+		if snippet := errors[0].Snippet(tt.input); snippet != tt.expectedSnippet {
+			t.Errorf("wrong snippet. expected=\n%s\ngot=\n%s", tt.expectedSnippet, snippet)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{
+			"let 5 = 2; let x = 1; let = 3; x",
+			[]string{
+				"1:5: unexpected number 5, expected a name",
+				"1:27: unexpected '=', expected a name",
+			},
+		},
+		{
+			"let f = fn() {\n  let = 1;\n  let y = 2;\n  y\n};\nlet z = * 2;\nf()",
+			[]string{
+				"2:7: unexpected '=', expected a name",
+				"6:9: unexpected '*', expected an expression",
+			},
+		},
+		{
+			"puts(1,);\nlet a = [1, 2;\nlet b = 3;",
+			[]string{
+				"1:8: unexpected ')', expected an expression",
+				"2:14: unexpected ';', expected ']' ('[' at 2:9 is never closed)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := []string{}
+		for _, err := range p.Errors() {
+			errors = append(errors, err.Error())
+		}
+
+		if strings.Join(errors, "\n") != strings.Join(tt.expectedErrors, "\n") {
+			t.Errorf("wrong errors for %q. expected=\n%s\ngot=\n%s",
+				tt.input, strings.Join(tt.expectedErrors, "\n"), strings.Join(errors, "\n"))
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedWarnings []string
+	}{
+		{"let x = 1; let f = fn(y) { y + x }; f(x)", []string{}},
+		{
+			"let x = 1;\nlet f = fn(x) { let y = 2; fn(y) { y } };\nf",
+			[]string{
+				"2:12: warning: x shadows a variable of an enclosing scope",
+				"2:31: warning: y shadows a variable of an enclosing scope",
+			},
+		},
+		{"let x = 1; fn() { let x = 2; let x = 3; x }", []string{"1:23: warning: x shadows a variable of an enclosing scope"}},
+		{"let _ = 1; fn(_) { 1 }", []string{}},
+		// Only names bound before the function count
+		{"let add = fn(a, b) { a + b }; let a = 1; add(a, 2)", []string{}},
+		{"let a = 1; let add = fn(a, b) { a + b }; add(a, 2)", []string{"1:25: warning: a shadows a variable of an enclosing scope"}},
+		{"fn() { let g = fn(y) { y }; let y = 1; g(y) }", []string{}},
+		{"let f = fn(x) { x; -x + 1; x }; f; f(1); 2", []string{
+			"1:17: warning: expression result is unused",
+			"1:20: warning: expression result is unused",
+			"1:33: warning: expression result is unused",
+		}},
+		{"if (true) { 1 }; puts(2); 3", []string{}},
+		{"let x = ;\n1; 2", []string{}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		warnings := []string{}
+		for _, warning := range p.Warnings() {
+			warnings = append(warnings, warning.String())
+		}
+
+		if strings.Join(warnings, "\n") != strings.Join(tt.expectedWarnings, "\n") {
+			t.Errorf("wrong warnings for %q. expected=\n%s\ngot=\n%s",
+				tt.input, strings.Join(tt.expectedWarnings, "\n"), strings.Join(warnings, "\n"))
+		}
+	}
+}
+
+func TestFormatDiagnostic(t *testing.T) {
+	input := "let x = 1;\nlet f = fn(x) { x };\nlet y = ;"
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	paint := func(s string) string { return "<" + s + ">" }
+	tests := []struct {
+		diagnostic Diagnostic
+		paint      func(string) string
+		expected   string
+	}{
+		{p.Errors()[0], nil, "\t3:9: unexpected ';', expected an expression\n\tlet y = ;\n\t        ^\n"},
+		{p.Errors()[0], paint, "<\t3:9: unexpected ';', expected an expression>\n\tlet y = ;\n\t        ^\n"},
+		{&Warning{Message: "unused"}, nil, "\twarning: unused\n"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatDiagnostic(tt.diagnostic, input, tt.paint); got != tt.expected {
+			t.Errorf("wrong output. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestReadError(t *testing.T) {
+	// The program read so far is complete, but there's more that couldn't
+	// be read
+	r := io.MultiReader(strings.NewReader("let x = 1;"), iotest.ErrReader(errors.New("connection reset")))
+	p := New(lexer.NewReader("", r))
+	p.ParseProgram()
+
+	errs := p.Errors()
+	if len(errs) != 1 || errs[0].Error() != "1:11: failed to read source: connection reset" {
+		t.Errorf("expected the read error, got %v", errs)
+	}
+}
+
+func TestFriendlyErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"[1 2]", "1:4: unexpected number 2, expected ']' (missing ','?)"},
+		{"fn(x: 1) { x }", "1:7: unexpected number 1, expected a type"},
+		{"fn(x) -> { x }", "1:10: unexpected '{', expected a type"},
+		{"puts(a b)", "1:8: unexpected name b, expected ')' (missing ','?)"},
+		{`{"a": 1 "b": 2}`, `1:9: unexpected string "b", expected ','`},
+		{"let f = fn(x) {\n  x + 1;\n", "3:1: unexpected end of input, expected '}' ('{' at 1:15 is never closed)"},
+		{"let x = (1 + ", "1:14: unexpected end of input, expected an expression ('(' at 1:9 is never closed)"},
+		{"x = 5", "1:3: unexpected '=', expected an expression (names are bound with let, e.g. let x = ...)"},
+		{"let y = @", "1:9: unexpected character \"@\", expected an expression"},
+		{"if (x) { 1 } else 2", "1:19: unexpected number 2, expected '{'"},
+		{"arr.1", "1:5: unexpected number 1, expected a name"},
+		{"f(a: 1, a: 2)", "1:9: duplicate argument a"},
+		{"f(a: 1, 2)", "1:9: positional argument after named arguments"},
+		{"f(...xs, a: 1)", "1:10: named argument a can't follow a spread"},
+		{"a.f(x: 1)", "1:6: unexpected ':', expected ')' ('(' at 1:4 is never closed)"},
+		{"match (x) { a + 1 => a }", "1:13: invalid pattern (a + 1)"},
+		{"match (x) { [...t, h] => h }", "1:14: invalid pattern ...t"},
+		{"match (x) { {f(): y} => y }", "1:14: invalid pattern f()"},
+		{"match (x) { [a, a] => a }", "1:17: a bound more than once in pattern"},
+		{"match (x) { 1 2 }", "1:15: unexpected number 2, expected '=>'"},
+		{"import lib", "1:8: unexpected name lib, expected a string"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("wrong error for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := `
+let add = fn(a, b) { return a + b; };
+let values = [1, -2, "three", true, {"k": add(1, 2)[0]}];
+if (!(values[0] == 1)) { puts("no") } else { values };
+let noop = fn() { return; };
+values.push(values.len());
+values[4].k;
+values?[4]?.k?.len();
+add(1, b: values[0]);
+match (values) { [a, ...rest] if a > 0 => rest, {"k": k} => k, _ => 0 };
+let lib = import "lib";
+let typed = fn(x: int, y) -> string { x };
+`
+
+	program := New(lexer.New(input)).ParseProgram()
+
+	data, err := ast.ToJSON(program)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %s", err)
+	}
+
+	decoded, err := ast.FromJSON(data)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %s", err)
+	}
+
+	if decoded.String() != program.String() {
+		t.Errorf("program changed in round trip.\nexpected=%s\ngot=     %s", program.String(), decoded.String())
+	}
+
+	again, err := ast.ToJSON(decoded)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %s", err)
+	}
+
+	if string(again) != string(data) {
+		t.Errorf("JSON changed in round trip.\nexpected=%s\ngot=     %s", data, again)
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x;", "x"},
+		{"  12345 ;", "12345"},
+		{`"hello world";`, `"hello world"`},
+		{"-a * b;", "-a * b"},
+		{"add(1, 2 * 3) ;", "add(1, 2 * 3)"},
+		{"a[1 + 2];", "a[1 + 2]"},
+		{"a.push(1) ;", "a.push(1)"},
+		{"person.name ;", "person.name"},
+		{"f(x, y: 2) ;", "f(x, y: 2)"},
+		{"match (x) { [_, ...t] => t } ;", "match (x) { [_, ...t] => t }"},
+		{"[1, 2, 3];", "[1, 2, 3]"},
+		{`{"a": 1};`, `{"a": 1}`},
+		{"if (x) { y } else { z };", "if (x) { y } else { z }"},
+		{"fn(a, b) { a + b }(1, 2);", "fn(a, b) { a + b }(1, 2)"},
+		{"let x = 5 ;", "let x = 5"},
+		{"return a+b;", "return a+b"},
+	}
+
+	for _, tt := range tests {
+		program := New(lexer.New(tt.input)).ParseProgram()
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement for %q, got=%d", tt.input, len(program.Statements))
+		}
+
+		var node ast.Node = program.Statements[0]
+		if stmt, ok := node.(*ast.ExpressionStatement); ok {
+			node = stmt.Expression
+		}
+
+		pos, end := node.Pos(), node.End()
+		if pos.Line != 1 || end.Line != 1 {
+			t.Fatalf("span of %q not on line 1: %s-%s", tt.input, pos, end)
+		}
+
+		if got := tt.input[pos.Column-1 : end.Column-1]; got != tt.expected {
+			t.Errorf("wrong span for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestNodeSpansMultiline(t *testing.T) {
+	input := "let s = \"a\nbc\";\nlet f = fn() {\n  s\n};"
+
+	program := New(lexer.New(input)).ParseProgram()
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got=%d", len(program.Statements))
+	}
+
+	tests := []struct {
+		node     ast.Node
+		pos, end string
+	}{
+		{program.Statements[0], "1:1", "2:4"},
+		{program.Statements[1], "3:1", "5:2"},
+		{program, "1:1", "5:2"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.node.Pos().String(); got != tt.pos {
+			t.Errorf("wrong Pos for %q. expected=%s, got=%s", tt.node, tt.pos, got)
+		}
+		if got := tt.node.End().String(); got != tt.end {
+			t.Errorf("wrong End for %q. expected=%s, got=%s", tt.node, tt.end, got)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	input := `
+let g = 1;
+let f = fn(a, b) {
+  let c = a + g;
+  if (c) { let d = b; };
+  fn(e) { e + c + d + h };
+};
+`
+
+	program := New(lexer.New(input)).ParseProgram()
+
+	bindings := []string{}
+	locals := [][]string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			b := node.Binding
+			switch b.Scope {
+			case ast.Global:
+				bindings = append(bindings, node.Value+":global")
+			case ast.Local:
+				bindings = append(bindings, fmt.Sprintf("%s:%d/%d", node.Value, b.Depth, b.Slot))
+			default:
+				bindings = append(bindings, node.Value+":unresolved")
+			}
+		case *ast.FunctionLiteral:
+			locals = append(locals, node.Locals)
+		}
+		return true
+	})
+
+	expectedBindings := []string{
+		"g:global", "f:global",
+		"a:0/0", "b:0/1",
+		"c:0/2", "a:0/0", "g:global",
+		"c:0/2", "d:0/3", "b:0/1",
+		"e:0/0", "e:0/0", "c:1/2", "d:1/3", "h:global",
+	}
+	if !reflect.DeepEqual(bindings, expectedBindings) {
+		t.Errorf("wrong bindings.\nexpected=%v\ngot=     %v", expectedBindings, bindings)
+	}
+
+	expectedLocals := [][]string{{"a", "b", "c", "d"}, {"e"}}
+	if !reflect.DeepEqual(locals, expectedLocals) {
+		t.Errorf("wrong locals.\nexpected=%v\ngot=     %v", expectedLocals, locals)
+	}
+}
+
+// fuzzSeeds cover most of the syntax, for the fuzzer to mutate.
+var fuzzSeeds = []string{
+	`let x = 5; let y = x * (2 + 3); return -y;`,
+	`if (x < 10) { "small" } else { "big" }`,
+	`let f = fn(a, b) { a + b }; f(1, b: 2); f(...[1, 2]);`,
+	`[1, 2, 3][0]; {"a": 1, true: [2]}["a"]; "s".len();`,
+	`match (x) { [a, ...rest] if a > 1 -> rest, {"k": v} -> v, _ -> null }`,
+	`// comment
+	let s = "esc\n\"aped\""; !true == false;`,
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+
+		for _, err := range p.Errors() {
+			_ = err.Error()
+			_ = err.Snippet(input)
+		}
+		if len(p.Errors()) == 0 {
+			_ = program.String()
+		}
+	})
+}
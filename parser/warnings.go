@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/ast"
+	"sort"
+)
+
+// Warnings returns the likely mistakes found in the program ParseProgram
+// returned: variables that shadow one of an enclosing function or the top
+// level, and expressions whose results are thrown away without them having
+// any effect. It's empty if there were syntax errors.
+func (p *Parser) Warnings() []*Warning {
+	return p.warnings
+}
+
+// findWarnings returns the warnings for program, in source order.
+func findWarnings(program *ast.Program) []*Warning {
+	v := &warner{warnings: &[]*Warning{}, scope: newWarnScope(nil)}
+	ast.Walk(v, program)
+
+	warnings := *v.warnings
+	sort.SliceStable(warnings, func(i, j int) bool {
+		a, b := warnings[i].Pos, warnings[j].Pos
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return warnings
+}
+
+// warnScope holds the names bound by a function, or at the top level.
+type warnScope struct {
+	names map[string]bool
+	outer *warnScope
+}
+
+func newWarnScope(outer *warnScope) *warnScope {
+	return &warnScope{names: map[string]bool{}, outer: outer}
+}
+
+func (s *warnScope) has(name string) bool {
+	for current := s; current != nil; current = current.outer {
+		if current.names[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+type warner struct {
+	// Shared by the warners of nested functions
+	warnings *[]*Warning
+	scope    *warnScope
+}
+
+func (v *warner) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Program:
+		v.unused(node.Statements)
+	case *ast.BlockStatement:
+		v.unused(node.Statements)
+	case *ast.LetStatement:
+		if node.Name != nil {
+			v.declare(node.Name)
+		}
+	case *ast.FunctionLiteral:
+		inner := &warner{warnings: v.warnings, scope: newWarnScope(v.scope)}
+		for _, param := range node.Parameters {
+			if param != nil {
+				inner.declare(param)
+			}
+		}
+		return inner
+	}
+
+	return v
+}
+
+// declare binds name in the current scope, warning if it shadows a name of
+// an enclosing one. Names are bound as their lets are reached, so only those
+// bound earlier in the source count, even at the top level.
+func (v *warner) declare(name *ast.Identifier) {
+	// Names starting with _ are for values meant to be left unused
+	if !v.scope.names[name.Value] && name.Value[0] != '_' && v.scope.has(name.Value) {
+		v.warn(name, "%s shadows a variable of an enclosing scope", name.Value)
+	}
+	v.scope.names[name.Value] = true
+}
+
+// unused warns about each statement but the last, whose result may be the
+// value of the block, that computes a value only to throw it away.
+func (v *warner) unused(statements []ast.Statement) {
+	for i := 0; i < len(statements)-1; i++ {
+		stmt, ok := statements[i].(*ast.ExpressionStatement)
+		if ok && stmt.Expression != nil && noEffect(stmt.Expression) {
+			v.warn(stmt.Expression, "expression result is unused")
+		}
+	}
+}
+
+func (v *warner) warn(node ast.Node, format string, a ...any) {
+	*v.warnings = append(*v.warnings, &Warning{Pos: node.Pos(), Message: fmt.Sprintf(format, a...)})
+}
+
+// noEffect reports whether evaluating e does nothing but compute its value.
+func noEffect(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean, *ast.FunctionLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return noEffect(e.Right)
+	case *ast.InfixExpression:
+		return noEffect(e.Left) && noEffect(e.Right)
+	}
+
+	return false
+}
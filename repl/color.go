@@ -0,0 +1,169 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ANSI escape sequences used when rendering results
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+// Config controls how the REPL renders its output.
+type Config struct {
+	// Color enables ANSI colors in printed results and errors.
+	Color bool
+	// HandleInterrupts makes Ctrl-C abort the line being evaluated instead
+	// of killing the process.
+	HandleInterrupts bool
+	// ShowTypes follows each result with its type, as in 6 : INTEGER.
+	ShowTypes bool
+	// Prompt is printed before each line is read, PROMPT if empty.
+	Prompt string
+	// Banner is printed once, before the first prompt.
+	Banner string
+	// Quiet leaves out the banner and prompts, leaving only results.
+	Quiet bool
+	// WarningsAsErrors skips lines that have warnings instead of running
+	// them.
+	WarningsAsErrors bool
+}
+
+// DefaultConfig only enables colors when out is a terminal.
+func DefaultConfig(out io.Writer) Config {
+	return Config{Color: IsTerminal(out)}
+}
+
+// IsTerminal reports whether w writes to a character device such as a TTY.
+// Pipes, files, and network connections are never considered terminals.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (c Config) paint(color string, s string) string {
+	if !c.Color {
+		return s
+	}
+
+	return color + s + colorReset
+}
+
+// render returns the Inspect form of obj, colored by object type.
+func (c Config) render(obj object.Object) string {
+	return c.pretty(obj, 0, map[object.Object]bool{})
+}
+
+// sortedPairs orders hash pairs by their keys so output is stable between runs.
+func sortedPairs(h *object.Hash) []object.HashPair {
+	pairs := make([]object.HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+
+	return pairs
+}
+
+func (c Config) printBanner(out io.Writer) {
+	if c.Quiet || c.Banner == "" {
+		return
+	}
+
+	io.WriteString(out, strings.TrimSuffix(c.Banner, "\n")+"\n")
+}
+
+func (c Config) printPrompt(out io.Writer) {
+	if c.Quiet {
+		return
+	}
+
+	if c.Prompt == "" {
+		io.WriteString(out, PROMPT)
+	} else {
+		io.WriteString(out, c.Prompt)
+	}
+}
+
+func (c Config) printResult(out io.Writer, obj object.Object) {
+	io.WriteString(out, c.render(obj))
+	if c.ShowTypes && obj.Type() != object.ERROR_OBJ {
+		io.WriteString(out, c.paint(colorGray, " : "+string(obj.Type())))
+	}
+	io.WriteString(out, "\n")
+}
+
+func (c Config) printError(out io.Writer, format string, a ...any) {
+	io.WriteString(out, c.paint(colorRed, fmt.Sprintf(format, a...)))
+}
+
+// printExcerpt prints the line a runtime error happened on with a caret under
+// where, if v is one. Each line is parsed on its own, so errors inside
+// functions, which may have been defined on an earlier line, aren't shown.
+func (c Config) printExcerpt(out io.Writer, line string, v any) {
+	err, ok := v.(*object.Error)
+	if !ok || len(err.Stack) != 0 {
+		return
+	}
+
+	if excerpt := err.Pos.Excerpt(line); excerpt != "" {
+		io.WriteString(out, "\t"+strings.ReplaceAll(excerpt, "\n", "\n\t")+"\n")
+	}
+}
+
+// printStackTrace prints the calls a runtime error passed through, if v is
+// one.
+func (c Config) printStackTrace(out io.Writer, v any) {
+	if err, ok := v.(*object.Error); ok {
+		io.WriteString(out, c.paint(colorRed, err.StackTrace()))
+	}
+}
+
+// printParserErrors prints each error followed by the line of source it's on,
+// with a caret under the offending token.
+func (c Config) printParserErrors(out io.Writer, source string, errors []*parser.ParseError) {
+	for _, error := range errors {
+		c.printDiagnostic(out, source, error, colorRed)
+	}
+}
+
+// printDiagnostic prints a parser error or warning in color followed by the
+// line of source it's about.
+func (c Config) printDiagnostic(out io.Writer, source string, d parser.Diagnostic, color string) {
+	io.WriteString(out, parser.FormatDiagnostic(d, source, func(s string) string {
+		return c.paint(color, s)
+	}))
+}
+
+// warningsFail prints the warnings about line and reports whether it
+// shouldn't be run because of them.
+func (c Config) warningsFail(out io.Writer, line string, warnings []*parser.Warning) bool {
+	for _, warning := range warnings {
+		c.printDiagnostic(out, line, warning, colorYellow)
+	}
+	return c.WarningsAsErrors && len(warnings) != 0
+}
@@ -0,0 +1,144 @@
+package repl
+
+import (
+	"io"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// CompletionSuffix ends lines asking for what could follow the rest of the
+// line, which is listed instead of being run. Typing a tab before enter at a
+// terminal sends one.
+const CompletionSuffix = "\t"
+
+// completions returns what could complete line when it ends in indexing or
+// taking a field of a value bound to a name, as in h[ or h.na, with lookup
+// finding the value a name is bound to. Indexing a hash can be completed with
+// its string keys, as string literals, and fields with those keys that are
+// names as well as the methods of the value's type. The name may be followed
+// by fields leading to a hash within it, as in config.db.
+func completions(line string, lookup func(name string) (object.Object, bool)) []string {
+	op, partial, receiver, ok := completionTarget(line)
+	if !ok {
+		return nil
+	}
+
+	names := strings.Split(receiver, ".")
+	obj, ok := lookup(names[0])
+	if !ok || obj == nil {
+		return nil
+	}
+	for _, name := range names[1:] {
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return nil
+		}
+		pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+		if !ok {
+			return nil
+		}
+		obj = pair.Value
+	}
+
+	found := []string{}
+	seen := map[string]bool{}
+	add := func(candidate string) {
+		if strings.HasPrefix(candidate, partial) && !seen[candidate] {
+			seen[candidate] = true
+			found = append(found, candidate)
+		}
+	}
+
+	if hash, ok := obj.(*object.Hash); ok {
+		for _, pair := range object.SortedPairs(hash) {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				continue
+			}
+			if op == '[' {
+				add(key.Inspect())
+			} else if isName(key.Value) {
+				add(key.Value)
+			}
+		}
+	}
+
+	if op == '.' {
+		methods := []string{}
+		for name := range object.Methods[obj.Type()] {
+			methods = append(methods, name)
+		}
+		sort.Strings(methods)
+		for _, name := range methods {
+			add(name)
+		}
+	}
+
+	return found
+}
+
+// completionTarget splits line, ending in h[ or h.na, into the operator, the
+// part of the key or field typed after it and the names before it, as in h
+// or config.db. ok is false if line ends in neither.
+func completionTarget(line string) (op byte, partial, receiver string, ok bool) {
+	end := -1
+	if i := strings.LastIndexByte(line, '['); i >= 0 {
+		// Nothing, or the start of a string, follows the bracket
+		rest := line[i+1:]
+		if rest == "" || (rest[0] == '"' && !strings.ContainsAny(rest[1:], `"\`)) {
+			op, partial, end = '[', rest, i
+		}
+	}
+	if end < 0 {
+		start := len(line)
+		for start > 0 && isNameChar(line[start-1]) {
+			start--
+		}
+		if start == 0 || line[start-1] != '.' {
+			return 0, "", "", false
+		}
+		op, partial, end = '.', line[start:], start-1
+	}
+
+	start := end
+	for start > 0 && (isNameChar(line[start-1]) || line[start-1] == '.') {
+		start--
+	}
+	receiver = line[start:end]
+
+	for _, name := range strings.Split(receiver, ".") {
+		if !isName(name) {
+			return 0, "", "", false
+		}
+	}
+
+	return op, partial, receiver, true
+}
+
+// isName reports whether s could be written as an identifier, so as a field.
+func isName(s string) bool {
+	if s == "" || ('0' <= s[0] && s[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+
+	return token.LookupIdent(s) == token.IDENT
+}
+
+func isNameChar(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '_'
+}
+
+func (c Config) printCompletions(out io.Writer, candidates []string) {
+	if len(candidates) == 0 {
+		return
+	}
+
+	io.WriteString(out, strings.Join(candidates, "  ")+"\n")
+}
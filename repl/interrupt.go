@@ -0,0 +1,51 @@
+package repl
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// interrupts delivers SIGINT to the REPL while it is running. A nil channel
+// means interrupts are left to their default behavior.
+func (c Config) interrupts() (<-chan os.Signal, func()) {
+	if !c.HandleInterrupts {
+		return nil, func() {}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+
+	return sigs, func() { signal.Stop(sigs) }
+}
+
+// runInterruptible calls fn on its own goroutine and cancels the context it
+// was given when an interrupt arrives, waiting for fn to wind down.
+func runInterruptible(parent context.Context, sigs <-chan os.Signal, fn func(ctx context.Context)) {
+	if sigs == nil {
+		fn(parent)
+		return
+	}
+
+	// Drop interrupts that arrived while waiting for input
+	select {
+	case <-sigs:
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+
+	select {
+	case <-done:
+	case <-sigs:
+		cancel()
+		<-done
+	}
+}
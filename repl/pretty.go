@@ -0,0 +1,133 @@
+package repl
+
+import (
+	"fmt"
+	"monkey/object"
+	"strings"
+)
+
+const (
+	// Composite values nested deeper than this are elided as [...] or {...}
+	maxPrettyDepth = 8
+	// Composites whose single line form is at most this wide stay on one line
+	maxInlineWidth = 60
+	indentUnit     = "  "
+)
+
+// pretty renders obj, breaking arrays and hashes across indented lines when
+// they are too wide or contain other composites. seen holds the composites
+// currently being printed so self-referencing values don't recurse forever.
+func (c Config) pretty(obj object.Object, depth int, seen map[object.Object]bool) string {
+	switch obj := obj.(type) {
+	case *object.Error:
+		return c.paint(colorRed, obj.Inspect())
+	case *object.String:
+		return c.paint(colorGreen, obj.Inspect())
+	case *object.Integer, *object.BigInt:
+		return c.paint(colorCyan, obj.Inspect())
+	case *object.Boolean:
+		return c.paint(colorYellow, obj.Inspect())
+	case *object.Null:
+		return c.paint(colorGray, obj.Inspect())
+	case *object.Array:
+		if seen[obj] || depth >= maxPrettyDepth {
+			return "[...]"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		elements := []string{}
+		for _, el := range obj.Elements {
+			elements = append(elements, c.pretty(el, depth+1, seen))
+		}
+
+		return c.layout("[", "]", ",", elements, depth, isFlat(obj))
+	case *object.Hash:
+		if seen[obj] || depth >= maxPrettyDepth {
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		pairs := []string{}
+		for _, pair := range sortedPairs(obj) {
+			key := c.pretty(pair.Key, depth+1, seen)
+			value := c.pretty(pair.Value, depth+1, seen)
+			pairs = append(pairs, fmt.Sprintf("%s: %s", key, value))
+		}
+
+		return c.layout("{", "}", ", ", pairs, depth, isFlat(obj))
+	default:
+		return c.paint(colorMagenta, obj.Inspect())
+	}
+}
+
+// layout joins already rendered elements either on a single line or one
+// element per line, indented one level deeper than the enclosing value.
+func (c Config) layout(open, close, sep string, elements []string, depth int, flat bool) string {
+	inline := open + strings.Join(elements, sep) + close
+
+	if len(elements) == 0 || (flat && visibleWidth(inline) <= maxInlineWidth) {
+		return inline
+	}
+
+	inner := strings.Repeat(indentUnit, depth+1)
+	outer := strings.Repeat(indentUnit, depth)
+
+	var out strings.Builder
+	out.WriteString(open + "\n")
+	for i, el := range elements {
+		out.WriteString(inner + el)
+		if i < len(elements)-1 {
+			out.WriteString(strings.TrimRight(sep, " "))
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString(outer + close)
+
+	return out.String()
+}
+
+// isFlat reports whether a composite holds no other arrays or hashes.
+func isFlat(obj object.Object) bool {
+	var values []object.Object
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		values = obj.Elements
+	case *object.Hash:
+		for _, pair := range obj.Pairs {
+			values = append(values, pair.Key, pair.Value)
+		}
+	}
+
+	for _, v := range values {
+		switch v.(type) {
+		case *object.Array, *object.Hash:
+			return false
+		}
+	}
+
+	return true
+}
+
+// visibleWidth counts the characters of s that are not part of ANSI escapes.
+func visibleWidth(s string) int {
+	width := 0
+	inEscape := false
+
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		default:
+			width++
+		}
+	}
+
+	return width
+}
@@ -2,22 +2,50 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
+	"os/user"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// Greeting is the banner of an interactive session, welcoming the user by
+// name when it's known.
+func Greeting() string {
+	name := ""
+	if u, err := user.Current(); err == nil {
+		name = " " + u.Username
+	}
+
+	return fmt.Sprintf("Hello%s! This is the Monkey programming language!\nFeel free to type in commands\n", name)
+}
+
+// LastResultName is bound to the result of the previous line.
+const LastResultName = "_"
+
 func Start(in io.Reader, out io.Writer) {
+	StartWithConfig(in, out, DefaultConfig(out))
+}
+
+func StartWithConfig(in io.Reader, out io.Writer, cfg Config) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	ctx := object.WithOutput(context.Background(), out)
+	// Modules imported during the session are only evaluated once
+	ctx = module.WithLoader(ctx, module.NewLoader(""))
+	sigs, stop := cfg.interrupts()
+	defer stop()
 
+	cfg.printBanner(out)
 	for {
-		fmt.Fprintf(out, PROMPT)
+		cfg.printPrompt(out)
 		scanned := scanner.Scan()
 
 		if !scanned {
@@ -25,27 +53,38 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
+		if strings.HasSuffix(line, CompletionSuffix) {
+			cfg.printCompletions(out, completions(strings.TrimSuffix(line, CompletionSuffix), env.Get))
+			continue
+		}
+
 		l := lexer.New(line)
 		p := parser.New(l)
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			cfg.printParserErrors(out, line, p.Errors())
+			continue
+		}
+
+		if cfg.warningsFail(out, line, p.Warnings()) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		var evaluated object.Object
+		runInterruptible(ctx, sigs, func(ctx context.Context) {
+			evaluated = evaluator.EvalContext(ctx, program, env)
+		})
 
 		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+			if evaluated.Type() != object.ERROR_OBJ {
+				env.Set(LastResultName, evaluated)
+			}
+
+			cfg.printResult(out, evaluated)
+			cfg.printExcerpt(out, line, evaluated)
+			cfg.printStackTrace(out, evaluated)
 		}
 	}
 
 }
-
-func printParserErrors(out io.Writer, errors []string) {
-	for _, error := range errors {
-		io.WriteString(out, "\t"+error+"\t")
-	}
-}
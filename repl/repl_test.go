@@ -0,0 +1,232 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"monkey/object"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestColoredOutput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`5`, colorCyan + "5" + colorReset},
+		{`"hi"`, colorGreen + `"hi"` + colorReset},
+		{`true`, colorYellow + "true" + colorReset},
+		{`[1]`, "[" + colorCyan + "1" + colorReset + "]"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		StartVMReplWithConfig(strings.NewReader(tt.input), &out, Config{Color: true})
+
+		if !strings.Contains(out.String(), tt.expected) {
+			t.Errorf("expected output to contain %q, got %q", tt.expected, out.String())
+		}
+	}
+}
+
+func TestNoColorForNonTerminal(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader(`5 + true`), &out)
+
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("expected no escape sequences, got %q", out.String())
+	}
+
+	if !strings.Contains(out.String(), "ERROR: type mismatch") {
+		t.Errorf("expected error output, got %q", out.String())
+	}
+}
+
+func TestPrettyPrint(t *testing.T) {
+	cfg := Config{}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3]`, "[1,2,3]"},
+		{`{"b": 2, "a": 1}`, `{"a": 1, "b": 2}`},
+		{`[[1, 2], [3]]`, "[\n  [1,2],\n  [3]\n]"},
+		{`{"a": [1], "b": {"c": true}}`, "{\n  \"a\": [1],\n  \"b\": {\"c\": true}\n}"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		StartVMReplWithConfig(strings.NewReader(tt.input), &out, cfg)
+
+		expected := PROMPT + tt.expected + "\n" + PROMPT
+		if out.String() != expected {
+			t.Errorf("wrong output. expected %q, got %q", expected, out.String())
+		}
+	}
+}
+
+func TestShowTypes(t *testing.T) {
+	input := "1 + 5\n\"hi\"\nputs(1)\n1 + true\n"
+
+	var out bytes.Buffer
+	StartVMReplWithConfig(strings.NewReader(input), &out, Config{ShowTypes: true})
+	for _, expected := range []string{"6 : INTEGER\n", "\"hi\" : STRING\n", "null : NULL\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("vm repl: expected output to contain %q, got %q", expected, out.String())
+		}
+	}
+
+	out.Reset()
+	StartWithConfig(strings.NewReader(input), &out, Config{ShowTypes: true})
+	for _, expected := range []string{"6 : INTEGER\n", "\"hi\" : STRING\n", "BOOLEAN at 1:1\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("evaluator repl: expected output to contain %q, got %q", expected, out.String())
+		}
+	}
+}
+
+func TestPromptAndBanner(t *testing.T) {
+	tests := []struct {
+		cfg      Config
+		expected string
+	}{
+		{Config{Prompt: "monkey> ", Banner: "hi"}, "hi\nmonkey> 2\nmonkey> "},
+		{Config{Banner: "hi\n"}, "hi\n" + PROMPT + "2\n" + PROMPT},
+		{Config{Prompt: "monkey> ", Banner: "hi", Quiet: true}, "2\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		StartVMReplWithConfig(strings.NewReader("1 + 1\n"), &out, tt.cfg)
+		if out.String() != tt.expected {
+			t.Errorf("vm repl: expected %q, got %q", tt.expected, out.String())
+		}
+
+		out.Reset()
+		StartWithConfig(strings.NewReader("1 + 1\n"), &out, tt.cfg)
+		if out.String() != tt.expected {
+			t.Errorf("evaluator repl: expected %q, got %q", tt.expected, out.String())
+		}
+	}
+}
+
+func TestPrettyPrintCycles(t *testing.T) {
+	arr := &object.Array{}
+	arr.Elements = []object.Object{&object.Integer{Value: 1}, arr}
+
+	got := Config{}.render(arr)
+	if got != "[\n  1,\n  [...]\n]" {
+		t.Errorf("wrong output for cyclic array, got %q", got)
+	}
+}
+
+func TestServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+	defer ln.Close()
+
+	go Serve(ln, Config{})
+
+	session := func(input string) string {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("could not dial: %s", err)
+		}
+		defer conn.Close()
+
+		io.WriteString(conn, input)
+		conn.(*net.TCPConn).CloseWrite()
+
+		out, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatalf("could not read: %s", err)
+		}
+
+		return string(out)
+	}
+
+	first := session("let x = 5;\nputs(x * 2);\n")
+	if !strings.Contains(first, "10\n") {
+		t.Errorf("expected puts output over the connection, got %q", first)
+	}
+
+	// A fresh connection must not see bindings from other sessions
+	second := session("x\n")
+	if !strings.Contains(second, "undefined variable x") {
+		t.Errorf("expected x to be undefined in a new session, got %q", second)
+	}
+}
+
+func TestLastResultBinding(t *testing.T) {
+	input := "5 * 2\n_ + 1\n[_, _]\n"
+	expected := PROMPT + "10\n" + PROMPT + "11\n" + PROMPT + "[11,11]\n" + PROMPT
+
+	var out bytes.Buffer
+	StartVMReplWithConfig(strings.NewReader(input), &out, Config{})
+	if out.String() != expected {
+		t.Errorf("vm repl: expected %q, got %q", expected, out.String())
+	}
+
+	out.Reset()
+	StartWithConfig(strings.NewReader(input), &out, Config{})
+	if out.String() != expected {
+		t.Errorf("evaluator repl: expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestErrorExcerpt(t *testing.T) {
+	input := "let f = fn() { 1 + true }\n1 + true\nf()\n"
+	excerpt := "\t1 + true\n\t^\n"
+
+	var out bytes.Buffer
+	StartVMReplWithConfig(strings.NewReader(input), &out, Config{})
+	if strings.Count(out.String(), excerpt) != 1 || strings.Contains(out.String(), "\tf()") {
+		t.Errorf("vm repl: expected one excerpt %q, got %q", excerpt, out.String())
+	}
+
+	out.Reset()
+	StartWithConfig(strings.NewReader(input), &out, Config{})
+	if strings.Count(out.String(), excerpt) != 1 || strings.Contains(out.String(), "\tf()") {
+		t.Errorf("evaluator repl: expected one excerpt %q, got %q", excerpt, out.String())
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{`h[`, `"name"  "nested"  "two words"` + "\n"},
+		{`puts(h["n`, `"name"  "nested"` + "\n"},
+		{`h.n`, "name  nested\n"},
+		{`h.`, "name  nested  keys  len  values\n"},
+		{`h.nested.`, "inner  keys  len  values\n"},
+		{`h.nested["i`, `"inner"` + "\n"},
+		{`s.st`, "startsWith\n"},
+		{`h.missing.`, ""},
+		{`undefined[`, ""},
+		{`1 + `, ""},
+	}
+
+	setup := `let h = {"name": "monkey", "two words": 2, 3: 3, "nested": {"inner": 1}}; let s = "str"; 0` + "\n"
+	for _, tt := range tests {
+		input := setup + tt.line + CompletionSuffix + "\n"
+		expected := "0\n" + tt.expected
+
+		var out bytes.Buffer
+		StartVMReplWithConfig(strings.NewReader(input), &out, Config{Quiet: true})
+		if out.String() != expected {
+			t.Errorf("vm repl: completing %q, expected %q, got %q", tt.line, expected, out.String())
+		}
+
+		out.Reset()
+		StartWithConfig(strings.NewReader(input), &out, Config{Quiet: true})
+		if out.String() != expected {
+			t.Errorf("evaluator repl: completing %q, expected %q, got %q", tt.line, expected, out.String())
+		}
+	}
+}
@@ -2,27 +2,47 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
+	"context"
 	"io"
 	"monkey/compiler"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
+	"strings"
 )
 
 func StartVMRepl(in io.Reader, out io.Writer) {
+	StartVMReplWithConfig(in, out, DefaultConfig(out))
+}
+
+func StartVMReplWithConfig(in io.Reader, out io.Writer, cfg Config) {
 	scanner := bufio.NewScanner(in)
 	constants := []object.Object{}
 	globals := make([]object.Object, vm.GlobalsSize)
 	symbolTable := compiler.NewSymbolTable()
+	ctx := object.WithOutput(context.Background(), out)
+	sigs, stop := cfg.interrupts()
+	defer stop()
 
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
 
+	lookupGlobal := func(name string) (object.Object, bool) {
+		symbol, ok := symbolTable.Lookup(name)
+		if !ok || symbol.Scope != compiler.GlobalScope {
+			return nil, false
+		}
+		return globals[symbol.Index], true
+	}
+
+	last := symbolTable.Define(LastResultName)
+	globals[last.Index] = vm.Null
+
+	cfg.printBanner(out)
 	for {
-		fmt.Fprintf(out, PROMPT)
+		cfg.printPrompt(out)
 		scanned := scanner.Scan()
 
 		if !scanned {
@@ -30,6 +50,11 @@ func StartVMRepl(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
+		if strings.HasSuffix(line, CompletionSuffix) {
+			cfg.printCompletions(out, completions(strings.TrimSuffix(line, CompletionSuffix), lookupGlobal))
+			continue
+		}
+
 		l := lexer.New(line)
 		p := parser.New(l)
 
@@ -38,7 +63,11 @@ func StartVMRepl(in io.Reader, out io.Writer) {
 		errs := p.Errors()
 
 		if len(errs) != 0 {
-			printParserErrors(out, p.Errors())
+			cfg.printParserErrors(out, line, p.Errors())
+			continue
+		}
+
+		if cfg.warningsFail(out, line, p.Warnings()) {
 			continue
 		}
 
@@ -46,7 +75,7 @@ func StartVMRepl(in io.Reader, out io.Writer) {
 		err := c.Compile(program)
 
 		if err != nil {
-			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
+			cfg.printError(out, "Woops! Compilation failed:\n %s\n", err)
 			continue
 		}
 
@@ -54,16 +83,20 @@ func StartVMRepl(in io.Reader, out io.Writer) {
 		constants = code.Constants
 
 		machine := vm.NewWithGlobalsStore(code, globals)
-		err = machine.Run()
+		runInterruptible(ctx, sigs, func(ctx context.Context) {
+			err = machine.RunContext(ctx)
+		})
 
 		if err != nil {
-			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
+			cfg.printError(out, "Woops! Executing bytecode failed:\n %s\n", err)
+			cfg.printExcerpt(out, line, err)
+			cfg.printStackTrace(out, err)
 			continue
 		}
 
 		lastPopped := machine.LastPoppedStackElem()
-		io.WriteString(out, lastPopped.Inspect())
-		io.WriteString(out, "\n")
+		globals[last.Index] = lastPopped
+		cfg.printResult(out, lastPopped)
 	}
 
 }
@@ -0,0 +1,29 @@
+package repl
+
+import (
+	"log"
+	"net"
+)
+
+// Serve accepts connections on ln and runs a VM REPL on each of them. Every
+// connection gets its own globals and symbol table, and output from puts is
+// sent back over the connection rather than to the server's stdout.
+func Serve(ln net.Listener, cfg Config) error {
+	// Signals belong to the server process, not to individual sessions
+	cfg.HandleInterrupts = false
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+
+			log.Printf("session opened: %s", conn.RemoteAddr())
+			StartVMReplWithConfig(conn, conn, cfg)
+			log.Printf("session closed: %s", conn.RemoteAddr())
+		}()
+	}
+}
@@ -0,0 +1,111 @@
+package run
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/vm"
+	"os"
+	"time"
+)
+
+// The workload benchmarked when no script is given, as in the book's
+// benchmark chapter
+const BenchmarkProgram = `
+let fibonacci = fn(x) {
+  if (x == 0) {
+    0
+  } else {
+    if (x == 1) {
+      return 1;
+    } else {
+      fibonacci(x - 1) + fibonacci(x - 2);
+    }
+  }
+};
+fibonacci(27);
+`
+
+// Bench runs source under both engines, printing the result and wall time of
+// each and whether the results agree. Differing results are reported as a
+// runtime error.
+func Bench(source string, opts Options) int {
+	program, ok := parse(source, opts.Filename, opts)
+	if !ok {
+		return ExitParseError
+	}
+
+	out := opts.stdout()
+	results := []string{}
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		start := time.Now()
+		result, err := benchEngine(engine, program, opts)
+		duration := time.Since(start)
+
+		if err != nil {
+			fmt.Fprintf(opts.stderr(), "engine=%s: %s\n", engine, err)
+			return ExitRuntimeError
+		}
+
+		results = append(results, result)
+		fmt.Fprintf(out, "engine=%s, result=%s, duration=%s\n", engine, result, duration)
+	}
+
+	if results[0] != results[1] {
+		fmt.Fprintln(out, "results differ")
+		return ExitRuntimeError
+	}
+
+	fmt.Fprintln(out, "results match")
+	return ExitOK
+}
+
+// BenchFile benchmarks the program in filename.
+func BenchFile(filename string, opts Options) int {
+	filename, opts = entrypoint(filename, opts)
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to read file: %s\n", err)
+		return ExitUsageError
+	}
+
+	opts.Filename = filename
+	return Bench(string(text), opts)
+}
+
+// benchEngine runs program and returns its result as printed by Inspect.
+func benchEngine(engine Engine, program *ast.Program, opts Options) (string, error) {
+	ctx := opts.context()
+
+	switch engine {
+	case EngineVM:
+		c := opts.compiler()
+		if err := c.Compile(program); err != nil {
+			return "", fmt.Errorf("compilation failed: %s", err)
+		}
+
+		v := vm.New(c.Bytecode())
+		if err := v.RunContext(ctx); err != nil {
+			return "", fmt.Errorf("executing bytecode failed: %s", err)
+		}
+
+		return inspect(v.LastPoppedStackElem()), nil
+	default:
+		result := evaluator.EvalContext(ctx, program, object.NewEnvironment())
+		if isError(result) {
+			return "", fmt.Errorf("%s", result.Inspect())
+		}
+
+		return inspect(result), nil
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "null"
+	}
+
+	return obj.Inspect()
+}
@@ -0,0 +1,133 @@
+package run
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"monkey/compiler"
+	"monkey/module"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+)
+
+// DefaultCacheDir returns where compiled bytecode is cached by default, a
+// monkey directory in the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "monkey"), nil
+}
+
+// cacheEntry is what's cached for a program: its serialized bytecode, the
+// warnings parsing it gave, and a hash of each module it imports, which the
+// bytecode includes, so it's only used while they're unchanged too.
+type cacheEntry struct {
+	Imports  map[string]string
+	Bytecode []byte
+	Warnings []*parser.Warning
+}
+
+// cachePath returns the file the bytecode of source is cached in. It's named
+// by a hash of the source, where it and its imports were read from, and the
+// options changing how it's compiled.
+func cachePath(source string, opts Options) string {
+	filename, root := opts.Filename, opts.Root
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	if abs, err := filepath.Abs(root); err == nil && root != "" {
+		root = abs
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%t\x00%t\x00%s", filename, root, opts.Inline, opts.Optimize, source)
+
+	return filepath.Join(opts.CacheDir, hex.EncodeToString(h.Sum(nil))+".cache")
+}
+
+// readCache returns the cached bytecode of source and the warnings parsing
+// it gave, or nil if there's none, it's from another version, or a module it
+// imports has changed since.
+func readCache(source string, opts Options) (*compiler.Bytecode, []*parser.Warning) {
+	data, err := os.ReadFile(cachePath(source, opts))
+	if err != nil {
+		return nil, nil
+	}
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return nil, nil
+	}
+
+	for filename, hash := range entry.Imports {
+		if hashFile(filename) != hash {
+			return nil, nil
+		}
+	}
+
+	bytecode, err := compiler.ReadBytecode(bytes.NewReader(entry.Bytecode))
+	if err != nil {
+		return nil, nil
+	}
+
+	return bytecode, entry.Warnings
+}
+
+// writeCache caches the bytecode c compiled from source. Failing to is left
+// unreported, as the program runs all the same.
+func writeCache(source string, c *compiler.Compiler, bytecode *compiler.Bytecode, opts Options) {
+	entry := cacheEntry{Imports: map[string]string{}, Warnings: opts.warnings}
+	for _, filename := range c.Imports() {
+		entry.Imports[filename] = hashFile(filename)
+	}
+
+	var serialized bytes.Buffer
+	if err := bytecode.Write(&serialized); err != nil {
+		return
+	}
+	entry.Bytecode = serialized.Bytes()
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(entry); err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(opts.CacheDir, 0755); err != nil {
+		return
+	}
+
+	// Written to a temporary file first so runs at the same time never read
+	// half an entry
+	f, err := os.CreateTemp(opts.CacheDir, "*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data.Bytes())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), cachePath(source, opts))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// hashFile returns a hash of the module in filename, or "" if it can't be
+// read.
+func hashFile(filename string) string {
+	text, err := module.Read(filename)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(text)
+	return hex.EncodeToString(sum[:])
+}
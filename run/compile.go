@@ -0,0 +1,124 @@
+package run
+
+import (
+	"fmt"
+	"monkey/compiler"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extension of files holding serialized bytecode
+const BytecodeExt = ".mkc"
+
+// IsBytecodeFile reports whether filename looks like compiled bytecode.
+func IsBytecodeFile(filename string) bool {
+	return filepath.Ext(filename) == BytecodeExt
+}
+
+// BytecodePath returns the default output path for compiling filename.
+func BytecodePath(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + BytecodeExt
+}
+
+// CompileFile compiles the program in filename and writes its bytecode to
+// output.
+func CompileFile(filename, output string, opts Options) int {
+	filename, opts = entrypoint(filename, opts)
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to read file: %s\n", err)
+		return ExitUsageError
+	}
+
+	opts.Filename = filename
+	bytecode, code := compile(string(text), opts)
+	if code != ExitOK {
+		return code
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to create %s: %s\n", output, err)
+		return ExitUsageError
+	}
+	defer f.Close()
+
+	if err := bytecode.Write(f); err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to write %s: %s\n", output, err)
+		return ExitUsageError
+	}
+
+	return ExitOK
+}
+
+// RunBytecodeFromFile runs bytecode written by CompileFile on the VM.
+func RunBytecodeFromFile(filename string, opts Options) int {
+	bytecode, code := readBytecode(filename, opts)
+	if code != ExitOK {
+		return code
+	}
+
+	return runBytecode("", bytecode, opts)
+}
+
+// DisassembleFile prints the bytecode of a source or compiled file.
+func DisassembleFile(filename string, opts Options) int {
+	if IsBytecodeFile(filename) {
+		bytecode, code := readBytecode(filename, opts)
+		if code != ExitOK {
+			return code
+		}
+
+		printBytecode(opts.stdout(), bytecode)
+		return ExitOK
+	}
+
+	filename, opts = entrypoint(filename, opts)
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to read file: %s\n", err)
+		return ExitUsageError
+	}
+
+	opts.Filename = filename
+	bytecode, code := compile(string(text), opts)
+	if code != ExitOK {
+		return code
+	}
+
+	printBytecode(opts.stdout(), bytecode)
+	return ExitOK
+}
+
+func compile(source string, opts Options) (*compiler.Bytecode, int) {
+	program, ok := parse(source, opts.Filename, opts)
+	if !ok {
+		return nil, ExitParseError
+	}
+
+	c := opts.compiler()
+	if err := c.Compile(program); err != nil {
+		fmt.Fprintf(opts.stderr(), "compilation failed: %s\n", err)
+		return nil, ExitCompileError
+	}
+
+	return c.Bytecode(), ExitOK
+}
+
+func readBytecode(filename string, opts Options) (*compiler.Bytecode, int) {
+	f, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to read file: %s\n", err)
+		return nil, ExitUsageError
+	}
+	defer f.Close()
+
+	bytecode, err := compiler.ReadBytecode(f)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "invalid bytecode in %s: %s\n", filename, err)
+		return nil, ExitUsageError
+	}
+
+	return bytecode, ExitOK
+}
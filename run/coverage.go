@@ -0,0 +1,134 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/parser"
+	"monkey/token"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// coverage records which lines of source run, as either engine reports them
+// through its hooks. Functions started with spawn run in goroutines of their
+// own, so it's safe to use from several at once.
+type coverage struct {
+	mu sync.Mutex
+	// Lines run, by the file they're in
+	lines map[string]map[int]bool
+	// Source of programs not read from files, by the filename they're
+	// known as, which may be ""
+	sources map[string]string
+}
+
+func newCoverage() *coverage {
+	return &coverage{lines: map[string]map[int]bool{}, sources: map[string]string{}}
+}
+
+// hit records the line at pos as run.
+func (c *coverage) hit(pos token.Position) {
+	if !pos.IsValid() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lines[pos.Filename] == nil {
+		c.lines[pos.Filename] = map[int]bool{}
+	}
+	c.lines[pos.Filename][pos.Line] = true
+}
+
+// forget leaves filename out of the report, as for test files.
+func (c *coverage) forget(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.lines, filename)
+	delete(c.sources, filename)
+}
+
+// report writes, for each file that ran, how many of the lines statements
+// start on were run, followed by its source with those lines marked + if
+// they were and - if they weren't. Modules of the standard library are left
+// out.
+func (c *coverage) report(out io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := []string{}
+	for filename := range c.lines {
+		if !strings.HasPrefix(filepath.ToSlash(filename), module.StdPrefix) {
+			files = append(files, filename)
+		}
+	}
+	sort.Strings(files)
+
+	for _, filename := range files {
+		source, ok := c.sources[filename]
+		if !ok {
+			text, err := module.Read(filename)
+			if err != nil {
+				continue
+			}
+			source = string(text)
+		}
+
+		statements := statementLines(filename, source)
+		run := 0
+		for line := range statements {
+			if c.lines[filename][line] {
+				run++
+			}
+		}
+
+		name := filename
+		if name == "" {
+			name = "program"
+		}
+		percent := 100.0
+		if len(statements) != 0 {
+			percent = float64(run) / float64(len(statements)) * 100
+		}
+		fmt.Fprintf(out, "%s: %d of %d lines run (%.1f%%)\n", name, run, len(statements), percent)
+
+		for i, text := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+			mark := " "
+			if statements[i+1] {
+				mark = "-"
+				if c.lines[filename][i+1] {
+					mark = "+"
+				}
+			}
+			fmt.Fprintf(out, "%s %4d  %s\n", mark, i+1, text)
+		}
+	}
+}
+
+// statementLines returns the lines of source that statements start on,
+// none if it doesn't parse.
+func statementLines(filename, source string) map[int]bool {
+	lines := map[int]bool{}
+
+	p := parser.New(lexer.NewWithFilename(filename, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return lines
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.LetStatement, *ast.ReturnStatement, *ast.ExpressionStatement:
+			lines[node.Pos().Line] = true
+		}
+		return true
+	})
+
+	return lines
+}
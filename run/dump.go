@@ -0,0 +1,98 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/token"
+)
+
+// dumpTokens prints every token l reads, one per line, as it reads them.
+func dumpTokens(l *lexer.Lexer, opts Options) int {
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(opts.stdout(), "%-7s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if err := l.Err(); err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to read program: %s\n", err)
+		return ExitUsageError
+	}
+	return ExitOK
+}
+
+// dumpAST prints the parsed tree of source.
+func dumpAST(source string, opts Options) int {
+	program, ok := parse(source, opts.Filename, opts)
+	if !ok {
+		return ExitParseError
+	}
+
+	ast.Fprint(opts.stdout(), program)
+	return ExitOK
+}
+
+// dumpASTJSON prints the parsed tree of source as JSON, for other tools.
+func dumpASTJSON(source string, opts Options) int {
+	program, ok := parse(source, opts.Filename, opts)
+	if !ok {
+		return ExitParseError
+	}
+
+	data, err := ast.ToJSON(program)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to encode AST: %s\n", err)
+		return ExitRuntimeError
+	}
+
+	fmt.Fprintln(opts.stdout(), string(data))
+	return ExitOK
+}
+
+// dumpBytecode prints the compiled instructions of source and its constant
+// pool, including the instructions of compiled functions.
+func dumpBytecode(program *ast.Program, opts Options) int {
+	c := opts.compiler()
+	if err := c.Compile(program); err != nil {
+		fmt.Fprintf(opts.stderr(), "compilation failed: %s\n", err)
+		return ExitCompileError
+	}
+
+	printBytecode(opts.stdout(), c.Bytecode())
+	return ExitOK
+}
+
+func printBytecode(out io.Writer, bytecode *compiler.Bytecode) {
+	builtins := []string{}
+	for _, def := range object.Builtins {
+		builtins = append(builtins, def.Name)
+	}
+
+	fmt.Fprintln(out, "Instructions:")
+	bytecode.Instructions.DisassembleSymbols(out, "  ", &code.Symbols{Globals: bytecode.Globals, Builtins: builtins})
+
+	fmt.Fprintln(out, "Constants:")
+	for i, constant := range bytecode.Constants {
+		switch constant := constant.(type) {
+		case *object.CompiledFunction:
+			fmt.Fprintf(out, "  %d: %s (params=%d, locals=%d)\n",
+				i, constant.Type(), constant.NumParameters, constant.NumLocals)
+			constant.Instructions.DisassembleSymbols(out, "    ", &code.Symbols{
+				Globals:  bytecode.Globals,
+				Locals:   constant.LocalNames,
+				Free:     constant.FreeNames,
+				Builtins: builtins,
+			})
+		default:
+			fmt.Fprintf(out, "  %d: %s %s\n", i, constant.Type(), constant.Inspect())
+		}
+	}
+}
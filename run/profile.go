@@ -0,0 +1,87 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// profile records how often each function is called and how long the calls
+// take, as either engine reports them through its hooks. Functions are told
+// apart by the name they were bound to, so anonymous ones are counted
+// together.
+type profile struct {
+	mu        sync.Mutex
+	functions map[string]*functionProfile
+}
+
+type functionProfile struct {
+	name  string
+	calls int
+	// Time spent in calls, including the calls they make
+	time time.Duration
+	// Calls running, so that those made recursively aren't timed again as
+	// part of the outermost
+	running int
+}
+
+func newProfile() *profile {
+	return &profile{functions: map[string]*functionProfile{}}
+}
+
+// call records a call to the function bound to name starting, returning the
+// function to call once it returns.
+func (p *profile) call(name string) func() {
+	if name == "" {
+		name = "anonymous function"
+	}
+
+	p.mu.Lock()
+	f := p.functions[name]
+	if f == nil {
+		f = &functionProfile{name: name}
+		p.functions[name] = f
+	}
+	f.calls++
+	f.running++
+	outermost := f.running == 1
+	p.mu.Unlock()
+
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		f.running--
+		if outermost {
+			f.time += elapsed
+		}
+	}
+}
+
+// report writes the calls to and time spent in each function, those that
+// took longest first.
+func (p *profile) report(out io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	functions := make([]*functionProfile, 0, len(p.functions))
+	for _, f := range p.functions {
+		functions = append(functions, f)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].time != functions[j].time {
+			return functions[i].time > functions[j].time
+		}
+		return functions[i].name < functions[j].name
+	})
+
+	fmt.Fprintf(out, "%10s %12s  %s\n", "calls", "time", "function")
+	for _, f := range functions {
+		fmt.Fprintf(out, "%10d %12s  %s\n", f.calls, f.time.Round(time.Microsecond), f.name)
+	}
+}
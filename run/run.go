@@ -1,47 +1,429 @@
 package run
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/optimizer"
 	"monkey/parser"
+	"monkey/token"
 	"monkey/vm"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
-func RunProgramFromFile(filename string) {
+type Engine string
+
+const (
+	// Tree-walking evaluator
+	EngineEval Engine = "eval"
+	// Bytecode compiler and virtual machine
+	EngineVM Engine = "vm"
+)
+
+// Process exit codes, so scripts can be used in shell pipelines and CI
+const (
+	ExitOK           = 0
+	ExitRuntimeError = 1
+	ExitParseError   = 2
+	ExitCompileError = 3
+	// The program could not be read or the options were invalid
+	ExitUsageError = 4
+)
+
+type Options struct {
+	Engine Engine
+
+	// Context the program runs under, which interrupts it once it's done.
+	// Defaults to context.Background().
+	Context context.Context
+
+	// Where results and puts output go, defaults to os.Stdout
+	Stdout io.Writer
+	// Where errors are reported, defaults to os.Stderr
+	Stderr io.Writer
+
+	// Arguments passed to the script, available through args()
+	Args []string
+	// File the program was read from, named in the positions of errors
+	Filename string
+	// Directory that imports not starting with ./ or ../ are relative to.
+	// Defaults to the directory of Filename, or the current one.
+	Root string
+	// How deeply functions may recurse, object.DefaultMaxDepth if zero
+	MaxDepth int
+	// Directory the bytecode of files run on the VM is cached in, so it's
+	// only compiled again once they change. No caching if empty.
+	CacheDir string
+	// Inline calls to small functions when compiling for the VM, see
+	// compiler.Compiler.SetInlining
+	Inline bool
+	// Simplify the program before running it, see the optimizer package.
+	// Modules it imports are run as they are.
+	Optimize bool
+
+	// Treat warnings as errors, failing before the program runs
+	WarningsAsErrors bool
+	// Run in strict mode, see object.WithStrict
+	Strict bool
+	// Check arguments and results against type annotations, see
+	// object.WithCheckTypes
+	CheckTypes bool
+
+	// Where to report which lines of source ran once the program finishes,
+	// no report if nil
+	Coverage io.Writer
+	// Where to report how often each function was called and how long the
+	// calls took once the program finishes, no report if nil
+	Profile io.Writer
+
+	// Record what the reports are on while there are some to write
+	coverage *coverage
+	profile  *profile
+	// Warnings found parsing the program, cached along with its bytecode
+	warnings []*parser.Warning
+
+	// Print the token stream instead of running the program
+	DumpTokens bool
+	// Print the parsed AST instead of running the program
+	DumpAST bool
+	// Print the parsed AST as JSON instead of running the program
+	DumpASTJSON bool
+	// Print the compiled bytecode instead of running it, VM engine only
+	DumpBytecode bool
+}
+
+// context builds the context the program runs with.
+func (o Options) context() context.Context {
+	ctx := o.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = object.WithOutput(ctx, o.stdout())
+	if o.MaxDepth > 0 {
+		ctx = object.WithMaxDepth(ctx, o.MaxDepth)
+	}
+	if o.Strict {
+		ctx = object.WithStrict(ctx)
+	}
+	if o.CheckTypes {
+		ctx = object.WithCheckTypes(ctx)
+	}
+	if o.coverage != nil || o.profile != nil {
+		ctx = o.withHooks(ctx)
+	}
+	ctx = module.WithLoader(ctx, o.loader())
+	return object.WithArgs(ctx, o.Args)
+}
+
+// withHooks returns a copy of ctx in which either engine reports what runs
+// to the coverage and profile being recorded.
+func (o Options) withHooks(ctx context.Context) context.Context {
+	evalHooks, vmHooks := &evaluator.Hooks{}, &vm.Hooks{}
+
+	if c := o.coverage; c != nil {
+		evalHooks.Enter = func(node ast.Node, env *object.Environment) { c.hit(node.Pos()) }
+		vmHooks.Line = func(v *vm.VM, pos token.Position) { c.hit(pos) }
+	}
+	if p := o.profile; p != nil {
+		evalHooks.Call = func(fn *object.FunctionValue) func() { return p.call(fn.Name) }
+		vmHooks.Call = func(v *vm.VM, fn *object.CompiledFunction) func() { return p.call(fn.Name) }
+	}
+
+	ctx = evaluator.WithHooks(ctx, evalHooks)
+	return vm.WithHooks(ctx, vmHooks)
+}
+
+// loader returns a loader resolving imports against the module root.
+func (o Options) loader() *module.Loader {
+	root := o.Root
+	if root == "" && o.Filename != "" {
+		root = filepath.Dir(o.Filename)
+	}
+	return module.NewLoader(root)
+}
+
+// compiler returns a compiler resolving imports against the module root.
+func (o Options) compiler() *compiler.Compiler {
+	c := compiler.New()
+	c.SetLoader(o.loader())
+	c.SetInlining(o.Inline)
+	return c
+}
+
+// caching reports whether the bytecode of the program is cached, which is
+// only done for programs read from files.
+func (o Options) caching() bool {
+	return o.CacheDir != "" && o.Filename != ""
+}
+
+func (o Options) stdout() io.Writer {
+	if o.Stdout == nil {
+		return os.Stdout
+	}
+	return o.Stdout
+}
+
+func (o Options) stderr() io.Writer {
+	if o.Stderr == nil {
+		return os.Stderr
+	}
+	return o.Stderr
+}
+
+// Entrypoint is the file run for a project directory, which is the root of
+// its imports.
+const Entrypoint = "main" + module.Ext
+
+// RunProgramFromFile runs the program in filename and returns the exit code
+// the process should finish with. filename may be a project directory, to
+// run its Entrypoint.
+func RunProgramFromFile(filename string, opts Options) int {
+	if IsBytecodeFile(filename) {
+		return RunBytecodeFromFile(filename, opts)
+	}
+
+	filename, opts = entrypoint(filename, opts)
+
+	// Tokens are printed as they're read, however big the file is
+	if opts.DumpTokens {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintf(opts.stderr(), "failed to read file: %s\n", err)
+			return ExitUsageError
+		}
+		defer f.Close()
+
+		return dumpTokens(lexer.NewReader(filename, f), opts)
+	}
+
 	text, err := os.ReadFile(filename)
 
 	if err != nil {
-		panic("Failed to read file: " + err.Error())
+		fmt.Fprintf(opts.stderr(), "failed to read file: %s\n", err)
+		return ExitUsageError
+	}
+
+	opts.Filename = filename
+	return RunProgram(string(text), opts)
+}
+
+// entrypoint returns the file to run for filename, the Entrypoint of a
+// directory with the directory as the module root, or filename itself.
+func entrypoint(filename string, opts Options) (string, Options) {
+	info, err := os.Stat(filename)
+	if err != nil || !info.IsDir() {
+		return filename, opts
 	}
 
-	l := lexer.New(string(text))
+	if opts.Root == "" {
+		opts.Root = filename
+	}
+
+	return filepath.Join(filename, Entrypoint), opts
+}
+
+// RunProgramFromReader runs the program read from r, e.g. piped into stdin.
+func RunProgramFromReader(r io.Reader, opts Options) int {
+	if opts.DumpTokens {
+		return dumpTokens(lexer.NewReader(opts.Filename, r), opts)
+	}
+
+	text, err := io.ReadAll(r)
+
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to read program: %s\n", err)
+		return ExitUsageError
+	}
+
+	return RunProgram(string(text), opts)
+}
+
+// RunProgram parses and runs source with the configured engine, printing the
+// final result, and returns the exit code the process should finish with.
+func RunProgram(source string, opts Options) int {
+	if opts.Coverage != nil {
+		opts.coverage = newCoverage()
+		opts.coverage.sources[opts.Filename] = source
+		defer opts.coverage.report(opts.Coverage)
+	}
+	if opts.Profile != nil {
+		opts.profile = newProfile()
+		defer opts.profile.report(opts.Profile)
+	}
+
+	if opts.DumpTokens {
+		return dumpTokens(lexer.NewWithFilename(opts.Filename, source), opts)
+	}
+
+	if opts.DumpASTJSON {
+		return dumpASTJSON(source, opts)
+	}
+
+	if opts.DumpAST {
+		return dumpAST(source, opts)
+	}
+
+	if opts.Engine == EngineVM && !opts.DumpBytecode && opts.caching() {
+		if bytecode, warnings := readCache(source, opts); bytecode != nil {
+			// The program isn't parsed again, so its warnings are replayed
+			for _, warning := range warnings {
+				io.WriteString(opts.stderr(), parser.FormatDiagnostic(warning, source, nil))
+			}
+			if opts.WarningsAsErrors && len(warnings) != 0 {
+				return ExitParseError
+			}
+			return runBytecode(source, bytecode, opts)
+		}
+	}
+
+	program, warnings, ok := parseWithWarnings(source, opts.Filename, opts)
+	if !ok {
+		return ExitParseError
+	}
+	opts.warnings = warnings
+
+	if opts.DumpBytecode && opts.Engine != EngineVM {
+		fmt.Fprintln(opts.stderr(), "dumping bytecode requires the vm engine")
+		return ExitUsageError
+	}
+
+	switch opts.Engine {
+	case EngineVM:
+		if opts.DumpBytecode {
+			return dumpBytecode(program, opts)
+		}
+		return runVM(source, program, opts)
+	case EngineEval, "":
+		return runEval(source, program, opts)
+	default:
+		fmt.Fprintf(opts.stderr(), "unknown engine %q\n", opts.Engine)
+		return ExitUsageError
+	}
+}
+
+// parse reports parser errors and warnings, returning false if there were
+// errors, or warnings with WarningsAsErrors set. filename, if known, is
+// recorded in the positions of the nodes.
+func parse(text, filename string, opts Options) (*ast.Program, bool) {
+	program, _, ok := parseWithWarnings(text, filename, opts)
+	return program, ok
+}
+
+// parseWithWarnings parses like parse, also returning the warnings it
+// printed.
+func parseWithWarnings(text, filename string, opts Options) (*ast.Program, []*parser.Warning, bool) {
+	l := lexer.NewWithFilename(filename, text)
 	p := parser.New(l)
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
-		printParserErrors(os.Stderr, p.Errors())
-		return
+		for _, error := range p.Errors() {
+			io.WriteString(opts.stderr(), parser.FormatDiagnostic(error, text, nil))
+		}
+		return nil, nil, false
 	}
 
-	c := compiler.New()
-	c.Compile(program)
+	for _, warning := range p.Warnings() {
+		io.WriteString(opts.stderr(), parser.FormatDiagnostic(warning, text, nil))
+	}
+	if opts.WarningsAsErrors && len(p.Warnings()) != 0 {
+		return nil, p.Warnings(), false
+	}
 
-	bytecode := c.Bytecode()
+	if opts.Optimize {
+		optimizer.Optimize(program)
+	}
 
-	for _, constant := range bytecode.Constants {
-		fmt.Println(constant.Inspect())
+	return program, p.Warnings(), true
+}
+
+func runEval(source string, program *ast.Program, opts Options) int {
+	ctx := opts.context()
+	env := object.NewEnvironment()
+	result := evaluator.EvalContext(ctx, program, env)
+
+	if result == nil {
+		return ExitOK
+	}
+
+	if result.Type() == object.ERROR_OBJ {
+		fmt.Fprintln(opts.stderr(), result.Inspect())
+		printExcerpt(opts.stderr(), source, opts.Filename, result.(*object.Error))
+		printStackTrace(opts.stderr(), result.(*object.Error))
+		return ExitRuntimeError
 	}
-	// fmt.Println(bytecode.Instructions)
 
+	printResult(opts.stdout(), result)
+	return ExitOK
+}
+
+func runVM(source string, program *ast.Program, opts Options) int {
+	c := opts.compiler()
+	err := c.Compile(program)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "compilation failed: %s\n", err)
+		return ExitCompileError
+	}
+
+	bytecode := c.Bytecode()
+	if opts.caching() {
+		writeCache(source, c, bytecode, opts)
+	}
+
+	return runBytecode(source, bytecode, opts)
+}
+
+// runBytecode runs bytecode compiled from source, which is only used to
+// show where errors happen and may be empty if it isn't known.
+func runBytecode(source string, bytecode *compiler.Bytecode, opts Options) int {
+	ctx := opts.context()
 	v := vm.New(bytecode)
-	v.Run()
-	fmt.Println(v.LastPoppedStackElem().Inspect())
+	err := v.RunContext(ctx)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "executing bytecode failed: %s\n", err)
+		printExcerpt(opts.stderr(), source, opts.Filename, err)
+		printStackTrace(opts.stderr(), err)
+		return ExitRuntimeError
+	}
+
+	printResult(opts.stdout(), v.LastPoppedStackElem())
+	return ExitOK
+}
+
+// printResult prints the value a program finished with. Null results, e.g.
+// from a trailing puts call, are left out.
+func printResult(out io.Writer, result object.Object) {
+	if result == nil || result.Type() == object.NULL_OBJ {
+		return
+	}
+
+	fmt.Fprintln(out, result.Inspect())
+}
+
+// printExcerpt prints the line of source a runtime error happened on, with a
+// caret under where. Errors in imported modules, which come from other files,
+// aren't shown.
+func printExcerpt(out io.Writer, source, filename string, err error) {
+	e, ok := err.(*object.Error)
+	if !ok || source == "" || e.Pos.Filename != filename {
+		return
+	}
+
+	if excerpt := e.Pos.Excerpt(source); excerpt != "" {
+		io.WriteString(out, "\t"+strings.ReplaceAll(excerpt, "\n", "\n\t")+"\n")
+	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, error := range errors {
-		io.WriteString(out, "\t"+error+"\t")
+// printStackTrace prints the calls a runtime error passed through.
+func printStackTrace(out io.Writer, err error) {
+	if err, ok := err.(*object.Error); ok {
+		io.WriteString(out, err.StackTrace())
 	}
 }
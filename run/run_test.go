@@ -0,0 +1,682 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		input    string
+		engine   Engine
+		expected int
+	}{
+		{`1 + 1`, EngineEval, ExitOK},
+		{`1 + 1`, EngineVM, ExitOK},
+		{`let x = ;`, EngineEval, ExitParseError},
+		{`let x = ;`, EngineVM, ExitParseError},
+		{`y`, EngineVM, ExitCompileError},
+		{`5 + true`, EngineEval, ExitRuntimeError},
+		{`5 + true`, EngineVM, ExitRuntimeError},
+		{`1`, Engine("jit"), ExitUsageError},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := RunProgram(tt.input, Options{Engine: tt.engine, Stdout: &stdout, Stderr: &stderr})
+
+		if code != tt.expected {
+			t.Errorf("%q with engine %s: expected exit code %d, got %d (stderr %q)",
+				tt.input, tt.engine, tt.expected, code, stderr.String())
+		}
+	}
+}
+
+func TestMissingFile(t *testing.T) {
+	var stderr bytes.Buffer
+	code := RunProgramFromFile("does-not-exist.monkey", Options{Stderr: &stderr})
+
+	if code != ExitUsageError {
+		t.Errorf("expected exit code %d, got %d", ExitUsageError, code)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		var stdout bytes.Buffer
+		RunProgram(`puts("hi"); 1 + 2`, Options{Engine: engine, Stdout: &stdout})
+
+		if stdout.String() != "hi\n3\n" {
+			t.Errorf("engine %s: wrong output %q", engine, stdout.String())
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		var stdout bytes.Buffer
+		RunProgram(`if (1 < 2) { puts("a" + "b") }; let f = fn(x) { !(x == 2 * 3) }; f(6)`, Options{Engine: engine, Stdout: &stdout, Optimize: true})
+
+		if stdout.String() != "ab\nfalse\n" {
+			t.Errorf("engine %s: wrong output %q", engine, stdout.String())
+		}
+	}
+}
+
+func TestRunProgramFromReader(t *testing.T) {
+	var stdout bytes.Buffer
+	code := RunProgramFromReader(strings.NewReader(`puts(1 + 1)`), Options{Stdout: &stdout})
+
+	if code != ExitOK {
+		t.Errorf("expected exit code %d, got %d", ExitOK, code)
+	}
+
+	if stdout.String() != "2\n" {
+		t.Errorf("wrong output %q", stdout.String())
+	}
+}
+
+func TestNullResultNotPrinted(t *testing.T) {
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		var stdout bytes.Buffer
+		RunProgram(`if (false) { 1 }`, Options{Engine: engine, Stdout: &stdout})
+
+		if stdout.String() != "" {
+			t.Errorf("engine %s: expected no output, got %q", engine, stdout.String())
+		}
+	}
+}
+
+func TestScriptArgs(t *testing.T) {
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		var stdout bytes.Buffer
+		RunProgram(`let a = args(); len(a) + len(first(a))`, Options{
+			Engine: engine,
+			Stdout: &stdout,
+			Args:   []string{"four", "x"},
+		})
+
+		if stdout.String() != "6\n" {
+			t.Errorf("engine %s: wrong output %q", engine, stdout.String())
+		}
+	}
+}
+
+func TestDumpTokens(t *testing.T) {
+	var stdout bytes.Buffer
+	RunProgram(`let x = 1;`, Options{Stdout: &stdout, DumpTokens: true})
+
+	expected := `1:1     LET        "let"
+1:5     IDENT      "x"
+1:7     =          "="
+1:9     INT        "1"
+1:10    ;          ";"
+1:11    EOF        ""
+`
+	if stdout.String() != expected {
+		t.Errorf("wrong token dump. expected=\n%s\ngot=\n%s", expected, stdout.String())
+	}
+}
+
+func TestDumpTokensStreamed(t *testing.T) {
+	// Tokens read before the input fails are printed, then the failure
+	r := io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(errors.New("connection reset")))
+	var stdout, stderr bytes.Buffer
+	code := RunProgramFromReader(r, Options{Stdout: &stdout, Stderr: &stderr, DumpTokens: true})
+
+	if code != ExitUsageError {
+		t.Errorf("expected exit code %d, got %d", ExitUsageError, code)
+	}
+	if !strings.HasPrefix(stdout.String(), "1:1     LET        \"let\"\n") {
+		t.Errorf("expected the tokens read, got %q", stdout.String())
+	}
+	if stderr.String() != "failed to read program: connection reset\n" {
+		t.Errorf("wrong error %q", stderr.String())
+	}
+}
+
+func TestDumpAST(t *testing.T) {
+	var stdout bytes.Buffer
+	code := RunProgram(`puts(1)`, Options{Stdout: &stdout, DumpAST: true})
+
+	if code != ExitOK {
+		t.Errorf("expected exit code %d, got %d", ExitOK, code)
+	}
+
+	if !strings.Contains(stdout.String(), "*ast.CallExpression {") {
+		t.Errorf("expected call expression in dump, got\n%s", stdout.String())
+	}
+
+	if strings.Contains(stdout.String(), "\n1\n") {
+		t.Errorf("program should not have been run, got\n%s", stdout.String())
+	}
+}
+
+func TestDumpBytecode(t *testing.T) {
+	var stdout bytes.Buffer
+	RunProgram(`let f = fn(x) { x + 1 }; f(2)`, Options{Engine: EngineVM, Stdout: &stdout, DumpBytecode: true})
+
+	expected := `Instructions:
+  0000 OpClosure 1 0
+  0004 OpSetGlobal 0 ; f
+  0007 OpGetGlobal 0 ; f
+  0010 OpConstant 2
+  0013 OpCall 1
+  0015 OpPop
+Constants:
+  0: INTEGER 1
+  1: COMPILED_FUNCTION_OBJ (params=1, locals=1)
+    0000 OpGetLocal 0 ; x
+    0002 OpConstantAdd 0
+    0005 OpReturnValue
+  2: INTEGER 2
+`
+	if stdout.String() != expected {
+		t.Errorf("wrong bytecode dump. expected=\n%s\ngot=\n%s", expected, stdout.String())
+	}
+
+	var stderr bytes.Buffer
+	code := RunProgram(`1`, Options{Engine: EngineEval, Stderr: &stderr, DumpBytecode: true})
+	if code != ExitUsageError {
+		t.Errorf("expected usage error without the vm engine, got %d", code)
+	}
+}
+
+func TestCompileAndRunBytecodeFile(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.monkey")
+	if err := os.WriteFile(source, []byte(`let double = fn(x) { x * 2 }; double(21)`), 0644); err != nil {
+		t.Fatalf("could not write source: %s", err)
+	}
+
+	output := BytecodePath(source)
+	if code := CompileFile(source, output, Options{}); code != ExitOK {
+		t.Fatalf("compile failed with exit code %d", code)
+	}
+
+	var stdout bytes.Buffer
+	code := RunProgramFromFile(output, Options{Stdout: &stdout})
+
+	if code != ExitOK {
+		t.Errorf("expected exit code %d, got %d", ExitOK, code)
+	}
+
+	if stdout.String() != "42\n" {
+		t.Errorf("wrong output %q", stdout.String())
+	}
+}
+
+func TestCompileCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	source := filepath.Join(dir, "main.monkey")
+	lib := filepath.Join(dir, "lib.monkey")
+	os.WriteFile(source, []byte(`let lib = import "./lib"; lib.double(21)`), 0644)
+	os.WriteFile(lib, []byte(`let double = fn(x) { x * 2 };`), 0644)
+
+	run := func() string {
+		var stdout, stderr bytes.Buffer
+		code := RunProgramFromFile(source, Options{Engine: EngineVM, CacheDir: cacheDir, Stdout: &stdout, Stderr: &stderr})
+		if code != ExitOK {
+			t.Fatalf("exit code %d, errors %q", code, stderr.String())
+		}
+		return stdout.String()
+	}
+
+	if out := run(); out != "42\n" {
+		t.Errorf("wrong output %q", out)
+	}
+
+	entries, _ := filepath.Glob(filepath.Join(cacheDir, "*"))
+	if len(entries) != 1 {
+		t.Fatalf("expected one cache entry, got %v", entries)
+	}
+
+	// Swap in the bytecode of another program to see that it's run from the
+	// cache rather than compiled again
+	opts := Options{Filename: source, CacheDir: cacheDir}
+	program, _ := parse(`let lib = import "./lib"; 7`, source, Options{Stderr: io.Discard})
+	c := opts.compiler()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	text, _ := os.ReadFile(source)
+	writeCache(string(text), c, c.Bytecode(), opts)
+
+	if out := run(); out != "7\n" {
+		t.Errorf("expected the cached program to run, got %q", out)
+	}
+
+	// Changing a module the program imports makes it compile again
+	os.WriteFile(lib, []byte(`let double = fn(x) { x * 2 + 1 };`), 0644)
+	if out := run(); out != "43\n" {
+		t.Errorf("expected the changed import to be compiled, got %q", out)
+	}
+}
+
+func TestCompileCacheWarnings(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.monkey")
+	os.WriteFile(source, []byte("1;\n2"), 0644)
+
+	run := func(werror bool) (int, string) {
+		var stdout, stderr bytes.Buffer
+		opts := Options{Engine: EngineVM, CacheDir: filepath.Join(dir, "cache"), WarningsAsErrors: werror, Stdout: &stdout, Stderr: &stderr}
+		return RunProgramFromFile(source, opts), stderr.String()
+	}
+
+	warning := "1:1: warning: expression result is unused"
+	if code, stderr := run(false); code != ExitOK || !strings.Contains(stderr, warning) {
+		t.Fatalf("expected a warning, got exit code %d and %q", code, stderr)
+	}
+
+	// Warnings are replayed from the cache, and still fail with -Werror
+	if code, stderr := run(false); code != ExitOK || !strings.Contains(stderr, warning) {
+		t.Errorf("expected the cached warning, got exit code %d and %q", code, stderr)
+	}
+	if code, stderr := run(true); code != ExitParseError || !strings.Contains(stderr, warning) {
+		t.Errorf("expected the cached warning to fail, got exit code %d and %q", code, stderr)
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "math_test.monkey"), []byte(`
+let add = fn(a, b) { a + b };
+let test_add = fn() { assert(add(1, 2) == 3, "1 + 2") };
+let test_broken = fn() { assert(add(2, 2) == 5, "2 + 2"); puts("unreachable") };
+let helper = fn() { assert(false) };
+`), 0o644)
+	os.WriteFile(filepath.Join(dir, "math.monkey"), []byte(`let test_ignored = fn() { assert(false) };`), 0o644)
+
+	files, err := FindTestFiles([]string{dir})
+	if err != nil {
+		t.Fatalf("FindTestFiles failed: %s", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 test file, got %v", files)
+	}
+
+	var stdout bytes.Buffer
+	code := RunTests(files, true, Options{Stdout: &stdout})
+
+	if code != ExitRuntimeError {
+		t.Errorf("expected exit code %d, got %d", ExitRuntimeError, code)
+	}
+
+	expected := "--- PASS: test_add (" + files[0] + ")\n" +
+		"--- FAIL: test_broken (" + files[0] + ")\n" +
+		"    ERROR: assertion failed: 2 + 2 at " + files[0] + ":4:26\n" +
+		"FAIL: 1 passed, 1 failed\n"
+	if stdout.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, stdout.String())
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	source := "let f = fn(x) {\n  if (x) {\n    1\n  } else {\n    2\n  }\n};\n\nf(true)\n"
+	expected := "program: 4 of 5 lines run (80.0%)\n" +
+		"+    1  let f = fn(x) {\n" +
+		"+    2    if (x) {\n" +
+		"+    3      1\n" +
+		"     4    } else {\n" +
+		"-    5      2\n" +
+		"     6    }\n" +
+		"     7  };\n" +
+		"     8  \n" +
+		"+    9  f(true)\n"
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		var report bytes.Buffer
+		RunProgram(source, Options{Engine: engine, Stdout: io.Discard, Coverage: &report})
+
+		if report.String() != expected {
+			t.Errorf("engine %s: wrong report.\nexpected=%q\ngot=%q", engine, expected, report.String())
+		}
+	}
+
+	// Test files are left out of the report on the modules they test
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "lib.monkey"), []byte("let double = fn(x) { x * 2 };\nlet half = fn(x) {\n  x / 2\n};\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "lib_test.monkey"), []byte(`let lib = import "./lib"; let test_double = fn() { assert(lib.double(2) == 4) };`), 0o644)
+
+	var report bytes.Buffer
+	RunTests([]string{filepath.Join(dir, "lib_test.monkey")}, false, Options{Stdout: io.Discard, Coverage: &report})
+
+	if !strings.HasSuffix(report.String(), "lib.monkey: 2 of 3 lines run (66.7%)\n"+
+		"+    1  let double = fn(x) { x * 2 };\n"+
+		"+    2  let half = fn(x) {\n"+
+		"-    3    x / 2\n"+
+		"     4  };\n") || strings.Contains(report.String(), "lib_test.monkey") {
+		t.Errorf("wrong report on tests: %q", report.String())
+	}
+}
+
+func TestProfile(t *testing.T) {
+	source := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let twice = fn(f, x) { f(f(x)) };
+twice(fn(x) { x + 1 }, fib(15));
+memoize(fib)(10);
+`
+	// Calls back from builtins like memoize are counted too
+	expected := map[string]string{"fib": "2150", "twice": "1", "anonymous function": "2"}
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		var report bytes.Buffer
+		RunProgram(source, Options{Engine: engine, Stdout: io.Discard, Profile: &report})
+
+		lines := strings.Split(strings.TrimSuffix(report.String(), "\n"), "\n")
+		if len(lines) != 4 || strings.Fields(lines[0])[0] != "calls" || !strings.HasSuffix(lines[1], " fib") {
+			t.Fatalf("engine %s: wrong report %q", engine, report.String())
+		}
+
+		for _, line := range lines[1:] {
+			fields := strings.Fields(line)
+			name := strings.Join(fields[2:], " ")
+			if expected[name] != fields[0] {
+				t.Errorf("engine %s: expected %s calls to %s, got %s", engine, expected[name], name, fields[0])
+			}
+		}
+	}
+}
+
+func TestBench(t *testing.T) {
+	var stdout bytes.Buffer
+	code := Bench(`let f = fn(x) { x * 2 }; f(21)`, Options{Stdout: &stdout})
+
+	if code != ExitOK {
+		t.Errorf("expected exit code %d, got %d", ExitOK, code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines of output, got %q", stdout.String())
+	}
+
+	for i, engine := range []Engine{EngineEval, EngineVM} {
+		prefix := "engine=" + string(engine) + ", result=42, duration="
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d: expected prefix %q, got %q", i, prefix, lines[i])
+		}
+	}
+
+	if lines[2] != "results match" {
+		t.Errorf("expected results to match, got %q", lines[2])
+	}
+}
+
+// syncBuffer lets a test read output while another goroutine writes it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "watched.monkey")
+	os.WriteFile(filename, []byte(`let x = 1; x`), 0o644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr syncBuffer
+	done := make(chan int)
+	go func() {
+		done <- WatchFile(ctx, filename, time.Millisecond, Options{Stdout: &stdout, Stderr: &stderr})
+	}()
+
+	waitFor := func(expected string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for stdout.String() != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected output %q, got %q", expected, stdout.String())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitFor("1\n")
+
+	// Each run starts with a fresh environment, so x can be defined again
+	os.WriteFile(filename, []byte(`let x = 20; x + 2`), 0o644)
+	waitFor("1\n22\n")
+
+	cancel()
+	if code := <-done; code != ExitOK {
+		t.Errorf("expected exit code %d, got %d", ExitOK, code)
+	}
+
+	if !strings.Contains(stderr.String(), "changed, running again") {
+		t.Errorf("expected a notice about the change, got %q", stderr.String())
+	}
+}
+
+func TestWatchFileInterrupted(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "watched.monkey")
+	os.WriteFile(filename, []byte(`puts("start"); sleep(60000)`), 0o644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout syncBuffer
+	done := make(chan int)
+	go func() {
+		done <- WatchFile(ctx, filename, time.Millisecond, Options{Stdout: &stdout, Stderr: io.Discard})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for stdout.String() != "start\n" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the program to start, got %q", stdout.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The program doesn't finish in time, so only the interrupt stops it
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("WatchFile didn't return once interrupted")
+	}
+}
+
+func TestParseErrorSnippet(t *testing.T) {
+	var stderr bytes.Buffer
+	RunProgram("let x = 1;\nlet y = * 2;", Options{Stderr: &stderr})
+
+	expected := "\t2:9: unexpected '*', expected an expression\n" +
+		"\tlet y = * 2;\n" +
+		"\t        ^\n"
+	if !strings.HasPrefix(stderr.String(), expected) {
+		t.Errorf("wrong parse error output. expected prefix=\n%q\ngot=\n%q", expected, stderr.String())
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	input := "let f = fn() { 1 + true };\nlet g = fn() { f() };\ng();"
+
+	tests := []struct {
+		engine   Engine
+		expected string
+	}{
+		{
+			EngineEval,
+			"ERROR: type mismatch: INTEGER + BOOLEAN at 1:16\n" +
+				"\tlet f = fn() { 1 + true };\n" +
+				"\t               ^\n" +
+				"  in f (called at 2:16)\n" +
+				"  in g (called at 3:1)\n",
+		},
+		{
+			EngineVM,
+			"executing bytecode failed: Unsupported types for binary operation: INTEGER BOOLEAN at 1:16\n" +
+				"\tlet f = fn() { 1 + true };\n" +
+				"\t               ^\n" +
+				"  in f (called at 2:16)\n" +
+				"  in g (called at 3:1)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		code := RunProgram(input, Options{Engine: tt.engine, Stderr: &stderr})
+
+		if code != ExitRuntimeError {
+			t.Errorf("%s: expected exit code %d, got %d", tt.engine, ExitRuntimeError, code)
+		}
+
+		if stderr.String() != tt.expected {
+			t.Errorf("%s: wrong error output.\nexpected=%q\ngot=     %q", tt.engine, tt.expected, stderr.String())
+		}
+	}
+}
+
+func TestErrorsNameFile(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.monkey")
+	failing := filepath.Join(dir, "failing.monkey")
+	os.WriteFile(broken, []byte("let x = ;"), 0644)
+	os.WriteFile(failing, []byte("let f = fn() { 1 + true };\nf();"), 0644)
+
+	tests := []struct {
+		filename string
+		engine   Engine
+		expected string
+	}{
+		{broken, EngineEval, "\t" + broken + ":1:9: unexpected ';', expected an expression\n"},
+		{failing, EngineEval, "ERROR: type mismatch: INTEGER + BOOLEAN at " + failing + ":1:16\n" +
+			"\tlet f = fn() { 1 + true };\n\t               ^\n" +
+			"  in f (called at " + failing + ":2:1)\n"},
+		{failing, EngineVM, "executing bytecode failed: Unsupported types for binary operation: INTEGER BOOLEAN at " + failing + ":1:16\n" +
+			"\tlet f = fn() { 1 + true };\n\t               ^\n" +
+			"  in f (called at " + failing + ":2:1)\n"},
+	}
+
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		RunProgramFromFile(tt.filename, Options{Engine: tt.engine, Stderr: &stderr})
+
+		if !strings.HasPrefix(stderr.String(), tt.expected) {
+			t.Errorf("%s: wrong error output.\nexpected=%q\ngot=     %q", tt.engine, tt.expected, stderr.String())
+		}
+	}
+}
+
+func TestRunProjectDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "lib", "text"), 0755)
+	os.WriteFile(filepath.Join(dir, "main.monkey"), []byte(`let greet = import "lib/greet"; puts(greet.hello("Ann"));`), 0644)
+	os.WriteFile(filepath.Join(dir, "lib", "greet.monkey"), []byte(`let text = import "./text/upper"; let hello = fn(name) { text.shout("hello " + name) };`), 0644)
+	os.WriteFile(filepath.Join(dir, "lib", "text", "upper.monkey"), []byte(`let shout = fn(s) { s + "!" };`), 0644)
+
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		var stdout, stderr bytes.Buffer
+		code := RunProgramFromFile(dir, Options{Engine: engine, Stdout: &stdout, Stderr: &stderr})
+
+		if code != ExitOK || stdout.String() != "hello Ann!\n" {
+			t.Errorf("engine %s: exit code %d, output %q, errors %q", engine, code, stdout.String(), stderr.String())
+		}
+	}
+
+	var stderr bytes.Buffer
+	if code := RunProgramFromFile(filepath.Join(dir, "lib"), Options{Stderr: &stderr}); code != ExitUsageError {
+		t.Errorf("expected a directory without %s to fail with exit code %d, got %d", Entrypoint, ExitUsageError, code)
+	}
+}
+
+func TestStdlib(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let list = import "std/list"; list.map(list.range(1, 4), fn(x) { x * 2 })`, "[2,4,6]"},
+		{`let list = import "std/list"; list.filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3,4]"},
+		{`let list = import "std/list"; list.reduce([1, 2, 3], 10, fn(acc, x) { acc + x })`, "16"},
+		{`let list = import "std/list"; [list.sum([]), list.index([5, 6], 6), list.index([5], 7)]`, "[0,1,-1]"},
+		{`let list = import "std/list"; [list.contains([1, 2], 2), list.any([1], fn(x) { x > 1 }), list.all([], fn(x) { false })]`, "[true,false,true]"},
+		{`let list = import "std/list"; [list.find([1, 2, 3], fn(x) { x > 1 }), list.take([1, 2, 3], 5), list.drop([1, 2, 3], 1)]`, "[2,[1,2,3],[2,3]]"},
+		{`let list = import "std/list"; list.each([1, 2], puts)`, "1\n2\n"},
+		{`let list = import "std/list"; list.filter("banana".chars(), fn(c) { c != "a" })`, `["b","n","n"]`},
+		{`let s = import "std/strings"; s.join(["a", "b", "c"], ", ")`, `"a, b, c"`},
+		{`let s = import "std/strings"; [s.join([], "-"), s.repeat("ab", 2), s.padLeft("7", 3, "0"), s.padRight("ab", 3, ".")]`, `["","abab","007","ab."]`},
+		{`let a = import "std/assert"; a.equal([1, 2], [1, 2]); a.contains([1, 2], 2); a.isTrue(1 < 2); "ok"`, `"ok"`},
+		{`import "std/list" == import "std/list"`, "true"},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []Engine{EngineEval, EngineVM} {
+			var stdout, stderr bytes.Buffer
+			code := RunProgram(tt.input, Options{Engine: engine, Stdout: &stdout, Stderr: &stderr})
+
+			if code != ExitOK || strings.TrimSuffix(stdout.String(), "\n") != strings.TrimSuffix(tt.expected, "\n") {
+				t.Errorf("%s with engine %s: exit code %d, output %q, errors %q", tt.input, engine, code, stdout.String(), stderr.String())
+			}
+		}
+	}
+
+	var stderr bytes.Buffer
+	RunProgram(`let a = import "std/assert"; a.equal(1, 2)`, Options{Stderr: &stderr})
+	if !strings.HasPrefix(stderr.String(), `ERROR: assertion failed: ["got",1,"want",2] at std/assert.monkey:`) {
+		t.Errorf("wrong assertion failure: %q", stderr.String())
+	}
+
+	stderr.Reset()
+	RunProgram(`import "std/missing"`, Options{Engine: EngineVM, Stderr: &stderr})
+	if stderr.String() != "compilation failed: cannot import \"std/missing\": no module std/missing in the standard library\n" {
+		t.Errorf("wrong error for a missing module: %q", stderr.String())
+	}
+}
+
+func TestDumpASTJSON(t *testing.T) {
+	var stdout bytes.Buffer
+	code := RunProgram(`x`, Options{Stdout: &stdout, DumpASTJSON: true})
+
+	if code != ExitOK {
+		t.Errorf("expected exit code %d, got %d", ExitOK, code)
+	}
+
+	expected := `{"node":"Program","statements":[{"node":"ExpressionStatement","line":1,"column":1,` +
+		`"expression":{"node":"Identifier","line":1,"column":1,"value":"x"}}]}` + "\n"
+	if stdout.String() != expected {
+		t.Errorf("wrong JSON dump.\nexpected=%s\ngot=     %s", expected, stdout.String())
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	input := "let x = 1;\nlet f = fn(x) { x };\nf(2)"
+	warning := "\t2:12: warning: x shadows a variable of an enclosing scope\n" +
+		"\tlet f = fn(x) { x };\n" +
+		"\t           ^\n"
+
+	var stdout, stderr bytes.Buffer
+	code := RunProgram(input, Options{Stdout: &stdout, Stderr: &stderr})
+	if code != ExitOK || stdout.String() != "2\n" || stderr.String() != warning {
+		t.Errorf("expected the program to run after warning %q, got exit code %d, output %q, errors %q", warning, code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = RunProgram(input, Options{Stdout: &stdout, Stderr: &stderr, WarningsAsErrors: true})
+	if code != ExitParseError || stdout.String() != "" || stderr.String() != warning {
+		t.Errorf("expected the program to fail with warning %q, got exit code %d, output %q, errors %q", warning, code, stdout.String(), stderr.String())
+	}
+}
@@ -0,0 +1,161 @@
+package run
+
+import (
+	"fmt"
+	"io/fs"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Suffix of files holding Monkey tests
+const TestFileSuffix = "_test.monkey"
+
+// Prefix of the functions run as tests
+const TestFuncPrefix = "test_"
+
+// FindTestFiles returns the test files under paths. Directories are searched
+// recursively, files are returned as given.
+func FindTestFiles(paths []string) ([]string, error) {
+	files := []string{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, TestFileSuffix) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// RunTests runs the test functions in files with the evaluator, printing a
+// line per failure (and per pass with verbose set) followed by a summary. A
+// test fails when it returns an error, e.g. from a failed assert. The
+// coverage report, if asked for, leaves out the test files themselves.
+func RunTests(files []string, verbose bool, opts Options) int {
+	passed, failed := 0, 0
+	code := ExitOK
+
+	if opts.Coverage != nil {
+		opts.coverage = newCoverage()
+	}
+
+	for _, filename := range files {
+		p, f, c := runTestFile(filename, verbose, opts)
+		passed += p
+		failed += f
+		if c != ExitOK && code == ExitOK {
+			code = c
+		}
+	}
+
+	if opts.coverage != nil {
+		for _, filename := range files {
+			opts.coverage.forget(filename)
+		}
+		opts.coverage.report(opts.Coverage)
+	}
+
+	out := opts.stdout()
+	if failed != 0 || code != ExitOK {
+		fmt.Fprintf(out, "FAIL: %d passed, %d failed\n", passed, failed)
+		if code == ExitOK {
+			code = ExitRuntimeError
+		}
+		return code
+	}
+
+	fmt.Fprintf(out, "PASS: %d passed\n", passed)
+	return ExitOK
+}
+
+func runTestFile(filename string, verbose bool, opts Options) (passed, failed, code int) {
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to read file: %s\n", err)
+		return 0, 0, ExitUsageError
+	}
+
+	program, ok := parse(string(text), filename, opts)
+	if !ok {
+		fmt.Fprintf(opts.stderr(), "%s: failed to parse\n", filename)
+		return 0, 0, ExitParseError
+	}
+
+	opts.Filename = filename
+	ctx := opts.context()
+	env := object.NewEnvironment()
+	if result := evaluator.EvalContext(ctx, program, env); isError(result) {
+		fmt.Fprintf(opts.stdout(), "--- FAIL: %s\n    %s\n", filename, result.Inspect())
+		return 0, 0, ExitRuntimeError
+	}
+
+	out := opts.stdout()
+	for _, name := range testNames(program) {
+		fn, ok := env.Get(name)
+		if !ok {
+			continue
+		}
+
+		result := evaluator.Apply(ctx, fn, []object.Object{})
+		if isError(result) {
+			fmt.Fprintf(out, "--- FAIL: %s (%s)\n    %s\n", name, filename, result.Inspect())
+			failed++
+			continue
+		}
+
+		if verbose {
+			fmt.Fprintf(out, "--- PASS: %s (%s)\n", name, filename)
+		}
+		passed++
+	}
+
+	return passed, failed, ExitOK
+}
+
+// testNames returns the top level functions named like tests, in the order
+// they're defined.
+func testNames(program *ast.Program) []string {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, TestFuncPrefix) || seen[let.Name.Value] {
+			continue
+		}
+
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			names = append(names, let.Name.Value)
+			seen[let.Name.Value] = true
+		}
+	}
+
+	return names
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
@@ -0,0 +1,73 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// How often watched files are checked for changes
+const DefaultWatchInterval = 500 * time.Millisecond
+
+// WatchFile runs the program in filename, then runs it again from scratch
+// every time the file changes, until ctx is done. The file is polled every
+// interval rather than relying on OS specific notifications. Runs are
+// interrupted once ctx is done, so WatchFile returns even while one is
+// stuck in a loop.
+func WatchFile(ctx context.Context, filename string, interval time.Duration, opts Options) int {
+	last, err := os.Stat(filename)
+	if err != nil {
+		fmt.Fprintf(opts.stderr(), "failed to read file: %s\n", err)
+		return ExitUsageError
+	}
+
+	watchRun(ctx, filename, opts)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ExitOK
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			// Editors often replace files rather than writing them in place,
+			// wait for it to come back
+			continue
+		}
+
+		if info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		fmt.Fprintf(opts.stderr(), "--- %s changed, running again\n", filename)
+		watchRun(ctx, filename, opts)
+	}
+}
+
+// watchRun runs the program in filename once, under a context of its own
+// that's cancelled when it finishes, stopping the tasks it spawned, or when
+// ctx is done. It returns as soon as either happens, without waiting for
+// the program to notice it was interrupted.
+func watchRun(ctx context.Context, filename string, opts Options) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	opts.Context = runCtx
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunProgramFromFile(filename, opts)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,10 @@
+// Package std holds the standard library, modules written in Monkey that
+// programs import as "std/name".
+package std
+
+import "embed"
+
+// FS holds the source of each module as name.monkey.
+//
+//go:embed *.monkey
+var FS embed.FS
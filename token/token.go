@@ -1,15 +1,79 @@
 package token
 
+import (
+	"fmt"
+	"strings"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	// Where the token starts in the source
+	Pos Position
+}
+
+// Position is a location in a source file. Lines and columns start at 1 and
+// columns count bytes. The zero Position means the location is unknown.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String formats p as file:line:column, leaving out the filename if it's
+// unknown.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// Excerpt returns the line of src p is on with a caret under its column, or
+// "" if p isn't in src.
+func (p Position) Excerpt(src string) string {
+	lines := strings.Split(src, "\n")
+	if !p.IsValid() || p.Line > len(lines) {
+		return ""
+	}
+
+	line := strings.TrimRight(lines[p.Line-1], "\r")
+	if p.Column > len(line)+1 {
+		return ""
+	}
+
+	// Copy tabs so the caret lines up however wide they're displayed
+	var caret strings.Builder
+	for _, ch := range []byte(line[:p.Column-1]) {
+		if ch == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+
+	return line + "\n" + caret.String()
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 
 	// Identifiers + literals
 	IDENT = "IDENT"
@@ -29,10 +93,18 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	ARROW    = "=>"
+	ELLIPSIS = "..."
+	// Return type annotations, fn(x: int) -> int
+	THIN_ARROW = "->"
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
+	// Null-safe field access and method calls, value?.name
+	OPTIONAL_DOT = "?."
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -47,11 +119,15 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MATCH    = "MATCH"
+	IMPORT   = "IMPORT"
 	STRING   = "STRING"
 
 	// Array
 	LBRACKET = "["
 	RBRACKET = "]"
+	// Null-safe indexing, value?[index]
+	OPTIONAL_LBRACKET = "?["
 )
 
 var keywords = map[string]TokenType{
@@ -62,6 +138,8 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"match":  MATCH,
+	"import": IMPORT,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,37 @@
+package vm
+
+import "monkey/object"
+
+// Integers handed out by an integerArena are allocated this many at a time
+const arenaChunkSize = 256
+
+// integerArena allocates the Integers resulting from arithmetic in chunks,
+// so loops doing arithmetic allocate once per chunk rather than once per
+// result. Any Integer still in use keeps its whole chunk alive, so chunks are
+// never reused: reset just starts a new one, leaving the old ones to the GC
+// once nothing refers to them.
+type integerArena struct {
+	chunk []object.Integer
+}
+
+func (a *integerArena) newInteger(value int64) *object.Integer {
+	if object.IsSmallInteger(value) {
+		return object.NewInteger(value)
+	}
+
+	if len(a.chunk) == 0 {
+		a.chunk = make([]object.Integer, arenaChunkSize)
+	}
+
+	i := &a.chunk[0]
+	i.Value = value
+	a.chunk = a.chunk[1:]
+
+	return i
+}
+
+// reset drops the current chunk, so Integers from different runs don't keep
+// each other's chunks alive.
+func (a *integerArena) reset() {
+	a.chunk = nil
+}
@@ -0,0 +1,72 @@
+package vm
+
+import (
+	"context"
+	"monkey/compiler"
+	"monkey/object"
+)
+
+// caller implements object.FunctionCaller for builtins running on the VM.
+// Functions are called on a fresh VM sharing the program's constants and
+// globals.
+type caller struct {
+	ctx       context.Context
+	constants []object.Object
+	globals   []object.Object
+	// The VM running the program, nil for forks, which call functions on
+	// VMs of their own
+	vm *VM
+}
+
+func (c *caller) Call(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		if result := fn.Call(c.ctx, args...); result != nil {
+			return result
+		}
+		return Null
+	case *object.Closure:
+		// Functions only define globals by importing modules, which the
+		// program may as well see
+		vm := NewWithGlobalsStore(&compiler.Bytecode{Constants: c.constants}, c.globals)
+
+		vm.push(fn)
+		for _, arg := range args {
+			if err := vm.push(arg); err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+		}
+
+		// The function is called before RunContext would set the hooks
+		vm.hooks = hooksFrom(c.ctx)
+		if err := vm.callFunction(fn, len(args)); err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+
+		if err := vm.RunContext(c.ctx); err != nil {
+			return err.(*object.Error)
+		}
+
+		return vm.StackTop()
+	default:
+		return &object.Error{Message: "calling non-closure and non-built-in"}
+	}
+}
+
+// Fork copies the globals, as the main program may go on to redefine them,
+// and with them the modules imported so far, whose functions read them.
+func (c *caller) Fork() object.FunctionCaller {
+	globals := make([]object.Object, len(c.globals))
+	copy(globals, c.globals)
+
+	return &caller{ctx: forkModules(c.ctx), constants: c.constants, globals: globals}
+}
+
+// Stats implements object.StatsReporter.
+func (c *caller) Stats() object.Stats {
+	stats := object.Stats{Constants: len(c.constants)}
+	if c.vm != nil {
+		stats.StackHighWater = c.vm.highWater
+	}
+	return stats
+}
@@ -0,0 +1,110 @@
+package vm
+
+import (
+	"context"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+)
+
+// Hooks observe a VM as it runs, for tools like debuggers. The VM waits for
+// each call to return, so the hook may inspect it with Frames, Locals and
+// Globals, or block to pause the program.
+type Hooks struct {
+	// Line is called before the first instruction compiled from a line of
+	// source runs, whenever the VM moves to another line or into or out of a
+	// function
+	Line func(vm *VM, pos token.Position)
+	// Call is called as fn is called, and the function it returns, if not
+	// nil, once fn returns. Calls the compiler turned into jumps or inlined
+	// aren't seen, and those a runtime error ends never return.
+	Call func(vm *VM, fn *object.CompiledFunction) func()
+}
+
+type hooksKey struct{}
+
+// WithHooks returns a copy of ctx in which RunContext calls hooks as the
+// program runs. Functions called back by builtins run on VMs of their own,
+// which call hooks too.
+func WithHooks(ctx context.Context, hooks *Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, hooks)
+}
+
+func hooksFrom(ctx context.Context) *Hooks {
+	hooks, _ := ctx.Value(hooksKey{}).(*Hooks)
+	return hooks
+}
+
+// traceLine calls the Line hook if the instruction about to run is on a
+// different line or in a different frame than the last one it was called for.
+func (vm *VM) traceLine() {
+	pos := vm.currentFrame().Pos()
+	if !pos.IsValid() || (pos.Line == vm.line && vm.framesIndex == vm.lineDepth) {
+		return
+	}
+
+	vm.line, vm.lineDepth = pos.Line, vm.framesIndex
+	vm.hooks.Line(vm, pos)
+}
+
+// A Variable is a named value the running program can see.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Frames returns the frames of the functions being run, innermost first. The
+// last one is the main program's.
+func (vm *VM) Frames() []*Frame {
+	frames := make([]*Frame, vm.framesIndex)
+	for i := range frames {
+		frames[i] = vm.frames[vm.framesIndex-1-i]
+	}
+
+	return frames
+}
+
+// Function returns the name of the frame's function, which is empty for the
+// main program and anonymous functions.
+func (f *Frame) Function() string {
+	return f.cl.Fn.Name
+}
+
+// Locals returns the parameters, local and free variables of f, which must
+// be one of the VM's frames. Locals not yet assigned are left out.
+func (vm *VM) Locals(f *Frame) []Variable {
+	vars := []Variable{}
+
+	fn := f.cl.Fn
+	for i, name := range fn.LocalNames {
+		if value := vm.stack[f.basePointer+i]; value != nil {
+			vars = append(vars, Variable{Name: name, Value: value})
+		}
+	}
+	for i, name := range fn.FreeNames {
+		if i < len(f.cl.Free) {
+			vars = append(vars, Variable{Name: name, Value: f.cl.Free[i]})
+		}
+	}
+
+	return vars
+}
+
+// Globals returns the global variables defined so far, sorted by name. Only
+// the latest of several definitions of a name is included.
+func (vm *VM) Globals() []Variable {
+	latest := map[string]object.Object{}
+	for i, name := range vm.globalNames {
+		if i < len(vm.globals) && vm.globals[i] != nil {
+			latest[name] = vm.globals[i]
+		}
+	}
+
+	vars := []Variable{}
+	for name, value := range latest {
+		vars = append(vars, Variable{Name: name, Value: value})
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+
+	return vars
+}
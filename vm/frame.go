@@ -3,12 +3,15 @@ package vm
 import (
 	"monkey/code"
 	"monkey/object"
+	"monkey/token"
 )
 
 type Frame struct {
 	cl          *object.Closure
 	ip          int
 	basePointer int
+	// Returned by the Call hook, called as the frame returns
+	returned func()
 }
 
 func NewFrame(cl *object.Closure, basePointer int) *Frame {
@@ -18,3 +21,8 @@ func NewFrame(cl *object.Closure, basePointer int) *Frame {
 func (f *Frame) Instructions() code.Instructions {
 	return f.cl.Fn.Instructions
 }
+
+// Pos returns where the instruction the frame is at was compiled from.
+func (f *Frame) Pos() token.Position {
+	return f.cl.Fn.SourceMap.Lookup(f.ip)
+}
@@ -0,0 +1,85 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"monkey/compiler"
+	"monkey/object"
+	"sync"
+)
+
+// modules holds the modules a program has imported, shared with the VMs
+// functions are called back on so each module only runs once.
+type modules struct {
+	mu      sync.Mutex
+	modules map[*object.CompiledFunction]*object.Module
+}
+
+type modulesKey struct{}
+
+// withModules returns ctx carrying a cache of imported modules, unless it
+// has one already.
+func withModules(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(modulesKey{}).(*modules); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, modulesKey{}, &modules{modules: map[*object.CompiledFunction]*object.Module{}})
+}
+
+// forkModules returns ctx with a copy of its cache of imported modules, for
+// a VM with a copy of the globals.
+func forkModules(ctx context.Context) context.Context {
+	cache, ok := ctx.Value(modulesKey{}).(*modules)
+	if !ok {
+		return ctx
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return context.WithValue(ctx, modulesKey{}, &modules{modules: maps.Clone(cache.modules)})
+}
+
+// importModule returns the module compiled into fn, running it on a VM of
+// its own the first time it's imported.
+func (vm *VM) importModule(fn *object.CompiledFunction) (*object.Module, error) {
+	cache := vm.ctx.Value(modulesKey{}).(*modules)
+
+	cache.mu.Lock()
+	m, ok := cache.modules[fn]
+	cache.mu.Unlock()
+	if ok {
+		return m, nil
+	}
+
+	// Its globals are allocated alongside the program's
+	module := NewWithGlobalsStore(&compiler.Bytecode{Constants: vm.constants}, vm.globals)
+	module.globalNames = vm.globalNames
+
+	cl := &object.Closure{Fn: fn}
+	module.push(cl)
+	if err := module.callFunction(cl, 0); err != nil {
+		return nil, err
+	}
+	if err := module.RunContext(vm.ctx); err != nil {
+		return nil, err
+	}
+
+	exports, ok := module.StackTop().(*object.Hash)
+	if !ok {
+		return nil, fmt.Errorf("module %s returned before exporting anything", fn.Name)
+	}
+
+	m = &object.Module{Name: fn.Name, Exports: map[string]object.Object{}}
+	for _, pair := range exports.Pairs {
+		m.Exports[pair.Key.(*object.String).Value] = pair.Value
+	}
+
+	cache.mu.Lock()
+	cache.modules[fn] = m
+	cache.mu.Unlock()
+
+	return m, nil
+}
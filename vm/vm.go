@@ -1,38 +1,63 @@
 package vm
 
 import (
+	"context"
 	"fmt"
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
 )
 
+// StackSize is how many values a VM's stack starts out with room for. It grows
+// as needed, deep recursion being limited by object.MaxDepth instead.
 const StackSize = 2048
 const GlobalsSize = 65536
 
+// MaxFrames is how many frames a VM starts out with room for. More are added
+// as needed, up to the context's object.MaxDepth.
 const MaxFrames = 1024
 
+// RunContext checks for cancellation once every this many instructions.
+const interruptCheckInterval = 1024
+
 // Global boolean objects
-var True = &object.Boolean{
-	Value: true,
-}
-var False = &object.Boolean{
-	Value: false,
-}
-var Null = &object.Null{}
+var True = object.TRUE
+var False = object.FALSE
+var Null = object.NULL
 
 type VM struct {
 	constants []object.Object
 	stack     []object.Object
 	sp        int // This points to the next value, the top value in the stack is always at sp - 1.
 	globals   []object.Object
+	// Most values the stack has held at once
+	highWater int
 
 	frames      []*Frame
 	framesIndex int
+
+	// Context of the current RunContext call, handed to builtins
+	ctx context.Context
+	// Most function frames allowed on top of the main one
+	maxDepth int
+	// Set with object.WithStrict
+	strict bool
+	// Set with object.WithCheckTypes
+	checkTypes bool
+
+	// Where the results of integer arithmetic are allocated
+	integers integerArena
+
+	// Set with WithHooks, nil if nothing is observing the VM
+	hooks *Hooks
+	// Line and frame the Line hook was last called for
+	line, lineDepth int
+	// Names of the globals by index, for debuggers
+	globalNames []string
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
 	mainClosure := &object.Closure{
 		Fn: mainFn,
 	}
@@ -50,6 +75,11 @@ func New(bytecode *compiler.Bytecode) *VM {
 
 		frames:      frames,
 		framesIndex: 1,
+
+		ctx:      context.Background(),
+		maxDepth: object.DefaultMaxDepth,
+
+		globalNames: bytecode.Globals,
 	}
 }
 
@@ -68,17 +98,82 @@ func (vm *VM) StackTop() object.Object {
 }
 
 func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext executes the bytecode like Run, but stops with an error once ctx
+// is cancelled. Errors are *object.Error, with the stack of functions that
+// were running when the program failed.
+func (vm *VM) RunContext(ctx context.Context) error {
+	ctx = withModules(ctx)
+	vm.ctx = object.WithCaller(ctx, &caller{ctx: ctx, constants: vm.constants, globals: vm.globals, vm: vm})
+	vm.maxDepth = object.MaxDepth(ctx)
+	vm.strict = object.Strict(ctx)
+	vm.checkTypes = object.CheckTypes(ctx)
+	vm.integers.reset()
+	vm.hooks = hooksFrom(ctx)
+	vm.line, vm.lineDepth = 0, 0
+
+	if err := vm.run(ctx); err != nil {
+		return vm.traceError(err)
+	}
+
+	return nil
+}
+
+// traceError turns err into an *object.Error carrying the position of the
+// failed instruction and the frames still on the stack, so the trace includes
+// functions called through a builtin.
+func (vm *VM) traceError(err error) *object.Error {
+	e, ok := err.(*object.Error)
+	if !ok {
+		e = &object.Error{Message: err.Error()}
+	}
+
+	if !e.Pos.IsValid() {
+		e.Pos = vm.frames[vm.framesIndex-1].Pos()
+	}
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		e.Stack = append(e.Stack, object.StackFrame{
+			Function: vm.frames[i].cl.Fn.Name,
+			Pos:      vm.frames[i-1].Pos(),
+		})
+	}
+
+	return e
+}
+
+func (vm *VM) run(ctx context.Context) error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
 
+	done := ctx.Done()
+	steps := 0
+
 	// ip is instruction pointer
 	// it starts at the beginning an goes until there are no instructions left.
 	// vm.instructions is a []byte, meaning we need to parse instructions correctly
 	// otherwise we'll end up at the beginning of a loop on a byte that isn't an opcode.
 	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		if done != nil {
+			steps++
+			if steps%interruptCheckInterval == 0 {
+				select {
+				case <-done:
+					return fmt.Errorf("execution interrupted: %s", ctx.Err())
+				default:
+				}
+			}
+		}
+
 		vm.currentFrame().ip++
 
+		if vm.hooks != nil && vm.hooks.Line != nil {
+			vm.traceLine()
+		}
+
 		// fmt.Printf("ip: %d frame index: %d stack pointer: %d\n", vm.currentFrame().ip, vm.framesIndex, vm.sp)
 
 		ip = vm.currentFrame().ip
@@ -126,6 +221,52 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpConstantAdd, code.OpConstantSub:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			arith := code.OpAdd
+			if op == code.OpConstantSub {
+				arith = code.OpSub
+			}
+
+			err := vm.executeBinaryOperands(arith, vm.pop(), vm.constants[constIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpGetLocalGetLocalAdd:
+			leftIndex := int(code.ReadUint8(ins[ip+1:]))
+			rightIndex := int(code.ReadUint8(ins[ip+2:]))
+			vm.currentFrame().ip += 2
+			frame := vm.currentFrame()
+
+			left := vm.stack[frame.basePointer+leftIndex]
+			if left == nil {
+				return undefinedLocal(frame, leftIndex)
+			}
+			right := vm.stack[frame.basePointer+rightIndex]
+			if right == nil {
+				return undefinedLocal(frame, rightIndex)
+			}
+
+			err := vm.executeBinaryOperands(code.OpAdd, left, right)
+			if err != nil {
+				return err
+			}
+		case code.OpCompareJumpNotTruthy:
+			comparison := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			err := vm.executeComparison(comparison)
+			if err != nil {
+				return err
+			}
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
 		case code.OpTrue:
 			err := vm.push(True)
 			if err != nil {
@@ -155,8 +296,18 @@ func (vm *VM) Run() error {
 			pos := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
 
-			condition := vm.pop()
-			if !isTruthy(condition) {
+			truthy, err := vm.condition(vm.pop())
+			if err != nil {
+				return err
+			}
+			if !truthy {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1] == Null {
 				vm.currentFrame().ip = pos - 1
 			}
 		case code.OpNull:
@@ -175,7 +326,12 @@ func (vm *VM) Run() error {
 			index := int(code.ReadUint16(ins[ip+1:]))
 
 			vm.currentFrame().ip += 2
-			err := vm.push(vm.globals[index])
+			value := vm.globals[index]
+			if value == nil {
+				return vm.undefinedGlobal(index)
+			}
+
+			err := vm.push(value)
 			if err != nil {
 				return err
 			}
@@ -237,8 +393,23 @@ func (vm *VM) Run() error {
 		case code.OpReturnValue:
 			returnValue := vm.pop()
 
+			// Returning from the main program ends it, with the value left
+			// as the last one popped
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			if vm.checkTypes {
+				if err := vm.checkResult(returnValue); err != nil {
+					return err
+				}
+			}
+
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer - 1
+			if frame.returned != nil {
+				frame.returned()
+			}
 
 			err := vm.push(returnValue)
 
@@ -246,8 +417,17 @@ func (vm *VM) Run() error {
 				return err
 			}
 		case code.OpReturn:
+			if vm.checkTypes {
+				if err := vm.checkResult(Null); err != nil {
+					return err
+				}
+			}
+
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer - 1
+			if frame.returned != nil {
+				frame.returned()
+			}
 
 			err := vm.push(Null)
 
@@ -265,6 +445,96 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpCallMethod:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			numArgs := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			name := vm.constants[nameIndex].(*object.String).Value
+			if err := vm.callMethod(name, numArgs); err != nil {
+				return err
+			}
+
+		case code.OpMatch:
+			index := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			pattern := vm.constants[index].(*object.Pattern)
+			values, ok := pattern.Match(vm.stack[vm.sp-1])
+			for _, value := range values {
+				if err := vm.push(value); err != nil {
+					return err
+				}
+			}
+			if err := vm.push(nativeBoolToBooleanObject(ok)); err != nil {
+				return err
+			}
+
+		case code.OpSpread:
+			value := vm.pop()
+			arr, ok := value.(*object.Array)
+			if !ok {
+				return fmt.Errorf("spread operator not supported: %s", value.Type())
+			}
+
+			target := vm.stack[vm.sp-1].(*object.Array)
+			target.Elements = append(target.Elements, arr.Elements...)
+
+		case code.OpCallSpread:
+			numArgs, err := vm.unpackArguments()
+			if err != nil {
+				return err
+			}
+
+			if err := vm.executeCall(numArgs); err != nil {
+				return err
+			}
+
+		case code.OpCallMethodSpread:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			numArgs, err := vm.unpackArguments()
+			if err != nil {
+				return err
+			}
+
+			name := vm.constants[nameIndex].(*object.String).Value
+			if err := vm.callMethod(name, numArgs); err != nil {
+				return err
+			}
+
+		case code.OpCopyConstant:
+			index := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(object.Copy(vm.constants[index])); err != nil {
+				return err
+			}
+
+		case code.OpImport:
+			fnIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			m, err := vm.importModule(vm.constants[fnIndex].(*object.CompiledFunction))
+			if err != nil {
+				return err
+			}
+
+			if err := vm.push(m); err != nil {
+				return err
+			}
+
+		case code.OpCallNamed:
+			namesIndex := code.ReadUint16(ins[ip+1:])
+			numPositional := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			names := vm.constants[namesIndex].(*object.Array).Elements
+			if err := vm.callNamed(names, numPositional); err != nil {
+				return err
+			}
+
 		case code.OpSetLocal:
 			// Read index off of instruction
 			index := int(code.ReadUint8(ins[ip+1:]))
@@ -280,8 +550,12 @@ func (vm *VM) Run() error {
 			index := int(code.ReadUint8(ins[ip+1:]))
 			vm.currentFrame().ip += 1
 			frame := vm.currentFrame()
+			value := vm.stack[frame.basePointer+index]
+			if value == nil {
+				return undefinedLocal(frame, index)
+			}
 
-			err := vm.push(vm.stack[frame.basePointer+index])
+			err := vm.push(value)
 
 			if err != nil {
 				return err
@@ -344,7 +618,13 @@ func (vm *VM) callBuiltin(fn *object.Builtin, numArgs int) error {
 	// read off arguments in between function and stack
 	args := vm.stack[vm.sp-numArgs : vm.sp]
 
-	result := fn.Fn(args...)
+	result := fn.Call(vm.ctx, args...)
+
+	// Builtins that block, like sleep and recv, return early when the
+	// program is interrupted, which it should stop at rather than carry on
+	if err := vm.ctx.Err(); err != nil {
+		return fmt.Errorf("execution interrupted: %s", err)
+	}
 
 	vm.sp = vm.sp - numArgs - 1
 
@@ -358,6 +638,91 @@ func (vm *VM) callBuiltin(fn *object.Builtin, numArgs int) error {
 	return nil
 }
 
+// callNamed calls the function below the arguments, the positional ones
+// followed by the values of the named ones, after putting them in parameter
+// order.
+func (vm *VM) callNamed(names []object.Object, numPositional int) error {
+	base := vm.sp - numPositional - len(names)
+
+	cl, ok := vm.stack[base-1].(*object.Closure)
+	if !ok {
+		return fmt.Errorf("named arguments not supported: %s", vm.stack[base-1].Type())
+	}
+
+	nameValues := []string{}
+	for _, name := range names {
+		nameValues = append(nameValues, name.(*object.String).Value)
+	}
+
+	args, err := object.ArrangeArguments(cl.Fn.Parameters, vm.stack[base:base+numPositional], nameValues, vm.stack[base+numPositional:vm.sp])
+	if err != nil {
+		return err
+	}
+
+	copy(vm.stack[base:], args)
+	vm.sp = base + len(args)
+
+	return vm.callFunction(cl, len(args))
+}
+
+// unpackArguments replaces the array of arguments on top of the stack with
+// its elements, returning how many there are.
+func (vm *VM) unpackArguments() (int, error) {
+	args := vm.pop().(*object.Array)
+	for _, arg := range args.Elements {
+		if err := vm.push(arg); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(args.Elements), nil
+}
+
+// callMethod calls the method of the receiver's type, or the function in
+// the field of a hash, with the receiver as the first argument. The receiver
+// is found below the arguments on the stack.
+func (vm *VM) callMethod(name string, numArgs int) error {
+	receiver := vm.stack[vm.sp-1-numArgs]
+
+	// The functions a module exports aren't passed the module
+	if m, ok := receiver.(*object.Module); ok {
+		fn, ok := m.Export(name)
+		if !ok {
+			return fmt.Errorf("%s has no export %s", m.Inspect(), name)
+		}
+
+		vm.stack[vm.sp-1-numArgs] = fn
+		return vm.executeCall(numArgs)
+	}
+
+	if hash, ok := receiver.(*object.Hash); ok {
+		if fn, ok := hash.Method(name); ok {
+			// Move the receiver and arguments up to make room for the
+			// function below them, as if it had been called with self
+			base := vm.sp - 1 - numArgs
+			vm.push(nil)
+			copy(vm.stack[base+1:vm.sp], vm.stack[base:vm.sp-1])
+			vm.stack[base] = fn
+
+			return vm.executeCall(numArgs + 1)
+		}
+	}
+
+	method, ok := object.LookupMethod(receiver, name)
+	if !ok {
+		return fmt.Errorf("%s has no method %s", receiver.Type(), name)
+	}
+
+	result := method.Call(vm.ctx, vm.stack[vm.sp-1-numArgs:vm.sp]...)
+
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = Null
+	}
+	return vm.push(result)
+}
+
 func (vm *VM) executeIndexOperation() error {
 	index := vm.pop()
 	container := vm.pop()
@@ -366,8 +731,25 @@ func (vm *VM) executeIndexOperation() error {
 
 	case container.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndexOperation(container, index)
+	case container.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		char, ok := container.(*object.String).Char(index.(*object.Integer).Value)
+		if !ok {
+			if vm.strict {
+				return outOfRange(container, index.(*object.Integer).Value, container.(*object.String).Len())
+			}
+			return vm.push(Null)
+		}
+		return vm.push(char)
 	case container.Type() == object.HASH_OBJ:
 		return vm.executeHashIndexOperation(container, index)
+	case container.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		m := container.(*object.Module)
+		name := index.(*object.String).Value
+		value, ok := m.Export(name)
+		if !ok {
+			return fmt.Errorf("%s has no export %s", m.Inspect(), name)
+		}
+		return vm.push(value)
 	default:
 		return fmt.Errorf("Unknown operands for index, %q and %q", container.Type(), index.Type())
 	}
@@ -409,6 +791,9 @@ func (vm *VM) executeArrayIndexOperation(array object.Object, index object.Objec
 	}
 
 	if idx.Value < 0 || int(idx.Value) >= len(arr.Elements) {
+		if vm.strict {
+			return outOfRange(arr, idx.Value, len(arr.Elements))
+		}
 		return vm.push(Null)
 	} else {
 		return vm.push(arr.Elements[idx.Value])
@@ -419,12 +804,20 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
+	return vm.executeBinaryOperands(op, left, right)
+}
+
+// executeBinaryOperands pushes the result of op on left and right, which
+// superinstructions have already taken from the stack or constants.
+func (vm *VM) executeBinaryOperands(op code.Opcode, left, right object.Object) error {
 	leftType := left.Type()
 	rightType := right.Type()
 
 	switch {
 	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return vm.executeBinaryNumberOperation(op, left, right)
 	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
 	default:
@@ -445,34 +838,60 @@ func (vm *VM) executeBangOperation() error {
 
 func (vm *VM) executeMinusOperation() error {
 	right := vm.pop()
-	if right.Type() != object.INTEGER_OBJ {
+	if !object.IsNumber(right) {
 		return fmt.Errorf("Minus operator only works on integers, got %s", right.Type())
 	}
 
-	value := right.(*object.Integer).Value
-
-	return vm.push(&object.Integer{Value: -value})
+	return vm.push(object.Negate(right))
 }
 
 func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
 	leftValue := left.(*object.Integer).Value
 	rightValue := right.(*object.Integer).Value
 
-	var result int64
-	switch op {
-	case code.OpAdd:
-		result = leftValue + rightValue
-	case code.OpSub:
-		result = leftValue - rightValue
-	case code.OpMul:
-		result = leftValue * rightValue
-	case code.OpDiv:
-		result = leftValue / rightValue
-	default:
+	operator, ok := arithmeticOperators[op]
+	if !ok {
 		return fmt.Errorf("unknown integer operator: %d", op)
 	}
 
-	return vm.push(&object.Integer{Value: result})
+	result, ok, err := object.CheckedArithmetic(operator, leftValue, rightValue)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		// Promoted to a BigInt
+		big, err := object.IntegerArithmetic(operator, leftValue, rightValue)
+		if err != nil {
+			return err
+		}
+		return vm.push(big)
+	}
+
+	return vm.push(vm.integers.newInteger(result))
+}
+
+var arithmeticOperators = map[code.Opcode]string{
+	code.OpAdd: "+",
+	code.OpSub: "-",
+	code.OpMul: "*",
+	code.OpDiv: "/",
+}
+
+// executeBinaryNumberOperation handles operations where at least one operand
+// is a BigInt.
+func (vm *VM) executeBinaryNumberOperation(op code.Opcode, left, right object.Object) error {
+	operator, ok := arithmeticOperators[op]
+	if !ok {
+		return fmt.Errorf("unknown integer operator: %d", op)
+	}
+
+	result, err := object.Arithmetic(operator, left, right)
+	if err != nil {
+		return err
+	}
+
+	return vm.push(result)
 }
 
 func (vm *VM) executeBinaryStringOperation(op code.Opcode, left object.Object, right object.Object) error {
@@ -496,18 +915,35 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
-	// If right and left are integers, do eq, noteq, gt
+	if object.IsNumber(left) && object.IsNumber(right) {
+		return vm.executeNumberComparison(op, left, right)
+	}
+
 	switch op {
 	case code.OpEqual:
-		// We don't need to unwrap these because booleans are singleton values.
-		return vm.push(nativeBoolToBooleanObject(right == left))
+		return vm.push(nativeBoolToBooleanObject(object.Equal(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
+		return vm.push(nativeBoolToBooleanObject(!object.Equal(left, right)))
 	default:
 		return fmt.Errorf("Unsupported operator: %d (%s %s)", op, left.Type(), right.Type())
 	}
 }
 
+func (vm *VM) executeNumberComparison(op code.Opcode, left, right object.Object) error {
+	cmp := object.CompareNumbers(left, right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp == 0))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp != 0))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(cmp > 0))
+	default:
+		return fmt.Errorf("Unknown operator: %d", op)
+	}
+}
+
 func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
 	leftValue := left.(*object.Integer).Value
 	rightValue := right.(*object.Integer).Value
@@ -532,26 +968,74 @@ func (vm *VM) callFunction(cl *object.Closure, numArgs int) error {
 		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
 	}
 
+	if vm.framesIndex > vm.maxDepth {
+		return fmt.Errorf("maximum recursion depth exceeded")
+	}
+
+	if vm.checkTypes && cl.Fn.ParameterTypes != nil {
+		fn := cl.Fn
+		if err := object.CheckArguments(fn.Name, fn.Parameters, fn.ParameterTypes, vm.stack[vm.sp-numArgs:vm.sp]); err != nil {
+			return err
+		}
+	}
+
 	frame := NewFrame(cl, vm.sp-numArgs)
+	if vm.hooks != nil && vm.hooks.Call != nil {
+		frame.returned = vm.hooks.Call(vm, cl.Fn)
+	}
 	vm.pushFrame(frame)
 	// Leave NumLocals spaces on the stack for function locals
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	vm.growStack(vm.sp)
+	vm.highWater = max(vm.highWater, vm.sp)
+
+	// Locals read before they're assigned, as in let x = x, must not find
+	// values left over from earlier calls
+	clear(vm.stack[frame.basePointer+numArgs : vm.sp])
 
 	return nil
 }
 
+// checkResult returns an error if result, returned by the function in the
+// current frame, isn't of the type it's annotated to return.
+func (vm *VM) checkResult(result object.Object) error {
+	fn := vm.currentFrame().cl.Fn
+	if err := object.CheckResult(fn.Name, fn.ReturnType, result); err != nil {
+		return err
+	}
+	return nil
+}
+
+// undefinedGlobal reports the global at index being used before it's
+// defined, as in let x = x.
+func (vm *VM) undefinedGlobal(index int) error {
+	if index < len(vm.globalNames) {
+		return fmt.Errorf("identifier not found: %q", vm.globalNames[index])
+	}
+
+	return fmt.Errorf("identifier not found")
+}
+
+// undefinedLocal reports the local at index being used before it's defined.
+func undefinedLocal(frame *Frame, index int) error {
+	if names := frame.cl.Fn.LocalNames; index < len(names) {
+		return fmt.Errorf("identifier not found: %q", names[index])
+	}
+
+	return fmt.Errorf("identifier not found")
+}
+
 func (vm *VM) LastPoppedStackElem() object.Object {
 	return vm.stack[vm.sp]
 }
 
 func (vm *VM) push(obj object.Object) error {
 	// fmt.Printf("Pushing object %+v to sp %d\n", obj, vm.sp)
-	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow, oh no")
-	}
+	vm.growStack(vm.sp)
 
 	vm.stack[vm.sp] = obj
 	vm.sp++
+	vm.highWater = max(vm.highWater, vm.sp)
 
 	return nil
 }
@@ -589,13 +1073,24 @@ func (vm *VM) pop() object.Object {
 	return o
 }
 
+// growStack makes room on the stack for a value at index sp.
+func (vm *VM) growStack(sp int) {
+	for sp >= len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+}
+
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.framesIndex-1]
 }
 
 func (vm *VM) pushFrame(f *Frame) {
 	// fmt.Printf("Pushing new frame %+v \n", f)
-	vm.frames[vm.framesIndex] = f
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
 	vm.framesIndex++
 }
 
@@ -612,6 +1107,22 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return False
 }
 
+// condition reports whether obj, the value of an if condition or match
+// guard, counts as true. In strict mode it must be a boolean.
+func (vm *VM) condition(obj object.Object) (bool, error) {
+	if vm.strict && obj.Type() != object.BOOLEAN_OBJ {
+		return false, fmt.Errorf("condition must be BOOLEAN in strict mode, got %s", obj.Type())
+	}
+
+	return isTruthy(obj), nil
+}
+
+// outOfRange is the error indexing container, an array or string of length
+// length, with index fails with in strict mode.
+func outOfRange(container object.Object, index int64, length int) error {
+	return fmt.Errorf("index %d out of range for %s of length %d", index, container.Type(), length)
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 	case *object.Boolean:
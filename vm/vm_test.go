@@ -1,13 +1,22 @@
 package vm
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"monkey/ast"
 	"monkey/compiler"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func parse(input string) *ast.Program {
@@ -213,6 +222,20 @@ func TestBooleanExpressions(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[] == {}", false},
+		{`{"a": [1], 2: true} == {2: true, "a": [1]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`"a" == "a"`, true},
+		{`["a"] == ["a"]`, true},
+		{"[1] == [bigint(1)]", true},
+		{"let f = fn() {}; [f] == [f]", true},
+		{"[fn() {}] == [fn() {}]", false},
 		{"!true", false},
 		{"!false", true},
 		{"!5", false},
@@ -236,6 +259,35 @@ func TestConditionals(t *testing.T) {
 		{"if (1 < 2) { 10 } else { 20 }", 10},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (if (1 > 2) { 10 }) { 20 } else { 30 }", 30},
+		{"if (true) { }", Null},
+		{"if (true) { let a = 1; }", Null},
+		{"if (false) { 10 } else { }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSuperinstructions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(a, b) { if (a == b) { a + b } else { b - 1 } }; f(2, 2)", 4},
+		{"let f = fn(a, b) { if (a == b) { a + b } else { b - 1 } }; f(2, 3)", 2},
+		{"let f = fn(a, b) { if (a != b) { 1 } else { 2 } }; [f(1, 2), f(1, 1)]", []int{1, 2}},
+		{"let f = fn(a, b) { if (a > b) { 1 } else { 2 } }; [f(2, 1), f(1, 2)]", []int{1, 2}},
+		{`let f = fn(a, b) { a + b }; f("mon", "key")`, "monkey"},
+		{"1 + if (true) { 2 } else { 3 }", 3},
+		{"1 + if (false) { 2 } else { 3 }", 4},
+		{"let x = 10; x - 3 + 2", 9},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTopLevelReturn(t *testing.T) {
+	tests := []vmTestCase{
+		{"return 10; 9", 10},
+		{"let x = 2; return x * 5; 9", 10},
+		{"if (true) { return 10 } 9", 10},
+		{"let f = fn() { return 1 }; return f() + 1; 9", 2},
 	}
 
 	runVmTests(t, tests)
@@ -316,6 +368,9 @@ func TestIndexExpresions(t *testing.T) {
 		{"{1: 1, 2: 2}[2]", 2},
 		{"{1: 1}[0]", Null},
 		{"{}[0]", Null},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[5]`, Null},
+		{`"abc"[-1]`, Null},
 	}
 
 	runVmTests(t, tests)
@@ -531,15 +586,15 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
 			input:    `fn() { 1; }(1);`,
-			expected: `wrong number of arguments: want=0, got=1`,
+			expected: `wrong number of arguments: want=0, got=1 at 1:1`,
 		},
 		{
 			input:    `fn(a) { a; }();`,
-			expected: `wrong number of arguments: want=1, got=0`,
+			expected: `wrong number of arguments: want=1, got=0 at 1:1`,
 		},
 		{
 			input:    `fn(a,b) { a + b; }(1);`,
-			expected: `wrong number of arguments: want=2, got=1`,
+			expected: `wrong number of arguments: want=2, got=1 at 1:1`,
 		},
 	}
 
@@ -571,6 +626,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`"héllo".slice(1, -1)`, "éll"},
 		{
 			`len(1)`,
 			&object.Error{
@@ -583,7 +640,112 @@ func TestBuiltinFunctions(t *testing.T) {
 			},
 		},
 		{`len([1, 2, 3])`, 3},
+		{`builtins()[0].name`, "len"},
+		{`builtins()[1].arity`, -1},
+		{`builtins(1)`,
+			&object.Error{
+				Message: "wrong number of arguments. got=1, want=0",
+			},
+		},
 		{`len([])`, 0},
+		{`startsWith("monkey", "mon")`, true},
+		{`startsWith("monkey", "key")`, false},
+		{`endsWith("monkey", "key")`, true},
+		{`endsWith("monkey", "monkeys")`, false},
+		{`startsWith("monkey", 1)`,
+			&object.Error{
+				Message: "arguments to `startsWith` must be STRING, got INTEGER",
+			},
+		},
+		{`endsWith("key")`,
+			&object.Error{
+				Message: "wrong number of arguments. got=1, want=2",
+			},
+		},
+		{`reverse([1, 2, 3])`, []int{3, 2, 1}},
+		{`let a = [1, 2]; reverse(a); a`, []int{1, 2}},
+		{`reverse("abc")`,
+			&object.Error{
+				Message: "argument to `reverse` must be ARRAY, got STRING",
+			},
+		},
+		{`concat([1], [2, 3])`, []int{1, 2, 3}},
+		{`concat([1], 2)`,
+			&object.Error{
+				Message: "arguments to `concat` must be ARRAY, got INTEGER",
+			},
+		},
+		{`slice([1, 2, 3, 4], 1, 3)`, []int{2, 3}},
+		{`slice([1, 2, 3, 4], -2, 10)`, []int{3, 4}},
+		{`slice([1, 2, 3], 2, 1)`, []int{}},
+		{`slice([1, 2, 3], "a", 1)`,
+			&object.Error{
+				Message: "indexes to `slice` must be INTEGER, got STRING",
+			},
+		},
+		{`flatten([1, [2, [3]], []]) == [1, 2, [3]]`, true},
+		{`flatten([1, [2, [3]]], 5)`, []int{1, 2, 3}},
+		{`flatten(1)`,
+			&object.Error{
+				Message: "argument to `flatten` must be ARRAY, got INTEGER",
+			},
+		},
+		{`zip([1, 2, 3], [4, 5]) == [[1, 4], [2, 5]]`, true},
+		{`zip([1], "a")`,
+			&object.Error{
+				Message: "arguments to `zip` must be ARRAY, got STRING",
+			},
+		},
+		{`parse_int("0b11").value`, 3},
+		{`parse_int("0b11").ok`, true},
+		{`parse_int("0b11").error`, Null},
+		{`parse_int("x").ok`, false},
+		{`parse_int("x").error`, "invalid integer \"x\""},
+		{`parse_int(12)`,
+			&object.Error{
+				Message: "argument to `parse_int` must be STRING, got INTEGER",
+			},
+		},
+		{`let t = parse_time("2024-03-05 14:07:09", "2006-01-02 15:04:05"); [t.year(), t.month(), t.day(), t.hour(), t.minute(), t.second(), t.weekday()]`, []int{2024, 3, 5, 14, 7, 9, 2}},
+		{`let t = parse_time("2024-03-05", "2006-01-02"); format(add(t, 86400000), "Jan 2, 2006")`, "Mar 6, 2024"},
+		{`parse_time("2024-03-05", "2006-01-02").add(-1500).millisecond()`, 500},
+		{`parse_time("1970-01-01T00:00:01Z", "2006-01-02T15:04:05Z07:00").unix()`, 1000},
+		{`now().year() > 2000`, true},
+		{`add(1, 2)`,
+			&object.Error{
+				Message: "argument to `add` must be TIME, got INTEGER",
+			},
+		},
+		{`csv_parse("a,b
+1,2") == [["a", "b"], ["1", "2"]]`, true},
+		{`csv_parse("n
+1", {"header": true})[0]["n"]`, "1"},
+		{`csv_encode([["a", 1], ["b", 2]])`, "a,1\nb,2\n"},
+		{`csv_encode(1)`,
+			&object.Error{
+				Message: "argument to `csv_encode` must be ARRAY, got INTEGER",
+			},
+		},
+		{`b64_encode("hi")`, "aGk="},
+		{`b64_decode("aGk=")`, "hi"},
+		{`hex_decode(hex_encode("monkey"))`, "monkey"},
+		{`b64_decode(1)`,
+			&object.Error{
+				Message: "argument to `b64_decode` must be STRING, got INTEGER",
+			},
+		},
+		{`chars("héllo") == ["h", "é", "l", "l", "o"]`, true},
+		{`"ab".chars().reverse() == ["b", "a"]`, true},
+		{`chars([])`,
+			&object.Error{
+				Message: "argument to `chars` must be STRING, got ARRAY",
+			},
+		},
+		{`len(uuid())`, 36},
+		{`let check = fn(x) { if (x < 0) { error("negative") } else { x } }; [is_error(check(-1)), is_error(check(1))] == [true, false]`, true},
+		{`error("negative").message()`, "negative"},
+		{`error("a") == error("a")`, true},
+		{`uuid() == uuid()`, false},
 		{`puts("hello", "world!")`, Null},
 		{`first([1, 2, 3])`, 1},
 		{`first([])`, Null},
@@ -607,11 +769,234 @@ func TestBuiltinFunctions(t *testing.T) {
 				Message: "argument to `push` must be ARRAY, got INTEGER",
 			},
 		},
+		{`frozen([1])`, false},
+		{`frozen(freeze([1]))`, true},
+		{`let a = [{"b": [1]}]; freeze(a); frozen(a[0]["b"])`, true},
+		{`frozen(push(freeze([1]), 2))`, false},
+		{`frozen(1)`, true},
+		// Literals made of constants are copied from the constant pool
+		{`let f = fn() { [1, [2]] }; freeze(f()); frozen(f())`, false},
+		{`let f = fn() { {"a": [1]} }; freeze(f()); frozen(f()["a"])`, false},
+		{`let fib = memoize(fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } }); fib(80)`, 23416728348467685},
+		{`let f = memoize(fn(a, b) { a - b }); f(3, 1) + f(1, 3)`, 0},
+		{`memoize(fn(x) { x })([1])`,
+			&object.Error{
+				Message: "unusable as memoize key: ARRAY",
+			},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.txt")
+	os.WriteFile(filename, []byte("one\r\ntwo\nthree"), 0644)
+
+	path := fmt.Sprintf("let path = %q; ", filename)
+	tests := []vmTestCase{
+		{path + `let f = open(path); [f.read_line(), f.read_line(), read_line(f)]`, []string{"one", "two", "three"}},
+		{path + `let f = open(path); f.read_line(); f.read_line(); f.read_line(); f.read_line()`, Null},
+		{path + "let f = open(path, \"a\"); f.write(\"\nfour\"); f.close(); let g = open(path); g.read_line(); g.read_line(); g.read_line(); g.read_line()", "four"},
+		{path + `let f = open(path, "w"); write(f, "new"); close(f); open(path).read_line()`, "new"},
+		{path + `let f = open(path); f.close(); f.read_line()`,
+			&object.Error{
+				Message: "read from closed file " + filename,
+			},
+		},
+		{path + `open(path, "x")`,
+			&object.Error{
+				Message: `unknown file mode "x", want r, w or a`,
+			},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDirectories(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), nil, 0644)
+
+	prelude := fmt.Sprintf("let dir = %q; ", dir)
+	tests := []vmTestCase{
+		{prelude + `mkdir(path_join(dir, "b")); list_dir(dir)`, []string{"a.txt", "b"}},
+		{prelude + `[exists(path_join(dir, "a.txt")), exists(path_join(dir, "c.txt"))] == [true, false]`, true},
+		{prelude + `mkdir(1)`,
+			&object.Error{
+				Message: "argument to `mkdir` must be STRING, got INTEGER",
+			},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{`"four".len()`, 4},
+		{`"four".startsWith("fo") == "four".endsWith("ur")`, true},
+		{`[1, 2, 3].len()`, 3},
+		{`[1, 2].push(3)`, []int{1, 2, 3}},
+		{`[1, 2, 3].slice(0, 2).concat([0]).reverse()`, []int{0, 2, 1}},
+		{`[1, 2].zip([3, 4]).flatten()`, []int{1, 3, 2, 4}},
+		{`[1, 2, 3].rest().first()`, 2},
+		{`let a = [1, 2]; a.push(3).last() + a.len()`, 5},
+		{`{"b": 2, "a": 1}.keys() == ["a", "b"]`, true},
+		{`{2: "b", 1: "a"}.values() == ["a", "b"]`, true},
+		{`{"a": 1}.len()`, 1},
+		{`let f = fn(x) { x.len() }; f("ab") + f([1])`, 3},
+		{`let person = {"name": "Ada", "langs": ["monkey"]}; person.name`, "Ada"},
+		{`let person = {"langs": ["go", "monkey"]}; person.langs[1].len()`, 6},
+		{`{"a": {"b": 1}}.a.b`, 1},
+		{`{}.missing`, Null},
+		{`let Point = fn(x, y) { {"x": x, "y": y, "norm": fn(self) { self.x * self.x + self.y * self.y }} }; Point(3, 4).norm()`, 25},
+		{`let counter = {"n": 2, "add": fn(self, k) { self.n + k }, "twice": fn(self) { self.add(self.n) }}; counter.twice()`, 4},
+		{`{"len": fn(self) { 42 }}.len()`, 42},
+		{`{"keys": 1}.keys().len()`, 1},
+		{`{"size": len}.size()`, 1},
+		{`"s".len(1)`,
+			&object.Error{
+				Message: "wrong number of arguments. got=2, want=1",
+			},
+		},
+	}
+
+	runVmTests(t, tests)
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\nx.len()", "INTEGER has no method len at 2:1"},
+		{`{"f": fn() { 1 }}.f()`, "wrong number of arguments: want=0, got=1 at 1:1"},
+	}
+
+	for _, tt := range errorTests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestNullSafeAccess(t *testing.T) {
+	tests := []vmTestCase{
+		{`let config = {"server": {"port": 80}}; config?.server?.port`, 80},
+		{`let config = {}; config?.server?.port`, Null},
+		{`let xs = {}.items; xs?[0]`, Null},
+		{`let xs = [[1, 2]]; xs?[0]?[1]`, 2},
+		{`{}.name?.len()`, Null},
+		{`{}.name?.concat(...[[1]])`, Null},
+		{`"four"?.len()`, 4},
+		{`let f = fn(x) { x?.name }; [f({"name": "Ada"}), f({}.y)] == ["Ada", {}.y]`, true},
 	}
 
 	runVmTests(t, tests)
 }
 
+func TestMatchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`match (2) { 1 => 10, 2 => 20 }`, 20},
+		{`match (-1) { -1 => 1, _ => 2 }`, 1},
+		{`match ("b") { "a" => 1, x => x.len() }`, 1},
+		{`match (true) { false => 1, true => 2 }`, 2},
+		{`match (3) { 1 => 10 }`, Null},
+		{`match ([1, 2, 3]) { [a, b] => 0, [a, b, c] => a + b + c }`, 6},
+		{`match ([1, 2, 3]) { [h, ...t] => t }`, []int{2, 3}},
+		{`match ([]) { [h, ...t] => 1, [] => 2 }`, 2},
+		{`match ([1, [2, 3]]) { [_, [x, 3]] => x }`, 2},
+		{`match ({"name": "Ada", "age": 36}) { {name: n} => n }`, "Ada"},
+		{`match ({"name": "Ada"}) { {"age": a} => a, {name: "Ada"} => 1 }`, 1},
+		{`match (5) { n if n > 10 => 1, n if n > 1 => 2, _ => 3 }`, 2},
+		{`match ("s") { [x] => x, {x: x} => x }`, Null},
+		{`match (1) { x => x }; x`, 1},
+		{`let a = [1, 2]; match (a) { [x, y] => x + y } + match (a) { _ => 1 }`, 4},
+		{`let sum = fn(arr) { match (arr) { [] => 0, [h, ...t] => h + sum(t) } }; sum([1, 2, 3, 4])`, 10},
+		{`let f = fn(p) { match (p) { [x, y] if x == y => "same", [x, _] => x } }; f([1, 1]).len() + f([2, 3])`, 6},
+		{`let f = fn(p) { let k = 1; match (p) { [x, ...r] => fn() { x + r.len() + k } } }; f([2, 3, 4])()`, 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSpread(t *testing.T) {
+	tests := []vmTestCase{
+		{`let xs = [2, 3]; [1, ...xs, 4]`, []int{1, 2, 3, 4}},
+		{`[...[], ...[1], ...[]]`, []int{1}},
+		{`[1, 2, ...[3]]`, []int{1, 2, 3}},
+		{`let xs = [1]; let ys = [...xs]; push(ys, 2); len(xs)`, 1},
+		{`let add = fn(a, b, c) { a + b * c }; add(...[1, 2, 3])`, 7},
+		{`let add = fn(a, b, c) { a + b * c }; add(1, ...[2], 3)`, 7},
+		{`let f = fn(xs) { let g = fn(a, b) { a - b }; g(...xs) }; f([5, 2])`, 3},
+		{`len(...["four"])`, 4},
+		{`[1].push(...[2])`, []int{1, 2}},
+		{`{"add": fn(self, a, b) { a + b }}.add(...[1, 2])`, 3},
+	}
+
+	runVmTests(t, tests)
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, ...2]`, "spread operator not supported: INTEGER at 1:1"},
+		{`let f = fn(a, b) { a }; f(...[1])`, "wrong number of arguments: want=2, got=1 at 1:25"},
+	}
+
+	for _, tt := range errorTests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{`let sub = fn(a, b) { a - b }; sub(b: 1, a: 5)`, 4},
+		{`let sub = fn(a, b) { a - b }; sub(5, b: 1)`, 4},
+		{`let greet = fn(name, excited) { if (excited) { name + "!" } else { name } }; greet("Ann", excited: true)`, "Ann!"},
+		{`let f = fn(a, b, c) { [a, b, c] }; f(1, c: 3, b: 2)`, []int{1, 2, 3}},
+		{`let f = fn(a, b) { let c = a * 10; c + b }; let g = fn() { f(b: 2, a: 1) }; g()`, 12},
+	}
+
+	runVmTests(t, tests)
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(a) { a }; f(b: 1)`, "unknown argument b at 1:22"},
+		{`let f = fn(a) { a }; f(1, a: 2)`, "duplicate argument a at 1:22"},
+		{`let f = fn(a, b) { a }; f(b: 2)`, "missing argument a at 1:25"},
+		{`let f = fn(a) { a }; f(1, 2, a: 3)`, "wrong number of arguments: want=1, got=3 at 1:22"},
+		{`len(x: "s")`, "named arguments not supported: BUILTIN at 1:1"},
+	}
+
+	for _, tt := range errorTests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -749,3 +1134,708 @@ func TestRecursiveFunctions(t *testing.T) {
 
 	runVmTests(t, tests)
 }
+
+func TestInlining(t *testing.T) {
+	tests := []vmTestCase{
+		{`let sq = fn(x) { x * x }; sq(sq(2))`, 16},
+		{`let sub = fn(a, b) { a - b }; sub(sub(10, 3), sub(5, 4))`, 6},
+		{`let max = fn(a, b) { if (a > b) { a } else { b } }; let f = fn(n) { max(n, 10) + max(n, 0) }; f(3)`, 13},
+		{`let first = fn(arr) { arr[0] }; let r = fn(n) { if (n == 0) { 0 } else { first([n]) + r(n - 1) } }; r(4)`, 10},
+		{`let noop = fn(x) { }; noop(1)`, Null},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		comp.SetInlining(true)
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType object.ObjectType
+		expected     string
+	}{
+		{"9223372036854775807 + 1", object.BIGINT_OBJ, "9223372036854775808"},
+		{"-9223372036854775807 - 2", object.BIGINT_OBJ, "-9223372036854775809"},
+		{"4294967296 * 4294967296", object.BIGINT_OBJ, "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", object.BIGINT_OBJ, "9223372036854775808"},
+		{"(9223372036854775807 + 1) - 1", object.BIGINT_OBJ, "9223372036854775807"},
+		{`bigint("-123456789012345678901234567890") / 10`, object.BIGINT_OBJ, "-12345678901234567890123456789"},
+		{`bigint(5) == 5`, object.BOOLEAN_OBJ, "true"},
+		{`5 != bigint(5)`, object.BOOLEAN_OBJ, "false"},
+		{`9223372036854775807 < 9223372036854775807 + 1`, object.BOOLEAN_OBJ, "true"},
+		{`bigint(2) > 3`, object.BOOLEAN_OBJ, "false"},
+		{`{bigint(1): "one"}[1]`, object.STRING_OBJ, `"one"`},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)`,
+			object.BIGINT_OBJ, "15511210043330985984000000"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		result := vm.LastPoppedStackElem()
+		if result.Type() != tt.expectedType || result.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s %s, got=%s %s",
+				tt.input, tt.expectedType, tt.expected, result.Type(), result.Inspect())
+		}
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"bigint(1) / 0", "division by zero: 1 / 0"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q", tt.input)
+		}
+
+		if msg := err.(*object.Error).Message; msg != tt.expected {
+			t.Errorf("wrong VM error. expected=%q, got=%q", tt.expected, msg)
+		}
+	}
+}
+
+func TestUndefinedVariables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = f(1);", `identifier not found: "f"`},
+		{"let f = f(a: 1);", `identifier not found: "f"`},
+		{"let a = 1; let b = [a, b];", `identifier not found: "b"`},
+		{"fn() { let x = x + 1; x }()", `identifier not found: "x"`},
+		{"fn(a) { let b = a + b; b }(1)", `identifier not found: "b"`},
+		{"let f = fn() { let x = 1; x }; f(); fn() { let y = y; y }()", `identifier not found: "y"`},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q", tt.input)
+		}
+
+		if msg := err.(*object.Error).Message; msg != tt.expected {
+			t.Errorf("wrong VM error. expected=%q, got=%q", tt.expected, msg)
+		}
+	}
+}
+
+func TestRunContextCancellation(t *testing.T) {
+	program := parse(`
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	fib(30);
+	`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	vm := New(comp.Bytecode())
+	err := vm.RunContext(ctx)
+
+	if err == nil {
+		t.Fatalf("expected an error from cancelled run")
+	}
+
+	if err.Error() != "execution interrupted: context canceled at 2:61" {
+		t.Errorf("wrong error message, got %q", err.Error())
+	}
+}
+
+func TestSleepInterrupted(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`sleep(60000); 1`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := New(comp.Bytecode()).RunContext(ctx)
+
+	if err == nil || !strings.HasPrefix(err.Error(), "execution interrupted: context deadline exceeded") {
+		t.Fatalf("expected the run to be interrupted, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("sleep wasn't interrupted, took %s", elapsed)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`[1, 2][1]`, 2},
+		{`[1, 2][2]`, "index 2 out of range for ARRAY of length 2"},
+		{`"ab"[-1]`, "index -1 out of range for STRING of length 2"},
+		{`{"a": 1}["b"]`, Null},
+		{`if (1 < 2) { 1 } else { 2 }`, 1},
+		{`if (1) { 1 }`, "condition must be BOOLEAN in strict mode, got INTEGER"},
+		{`match (1) { x if x => x }`, "condition must be BOOLEAN in strict mode, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.RunContext(object.WithStrict(context.Background()))
+
+		if expected, ok := tt.expected.(string); ok {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.input)
+			} else if msg := err.(*object.Error).Message; msg != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, msg)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: vm error: %s", tt.input, err)
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestCheckTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let add = fn(x: int, y: int) -> int { x + y }; add(1, 2)`, 3},
+		{`let add = fn(x: int, y: int) -> int { x + y }; add(1, "2")`, "argument y to `add` must be int, got STRING"},
+		{`let f = fn(x, s: string) { x }; f(true, "s"); f(1, 2)`, "argument s to `f` must be string, got INTEGER"},
+		{`fn(x: any, f: fn, n: null) { x }(1, len, puts())`, 1},
+		{`let f = fn(x) -> int { if (x) { return "a" } 1 }; f(false)`, 1},
+		{`let f = fn(x) -> int { if (x) { return "a" } 1 }; f(true)`, "`f` must return int, got STRING"},
+		{`fn() -> int { }()`, "function must return int, got NULL"},
+		{`fn(x: number) { x }(1)`, "unknown type number"},
+		{`memoize(fn(x: int) -> int { x * 2 })(3)`, 6},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.RunContext(object.WithCheckTypes(context.Background()))
+
+		if expected, ok := tt.expected.(string); ok {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.input)
+			} else if msg := err.(*object.Error).Message; msg != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, msg)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: vm error: %s", tt.input, err)
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+
+	runVmTests(t, []vmTestCase{{`fn(x: string) -> string { x }(1)`, 1}})
+}
+
+func TestMaxRecursionDepth(t *testing.T) {
+	input := `
+	let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } };
+	depth(%d);
+	`
+
+	tests := []struct {
+		n        int
+		maxDepth int
+		expected any
+	}{
+		{50, 100, 50},
+		{99, 100, 99},
+		{100, 100, "maximum recursion depth exceeded"},
+		{5000, 0, 5000},
+		{object.DefaultMaxDepth, 0, "maximum recursion depth exceeded"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(fmt.Sprintf(input, tt.n))); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		ctx := context.Background()
+		if tt.maxDepth > 0 {
+			ctx = object.WithMaxDepth(ctx, tt.maxDepth)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.RunContext(ctx)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			if err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+			testExpectedObject(t, expected, vm.LastPoppedStackElem())
+		case string:
+			if err == nil {
+				t.Fatalf("expected an error for depth %d", tt.n)
+			}
+			if msg := err.(*object.Error).Message; msg != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, msg)
+			}
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	runVmTests(t, []vmTestCase{
+		{`let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(100, 0)`, 5050},
+		{`let swap = fn(a, b, n) { if (n == 0) { [a, b] } else { swap(b, a, n - 1) } }; swap(1, 2, 3)`, []int{2, 1}},
+		{`let f = fn(n) { if (n > 2) { return f(n - 1) }; n * 10 }; f(5)`, 20},
+		{`let f = fn(n) { match (n) { 0 => "done", _ => f(n - 1) } }; f(3)`, "done"},
+		// Each closure keeps the value its parameter had when it was made
+		{`let f = fn(n, fs) { if (n == 0) { fs } else { f(n - 1, push(fs, fn() { n })) } }; f(2, [])[0]()`, 2},
+	})
+
+	// Recursing in tail position doesn't use up frames
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(10000)`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.RunContext(object.WithMaxDepth(context.Background(), 100)); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 0, vm.LastPoppedStackElem())
+}
+
+func TestStackTrace(t *testing.T) {
+	program := parse(`
+	let inner = fn(x) { x + true };
+	let outer = fn(x) { inner(x) };
+	let apply = fn(f) { f(1) };
+	apply(fn(x) { outer(x) });
+	`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err := vm.Run()
+
+	errObj, ok := err.(*object.Error)
+	if !ok {
+		t.Fatalf("error is not *object.Error. got=%T (%+v)", err, err)
+	}
+
+	if errObj.Pos.String() != "2:22" {
+		t.Errorf("wrong error position. got=%s", errObj.Pos)
+	}
+
+	expected := []object.StackFrame{
+		{Function: "inner", Pos: token.Position{Line: 3, Column: 22}},
+		{Function: "outer", Pos: token.Position{Line: 5, Column: 16}},
+		{Function: "", Pos: token.Position{Line: 4, Column: 22}},
+		{Function: "apply", Pos: token.Position{Line: 5, Column: 2}},
+	}
+
+	if !reflect.DeepEqual(errObj.Stack, expected) {
+		t.Errorf("wrong stack.\nexpected=%v\ngot=     %v", expected, errObj.Stack)
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/math.monkey":  `let square = fn(x) { x * x }; let cube = fn(x) { x * square(x) }; let _secret = 1; let scale = (import "./scale").factor;`,
+		"lib/scale.monkey": `let factor = 3;`,
+		"cycle/a.monkey":   `let b = import "./b";`,
+		"cycle/b.monkey":   `let a = import "./a";`,
+		"broken.monkey":    "let x = 1;\nlet y = x + true;",
+	}
+	for name, text := range files {
+		filename := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(filename), 0755)
+		if err := os.WriteFile(filename, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compile := func(input string) (*compiler.Bytecode, error) {
+		program := parser.New(lexer.NewWithFilename(filepath.Join(dir, "main.monkey"), input)).ParseProgram()
+		comp := compiler.New()
+		comp.SetLoader(module.NewLoader(dir))
+		if err := comp.Compile(program); err != nil {
+			return nil, err
+		}
+		return comp.Bytecode(), nil
+	}
+
+	tests := []vmTestCase{
+		{`let m = import "lib/math"; m.square(4)`, 16},
+		{`let m = import "lib/math"; m.cube(2)`, 8},
+		{`let square = 1; let m = import "./lib/math"; m.scale + square`, 4},
+		{`let m = import "lib/math"; let f = m.square; f(5)`, 25},
+		{`let get = fn() { import "lib/math" }; get() == get()`, true},
+		{`wait(spawn(fn() { (import "lib/math").square(3) }))`, 9},
+	}
+
+	for _, tt := range tests {
+		bytecode, err := compile(tt.input)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(bytecode)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("%s: vm error: %s", tt.input, err)
+		}
+
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+
+	if _, err := compile(`import "cycle/a"`); err == nil || err.Error() != "cannot import \"./a\": import cycle through "+filepath.Join(dir, "cycle/a.monkey") {
+		t.Errorf("expected an import cycle, got=%v", err)
+	}
+
+	runtimeErrors := []struct {
+		input    string
+		expected string
+	}{
+		{`(import "lib/math").double(2)`, "module lib/math has no export double at " + filepath.Join(dir, "main.monkey") + ":1:2"},
+		{`import "broken"`, "Unsupported types for binary operation: INTEGER BOOLEAN at " + filepath.Join(dir, "broken.monkey") + ":2:9"},
+		{"let m = import \"lib/math\";\nm._secret", "module lib/math has no export _secret at " + filepath.Join(dir, "main.monkey") + ":2:1"},
+	}
+
+	for _, tt := range runtimeErrors {
+		bytecode, err := compile(tt.input)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		if err := New(bytecode).Run(); err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	tests := []vmTestCase{
+		{`wait(spawn(fn() { 1 + 2 }))`, 3},
+		{`let add = fn(a, b) { a + b }; wait(spawn(add, 40, 2))`, 42},
+		{
+			`
+			let fib = fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } };
+			let tasks = [spawn(fib, 10), spawn(fib, 15), spawn(fib, 20)];
+			wait(tasks[0]) + wait(tasks[1]) + wait(tasks[2]);
+			`,
+			55 + 610 + 6765,
+		},
+		{
+			`let n = 10; let f = fn(x) { fn() { x * n } }; wait(spawn(f(4)))`,
+			40,
+		},
+		{
+			`wait(spawn(fn(a) { a }))`,
+			&object.Error{Message: "wrong number of arguments: want=1, got=0"},
+		},
+		{
+			`wait(1)`,
+			&object.Error{Message: "argument to `wait` must be TASK, got INTEGER"},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestChannels(t *testing.T) {
+	tests := []vmTestCase{
+		{`let ch = chan(1); send(ch, 5); recv(ch)`, 5},
+		{
+			`
+			let ch = chan();
+			let produce = fn(n) { if (n > 0) { send(ch, n); produce(n - 1) } else { close(ch) } };
+			let sum = fn(total) { let v = recv(ch); if (v) { sum(total + v) } else { total } };
+			spawn(produce, 10);
+			sum(0);
+			`,
+			55,
+		},
+		{`let ch = chan(); close(ch); recv(ch)`, Null},
+		{
+			`let ch = chan(1); close(ch); send(ch, 1)`,
+			&object.Error{Message: "send on closed channel"},
+		},
+		{
+			`let ch = chan(); close(ch); close(ch)`,
+			&object.Error{Message: "close of closed channel"},
+		},
+		{
+			`chan(-1)`,
+			&object.Error{Message: "negative channel size: -1"},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIntegerArena(t *testing.T) {
+	var arena integerArena
+
+	// Enough to span several chunks
+	integers := []*object.Integer{}
+	for i := 0; i < 3*arenaChunkSize; i++ {
+		integers = append(integers, arena.newInteger(int64(i*1000)))
+	}
+	arena.reset()
+	arena.newInteger(-5000)
+
+	for i, integer := range integers {
+		if integer.Value != int64(i*1000) {
+			t.Fatalf("integer %d changed. want=%d, got=%d", i, i*1000, integer.Value)
+		}
+	}
+
+	if arena.newInteger(1) != object.NewInteger(1) {
+		t.Errorf("small integers aren't shared")
+	}
+
+	// Results of arithmetic outlive the run they came from
+	runVmTests(t, []vmTestCase{
+		{`let xs = [2000 * 2, 3000 * 3]; let ys = [xs[0] + 1, xs[1] + 1]; [xs[0], xs[1], ys[0], ys[1]]`, []int{4000, 9000, 4001, 9001}},
+	})
+}
+
+func TestMemoryStats(t *testing.T) {
+	runVmTests(t, []vmTestCase{
+		{`memory_stats().objects_allocated > 0`, true},
+		{`
+		let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };
+		let before = memory_stats().stack_high_water;
+		count(100);
+		memory_stats().stack_high_water - before > 100
+		`, true},
+	})
+
+	program := parse(`let stats = memory_stats(); stats["constants"]`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	vm := New(bytecode)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, len(bytecode.Constants), vm.LastPoppedStackElem())
+}
+
+func benchmarkProgram(b *testing.B, input string) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		b.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := New(bytecode).Run(); err != nil {
+			b.Fatalf("vm error: %s", err)
+		}
+	}
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	benchmarkProgram(b, `
+	let fib = fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } };
+	fib(20);
+	`)
+}
+
+// Counting stays within the small Integers, which aren't allocated
+func BenchmarkCount(b *testing.B) {
+	benchmarkProgram(b, `
+	let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };
+	count(1000);
+	`)
+}
+
+// Arithmetic on locals and constants, which runs as superinstructions
+func BenchmarkArithmetic(b *testing.B) {
+	benchmarkProgram(b, `
+	let step = fn(n, a, b) { if (n == 0) { a } else { step(n - 1, a + b - 1, b) } };
+	step(1000, 0, 2);
+	`)
+}
+
+// Summing goes past the small Integers, which are allocated from the arena
+func BenchmarkSum(b *testing.B) {
+	benchmarkProgram(b, `
+	let sum = fn(n, total) { if (n == 0) { total } else { sum(n - 1, total + n * 1000) } };
+	sum(1000, 0);
+	`)
+}
+
+func TestHooks(t *testing.T) {
+	program := parse(`let double = fn(x) {
+	let y = x * 2;
+	y
+};
+let a = double(1);
+let b = double(a);`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	type stop struct {
+		line      int
+		functions string
+		locals    string
+		globals   string
+	}
+	stops := []stop{}
+	calls := []string{}
+
+	describe := func(vars []Variable) string {
+		out := []string{}
+		for _, v := range vars {
+			out = append(out, v.Name+"="+v.Value.Inspect())
+		}
+		return strings.Join(out, " ")
+	}
+
+	hooks := &Hooks{Line: func(vm *VM, pos token.Position) {
+		functions := []string{}
+		for _, f := range vm.Frames() {
+			functions = append(functions, f.Function())
+		}
+
+		stops = append(stops, stop{
+			line:      pos.Line,
+			functions: fmt.Sprint(functions),
+			locals:    describe(vm.Locals(vm.Frames()[0])),
+			globals:   describe(vm.Globals()),
+		})
+	}, Call: func(vm *VM, fn *object.CompiledFunction) func() {
+		calls = append(calls, "call "+fn.Name)
+		return func() { calls = append(calls, "return "+fn.Name) }
+	}}
+
+	vm := New(comp.Bytecode())
+	if err := vm.RunContext(WithHooks(context.Background(), hooks)); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	expected := []stop{
+		{1, "[]", "", ""},
+		{5, "[]", "", ""},
+		{2, "[double ]", "x=1", ""},
+		{3, "[double ]", "x=1 y=2", ""},
+		{5, "[]", "", ""},
+		{6, "[]", "", "a=2"},
+		{2, "[double ]", "x=2", "a=2"},
+		{3, "[double ]", "x=2 y=4", "a=2"},
+		{6, "[]", "", "a=2"},
+	}
+
+	if len(stops) != len(expected) {
+		t.Fatalf("wrong number of lines. want=%d, got=%d (%v)", len(expected), len(stops), stops)
+	}
+	for i, s := range stops {
+		if s.line != expected[i].line || s.functions != expected[i].functions ||
+			s.locals != expected[i].locals || !strings.HasPrefix(s.globals, expected[i].globals) {
+			t.Errorf("wrong stop %d. want=%v, got=%v", i, expected[i], s)
+		}
+	}
+
+	expectedCalls := []string{"call double", "return double", "call double", "return double"}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf("wrong calls. want=%v, got=%v", expectedCalls, calls)
+	}
+}
+
+func FuzzCompileRun(f *testing.F) {
+	seeds := []string{
+		`let x = 5; let y = x * (2 + 3); return -y;`,
+		`if (x < 10) { "small" } else { "big" }`,
+		`let f = fn(a, b) { a + b }; f(1, b: 2); f(...[1, 2]);`,
+		`[1, 2, 3][0]; {"a": 1, true: [2]}["a"]; "s".len();`,
+		`match ([1, 2]) { [a, ...rest] if a > 1 -> rest, {"k": v} -> v, _ -> null }`,
+		`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10);`,
+		`let c = chan(1); send(c, "x"); recv(c); wait(spawn(fn() { 1 }));`,
+		`let adder = fn(a) { fn(b) { a + b } }; adder(1)(2); [[1, 2], {"a": [3]}];`,
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return
+		}
+
+		// Programs may recurse without end or wait on channels forever
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		ctx = object.WithOutput(object.WithMaxDepth(ctx, 100), io.Discard)
+
+		New(comp.Bytecode()).RunContext(ctx)
+	})
+}
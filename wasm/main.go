@@ -0,0 +1,52 @@
+//go:build js && wasm
+
+// Command wasm runs the interpreter in the browser, e.g. for an online
+// playground. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o monkey.wasm ./wasm
+//
+// and load it with the wasm_exec.js shipped with Go. It exposes one global
+// function to JavaScript:
+//
+//	monkeyEval(src) -> {result, output, error}
+//
+// result is the inspected value the program finished with, output holds what
+// it printed with puts, and error is set instead of result for programs that
+// fail to parse or run. Every call starts from an empty environment.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"monkey/interp"
+	"syscall/js"
+)
+
+func main() {
+	js.Global().Set("monkeyEval", js.FuncOf(monkeyEval))
+
+	// Keep the exported function alive
+	select {}
+}
+
+func monkeyEval(this js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return map[string]any{"error": "monkeyEval expects a single source string"}
+	}
+
+	var output bytes.Buffer
+	i := interp.New()
+	i.Stdout = &output
+
+	result, err := i.Eval(args[0].String())
+	if err != nil {
+		var parseErr *interp.ParseError
+		if errors.As(err, &parseErr) {
+			return map[string]any{"error": "parse errors:\n" + err.Error(), "output": output.String()}
+		}
+
+		return map[string]any{"error": "ERROR: " + err.Error(), "output": output.String()}
+	}
+
+	return map[string]any{"result": result.Inspect(), "output": output.String()}
+}